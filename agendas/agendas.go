@@ -0,0 +1,368 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+// Package agendas tallies the votes on the consensus rule change agendas for
+// each rule change interval, from the vote version and vote bits stored for
+// every vote.
+package agendas
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Legenddigital/lddld/chaincfg"
+	"github.com/Legenddigital/lddldata/db/dbtypes"
+	"github.com/Legenddigital/lddldata/txhelpers"
+)
+
+// The voting status of an agenda. These are derived from the stored votes
+// rather than from the node's threshold state, which remains authoritative.
+const (
+	StatusDefined  = "defined"
+	StatusStarted  = "started"
+	StatusLockedIn = "lockedin"
+	StatusActive   = "active"
+	StatusFailed   = "failed"
+)
+
+// VoteSource is the source of the stored votes, such as lddlpg.ChainDB.
+type VoteSource interface {
+	Height() uint64
+	VoteBitsCounts(version uint32) ([]*dbtypes.VoteBitsCount, error)
+}
+
+// Choice describes one of the choices of an agenda.
+type Choice struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Bits        uint16 `json:"bits"`
+	IsAbstain   bool   `json:"is_abstain"`
+	IsNo        bool   `json:"is_no"`
+}
+
+// ChoiceCount is the number of votes for a choice of an agenda, and their
+// percentage of the votes that did not abstain.
+type ChoiceCount struct {
+	ID      string  `json:"id"`
+	Count   int64   `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// BlockVotes is the number of votes for each choice of an agenda in a block.
+type BlockVotes struct {
+	Height  int64            `json:"height"`
+	Choices map[string]int64 `json:"choices"`
+}
+
+// Interval models the votes on an agenda in one rule change interval. Outcome
+// is the ID of the choice that reached the activation threshold of a complete
+// interval, if any.
+type Interval struct {
+	Index          int64         `json:"index"`
+	StartHeight    int64         `json:"start_height"`
+	EndHeight      int64         `json:"end_height"`
+	Complete       bool          `json:"complete"`
+	NumVotes       int64         `json:"num_votes"`
+	NumAbstain     int64         `json:"num_abstain"`
+	Choices        []ChoiceCount `json:"choices"`
+	QuorumProgress float64       `json:"quorum_progress"`
+	QuorumMet      bool          `json:"quorum_met"`
+	Outcome        string        `json:"outcome,omitempty"`
+	Blocks         []*BlockVotes `json:"blocks,omitempty"`
+
+	firstBlockTime, lastBlockTime int64
+	voting                        bool
+}
+
+// Summary models an agenda and its voting status. The quorum progress is that
+// of the current interval.
+type Summary struct {
+	ID             string    `json:"id"`
+	Description    string    `json:"description"`
+	VoteVersion    uint32    `json:"vote_version"`
+	Mask           uint16    `json:"mask"`
+	StartTime      int64     `json:"start_time"`
+	ExpireTime     int64     `json:"expire_time"`
+	Status         string    `json:"status"`
+	LockedInHeight int64     `json:"locked_in_height,omitempty"`
+	ActiveHeight   int64     `json:"active_height,omitempty"`
+	Current        *Interval `json:"current_interval,omitempty"`
+}
+
+// Agenda models an agenda with its vote tally for every rule change interval
+// in which it was voted on.
+type Agenda struct {
+	Summary
+	Choices          []Choice    `json:"choices"`
+	IntervalBlocks   int64       `json:"interval_blocks"`
+	QuorumVotes      int64       `json:"quorum_votes"`
+	ThresholdPercent float64     `json:"threshold_percent"`
+	Intervals        []*Interval `json:"intervals"`
+}
+
+// Tracker tallies the votes on the agendas of every vote version of the
+// network. The tallies are computed again when the best block height of the
+// vote source changes.
+type Tracker struct {
+	source  VoteSource
+	params  *chaincfg.Params
+	mtx     sync.Mutex
+	height  int64
+	agendas []*Agenda
+}
+
+// NewTracker creates a Tracker for the votes of the given source.
+func NewTracker(source VoteSource, params *chaincfg.Params) *Tracker {
+	return &Tracker{
+		source: source,
+		params: params,
+		height: -1,
+	}
+}
+
+// update tallies the votes again if the best block has changed. The mutex must
+// be locked.
+func (t *Tracker) update() error {
+	height := int64(t.source.Height())
+	if height == t.height && t.agendas != nil {
+		return nil
+	}
+
+	versions := make([]uint32, 0, len(t.params.Deployments))
+	for version := range t.params.Deployments {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	agendas := make([]*Agenda, 0, len(versions))
+	for _, version := range versions {
+		counts, err := t.source.VoteBitsCounts(version)
+		if err != nil {
+			return err
+		}
+		agendas = append(agendas, tally(t.params, version, counts, height)...)
+	}
+
+	log.Debugf("Tallied the votes on %d agendas at height %d.", len(agendas), height)
+	t.agendas, t.height = agendas, height
+	return nil
+}
+
+// Agendas returns the summary of every agenda, by increasing vote version.
+func (t *Tracker) Agendas() ([]*Summary, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if err := t.update(); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]*Summary, 0, len(t.agendas))
+	for _, agenda := range t.agendas {
+		summary := agenda.Summary
+		summaries = append(summaries, &summary)
+	}
+	return summaries, nil
+}
+
+// Agenda returns the agenda with the given ID and its vote tally for each
+// interval, including the tally of each block if withBlocks is true. If the ID
+// is used by several vote versions, the latest one is returned. A nil *Agenda
+// is returned if there is no such agenda.
+func (t *Tracker) Agenda(id string, withBlocks bool) (*Agenda, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if err := t.update(); err != nil {
+		return nil, err
+	}
+
+	for i := len(t.agendas) - 1; i >= 0; i-- {
+		if t.agendas[i].ID != id {
+			continue
+		}
+		agenda := *t.agendas[i]
+		if !withBlocks {
+			agenda.Intervals = make([]*Interval, 0, len(t.agendas[i].Intervals))
+			for _, iv := range t.agendas[i].Intervals {
+				interval := *iv
+				interval.Blocks = nil
+				agenda.Intervals = append(agenda.Intervals, &interval)
+			}
+		}
+		return &agenda, nil
+	}
+	return nil, nil
+}
+
+// tally counts the votes of a vote version on each of its agendas, and
+// determines the status of the agendas at the given best block height. As in
+// the node's threshold state, the rule change intervals start at the stake
+// validation height, where voting begins.
+func tally(params *chaincfg.Params, version uint32, counts []*dbtypes.VoteBitsCount,
+	bestHeight int64) []*Agenda {
+	deployments := params.Deployments[version]
+	intervalBlocks := int64(params.RuleChangeActivationInterval)
+	if intervalBlocks < 1 {
+		intervalBlocks = 1
+	}
+	svh := params.StakeValidationHeight
+
+	agendas := make([]*Agenda, 0, len(deployments))
+	for d := range deployments {
+		deployment := &deployments[d]
+		agenda := &Agenda{
+			Summary: Summary{
+				ID:          deployment.Vote.Id,
+				Description: deployment.Vote.Description,
+				VoteVersion: version,
+				Mask:        deployment.Vote.Mask,
+				StartTime:   int64(deployment.StartTime),
+				ExpireTime:  int64(deployment.ExpireTime),
+				Status:      StatusDefined,
+			},
+			Choices:          make([]Choice, 0, len(deployment.Vote.Choices)),
+			IntervalBlocks:   intervalBlocks,
+			QuorumVotes:      int64(params.RuleChangeActivationQuorum),
+			ThresholdPercent: 100 * float64(params.RuleChangeActivationMultiplier) / float64(params.RuleChangeActivationDivisor),
+			Intervals:        []*Interval{},
+		}
+		for _, choice := range deployment.Vote.Choices {
+			agenda.Choices = append(agenda.Choices, Choice{
+				ID:          choice.Id,
+				Description: choice.Description,
+				Bits:        choice.Bits,
+				IsAbstain:   choice.IsAbstain,
+				IsNo:        choice.IsNo,
+			})
+		}
+		agendas = append(agendas, agenda)
+	}
+
+	// Count the votes for each choice, by interval and by block.
+	for _, count := range counts {
+		if count.Height < svh {
+			continue
+		}
+		index := (count.Height - svh) / intervalBlocks
+		for _, voteChoice := range txhelpers.VoteBitsChoices(version, count.VoteBits, params) {
+			agenda := agendas[voteChoice.VoteIndex]
+			var iv *Interval
+			if n := len(agenda.Intervals); n > 0 && agenda.Intervals[n-1].Index == index {
+				iv = agenda.Intervals[n-1]
+			} else {
+				iv = &Interval{
+					Index:          index,
+					StartHeight:    svh + index*intervalBlocks,
+					EndHeight:      svh + (index+1)*intervalBlocks - 1,
+					Choices:        make([]ChoiceCount, len(agenda.Choices)),
+					firstBlockTime: count.BlockTime,
+				}
+				for i := range agenda.Choices {
+					iv.Choices[i].ID = agenda.Choices[i].ID
+				}
+				agenda.Intervals = append(agenda.Intervals, iv)
+			}
+
+			iv.NumVotes += count.Count
+			if voteChoice.Choice.IsAbstain {
+				iv.NumAbstain += count.Count
+			}
+			iv.Choices[voteChoice.ChoiceIdx].Count += count.Count
+			if count.BlockTime > iv.lastBlockTime {
+				iv.lastBlockTime = count.BlockTime
+			}
+
+			var block *BlockVotes
+			if n := len(iv.Blocks); n > 0 && iv.Blocks[n-1].Height == count.Height {
+				block = iv.Blocks[n-1]
+			} else {
+				block = &BlockVotes{Height: count.Height, Choices: make(map[string]int64)}
+				iv.Blocks = append(iv.Blocks, block)
+			}
+			block.Choices[voteChoice.Choice.Id] += count.Count
+		}
+	}
+
+	for _, agenda := range agendas {
+		agenda.evaluate(params, bestHeight)
+	}
+	return agendas
+}
+
+// evaluate computes the quorum progress and outcome of each interval, and the
+// status of the agenda at the given best block height. Votes only count in the
+// intervals starting after the agenda's start time. The time of the first
+// block with votes in the interval stands in for the median time of the last
+// block of the previous interval, which the node checks.
+func (a *Agenda) evaluate(params *chaincfg.Params, bestHeight int64) {
+	multiplier := int64(params.RuleChangeActivationMultiplier)
+	divisor := int64(params.RuleChangeActivationDivisor)
+
+	for _, iv := range a.Intervals {
+		iv.Complete = iv.EndHeight <= bestHeight
+		iv.voting = iv.firstBlockTime >= a.StartTime
+		nonAbstain := iv.NumVotes - iv.NumAbstain
+		if a.QuorumVotes > 0 {
+			iv.QuorumProgress = float64(nonAbstain) / float64(a.QuorumVotes)
+		}
+		iv.QuorumMet = nonAbstain >= a.QuorumVotes
+		for i := range iv.Choices {
+			if nonAbstain > 0 && !a.Choices[i].IsAbstain {
+				iv.Choices[i].Percent = 100 * float64(iv.Choices[i].Count) / float64(nonAbstain)
+			}
+			if iv.Complete && iv.voting && iv.QuorumMet && !a.Choices[i].IsAbstain &&
+				iv.Choices[i].Count*divisor >= nonAbstain*multiplier {
+				iv.Outcome = iv.Choices[i].ID
+			}
+		}
+	}
+
+	// Only complete intervals change the status. Once locked in, the agenda
+	// becomes active after one more interval.
+	for _, iv := range a.Intervals {
+		if !iv.Complete {
+			break
+		}
+		if a.ExpireTime > 0 && iv.lastBlockTime >= a.ExpireTime {
+			a.Status = StatusFailed
+			break
+		}
+		if !iv.voting {
+			continue
+		}
+		if iv.Outcome == "" {
+			a.Status = StatusStarted
+			continue
+		}
+		if a.Choices[choiceIndex(iv.Choices, iv.Outcome)].IsNo {
+			a.Status = StatusFailed
+			break
+		}
+		a.Status = StatusLockedIn
+		a.LockedInHeight = iv.EndHeight
+		a.ActiveHeight = iv.EndHeight + a.IntervalBlocks + 1
+		if bestHeight >= a.ActiveHeight {
+			a.Status = StatusActive
+		}
+		break
+	}
+	if n := len(a.Intervals); a.Status == StatusDefined && n > 0 &&
+		a.Intervals[n-1].voting {
+		a.Status = StatusStarted
+	}
+
+	if n := len(a.Intervals); n > 0 && !a.Intervals[n-1].Complete {
+		current := *a.Intervals[n-1]
+		current.Blocks = nil
+		a.Current = &current
+	}
+}
+
+// choiceIndex returns the index of the choice with the given ID.
+func choiceIndex(choices []ChoiceCount, id string) int {
+	for i := range choices {
+		if choices[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
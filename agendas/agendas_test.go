@@ -0,0 +1,167 @@
+package agendas
+
+import (
+	"testing"
+
+	"github.com/Legenddigital/lddld/chaincfg"
+	"github.com/Legenddigital/lddldata/db/dbtypes"
+)
+
+var testParams = &chaincfg.Params{
+	RuleChangeActivationQuorum:     10,
+	RuleChangeActivationMultiplier: 3,
+	RuleChangeActivationDivisor:    4,
+	RuleChangeActivationInterval:   100,
+	Deployments: map[uint32][]chaincfg.ConsensusDeployment{
+		5: {{
+			Vote: chaincfg.Vote{
+				Id:          "testagenda",
+				Description: "Test agenda",
+				Mask:        0x0006,
+				Choices: []chaincfg.Choice{
+					{Id: "abstain", Bits: 0x0000, IsAbstain: true},
+					{Id: "no", Bits: 0x0002, IsNo: true},
+					{Id: "yes", Bits: 0x0004},
+				},
+			},
+			ExpireTime: 1e10,
+		}},
+	},
+}
+
+type testVoteSource struct {
+	height uint64
+	counts []*dbtypes.VoteBitsCount
+}
+
+func (s *testVoteSource) Height() uint64 {
+	return s.height
+}
+
+func (s *testVoteSource) VoteBitsCounts(version uint32) ([]*dbtypes.VoteBitsCount, error) {
+	if version != 5 {
+		return nil, nil
+	}
+	return s.counts, nil
+}
+
+func TestTrackerLockIn(t *testing.T) {
+	source := &testVoteSource{
+		height: 250,
+		counts: []*dbtypes.VoteBitsCount{
+			// Interval 0: quorum is not met.
+			{Height: 10, VoteBits: 0x0005, Count: 3},
+			{Height: 10, VoteBits: 0x0001, Count: 2},
+			// Interval 1: 9 yes and 3 no of 12 non-abstaining votes.
+			{Height: 120, VoteBits: 0x0005, Count: 5},
+			{Height: 121, VoteBits: 0x0005, Count: 4},
+			{Height: 121, VoteBits: 0x0003, Count: 3},
+			{Height: 121, VoteBits: 0x0001, Count: 1},
+			// Interval 2 is not complete.
+			{Height: 230, VoteBits: 0x0005, Count: 5},
+		},
+	}
+	tracker := NewTracker(source, testParams)
+
+	agenda, err := tracker.Agenda("testagenda", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agenda == nil {
+		t.Fatal("agenda not found")
+	}
+	if len(agenda.Intervals) != 3 {
+		t.Fatalf("expected 3 intervals, got %d", len(agenda.Intervals))
+	}
+
+	iv := agenda.Intervals[0]
+	if iv.QuorumMet || iv.Outcome != "" || iv.QuorumProgress != 0.3 {
+		t.Errorf("interval 0: quorum met %v, outcome %q, progress %v",
+			iv.QuorumMet, iv.Outcome, iv.QuorumProgress)
+	}
+
+	iv = agenda.Intervals[1]
+	if iv.NumVotes != 13 || iv.NumAbstain != 1 || !iv.QuorumMet || iv.Outcome != "yes" {
+		t.Errorf("interval 1: %d votes, %d abstain, quorum met %v, outcome %q",
+			iv.NumVotes, iv.NumAbstain, iv.QuorumMet, iv.Outcome)
+	}
+	if iv.Choices[2].Percent != 75 {
+		t.Errorf("interval 1: yes percent %v, expected 75", iv.Choices[2].Percent)
+	}
+	if len(iv.Blocks) != 2 || iv.Blocks[1].Choices["no"] != 3 {
+		t.Errorf("interval 1: unexpected blocks %v", iv.Blocks)
+	}
+
+	if agenda.Status != StatusLockedIn || agenda.LockedInHeight != 199 || agenda.ActiveHeight != 300 {
+		t.Errorf("status %s, locked in at %d, active at %d", agenda.Status,
+			agenda.LockedInHeight, agenda.ActiveHeight)
+	}
+	if agenda.Current == nil || agenda.Current.Index != 2 || agenda.Current.Blocks != nil {
+		t.Errorf("unexpected current interval %v", agenda.Current)
+	}
+
+	// The tally is updated for a new best block.
+	source.height = 300
+	summaries, err := tracker.Agendas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].Status != StatusActive {
+		t.Errorf("unexpected summaries %v", summaries)
+	}
+
+	agenda, _ = tracker.Agenda("testagenda", false)
+	if agenda.Intervals[1].Blocks != nil {
+		t.Error("blocks included")
+	}
+	if agenda, _ = tracker.Agenda("unknown", false); agenda != nil {
+		t.Error("found unknown agenda")
+	}
+}
+
+func TestTrackerStakeValidationHeight(t *testing.T) {
+	params := *testParams
+	params.StakeValidationHeight = 30
+	deployment := testParams.Deployments[5][0]
+	deployment.StartTime = 1000
+	params.Deployments = map[uint32][]chaincfg.ConsensusDeployment{5: {deployment}}
+
+	counts := []*dbtypes.VoteBitsCount{
+		// Below the stake validation height.
+		{Height: 20, VoteBits: 0x0005, Count: 5, BlockTime: 400},
+		// Interval 0, from height 30 to 129, is before the start time.
+		{Height: 40, VoteBits: 0x0005, Count: 12, BlockTime: 500},
+		// Interval 1, from height 130 to 229: 10 yes and 2 no votes.
+		{Height: 130, VoteBits: 0x0005, Count: 10, BlockTime: 1100},
+		{Height: 229, VoteBits: 0x0003, Count: 2, BlockTime: 1200},
+	}
+
+	agendas := tally(&params, 5, counts[:2], 100)
+	if agendas[0].Status != StatusDefined {
+		t.Errorf("status %s before the start time, expected %s",
+			agendas[0].Status, StatusDefined)
+	}
+
+	agendas = tally(&params, 5, counts, 250)
+	agenda := agendas[0]
+	if len(agenda.Intervals) != 2 {
+		t.Fatalf("expected 2 intervals, got %d", len(agenda.Intervals))
+	}
+
+	iv := agenda.Intervals[0]
+	if iv.StartHeight != 30 || iv.EndHeight != 129 || iv.NumVotes != 12 || iv.Outcome != "" {
+		t.Errorf("interval 0: heights %d to %d, %d votes, outcome %q",
+			iv.StartHeight, iv.EndHeight, iv.NumVotes, iv.Outcome)
+	}
+
+	iv = agenda.Intervals[1]
+	if iv.StartHeight != 130 || iv.EndHeight != 229 || iv.NumVotes != 12 || iv.Outcome != "yes" {
+		t.Errorf("interval 1: heights %d to %d, %d votes, outcome %q",
+			iv.StartHeight, iv.EndHeight, iv.NumVotes, iv.Outcome)
+	}
+
+	if agenda.Status != StatusLockedIn || agenda.LockedInHeight != 229 || agenda.ActiveHeight != 330 {
+		t.Errorf("status %s, locked in at %d, active at %d", agenda.Status,
+			agenda.LockedInHeight, agenda.ActiveHeight)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/Legenddigital/lddldata/agendas"
+	m "github.com/Legenddigital/lddldata/middleware"
+)
+
+// AgendaSource specifies an interface for the vote tallies of the consensus
+// rule change agendas.
+type AgendaSource interface {
+	Agendas() ([]*agendas.Summary, error)
+	Agenda(id string, withBlocks bool) (*agendas.Agenda, error)
+}
+
+// AgendasCtx makes the agenda routes unavailable if the agenda source is not
+// set, as in lite mode.
+func (c *appContext) AgendasCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Agendas == nil {
+			http.Error(w, "agenda data is not available in lite mode", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getAgendas lists the agendas of every vote version with their status and
+// the votes of the current rule change interval.
+func (c *appContext) getAgendas(w http.ResponseWriter, r *http.Request) {
+	summaries, err := c.Agendas.Agendas()
+	if err != nil {
+		apiLog.Errorf("Unable to tally the agenda votes: %v", err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, summaries, c.getIndentQuery(r))
+}
+
+// getAgenda gets the votes on an agenda for each rule change interval. The
+// blocks URL query includes the votes in each block.
+func (c *appContext) getAgenda(w http.ResponseWriter, r *http.Request) {
+	id := m.GetAgendaIDCtx(r)
+	blocks := r.URL.Query().Get("blocks")
+	agenda, err := c.Agendas.Agenda(id, blocks == "1" || blocks == "true")
+	if err != nil {
+		apiLog.Errorf("Unable to tally the agenda votes: %v", err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	if agenda == nil {
+		http.Error(w, "unknown agenda "+id, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, agenda, c.getIndentQuery(r))
+}
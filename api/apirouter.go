@@ -34,7 +34,11 @@ func NewAPIRouter(app *appContext, userRealIP bool) apiMux {
 	mux.Get("/", app.root)
 
 	mux.Get("/status", app.status)
-	mux.Get("/supply", app.coinSupply)
+	mux.Route("/supply", func(r chi.Router) {
+		r.Get("/", app.coinSupply)
+		r.Get("/schedule", app.getSupplySchedule)
+		r.With(app.ChartsCtx).Get("/history", app.getSupplyHistory)
+	})
 
 	mux.Route("/block", func(r chi.Router) {
 		r.Route("/best", func(rd chi.Router) {
@@ -46,6 +50,7 @@ func NewAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.Get("/size", app.getBlockSize)
 			rd.With((middleware.Compress(1))).Get("/verbose", app.getBlockVerbose)
 			rd.Get("/pos", app.getBlockStakeInfoExtended)
+			rd.Get("/fees", app.getBlockFeeInfo)
 			rd.Route("/tx", func(rt chi.Router) {
 				rt.Get("/", app.getBlockTransactions)
 				rt.Get("/count", app.getBlockTransactionsCount)
@@ -60,6 +65,7 @@ func NewAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.Get("/size", app.getBlockSize)
 			rd.With((middleware.Compress(1))).Get("/verbose", app.getBlockVerbose)
 			rd.Get("/pos", app.getBlockStakeInfoExtended)
+			rd.Get("/fees", app.getBlockFeeInfo)
 			rd.Route("/tx", func(rt chi.Router) {
 				rt.Get("/", app.getBlockTransactions)
 				rt.Get("/count", app.getBlockTransactionsCount)
@@ -74,6 +80,7 @@ func NewAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.Get("/size", app.getBlockSize)
 			rd.With((middleware.Compress(1))).Get("/verbose", app.getBlockVerbose)
 			rd.Get("/pos", app.getBlockStakeInfoExtended)
+			rd.Get("/fees", app.getBlockFeeInfo)
 			rd.Route("/tx", func(rt chi.Router) {
 				rt.Get("/", app.getBlockTransactions)
 				rt.Get("/count", app.getBlockTransactionsCount)
@@ -85,10 +92,12 @@ func NewAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.Use(middleware.Compress(1))
 			rd.Get("/", app.getBlockRangeSummary)
 			rd.Get("/size", app.getBlockRangeSize)
+			rd.Get("/fees", app.getBlockRangeFeeInfo)
 			rd.Route("/{step}", func(rs chi.Router) {
 				rs.Use(m.BlockStepPathCtx)
 				rs.Get("/", app.getBlockRangeSteppedSummary)
 				rs.Get("/size", app.getBlockRangeSteppedSize)
+				rs.Get("/fees", app.getBlockRangeSteppedFeeInfo)
 			})
 			// rd.Get("/header", app.getBlockHeader)
 			// rd.Get("/pos", app.getBlockStakeInfoExtended)
@@ -108,14 +117,23 @@ func NewAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.With(m.BlockIndexPathCtx).Get("/b/{idx}", app.getTicketPoolInfo)
 			rd.With(m.BlockIndexOrHashPathCtx).Get("/b/{idxorhash}/full", app.getTicketPool)
 			rd.With(m.BlockIndex0PathCtx, m.BlockIndexPathCtx).Get("/r/{idx0}/{idx}", app.getTicketPoolInfoRange)
+			rd.With(m.BlockIndex0PathCtx, m.BlockIndexPathCtx).Get("/r/{idx0}/{idx}/valsize", app.getTicketPoolValAndSizeRange)
 		})
 		r.Route("/diff", func(rd chi.Router) {
 			rd.Get("/", app.getStakeDiffSummary)
 			rd.Get("/current", app.getStakeDiffCurrent)
 			rd.Get("/estimates", app.getStakeDiffEstimates)
+			rd.Get("/projection", app.getStakeDiffProjection)
 			rd.With(m.BlockIndexPathCtx).Get("/b/{idx}", app.getStakeDiff)
 			rd.With(m.BlockIndex0PathCtx, m.BlockIndexPathCtx).Get("/r/{idx0}/{idx}", app.getStakeDiffRange)
 		})
+		r.Get("/calculator", app.getStakeCalculation)
+		r.Route("/misses", func(rd chi.Router) {
+			rd.Get("/expiries", app.getTicketExpiries)
+			rd.With(m.BlockIndex0PathCtx, m.BlockIndexPathCtx).Get("/r/{idx0}/{idx}", app.getMissesRange)
+			rd.With(m.BlockIndex0PathCtx, m.BlockIndexPathCtx).Get("/days/r/{idx0}/{idx}", app.getMissesByDay)
+			rd.With(m.BlockIndex0PathCtx, m.BlockIndexPathCtx).Get("/addresses/r/{idx0}/{idx}", app.getMissesByAddress)
+		})
 	})
 
 	mux.Route("/tx", func(r chi.Router) {
@@ -139,6 +157,8 @@ func NewAPIRouter(app *appContext, userRealIP bool) apiMux {
 		r.With(m.TransactionHashCtx).Get("/decoded/{txid}", app.getDecodedTx)
 	})
 
+	mux.With(m.TransactionHashCtx).Get("/ticket/{txid}", app.getTicketInfo)
+
 	mux.Route("/txs", func(r chi.Router) {
 		r.Use(middleware.AllowContentType("application/json"),
 			m.ValidateTxnsPostCtx, m.PostTxnsCtx)
@@ -150,6 +170,9 @@ func NewAPIRouter(app *appContext, userRealIP bool) apiMux {
 		r.Route("/{address}", func(rd chi.Router) {
 			rd.Use(m.AddressPathCtx)
 			rd.Get("/totals", app.addressTotals)
+			rd.Get("/utxos", app.getAddressUTXOs)
+			rd.Get("/balance", app.getAddressBalance)
+			rd.Get("/tickets", app.getAddressTickets)
 			rd.Get("/", app.getAddressTransactions)
 			rd.With((middleware.Compress(1))).Get("/raw", app.getAddressTransactionsRaw)
 			rd.Route("/count/{N}", func(ri chi.Router) {
@@ -165,8 +188,16 @@ func NewAPIRouter(app *appContext, userRealIP bool) apiMux {
 		})
 	})
 
+	mux.Route("/addresses", func(r chi.Router) {
+		r.Use(middleware.AllowContentType("application/json"))
+		r.Post("/txs", app.getAddressesTxns)
+		r.Post("/totals", app.getAddressesTotals)
+	})
+
 	mux.Route("/mempool", func(r chi.Router) {
-		r.Get("/", http.NotFound /*app.getMempoolOverview*/)
+		r.With(app.MempoolCtx).Get("/", app.getMempoolOverview)
+		r.With(app.MempoolCtx).Get("/txs", app.getMempoolTxns)
+		r.With(app.MempoolCtx, m.TransactionHashCtx).Get("/tx/{txid}", app.getMempoolTxn)
 		// ticket purchases
 		r.Route("/sstx", func(rd chi.Router) {
 			rd.Get("/", app.getSSTxSummary)
@@ -177,24 +208,36 @@ func NewAPIRouter(app *appContext, userRealIP bool) apiMux {
 		})
 	})
 
-	mux.NotFound(func(w http.ResponseWriter, r *http.Request) {
-		http.Error(w, r.URL.RequestURI()+" ain't no country I've ever heard of! (404)", http.StatusNotFound)
+	mux.With(app.AgendasCtx).Get("/agendas", app.getAgendas)
+	mux.With(app.AgendasCtx, m.AgendaPathCtx).Get("/agenda/{agendaid}", app.getAgenda)
+
+	mux.With(app.ChartsCtx, m.ChartNamePathCtx).Get("/chart/{chartname}", app.getChart)
+
+	mux.Route("/watch", func(r chi.Router) {
+		r.Use(app.WatchAuthCtx)
+		r.Get("/", app.getWatchList)
+		r.With(middleware.AllowContentType("application/json")).Post("/", app.addWatch)
+		r.Get("/deliveries", app.getWebhookDeliveries)
+		r.Route("/{address}", func(rd chi.Router) {
+			rd.Use(m.AddressPathCtx)
+			rd.Get("/", app.getWatch)
+			rd.Put("/", app.addWatch)
+			rd.Delete("/", app.removeWatch)
+		})
 	})
 
-	// if cfg.PrintAPIDirectory {
-	// 	var buf bytes.Buffer
-	// 	json.Indent(&buf, []byte(docgen.JSONRoutesDoc(mux)), "", "\t")
-	// 	buf.WriteTo(os.Stdout)
+	mux.Route("/stream", func(r chi.Router) {
+		r.Use(app.StreamCtx)
+		r.Get("/", app.streamSSE)
+		r.Get("/ws", app.streamWebsocket)
+	})
 
-	// 	fmt.Println(docgen.MarkdownRoutesDoc(mux, docgen.MarkdownOpts{
-	// 		ProjectPath: "github.com/Legenddigital/lddldata",
-	// 		Intro:       "lddldata HTTP router directory",
-	// 	}))
-	// 	return
-	// }
+	mux.Get("/openapi.json", app.getOpenAPI)
+	mux.Get("/directory", app.getDirectory)
 
-	// mux.HandleFunc("/directory", APIDirectory)
-	// mux.With(apiDocs(mux)).HandleFunc("/directory", APIDirectory)
+	mux.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, r.URL.RequestURI()+" ain't no country I've ever heard of! (404)", http.StatusNotFound)
+	})
 
 	var listRoutePatterns func(routes []chi.Route) []string
 	listRoutePatterns = func(routes []chi.Route) []string {
@@ -5,29 +5,42 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"net/http"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 
+	"github.com/Legenddigital/lddld/chaincfg"
 	"github.com/Legenddigital/lddld/lddljson"
+	"github.com/Legenddigital/lddld/lddlutil"
 	"github.com/Legenddigital/lddld/rpcclient"
+	"github.com/Legenddigital/lddld/wire"
+	"github.com/Legenddigital/lddldata/api/openapi"
 	apitypes "github.com/Legenddigital/lddldata/api/types"
 	"github.com/Legenddigital/lddldata/db/dbtypes"
 	"github.com/Legenddigital/lddldata/explorer"
 	m "github.com/Legenddigital/lddldata/middleware"
 	notify "github.com/Legenddigital/lddldata/notification"
+	"github.com/Legenddigital/lddldata/stakecalc"
+	"github.com/Legenddigital/lddldata/stakediff"
 	appver "github.com/Legenddigital/lddldata/version"
+	"github.com/Legenddigital/lddldata/watcher"
 )
 
 // DataSourceLite specifies an interface for collecting data from the built-in
 // databases (i.e. SQLite, badger, ffldb)
 type DataSourceLite interface {
+	GetChainParams() *chaincfg.Params
 	CoinSupply() *apitypes.CoinSupply
+	SupplySchedule() *apitypes.SupplySchedule
 	GetHeight() int
 	GetBestBlockHash() (string, error)
 	GetBlockHash(idx int64) (string, error)
@@ -49,10 +62,14 @@ type DataSourceLite interface {
 	GetTransactionsForBlock(idx int64) *apitypes.BlockTransactions
 	GetTransactionsForBlockByHash(hash string) *apitypes.BlockTransactions
 	GetFeeInfo(idx int) *lddljson.FeeInfoBlock
+	GetBlockFeeStats(idx int) *apitypes.BlockFeeStats
+	GetBlockFeeStatsRange(idx0, idx1, step int) ([]*apitypes.BlockFeeStats, error)
 	//GetStakeDiffEstimate(idx int) *lddljson.EstimateStakeDiffResult
 	GetStakeInfoExtended(idx int) *apitypes.StakeInfoExtended
 	//needs db update: GetStakeInfoExtendedByHash(hash string) *apitypes.StakeInfoExtended
 	GetStakeDiffEstimates() *apitypes.StakeDiff
+	GetStakeDiffProjection(windows int, rates []float64) (*stakediff.Projection, error)
+	StakeCalculation(in stakecalc.Inputs) (*stakecalc.Calculation, error)
 	//GetBestBlock() *blockdata.BlockData
 	GetSummary(idx int) *apitypes.BlockDataBasic
 	GetSummaryByHash(hash string) *apitypes.BlockDataBasic
@@ -65,6 +82,7 @@ type DataSourceLite interface {
 	GetPool(idx int64) ([]string, error)
 	GetPoolByHash(hash string) ([]string, error)
 	GetPoolValAndSizeRange(idx0, idx1 int) ([]float64, []float64)
+	GetTicketExpiries(binSize int64) *apitypes.TicketExpiries
 	GetSDiff(idx int) float64
 	GetSDiffRange(idx0, idx1 int) []float64
 	GetMempoolSSTxSummary() *apitypes.MempoolTicketFeeInfo
@@ -88,6 +106,35 @@ type DataSourceAux interface {
 	AddressTransactionDetails(addr string, count, skip int64,
 		txnType dbtypes.AddrTxnType) (*apitypes.Address, error)
 	AddressTotals(address string) (*apitypes.AddressTotals, error)
+	AddressUTXOs(address string, minConf, minAtoms, maxAtoms int64,
+		tree int8) ([]*apitypes.AddressUTXO, error)
+	AddressBalanceAtHeight(address string, height int64) (*apitypes.AddressBalance, error)
+	AddressTickets(address string) (*apitypes.AddressTickets, error)
+	TicketInfo(txid string) (*apitypes.TicketInfo, error)
+	MissesInBlockRange(idx0, idx int64) (*apitypes.MissesRange, error)
+	MissesByDay(idx0, idx int64) ([]*apitypes.DayMisses, error)
+	MissesByAddress(idx0, idx int64) ([]*apitypes.AddressMisses, error)
+	AddressesTxns(addresses []string, N, offset int64) (*apitypes.AddressesTxns, error)
+	AddressesTotals(addresses []string) (*apitypes.AddressesTotals, error)
+	DevBalance() (*explorer.AddressBalance, error)
+}
+
+// AddressWatcher specifies an interface for managing the watched addresses and
+// viewing the webhook delivery log.
+type AddressWatcher interface {
+	Authorized(key string) bool
+	Add(address, webhookURL string) (*watcher.Watch, error)
+	Remove(address string) (bool, error)
+	Get(address string) *watcher.Watch
+	List() []watcher.Watch
+	Deliveries(status string, N int) ([]watcher.Delivery, error)
+}
+
+// StreamServer specifies an interface for the streaming API over Server-Sent
+// Events and websockets.
+type StreamServer interface {
+	ServeSSE(w http.ResponseWriter, r *http.Request)
+	ServeWebsocket(w http.ResponseWriter, r *http.Request)
 }
 
 // lddldata application context used by all route handlers
@@ -95,6 +142,12 @@ type appContext struct {
 	nodeClient    *rpcclient.Client
 	BlockData     DataSourceLite
 	AuxDataSource DataSourceAux
+	Watcher       AddressWatcher
+	Stream        StreamServer
+	Mempool       MempoolSource
+	Agendas       AgendaSource
+	Charts        ChartSource
+	OpenAPI       *openapi.Document
 	LiteMode      bool
 	Status        apitypes.Status
 	statusMtx     sync.RWMutex
@@ -126,6 +179,14 @@ func NewContext(client *rpcclient.Client, dataSource DataSourceLite, auxDataSour
 	}
 }
 
+// NodeHeight returns the node's best block height, as last reported by the
+// block connected notifications.
+func (c *appContext) NodeHeight() uint32 {
+	c.statusMtx.RLock()
+	defer c.statusMtx.RUnlock()
+	return c.Status.Height
+}
+
 // StatusNtfnHandler keeps the appContext's Status up-to-date with changes in
 // node and DB status.
 func (c *appContext) StatusNtfnHandler(wg *sync.WaitGroup, quit chan struct{}) {
@@ -615,7 +676,7 @@ func (c *appContext) getBlockFeeInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	blockFeeInfo := c.BlockData.GetFeeInfo(int(idx))
+	blockFeeInfo := c.BlockData.GetBlockFeeStats(int(idx))
 	if blockFeeInfo == nil {
 		apiLog.Errorf("Unable to get block %d fee info", idx)
 		http.Error(w, http.StatusText(422), 422)
@@ -625,6 +686,43 @@ func (c *appContext) getBlockFeeInfo(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, blockFeeInfo, c.getIndentQuery(r))
 }
 
+func (c *appContext) getBlockRangeFeeInfo(w http.ResponseWriter, r *http.Request) {
+	c.writeBlockRangeFeeInfo(w, r, 1)
+}
+
+func (c *appContext) getBlockRangeSteppedFeeInfo(w http.ResponseWriter, r *http.Request) {
+	step := m.GetBlockStepCtx(r)
+	if step <= 0 {
+		http.Error(w, "Yeaaah, that step's not gonna work with me.", 422)
+		return
+	}
+	c.writeBlockRangeFeeInfo(w, r, step)
+}
+
+// writeBlockRangeFeeInfo writes the fee info of every step-th block from idx0
+// to idx, from the fee rate statistics stored for each block.
+func (c *appContext) writeBlockRangeFeeInfo(w http.ResponseWriter, r *http.Request, step int) {
+	idx0 := m.GetBlockIndex0Ctx(r)
+	if idx0 < 0 {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	idx := m.GetBlockIndexCtx(r)
+	if idx < 0 || idx < idx0 {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	blockFeeInfos, err := c.BlockData.GetBlockFeeStatsRange(idx0, idx, step)
+	if err != nil {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, blockFeeInfos, c.getIndentQuery(r))
+}
+
 func (c *appContext) getBlockStakeInfoExtended(w http.ResponseWriter, r *http.Request) {
 	idx := c.getBlockHeightCtx(r)
 	if idx < 0 {
@@ -680,6 +778,134 @@ func (c *appContext) getStakeDiffEstimates(w http.ResponseWriter, r *http.Reques
 	writeJSON(w, stakeDiff.Estimates, c.getIndentQuery(r))
 }
 
+const (
+	defaultProjectionWindows = 4
+	maxProjectionWindows     = 16
+)
+
+// getStakeDiffProjection projects the ticket price of the next windows. The
+// windows URL query parameter sets the number of windows, and the rate
+// parameter is a comma separated list of ticket purchase rates, in tickets per
+// block, to simulate.
+func (c *appContext) getStakeDiffProjection(w http.ResponseWriter, r *http.Request) {
+	windows := defaultProjectionWindows
+	if str := r.URL.Query().Get("windows"); str != "" {
+		n, err := strconv.Atoi(str)
+		if err != nil || n < 1 || n > maxProjectionWindows {
+			http.Error(w, fmt.Sprintf("windows must be an integer from 1 to %d",
+				maxProjectionWindows), http.StatusBadRequest)
+			return
+		}
+		windows = n
+	}
+
+	var rates []float64
+	if str := r.URL.Query().Get("rate"); str != "" {
+		for _, s := range strings.Split(str, ",") {
+			rate, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil || rate < 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+				http.Error(w, "rate must be a list of non-negative numbers",
+					http.StatusBadRequest)
+				return
+			}
+			rates = append(rates, rate)
+		}
+	}
+
+	projection, err := c.BlockData.GetStakeDiffProjection(windows, rates)
+	if err != nil {
+		apiLog.Errorf("Unable to project the ticket price: %v", err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, projection, c.getIndentQuery(r))
+}
+
+const (
+	defaultCalculatorBalance = 1000
+	defaultCalculatorDays    = 365
+	maxCalculatorDays        = 3650
+)
+
+// getStakeCalculation simulates staking and returns the schedule of the
+// ticket purchases and votes. The balance, price, price_change, pool_size,
+// vsp_fee, compound, integer and days URL query parameters set the inputs.
+// The simulation starts at the best block, and the price and pool size default
+// to those of the best block.
+func (c *appContext) getStakeCalculation(w http.ResponseWriter, r *http.Request) {
+	in := stakecalc.Inputs{
+		Balance: defaultCalculatorBalance,
+		Days:    defaultCalculatorDays,
+	}
+	q := r.URL.Query()
+	floats := []struct {
+		name string
+		v    *float64
+	}{
+		{"balance", &in.Balance},
+		{"price", &in.TicketPrice},
+		{"price_change", &in.PriceChange},
+		{"vsp_fee", &in.VSPFee},
+	}
+	for _, f := range floats {
+		if str := q.Get(f.name); str != "" {
+			v, err := strconv.ParseFloat(str, 64)
+			if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+				http.Error(w, f.name+" must be a number", http.StatusBadRequest)
+				return
+			}
+			*f.v = v
+		}
+	}
+	if str := q.Get("pool_size"); str != "" {
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || n < 1 {
+			http.Error(w, "pool_size must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		in.PoolSize = n
+	}
+	if str := q.Get("days"); str != "" {
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || n < 1 || n > maxCalculatorDays {
+			http.Error(w, fmt.Sprintf("days must be an integer from 1 to %d",
+				maxCalculatorDays), http.StatusBadRequest)
+			return
+		}
+		in.Days = n
+	}
+	in.Compound = q.Get("compound") == "1" || q.Get("compound") == "true"
+	in.IntegerTickets = q.Get("integer") == "1" || q.Get("integer") == "true"
+
+	summary := c.BlockData.GetBestBlockSummary()
+	if summary == nil {
+		apiLog.Errorf("Unable to get the best block summary")
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	in.Height = int64(summary.Height)
+	if q.Get("price") == "" {
+		in.TicketPrice = summary.StakeDiff
+	}
+	if in.PoolSize == 0 {
+		in.PoolSize = int64(summary.PoolInfo.Size)
+	}
+	if err := in.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	calc, err := c.BlockData.StakeCalculation(in)
+	if err != nil {
+		apiLog.Errorf("Unable to calculate the stake return: %v", err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, calc, c.getIndentQuery(r))
+}
+
 func (c *appContext) getSSTxSummary(w http.ResponseWriter, r *http.Request) {
 	sstxSummary := c.BlockData.GetMempoolSSTxSummary()
 	if sstxSummary == nil {
@@ -976,7 +1202,7 @@ func (c *appContext) getTicketPoolValAndSizeRange(w http.ResponseWriter, r *http
 	}
 
 	idx := m.GetBlockIndexCtx(r)
-	if idx < 0 {
+	if idx < 0 || idx < idx0 {
 		http.Error(w, http.StatusText(422), 422)
 		return
 	}
@@ -1047,6 +1273,252 @@ func (c *appContext) addressTotals(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, totals, c.getIndentQuery(r))
 }
 
+const (
+	// maxAddressesPost limits the addresses of a multi-address POST request.
+	maxAddressesPost = 1000
+
+	defaultAddressesTxnsCount = 100
+	maxAddressesTxnsCount     = 1000
+)
+
+// readAddressesPost reads and validates the addresses of a multi-address POST
+// request. Addresses must be for the network given by params. Duplicate
+// addresses are removed.
+func readAddressesPost(w http.ResponseWriter, r *http.Request, params *chaincfg.Params) (*apitypes.AddressesPost, error) {
+	var req apitypes.AddressesPost
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading JSON message")
+	}
+	if err = json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON request")
+	}
+
+	seen := make(map[string]bool, len(req.Addresses))
+	addresses := req.Addresses[:0]
+	for _, address := range req.Addresses {
+		if seen[address] {
+			continue
+		}
+		addr, err := lddlutil.DecodeAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q", address)
+		}
+		if !addr.IsForNet(params) {
+			return nil, fmt.Errorf("address %q is not for %s", address, params.Name)
+		}
+		seen[address] = true
+		addresses = append(addresses, address)
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("addresses not specified")
+	}
+	if len(addresses) > maxAddressesPost {
+		return nil, fmt.Errorf("too many addresses (maximum %d)", maxAddressesPost)
+	}
+	req.Addresses = addresses
+	return &req, nil
+}
+
+// getAddressesTxns gets the merged transaction history of the addresses in the
+// POST body, most recent first, paged by the count and skip fields.
+func (c *appContext) getAddressesTxns(w http.ResponseWriter, r *http.Request) {
+	if c.LiteMode {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	req, err := readAddressesPost(w, r, c.BlockData.GetChainParams())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	N := req.Count
+	if N <= 0 {
+		N = defaultAddressesTxnsCount
+	} else if N > maxAddressesTxnsCount {
+		N = maxAddressesTxnsCount
+	}
+	if req.Skip < 0 {
+		http.Error(w, "skip must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	txns, err := c.AuxDataSource.AddressesTxns(req.Addresses, N, req.Skip)
+	if err != nil {
+		log.Warnf("failed to get transactions of %d addresses: %v", len(req.Addresses), err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, txns, c.getIndentQuery(r))
+}
+
+// getAddressesTotals gets the combined and per-address totals of the
+// addresses in the POST body.
+func (c *appContext) getAddressesTotals(w http.ResponseWriter, r *http.Request) {
+	if c.LiteMode {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	req, err := readAddressesPost(w, r, c.BlockData.GetChainParams())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	totals, err := c.AuxDataSource.AddressesTotals(req.Addresses)
+	if err != nil {
+		log.Warnf("failed to get totals of %d addresses: %v", len(req.Addresses), err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, totals, c.getIndentQuery(r))
+}
+
+// amountQuery parses an amount in LDDL from the URL query, returning def if the
+// parameter is not set.
+func amountQuery(r *http.Request, name string, def int64) (int64, error) {
+	str := r.URL.Query().Get(name)
+	if str == "" {
+		return def, nil
+	}
+	coins, err := strconv.ParseFloat(str, 64)
+	if err != nil || coins < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative amount", name)
+	}
+	amt, err := lddlutil.NewAmount(coins)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a non-negative amount", name)
+	}
+	return int64(amt), nil
+}
+
+// getAddressUTXOs lists the unspent outputs of an address. The minconf,
+// min_amount, max_amount and tree URL query parameters filter the outputs.
+func (c *appContext) getAddressUTXOs(w http.ResponseWriter, r *http.Request) {
+	address := m.GetAddressCtx(r)
+	if address == "" || c.LiteMode {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	q := r.URL.Query()
+	var minConf int64 = 1
+	if str := q.Get("minconf"); str != "" {
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "minconf must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		minConf = n
+	}
+
+	minAtoms, err := amountQuery(r, "min_amount", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	maxAtoms, err := amountQuery(r, "max_amount", math.MaxInt64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var tree int8 = -1
+	switch q.Get("tree") {
+	case "":
+	case "regular":
+		tree = wire.TxTreeRegular
+	case "stake":
+		tree = wire.TxTreeStake
+	default:
+		http.Error(w, "tree must be regular or stake", http.StatusBadRequest)
+		return
+	}
+
+	utxos, err := c.AuxDataSource.AddressUTXOs(address, minConf, minAtoms, maxAtoms, tree)
+	if err != nil {
+		log.Warnf("failed to get address UTXOs (%s): %v", address, err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	if utxos == nil {
+		utxos = []*apitypes.AddressUTXO{}
+	}
+	writeJSON(w, utxos, c.getIndentQuery(r))
+}
+
+// getAddressBalance gets the balance of an address at the block height given
+// by the height URL query parameter, or at the best block.
+func (c *appContext) getAddressBalance(w http.ResponseWriter, r *http.Request) {
+	address := m.GetAddressCtx(r)
+	if address == "" || c.LiteMode {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	bestHeight := int64(c.BlockData.GetHeight())
+	height := bestHeight
+	if str := r.URL.Query().Get("height"); str != "" {
+		h, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || h < 0 || h > bestHeight {
+			http.Error(w, fmt.Sprintf("height must be an integer from 0 to %d",
+				bestHeight), http.StatusBadRequest)
+			return
+		}
+		height = h
+	}
+
+	balance, err := c.AuxDataSource.AddressBalanceAtHeight(address, height)
+	if err != nil {
+		log.Warnf("failed to get address balance (%s): %v", address, err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, balance, c.getIndentQuery(r))
+}
+
+// getAddressTickets lists the tickets with an address as their stake
+// submission address, with the address' return on investment.
+func (c *appContext) getAddressTickets(w http.ResponseWriter, r *http.Request) {
+	address := m.GetAddressCtx(r)
+	if address == "" || c.LiteMode {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	tickets, err := c.AuxDataSource.AddressTickets(address)
+	if err != nil {
+		log.Warnf("failed to get address tickets (%s): %v", address, err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, tickets, c.getIndentQuery(r))
+}
+
+// getTicketInfo gets the lifecycle of a ticket, from its purchase to its vote
+// or revocation.
+func (c *appContext) getTicketInfo(w http.ResponseWriter, r *http.Request) {
+	txid := m.GetTxIDCtx(r)
+	if txid == "" || c.LiteMode {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	ticket, err := c.AuxDataSource.TicketInfo(txid)
+	if err == sql.ErrNoRows {
+		http.Error(w, "ticket not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Warnf("failed to get ticket info (%s): %v", txid, err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, ticket, c.getIndentQuery(r))
+}
+
 func (c *appContext) getAddressTransactions(w http.ResponseWriter, r *http.Request) {
 	address := m.GetAddressCtx(r)
 	if address == "" {
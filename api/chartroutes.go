@@ -0,0 +1,54 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/Legenddigital/lddldata/charts"
+	m "github.com/Legenddigital/lddldata/middleware"
+)
+
+// ChartSource specifies an interface for the historical chart data.
+type ChartSource interface {
+	Chart(name, bin string) (*charts.Chart, error)
+}
+
+// ChartsCtx makes the chart routes unavailable if the chart source is not set,
+// as in lite mode.
+func (c *appContext) ChartsCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Charts == nil {
+			http.Error(w, "chart data is not available in lite mode", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getChart gets the data of a chart. The bin URL query aggregates the data by
+// block (the default), day, week or month.
+func (c *appContext) getChart(w http.ResponseWriter, r *http.Request) {
+	name := m.GetChartNameCtx(r)
+	bin := r.URL.Query().Get("bin")
+	if bin == "" {
+		bin = charts.BinBlock
+	}
+
+	chart, err := c.Charts.Chart(name, bin)
+	switch err {
+	case nil:
+	case charts.ErrUnknownChart:
+		http.Error(w, "unknown chart "+name, http.StatusNotFound)
+		return
+	case charts.ErrUnknownBin:
+		http.Error(w, "unknown bin "+bin, 422)
+		return
+	default:
+		apiLog.Errorf("Unable to get chart %s: %v", name, err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, chart, c.getIndentQuery(r))
+}
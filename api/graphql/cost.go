@@ -0,0 +1,195 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// estimatedListLength is the assumed length of lists without a first
+	// argument, such as the inputs and outputs of a transaction.
+	estimatedListLength = 10
+
+	// maxQueryCost bounds the computed cost to prevent overflow.
+	maxQueryCost = 1 << 40
+)
+
+// costEstimator estimates the cost of a query before it is executed. Each
+// field of an object type costs one data source lookup, multiplied by the
+// lengths of the lists enclosing it. List lengths are taken from the first
+// argument, the range of the blocks query, or estimatedListLength. Scalar
+// fields are free.
+type costEstimator struct {
+	schema    *gql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	defaults  map[string]ast.Value
+}
+
+// queryCost estimates the cost of the named operation of a validated
+// document.
+func queryCost(schema *gql.Schema, doc *ast.Document, operationName string,
+	variables map[string]interface{}) (int64, error) {
+	e := &costEstimator{
+		schema:    schema,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		defaults:  make(map[string]ast.Value),
+	}
+
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.OperationDefinition:
+			if operationName == "" && op != nil {
+				return 0, fmt.Errorf("operation name required")
+			}
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				op = def
+			}
+		case *ast.FragmentDefinition:
+			e.fragments[def.Name.Value] = def
+		}
+	}
+	if op == nil {
+		return 0, fmt.Errorf("unknown operation %q", operationName)
+	}
+	if op.Operation != ast.OperationTypeQuery {
+		return 0, fmt.Errorf("unsupported operation %s", op.Operation)
+	}
+	for _, v := range op.VariableDefinitions {
+		if v.DefaultValue != nil {
+			e.defaults[v.Variable.Name.Value] = v.DefaultValue
+		}
+	}
+
+	return e.selectionSetCost(schema.QueryType(), op.SelectionSet, 1, make(map[string]bool)), nil
+}
+
+// selectionSetCost returns the cost of the selections of a value of type t,
+// repeated multiplier times. spreads holds the fragments being expanded.
+func (e *costEstimator) selectionSetCost(t gql.Type, set *ast.SelectionSet,
+	multiplier int64, spreads map[string]bool) int64 {
+	if set == nil {
+		return 0
+	}
+	var cost int64
+	for _, sel := range set.Selections {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			obj, ok := t.(*gql.Object)
+			if !ok || sel.SelectionSet == nil {
+				continue
+			}
+			def, ok := obj.Fields()[sel.Name.Value]
+			if !ok {
+				// Introspection fields.
+				continue
+			}
+			fieldType := def.Type
+			if nonNull, ok := fieldType.(*gql.NonNull); ok {
+				fieldType = nonNull.OfType
+			}
+			n := multiplier
+			if list, ok := fieldType.(*gql.List); ok {
+				n = multiply(n, e.listLength(def, sel))
+				fieldType = list.OfType
+				if nonNull, ok := fieldType.(*gql.NonNull); ok {
+					fieldType = nonNull.OfType
+				}
+			}
+			cost = add(cost, add(n, e.selectionSetCost(fieldType, sel.SelectionSet, n, spreads)))
+		case *ast.InlineFragment:
+			fragType := t
+			if sel.TypeCondition != nil {
+				fragType = e.schema.Type(sel.TypeCondition.Name.Value)
+			}
+			cost = add(cost, e.selectionSetCost(fragType, sel.SelectionSet, multiplier, spreads))
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			frag, ok := e.fragments[name]
+			if !ok || spreads[name] {
+				continue
+			}
+			spreads[name] = true
+			cost = add(cost, e.selectionSetCost(e.schema.Type(frag.TypeCondition.Name.Value),
+				frag.SelectionSet, multiplier, spreads))
+			delete(spreads, name)
+		}
+	}
+	return cost
+}
+
+// listLength returns the expected length of the list returned by a field.
+func (e *costEstimator) listLength(def *gql.FieldDefinition, field *ast.Field) int64 {
+	args := make(map[string]int64)
+	for _, arg := range def.Args {
+		if v, ok := arg.DefaultValue.(int); ok {
+			args[arg.Name()] = int64(v)
+		}
+	}
+	for _, arg := range field.Arguments {
+		if v, ok := e.intValue(arg.Value); ok {
+			args[arg.Name.Value] = v
+		}
+	}
+
+	n := int64(estimatedListLength)
+	if first, ok := args["first"]; ok {
+		n = first
+	} else if from, ok := args["from"]; ok {
+		if to, ok := args["to"]; ok {
+			n = to - from + 1
+		}
+	}
+	if n < 0 {
+		return 0
+	}
+	if n > maxListLength {
+		return maxListLength
+	}
+	return n
+}
+
+// intValue returns the value of an integer argument.
+func (e *costEstimator) intValue(v ast.Value) (int64, bool) {
+	switch v := v.(type) {
+	case *ast.IntValue:
+		i, err := strconv.ParseInt(v.Value, 10, 64)
+		return i, err == nil
+	case *ast.Variable:
+		name := v.Name.Value
+		switch value := e.variables[name].(type) {
+		case float64:
+			return int64(value), true
+		case int:
+			return int64(value), true
+		case int64:
+			return value, true
+		}
+		if def, ok := e.defaults[name]; ok {
+			return e.intValue(def)
+		}
+	}
+	return 0, false
+}
+
+func add(a, b int64) int64 {
+	if a+b > maxQueryCost {
+		return maxQueryCost
+	}
+	return a + b
+}
+
+func multiply(a, b int64) int64 {
+	if b != 0 && a > maxQueryCost/b {
+		return maxQueryCost
+	}
+	return a * b
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+// Package graphql serves a GraphQL API over the blocks, transactions,
+// addresses and tickets of the lddldata data sources. Queries may walk from a
+// block to its transactions, their inputs and outputs, the spending
+// transactions and the ticket pool status, limited by an estimate of the
+// query's cost.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/Legenddigital/lddld/lddljson"
+	apitypes "github.com/Legenddigital/lddldata/api/types"
+	"github.com/Legenddigital/lddldata/db/dbtypes"
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+const (
+	// DefaultMaxCost is the default limit of the estimated cost of a query.
+	DefaultMaxCost = 5000
+
+	maxRequestSize = 1 << 16
+)
+
+// DataSourceLite specifies the methods of the built-in databases (i.e.
+// wiredDB) used to resolve queries.
+type DataSourceLite interface {
+	GetHeight() int
+	GetBlockVerbose(idx int, verboseTx bool) *lddljson.GetBlockVerboseResult
+	GetBlockVerboseByHash(hash string, verboseTx bool) *lddljson.GetBlockVerboseResult
+	GetRawTransaction(txid string) *apitypes.Tx
+	GetPoolInfo(idx int) *apitypes.TicketPoolInfo
+	GetPool(idx int64) ([]string, error)
+	GetAddressTransactionsRawWithSkip(addr string, count, skip int) []*apitypes.AddressTxRaw
+}
+
+// DataSourceAux specifies the methods of the auxiliary DB (e.g. PostgreSQL)
+// used to resolve the spending transactions and ticket status.
+type DataSourceAux interface {
+	SpendingTransaction(fundingTx string, vout uint32) (string, uint32, int8, error)
+	PoolStatusForTicket(txid string) (dbtypes.TicketSpendType, dbtypes.TicketPoolStatus, error)
+}
+
+// Server executes GraphQL queries.
+type Server struct {
+	schema     gql.Schema
+	maxCost    int
+	JSONIndent string
+}
+
+// NewServer creates a Server resolving queries from the data sources. aux may
+// be nil in lite mode, when the fields that require it resolve to null.
+// Queries with an estimated cost above maxCost are rejected. If maxCost is not
+// positive, DefaultMaxCost is used.
+func NewServer(lite DataSourceLite, aux DataSourceAux, maxCost int, JSONIndent string) (*Server, error) {
+	if maxCost <= 0 {
+		maxCost = DefaultMaxCost
+	}
+	schema, err := newSchema(&resolver{lite: lite, aux: aux})
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		schema:     schema,
+		maxCost:    maxCost,
+		JSONIndent: JSONIndent,
+	}, nil
+}
+
+// request is a GraphQL request.
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// ServeHTTP executes a query given in the URL query of a GET request, or in
+// the JSON or application/graphql body of a POST request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if vars := q.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				http.Error(w, "invalid variables", http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, "error reading request", http.StatusBadRequest)
+			return
+		}
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "application/graphql" {
+			req.Query = string(body)
+		} else if err = json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "failed to unmarshal JSON request", http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	if req.Query == "" {
+		http.Error(w, "query not specified", http.StatusBadRequest)
+		return
+	}
+
+	result := s.Do(r.Context(), req.Query, req.OperationName, req.Variables)
+
+	var indent string
+	if useIndentation := r.URL.Query().Get("indent"); useIndentation == "1" || useIndentation == "true" {
+		indent = s.JSONIndent
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(result); err != nil {
+		log.Infof("JSON encode error: %v", err)
+	}
+}
+
+// parse parses a GraphQL request document.
+func parse(query string) (*ast.Document, error) {
+	src := source.NewSource(&source.Source{
+		Body: []byte(query),
+		Name: "GraphQL request",
+	})
+	return parser.Parse(parser.ParseParams{Source: src})
+}
+
+// Do executes a query if its estimated cost is within the limit.
+func (s *Server) Do(ctx context.Context, query, operationName string,
+	variables map[string]interface{}) *gql.Result {
+	doc, err := parse(query)
+	if err != nil {
+		return &gql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	validation := gql.ValidateDocument(&s.schema, doc, nil)
+	if !validation.IsValid {
+		return &gql.Result{Errors: validation.Errors}
+	}
+
+	cost, err := queryCost(&s.schema, doc, operationName, variables)
+	if err != nil {
+		return &gql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if cost > int64(s.maxCost) {
+		log.Debugf("Rejected query with cost %d.", cost)
+		return &gql.Result{Errors: gqlerrors.FormatErrors(
+			fmt.Errorf("query cost %d exceeds the limit of %d", cost, s.maxCost))}
+	}
+
+	return gql.Execute(gql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: operationName,
+		Args:          variables,
+		Context:       ctx,
+	})
+}
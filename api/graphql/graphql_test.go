@@ -0,0 +1,171 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Legenddigital/lddld/lddljson"
+	apitypes "github.com/Legenddigital/lddldata/api/types"
+	"github.com/Legenddigital/lddldata/db/dbtypes"
+)
+
+type testLite struct {
+	blocks []*lddljson.GetBlockVerboseResult
+	txs    map[string]*apitypes.Tx
+}
+
+func (l *testLite) GetHeight() int { return len(l.blocks) - 1 }
+
+func (l *testLite) GetBlockVerbose(idx int, verboseTx bool) *lddljson.GetBlockVerboseResult {
+	return l.blocks[idx]
+}
+
+func (l *testLite) GetBlockVerboseByHash(hash string, verboseTx bool) *lddljson.GetBlockVerboseResult {
+	for _, b := range l.blocks {
+		if b.Hash == hash {
+			return b
+		}
+	}
+	return nil
+}
+
+func (l *testLite) GetRawTransaction(txid string) *apitypes.Tx { return l.txs[txid] }
+
+func (l *testLite) GetPoolInfo(idx int) *apitypes.TicketPoolInfo {
+	return &apitypes.TicketPoolInfo{Height: uint32(idx), Size: 1}
+}
+
+func (l *testLite) GetPool(idx int64) ([]string, error) { return []string{"ticket"}, nil }
+
+func (l *testLite) GetAddressTransactionsRawWithSkip(addr string, count, skip int) []*apitypes.AddressTxRaw {
+	return nil
+}
+
+type testAux struct{}
+
+func (testAux) SpendingTransaction(fundingTx string, vout uint32) (string, uint32, int8, error) {
+	if fundingTx == "coinbase" && vout == 0 {
+		return "ticket", 0, 1, nil
+	}
+	return "", 0, 0, sql.ErrNoRows
+}
+
+func (testAux) PoolStatusForTicket(txid string) (dbtypes.TicketSpendType, dbtypes.TicketPoolStatus, error) {
+	return dbtypes.TicketVoted, dbtypes.PoolStatusVoted, nil
+}
+
+func testServer(t *testing.T, maxCost int) *Server {
+	lite := &testLite{
+		blocks: []*lddljson.GetBlockVerboseResult{
+			{Hash: "genesis", Height: 0},
+			{Hash: "block1", Height: 1, Tx: []string{"coinbase"}, STx: []string{"ticket"}},
+		},
+		txs: map[string]*apitypes.Tx{
+			"coinbase": {
+				TxShort: apitypes.TxShort{
+					TxID: "coinbase",
+					Vin:  []lddljson.Vin{{Coinbase: "00"}},
+					Vout: []apitypes.Vout{{Value: 1, ScriptPubKeyDecoded: apitypes.ScriptPubKey{Type: "pubkeyhash"}}},
+				},
+				Block: &apitypes.BlockID{BlockHash: "block1", BlockHeight: 1},
+			},
+			"ticket": {
+				TxShort: apitypes.TxShort{
+					TxID: "ticket",
+					Vin:  []lddljson.Vin{{Txid: "coinbase", Vout: 0}},
+					Vout: []apitypes.Vout{{Value: 1, ScriptPubKeyDecoded: apitypes.ScriptPubKey{Type: ticketScriptType}}},
+				},
+				Block: &apitypes.BlockID{BlockHash: "block1", BlockHeight: 1, BlockIndex: 0},
+			},
+		},
+	}
+	s, err := NewServer(lite, testAux{}, maxCost, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestQueryCost(t *testing.T) {
+	s := testServer(t, 0)
+	tests := []struct {
+		query string
+		vars  map[string]interface{}
+		cost  int64
+	}{
+		{`{ bestBlock { hash height } }`, nil, 1},
+		// 1 block + 100 txs + 100*10 vouts + 100*10 spends
+		{`{ bestBlock { transactions { vout { spend { txid } } } } }`, nil, 2101},
+		{`{ blocks(from: 10, to: 19) { transactions(first: 5) { txid } } }`, nil, 60},
+		{`query($n: Int = 3) { block(height: 1) { transactions(first: $n) { block { hash } } } }`,
+			nil, 7},
+		{`query($n: Int = 3) { block(height: 1) { transactions(first: $n) { block { hash } } } }`,
+			map[string]interface{}{"n": float64(20)}, 41},
+		{`{ bestBlock { ...txs ... on Block { ticketPool { size } } } }
+			fragment txs on Block { transactions(first: 2) { txid } }`, nil, 4},
+		{`{ bestBlock { transactions(first: 100000) { vin { previousTransaction { vout { index } } } } } }`,
+			nil, 1 + 1000 + 1000*10*2 + 1000*10*10},
+	}
+	for _, test := range tests {
+		doc, err := parse(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cost, err := queryCost(&s.schema, doc, "", test.vars)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if cost != test.cost {
+			t.Errorf("%s: cost %d, expected %d", test.query, cost, test.cost)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	s := testServer(t, 300)
+
+	result := s.Do(context.Background(), `{
+		block(height: 1) {
+			hash
+			nextHash
+			transactions(tree: REGULAR, first: 1) {
+				txid
+				vin { coinbase previousTransaction { txid } }
+				vout {
+					value
+					spend {
+						vin
+						transaction {
+							blockHeight
+							vin { previousOutput { value } }
+							ticket { spendType poolStatus }
+						}
+					}
+				}
+			}
+		}
+	}`, "", nil)
+	if result.HasErrors() {
+		t.Fatalf("query failed: %v", result.Errors)
+	}
+	got, err := json.Marshal(result.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"block":{"hash":"block1","nextHash":null,"transactions":[{"txid":"coinbase",` +
+		`"vin":[{"coinbase":"00","previousTransaction":null}],"vout":[{"spend":{"transaction":` +
+		`{"blockHeight":1,"ticket":{"poolStatus":"voted","spendType":"voted"},` +
+		`"vin":[{"previousOutput":{"value":1}}]},"vin":0},"value":1}]}]}}`
+	if string(got) != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+
+	result = s.Do(context.Background(), `{ blocks(from: 0, to: 1) { transactions { vout { value } } } }`, "", nil)
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "exceeds the limit") {
+		t.Errorf("expected cost limit error, got %v", result.Errors)
+	}
+}
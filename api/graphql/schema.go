@@ -0,0 +1,720 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package graphql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Legenddigital/lddld/lddljson"
+	apitypes "github.com/Legenddigital/lddldata/api/types"
+	"github.com/Legenddigital/lddldata/db/dbtypes"
+	gql "github.com/graphql-go/graphql"
+)
+
+const (
+	// maxListLength limits the first argument of the paginated lists and the
+	// range of the blocks query.
+	maxListLength = 1000
+
+	// ticketScriptType is the decoded script type of the first output of a
+	// ticket purchase.
+	ticketScriptType = "stakesubmission"
+)
+
+// Transaction trees selected by the TxTree enum.
+const (
+	treeRegular = "regular"
+	treeStake   = "stake"
+	treeAll     = "all"
+)
+
+// resolver resolves the fields of the schema from the data sources.
+type resolver struct {
+	lite DataSourceLite
+	aux  DataSourceAux
+}
+
+// txSource is a transaction identified by its hash. The transaction is only
+// retrieved when a field other than the hash is requested.
+type txSource struct {
+	txid string
+	once sync.Once
+	tx   *apitypes.Tx
+}
+
+func newTxSource(txid string) *txSource {
+	return &txSource{txid: txid}
+}
+
+// transaction retrieves the transaction of src once.
+func (r *resolver) transaction(src *txSource) (*apitypes.Tx, error) {
+	src.once.Do(func() {
+		src.tx = r.lite.GetRawTransaction(src.txid)
+	})
+	if src.tx == nil {
+		return nil, fmt.Errorf("unable to get transaction %s", src.txid)
+	}
+	return src.tx, nil
+}
+
+// vinSource is a transaction input and its index.
+type vinSource struct {
+	vin   lddljson.Vin
+	index int
+}
+
+// voutSource is a transaction output and the hash of its transaction.
+type voutSource struct {
+	vout apitypes.Vout
+	txid string
+}
+
+// spendSource is the input spending a transaction output.
+type spendSource struct {
+	txid string
+	vin  uint32
+	tree int8
+}
+
+// ticketSource is a ticket purchase transaction. Its status is retrieved from
+// the auxiliary DB once, when requested.
+type ticketSource struct {
+	txid       string
+	once       sync.Once
+	spendType  dbtypes.TicketSpendType
+	poolStatus dbtypes.TicketPoolStatus
+	err        error
+}
+
+// ticketStatus retrieves the spend type and pool status of the ticket once.
+// ok is false if they are not known, such as when there is no auxiliary DB.
+func (r *resolver) ticketStatus(src *ticketSource) (ticket *ticketSource, ok bool, err error) {
+	if r.aux == nil {
+		return src, false, nil
+	}
+	src.once.Do(func() {
+		src.spendType, src.poolStatus, src.err = r.aux.PoolStatusForTicket(src.txid)
+	})
+	if src.err == sql.ErrNoRows {
+		return src, false, nil
+	}
+	return src, src.err == nil, src.err
+}
+
+// ticket returns the ticket of a transaction, or nil if the transaction is not
+// a ticket purchase.
+func (r *resolver) ticket(src *txSource) (interface{}, error) {
+	tx, err := r.transaction(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(tx.Vout) == 0 || tx.Vout[0].ScriptPubKeyDecoded.Type != ticketScriptType {
+		return nil, nil
+	}
+	return &ticketSource{txid: src.txid}, nil
+}
+
+// pageValues returns the first and skip arguments of a paginated list, with
+// first limited to maxListLength.
+func pageValues(args map[string]interface{}) (first, skip int) {
+	first, _ = args["first"].(int)
+	skip, _ = args["skip"].(int)
+	if first > maxListLength {
+		first = maxListLength
+	}
+	return first, skip
+}
+
+// page returns the range of the items of a list of length n selected by the
+// first and skip arguments.
+func page(n int, args map[string]interface{}) (start, end int) {
+	first, skip := pageValues(args)
+	if first < 0 || skip < 0 || skip >= n {
+		return 0, 0
+	}
+	end = skip + first
+	if end > n {
+		end = n
+	}
+	return skip, end
+}
+
+// pageArgs returns the first and skip arguments of a paginated list.
+func pageArgs(first int) gql.FieldConfigArgument {
+	return gql.FieldConfigArgument{
+		"first": &gql.ArgumentConfig{
+			Type:         gql.Int,
+			DefaultValue: first,
+			Description:  fmt.Sprintf("Maximum number of items, up to %d.", maxListLength),
+		},
+		"skip": &gql.ArgumentConfig{
+			Type:         gql.Int,
+			DefaultValue: 0,
+			Description:  "Number of items to skip.",
+		},
+	}
+}
+
+func txSources(txids []string) []*txSource {
+	txs := make([]*txSource, 0, len(txids))
+	for _, txid := range txids {
+		txs = append(txs, newTxSource(txid))
+	}
+	return txs
+}
+
+func blockField(f func(*lddljson.GetBlockVerboseResult) interface{}) gql.FieldResolveFn {
+	return func(p gql.ResolveParams) (interface{}, error) {
+		return f(p.Source.(*lddljson.GetBlockVerboseResult)), nil
+	}
+}
+
+func (r *resolver) txField(f func(*apitypes.Tx) interface{}) gql.FieldResolveFn {
+	return func(p gql.ResolveParams) (interface{}, error) {
+		tx, err := r.transaction(p.Source.(*txSource))
+		if err != nil {
+			return nil, err
+		}
+		return f(tx), nil
+	}
+}
+
+// blockIDField resolves a field of the block containing a transaction, or
+// null if the transaction is not mined.
+func (r *resolver) blockIDField(f func(*apitypes.BlockID) interface{}) gql.FieldResolveFn {
+	return r.txField(func(tx *apitypes.Tx) interface{} {
+		if tx.Block == nil {
+			return nil
+		}
+		return f(tx.Block)
+	})
+}
+
+func vinField(f func(*vinSource) interface{}) gql.FieldResolveFn {
+	return func(p gql.ResolveParams) (interface{}, error) {
+		return f(p.Source.(*vinSource)), nil
+	}
+}
+
+func voutField(f func(*voutSource) interface{}) gql.FieldResolveFn {
+	return func(p gql.ResolveParams) (interface{}, error) {
+		return f(p.Source.(*voutSource)), nil
+	}
+}
+
+func poolField(f func(*apitypes.TicketPoolInfo) interface{}) gql.FieldResolveFn {
+	return func(p gql.ResolveParams) (interface{}, error) {
+		return f(p.Source.(*apitypes.TicketPoolInfo)), nil
+	}
+}
+
+// block retrieves a block by height, or returns nil if there is no such block.
+func (r *resolver) block(height int) interface{} {
+	if height < 0 || height > r.lite.GetHeight() {
+		return nil
+	}
+	if block := r.lite.GetBlockVerbose(height, false); block != nil {
+		return block
+	}
+	return nil
+}
+
+// ticketPool retrieves the ticket pool info at a height.
+func (r *resolver) ticketPool(height int) (interface{}, error) {
+	if height < 0 || height > r.lite.GetHeight() {
+		return nil, nil
+	}
+	info := r.lite.GetPoolInfo(height)
+	if info == nil {
+		return nil, fmt.Errorf("unable to get ticket pool info at height %d", height)
+	}
+	return info, nil
+}
+
+// newSchema creates the GraphQL schema resolved by r.
+func newSchema(r *resolver) (gql.Schema, error) {
+	var blockType, txType, ticketType, ticketPoolType *gql.Object
+
+	txTreeEnum := gql.NewEnum(gql.EnumConfig{
+		Name:        "TxTree",
+		Description: "Transaction tree of a block.",
+		Values: gql.EnumValueConfigMap{
+			"REGULAR": &gql.EnumValueConfig{Value: treeRegular, Description: "Regular transactions."},
+			"STAKE":   &gql.EnumValueConfig{Value: treeStake, Description: "Stake transactions."},
+			"ALL":     &gql.EnumValueConfig{Value: treeAll, Description: "Regular and stake transactions."},
+		},
+	})
+
+	blockType = gql.NewObject(gql.ObjectConfig{
+		Name: "Block",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"hash": &gql.Field{Type: gql.NewNonNull(gql.String),
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.Hash })},
+				"height": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.Height })},
+				"size": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.Size })},
+				"time": &gql.Field{Type: gql.NewNonNull(gql.Int), Description: "Block time in seconds since the Unix epoch.",
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.Time })},
+				"version": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.Version })},
+				"confirmations": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.Confirmations })},
+				"difficulty": &gql.Field{Type: gql.NewNonNull(gql.Float),
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.Difficulty })},
+				"sbits": &gql.Field{Type: gql.NewNonNull(gql.Float), Description: "Ticket price.",
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.SBits })},
+				"poolSize": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.PoolSize })},
+				"voters": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.Voters })},
+				"freshStake": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.FreshStake })},
+				"revocations": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.Revocations })},
+				"stakeVersion": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.StakeVersion })},
+				"previousHash": &gql.Field{Type: gql.String,
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} { return b.PreviousHash })},
+				"nextHash": &gql.Field{Type: gql.String,
+					Resolve: blockField(func(b *lddljson.GetBlockVerboseResult) interface{} {
+						if b.NextHash == "" {
+							return nil
+						}
+						return b.NextHash
+					})},
+				"transactions": &gql.Field{
+					Type:        gql.NewNonNull(gql.NewList(gql.NewNonNull(txType))),
+					Description: "Transactions of the block, regular transactions first.",
+					Args: func() gql.FieldConfigArgument {
+						args := pageArgs(100)
+						args["tree"] = &gql.ArgumentConfig{Type: txTreeEnum, DefaultValue: treeAll}
+						return args
+					}(),
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						b := p.Source.(*lddljson.GetBlockVerboseResult)
+						var txids []string
+						switch p.Args["tree"] {
+						case treeRegular:
+							txids = b.Tx
+						case treeStake:
+							txids = b.STx
+						default:
+							txids = append(append(txids, b.Tx...), b.STx...)
+						}
+						start, end := page(len(txids), p.Args)
+						return txSources(txids[start:end]), nil
+					}},
+				"ticketPool": &gql.Field{Type: ticketPoolType,
+					Description: "Ticket pool after the block.",
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						return r.ticketPool(int(p.Source.(*lddljson.GetBlockVerboseResult).Height))
+					}},
+			}
+		}),
+	})
+
+	spendType := gql.NewObject(gql.ObjectConfig{
+		Name:        "Spend",
+		Description: "Input spending a transaction output.",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"txid": &gql.Field{Type: gql.NewNonNull(gql.String),
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						return p.Source.(*spendSource).txid, nil
+					}},
+				"vin": &gql.Field{Type: gql.NewNonNull(gql.Int), Description: "Index of the input.",
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						return p.Source.(*spendSource).vin, nil
+					}},
+				"tree": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						return p.Source.(*spendSource).tree, nil
+					}},
+				"transaction": &gql.Field{Type: gql.NewNonNull(txType),
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						return newTxSource(p.Source.(*spendSource).txid), nil
+					}},
+			}
+		}),
+	})
+
+	voutType := gql.NewObject(gql.ObjectConfig{
+		Name:        "Vout",
+		Description: "Transaction output.",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"index": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: voutField(func(v *voutSource) interface{} { return v.vout.N })},
+				"value": &gql.Field{Type: gql.NewNonNull(gql.Float),
+					Resolve: voutField(func(v *voutSource) interface{} { return v.vout.Value })},
+				"version": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: voutField(func(v *voutSource) interface{} { return v.vout.Version })},
+				"type": &gql.Field{Type: gql.NewNonNull(gql.String), Description: "Script type.",
+					Resolve: voutField(func(v *voutSource) interface{} { return v.vout.ScriptPubKeyDecoded.Type })},
+				"addresses": &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(gql.String))),
+					Resolve: voutField(func(v *voutSource) interface{} {
+						if v.vout.ScriptPubKeyDecoded.Addresses == nil {
+							return []string{}
+						}
+						return v.vout.ScriptPubKeyDecoded.Addresses
+					})},
+				"commitAmount": &gql.Field{Type: gql.Float, Description: "Amount committed by a ticket commitment output.",
+					Resolve: voutField(func(v *voutSource) interface{} { return v.vout.ScriptPubKeyDecoded.CommitAmt })},
+				"transaction": &gql.Field{Type: gql.NewNonNull(txType),
+					Resolve: voutField(func(v *voutSource) interface{} { return newTxSource(v.txid) })},
+				"spend": &gql.Field{Type: spendType,
+					Description: "Input spending the output. Null if the output is unspent or there is no auxiliary DB.",
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						if r.aux == nil {
+							return nil, nil
+						}
+						v := p.Source.(*voutSource)
+						txid, vin, tree, err := r.aux.SpendingTransaction(v.txid, v.vout.N)
+						if err == sql.ErrNoRows {
+							return nil, nil
+						}
+						if err != nil {
+							log.Errorf("SpendingTransaction(%s, %d) failed: %v", v.txid, v.vout.N, err)
+							return nil, fmt.Errorf("unable to get the spending transaction")
+						}
+						return &spendSource{txid: txid, vin: vin, tree: tree}, nil
+					}},
+			}
+		}),
+	})
+
+	vinType := gql.NewObject(gql.ObjectConfig{
+		Name:        "Vin",
+		Description: "Transaction input.",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"index": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: vinField(func(v *vinSource) interface{} { return v.index })},
+				"coinbase": &gql.Field{Type: gql.String,
+					Resolve: vinField(func(v *vinSource) interface{} {
+						if !v.vin.IsCoinBase() {
+							return nil
+						}
+						return v.vin.Coinbase
+					})},
+				"stakebase": &gql.Field{Type: gql.String,
+					Resolve: vinField(func(v *vinSource) interface{} {
+						if !v.vin.IsStakeBase() {
+							return nil
+						}
+						return v.vin.Stakebase
+					})},
+				"txid": &gql.Field{Type: gql.String, Description: "Hash of the transaction of the previous output.",
+					Resolve: vinField(func(v *vinSource) interface{} {
+						if v.vin.Txid == "" {
+							return nil
+						}
+						return v.vin.Txid
+					})},
+				"vout": &gql.Field{Type: gql.NewNonNull(gql.Int), Description: "Index of the previous output.",
+					Resolve: vinField(func(v *vinSource) interface{} { return v.vin.Vout })},
+				"tree": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: vinField(func(v *vinSource) interface{} { return v.vin.Tree })},
+				"sequence": &gql.Field{Type: gql.NewNonNull(gql.Float),
+					Resolve: vinField(func(v *vinSource) interface{} { return float64(v.vin.Sequence) })},
+				"amountIn": &gql.Field{Type: gql.NewNonNull(gql.Float),
+					Resolve: vinField(func(v *vinSource) interface{} { return v.vin.AmountIn })},
+				"blockHeight": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: vinField(func(v *vinSource) interface{} { return v.vin.BlockHeight })},
+				"previousTransaction": &gql.Field{Type: txType,
+					Description: "Transaction of the previous output. Null for coinbase and stakebase inputs.",
+					Resolve: vinField(func(v *vinSource) interface{} {
+						if v.vin.Txid == "" || v.vin.IsCoinBase() || v.vin.IsStakeBase() {
+							return nil
+						}
+						return newTxSource(v.vin.Txid)
+					})},
+				"previousOutput": &gql.Field{Type: voutType,
+					Description: "Previous output. Null for coinbase and stakebase inputs.",
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						v := p.Source.(*vinSource)
+						if v.vin.Txid == "" || v.vin.IsCoinBase() || v.vin.IsStakeBase() {
+							return nil, nil
+						}
+						tx, err := r.transaction(newTxSource(v.vin.Txid))
+						if err != nil {
+							return nil, err
+						}
+						if int(v.vin.Vout) >= len(tx.Vout) {
+							return nil, fmt.Errorf("invalid previous output %s:%d", v.vin.Txid, v.vin.Vout)
+						}
+						return &voutSource{vout: tx.Vout[v.vin.Vout], txid: v.vin.Txid}, nil
+					}},
+			}
+		}),
+	})
+
+	txType = gql.NewObject(gql.ObjectConfig{
+		Name: "Transaction",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"txid": &gql.Field{Type: gql.NewNonNull(gql.String),
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						return p.Source.(*txSource).txid, nil
+					}},
+				"size": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: r.txField(func(tx *apitypes.Tx) interface{} { return tx.Size })},
+				"version": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: r.txField(func(tx *apitypes.Tx) interface{} { return tx.Version })},
+				"locktime": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: r.txField(func(tx *apitypes.Tx) interface{} { return tx.Locktime })},
+				"expiry": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: r.txField(func(tx *apitypes.Tx) interface{} { return tx.Expiry })},
+				"confirmations": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: r.txField(func(tx *apitypes.Tx) interface{} { return tx.Confirmations })},
+				"blockHash": &gql.Field{Type: gql.String,
+					Resolve: r.blockIDField(func(b *apitypes.BlockID) interface{} { return b.BlockHash })},
+				"blockHeight": &gql.Field{Type: gql.Int,
+					Resolve: r.blockIDField(func(b *apitypes.BlockID) interface{} { return b.BlockHeight })},
+				"blockIndex": &gql.Field{Type: gql.Int, Description: "Index of the transaction in the block.",
+					Resolve: r.blockIDField(func(b *apitypes.BlockID) interface{} { return b.BlockIndex })},
+				"time": &gql.Field{Type: gql.Int, Description: "Block time in seconds since the Unix epoch.",
+					Resolve: r.blockIDField(func(b *apitypes.BlockID) interface{} { return b.Time })},
+				"block": &gql.Field{Type: blockType, Description: "Block of the transaction. Null if unconfirmed.",
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						tx, err := r.transaction(p.Source.(*txSource))
+						if err != nil || tx.Block == nil {
+							return nil, err
+						}
+						block := r.lite.GetBlockVerboseByHash(tx.Block.BlockHash, false)
+						if block == nil {
+							return nil, fmt.Errorf("unable to get block %s", tx.Block.BlockHash)
+						}
+						return block, nil
+					}},
+				"vin": &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(vinType))),
+					Resolve: r.txField(func(tx *apitypes.Tx) interface{} {
+						vins := make([]*vinSource, 0, len(tx.Vin))
+						for i := range tx.Vin {
+							vins = append(vins, &vinSource{vin: tx.Vin[i], index: i})
+						}
+						return vins
+					})},
+				"vout": &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(voutType))),
+					Resolve: r.txField(func(tx *apitypes.Tx) interface{} {
+						vouts := make([]*voutSource, 0, len(tx.Vout))
+						for i := range tx.Vout {
+							vouts = append(vouts, &voutSource{vout: tx.Vout[i], txid: tx.TxID})
+						}
+						return vouts
+					})},
+				"ticket": &gql.Field{Type: ticketType, Description: "Ticket, if the transaction is a ticket purchase.",
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						return r.ticket(p.Source.(*txSource))
+					}},
+			}
+		}),
+	})
+
+	ticketType = gql.NewObject(gql.ObjectConfig{
+		Name: "Ticket",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"txid": &gql.Field{Type: gql.NewNonNull(gql.String),
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						return p.Source.(*ticketSource).txid, nil
+					}},
+				"spendType": &gql.Field{Type: gql.String,
+					Description: "unspent, voted or revoked. Null if there is no auxiliary DB.",
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						t, ok, err := r.ticketStatus(p.Source.(*ticketSource))
+						if !ok {
+							return nil, err
+						}
+						return strings.ToLower(t.spendType.String()), nil
+					}},
+				"poolStatus": &gql.Field{Type: gql.String,
+					Description: "live, voted, expired or missed. Null if there is no auxiliary DB.",
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						t, ok, err := r.ticketStatus(p.Source.(*ticketSource))
+						if !ok {
+							return nil, err
+						}
+						return strings.ToLower(t.poolStatus.String()), nil
+					}},
+				"transaction": &gql.Field{Type: gql.NewNonNull(txType),
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						return newTxSource(p.Source.(*ticketSource).txid), nil
+					}},
+			}
+		}),
+	})
+
+	ticketPoolType = gql.NewObject(gql.ObjectConfig{
+		Name: "TicketPool",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"height": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: poolField(func(i *apitypes.TicketPoolInfo) interface{} { return i.Height })},
+				"size": &gql.Field{Type: gql.NewNonNull(gql.Int),
+					Resolve: poolField(func(i *apitypes.TicketPoolInfo) interface{} { return i.Size })},
+				"value": &gql.Field{Type: gql.NewNonNull(gql.Float),
+					Resolve: poolField(func(i *apitypes.TicketPoolInfo) interface{} { return i.Value })},
+				"valueAverage": &gql.Field{Type: gql.NewNonNull(gql.Float),
+					Resolve: poolField(func(i *apitypes.TicketPoolInfo) interface{} { return i.ValAvg })},
+				"winners": &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(ticketType))),
+					Description: "Tickets called to vote on the block.",
+					Resolve: poolField(func(i *apitypes.TicketPoolInfo) interface{} {
+						winners := make([]*ticketSource, 0, len(i.Winners))
+						for _, txid := range i.Winners {
+							winners = append(winners, &ticketSource{txid: txid})
+						}
+						return winners
+					})},
+				"tickets": &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(ticketType))),
+					Description: "Live tickets.",
+					Args:        pageArgs(100),
+					Resolve: func(p gql.ResolveParams) (interface{}, error) {
+						info := p.Source.(*apitypes.TicketPoolInfo)
+						pool, err := r.lite.GetPool(int64(info.Height))
+						if err != nil {
+							log.Errorf("GetPool(%d) failed: %v", info.Height, err)
+							return nil, fmt.Errorf("unable to get the ticket pool at height %d", info.Height)
+						}
+						start, end := page(len(pool), p.Args)
+						tickets := make([]*ticketSource, 0, end-start)
+						for _, txid := range pool[start:end] {
+							tickets = append(tickets, &ticketSource{txid: txid})
+						}
+						return tickets, nil
+					}},
+			}
+		}),
+	})
+
+	addressType := gql.NewObject(gql.ObjectConfig{
+		Name: "Address",
+		Fields: gql.Fields{
+			"address": &gql.Field{Type: gql.NewNonNull(gql.String),
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					return p.Source.(string), nil
+				}},
+			"transactions": &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(txType))),
+				Description: "Transactions of the address, most recent first.",
+				Args:        pageArgs(10),
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					first, skip := pageValues(p.Args)
+					if first <= 0 || skip < 0 {
+						return []*txSource{}, nil
+					}
+					txs := r.lite.GetAddressTransactionsRawWithSkip(p.Source.(string),
+						first, skip)
+					txids := make([]string, 0, len(txs))
+					for _, tx := range txs {
+						txids = append(txids, tx.TxID)
+					}
+					return txSources(txids), nil
+				}},
+		},
+	})
+
+	queryType := gql.NewObject(gql.ObjectConfig{
+		Name: "Query",
+		Fields: gql.Fields{
+			"bestBlock": &gql.Field{Type: blockType,
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					return r.block(r.lite.GetHeight()), nil
+				}},
+			"block": &gql.Field{Type: blockType,
+				Description: "Block with the hash or, if no hash is given, the height.",
+				Args: gql.FieldConfigArgument{
+					"height": &gql.ArgumentConfig{Type: gql.Int},
+					"hash":   &gql.ArgumentConfig{Type: gql.String},
+				},
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					if hash, ok := p.Args["hash"].(string); ok {
+						if block := r.lite.GetBlockVerboseByHash(hash, false); block != nil {
+							return block, nil
+						}
+						return nil, nil
+					}
+					height, ok := p.Args["height"].(int)
+					if !ok {
+						return nil, fmt.Errorf("height or hash required")
+					}
+					return r.block(height), nil
+				}},
+			"blocks": &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(blockType))),
+				Description: fmt.Sprintf("Blocks from height from to height to, up to %d blocks.", maxListLength),
+				Args: gql.FieldConfigArgument{
+					"from": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.Int)},
+					"to":   &gql.ArgumentConfig{Type: gql.NewNonNull(gql.Int)},
+				},
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					from, to := p.Args["from"].(int), p.Args["to"].(int)
+					if from < 0 || to < from || to-from >= maxListLength {
+						return nil, fmt.Errorf("invalid block range [%d, %d]", from, to)
+					}
+					if best := r.lite.GetHeight(); to > best {
+						to = best
+					}
+					if to < from {
+						return []*lddljson.GetBlockVerboseResult{}, nil
+					}
+					blocks := make([]*lddljson.GetBlockVerboseResult, 0, to-from+1)
+					for height := from; height <= to; height++ {
+						block := r.lite.GetBlockVerbose(height, false)
+						if block == nil {
+							return nil, fmt.Errorf("unable to get block %d", height)
+						}
+						blocks = append(blocks, block)
+					}
+					return blocks, nil
+				}},
+			"transaction": &gql.Field{Type: txType,
+				Args: gql.FieldConfigArgument{
+					"txid": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.String)},
+				},
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					tx := newTxSource(p.Args["txid"].(string))
+					if _, err := r.transaction(tx); err != nil {
+						return nil, nil
+					}
+					return tx, nil
+				}},
+			"address": &gql.Field{Type: addressType,
+				Args: gql.FieldConfigArgument{
+					"address": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.String)},
+				},
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					return p.Args["address"].(string), nil
+				}},
+			"ticket": &gql.Field{Type: ticketType,
+				Args: gql.FieldConfigArgument{
+					"txid": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.String)},
+				},
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					tx := newTxSource(p.Args["txid"].(string))
+					if _, err := r.transaction(tx); err != nil {
+						return nil, nil
+					}
+					return r.ticket(tx)
+				}},
+			"ticketPool": &gql.Field{Type: ticketPoolType,
+				Description: "Ticket pool at a height, by default the best block.",
+				Args: gql.FieldConfigArgument{
+					"height": &gql.ArgumentConfig{Type: gql.Int},
+				},
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					height, ok := p.Args["height"].(int)
+					if !ok {
+						height = r.lite.GetHeight()
+					}
+					return r.ticketPool(height)
+				}},
+		},
+	})
+
+	return gql.NewSchema(gql.SchemaConfig{Query: queryType})
+}
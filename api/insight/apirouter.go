@@ -9,8 +9,6 @@ package insight
 
 import (
 	m "github.com/Legenddigital/lddldata/middleware"
-	"github.com/didip/tollbooth"
-	"github.com/didip/tollbooth_chi"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 )
@@ -26,14 +24,9 @@ const APIVersion = 0
 // NewInsightApiRouter returns a new HTTP path router, ApiMux, for the Insight
 // API.
 func NewInsightApiRouter(app *insightApiContext, userRealIP bool) ApiMux {
-	// Create a limiter struct.
-	limiter := tollbooth.NewLimiter(1, nil)
-
-	// chi router
+	// chi router. Rate limiting is done by the parent router's RateLimiter.
 	mux := chi.NewRouter()
 
-	mux.Use(tollbooth_chi.LimitHandler(limiter))
-
 	if userRealIP {
 		mux.Use(middleware.RealIP)
 	}
@@ -0,0 +1,147 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package insight
+
+import (
+	"github.com/Legenddigital/lddldata/api/openapi"
+	apitypes "github.com/Legenddigital/lddldata/api/types"
+)
+
+var (
+	hashSchema    = &openapi.Schema{Type: "string", Pattern: "^[0-9a-f]{64}$"}
+	integerSchema = &openapi.Schema{Type: "integer", Format: "int64"}
+	flagSchema    = &openapi.Schema{Type: "boolean"}
+)
+
+// pathParams documents the path parameters of the Insight API routes.
+var pathParams = map[string]openapi.Parameter{
+	"idxorhash": {
+		Description: "Block index (height) or block hash.",
+		Schema:      &openapi.Schema{Type: "string", Pattern: "^([0-9]+|[0-9a-f]{64})$"},
+	},
+	"txid": {
+		Description: "Transaction hash.",
+		Schema:      hashSchema,
+	},
+	"address": {
+		Description: "Address, or comma-separated addresses for the /addrs routes.",
+		Schema:      &openapi.Schema{Type: "string"},
+	},
+	"command": {
+		Description: "Address amount in atoms.",
+		Schema: &openapi.Schema{Type: "string",
+			Enum: []string{"balance", "totalReceived", "totalSent", "unconfirmedBalance"}},
+	},
+}
+
+// fromToQuery are the pagination query parameters of the address routes.
+var fromToQuery = []openapi.Parameter{
+	{Name: "from", Description: "Index of the first item.", Schema: integerSchema},
+	{Name: "to", Description: "Index after the last item.", Schema: integerSchema},
+}
+
+// insightRoutes documents the routes of the router created by
+// NewInsightApiRouter.
+var insightRoutes = map[string]openapi.Route{
+	"GET /blocks": {Summary: "Summaries of the blocks mined on a day.",
+		Response: apitypes.InsightBlocksSummaryResult{},
+		Query: []openapi.Parameter{
+			{Name: "blockDate", Description: "Date in YYYY-MM-DD format. The default is today.",
+				Schema: &openapi.Schema{Type: "string", Format: "date"}},
+			{Name: "limit", Description: "Maximum number of blocks.", Schema: integerSchema},
+		}},
+	"GET /block/{idxorhash}": {Summary: "Block.", Response: []apitypes.InsightBlockResult{}},
+	"GET /block-index/{idxorhash}": {Summary: "Block hash.", Response: struct {
+		BlockHash string `json:"blockHash"`
+	}{}},
+	"GET /rawblock/{idxorhash}": {Summary: "Serialized block.", Response: struct {
+		BlockHash string `json:"rawblock"`
+	}{}},
+
+	"POST /tx/send": {Summary: "Broadcast a transaction.", Request: apitypes.InsightRawTx{},
+		Response: apitypes.InsightRawTx{}},
+	"GET /tx/{txid}":    {Summary: "Transaction.", Response: apitypes.InsightTx{}},
+	"GET /rawtx/{txid}": {Summary: "Serialized transaction.", Response: apitypes.InsightRawTx{}},
+	"GET /txs": {Summary: "Transactions of a block or address.",
+		Response: apitypes.InsightBlockAddrTxSummary{},
+		Query: []openapi.Parameter{
+			{Name: "block", Description: "Block hash.", Schema: hashSchema},
+			{Name: "address", Description: "Address.", Schema: &openapi.Schema{Type: "string"}},
+		}},
+
+	"GET /status": {Summary: "Node status, or the result of the q query.",
+		Response: openapi.OneOf(
+			struct {
+				Version         int32   `json:"version"`
+				Protocolversion int32   `json:"protocolversion"`
+				Blocks          int32   `json:"blocks"`
+				NodeTimeoffset  int64   `json:"timeoffset"`
+				NodeConnections int32   `json:"connections"`
+				Proxy           string  `json:"proxy"`
+				Difficulty      float64 `json:"difficulty"`
+				Testnet         bool    `json:"testnet"`
+				Relayfee        float64 `json:"relayfee"`
+				Errors          string  `json:"errors"`
+			}{},
+			struct {
+				Difficulty float64 `json:"difficulty"`
+			}{},
+			struct {
+				BestBlockHash string `json:"bestblockhash"`
+			}{},
+			struct {
+				SyncTipHash   string `json:"syncTipHash"`
+				LastBlockHash string `json:"lastblockhash"`
+			}{}),
+		Query: []openapi.Parameter{{Name: "q", Schema: &openapi.Schema{Type: "string",
+			Enum: []string{"getInfo", "getDifficulty", "getBestBlockHash", "getLastBlockHash"}}}}},
+	"GET /utils/estimatefee": {Summary: "Fee rate estimates by number of blocks.",
+		Response: map[string]float64{},
+		Query:    []openapi.Parameter{{Name: "nbBlocks", Schema: integerSchema}}},
+	"GET /peer": {Summary: "Node connection status.", Response: struct {
+		Connected bool    `json:"connected"`
+		Host      string  `json:"host"`
+		Port      *string `json:"port"`
+	}{}},
+
+	"GET /addrs/{address}/txs": {Summary: "Transactions of the addresses.",
+		Response: apitypes.InsightMultiAddrsTxOutput{}, Query: fromToQuery},
+	"GET /addrs/{address}/utxo": {Summary: "Unspent outputs of the addresses.",
+		Response: []apitypes.AddressTxnOutput{}},
+	"POST /addrs/txs": {Summary: "Transactions of the addresses.", Request: apitypes.InsightMultiAddrsTx{},
+		Response: apitypes.InsightMultiAddrsTxOutput{}},
+	"POST /addrs/utxo": {Summary: "Unspent outputs of the addresses.", Request: apitypes.InsightAddr{},
+		Response: []apitypes.AddressTxnOutput{}},
+
+	"GET /addr/{address}": {Summary: "Address balances and transactions.",
+		Response: apitypes.InsightAddressInfo{},
+		Query: append([]openapi.Parameter{{Name: "noTxList", Description: "Omit the transaction IDs.",
+			Schema: flagSchema}}, fromToQuery...)},
+	"GET /addr/{address}/utxo":      {Summary: "Unspent outputs of the address.", Response: []apitypes.AddressTxnOutput{}},
+	"GET /addr/{address}/{command}": {Summary: "Address amount in atoms.", Response: int64(0)},
+}
+
+// OpenAPI describes the routes of the Insight API router, mounted at prefix.
+func (mux ApiMux) OpenAPI(prefix string) openapi.API {
+	return openapi.API{
+		Prefix:      prefix,
+		Tag:         "insight",
+		Description: "Insight API",
+		Router:      mux.Mux,
+		Routes:      insightRoutes,
+		PathParams:  pathParams,
+		Query: []openapi.Parameter{{
+			Name:        "indent",
+			Description: "Indent the JSON response.",
+			Schema:      flagSchema,
+		}},
+	}
+}
+
+// NewDirectoryRouter creates an Insight API router that is not backed by data
+// sources, for describing the API without connecting to the node or
+// databases.
+func NewDirectoryRouter() ApiMux {
+	return NewInsightApiRouter(&insightApiContext{}, false)
+}
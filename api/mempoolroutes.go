@@ -0,0 +1,247 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	apitypes "github.com/Legenddigital/lddldata/api/types"
+	"github.com/Legenddigital/lddldata/explorer"
+	m "github.com/Legenddigital/lddldata/middleware"
+)
+
+const (
+	defaultMempoolTxnsCount = 100
+	maxMempoolTxnsCount     = 1000
+)
+
+// feeRateBinEdges are the lower edges in LDDL/kB of the fee rate histogram
+// bins.
+var feeRateBinEdges = []float64{0, 0.0001, 0.0002, 0.0005, 0.001, 0.002,
+	0.005, 0.01, 0.02, 0.05, 0.1}
+
+// mempoolTxTypes maps the transaction types of the API to those of the
+// explorer.
+var mempoolTxTypes = map[string]string{
+	"regular":    "Regular",
+	"ticket":     "Ticket",
+	"vote":       "Vote",
+	"revocation": "Revocation",
+}
+
+// MempoolSource specifies an interface for the transactions in mempool.
+type MempoolSource interface {
+	MempoolSnapshot() (explorer.MempoolShort, []explorer.MempoolTx)
+}
+
+// MempoolCtx makes the mempool overview and transaction routes unavailable if
+// the mempool source is not set.
+func (c *appContext) MempoolCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Mempool == nil {
+			http.Error(w, "mempool data is not available", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// mempoolTxn converts an explorer mempool transaction for the API.
+func mempoolTxn(tx *explorer.MempoolTx) *apitypes.MempoolTxn {
+	txn := &apitypes.MempoolTxn{
+		TxID:      tx.Hash,
+		Type:      strings.ToLower(tx.Type),
+		Size:      tx.Size,
+		TotalOut:  tx.TotalOut,
+		Fees:      tx.Fees,
+		FeeRate:   tx.FeeRate,
+		FirstSeen: tx.Time,
+	}
+	if tx.VoteInfo != nil {
+		txn.Vote = &apitypes.TxVote{
+			BlockHash:   tx.VoteInfo.Validation.Hash,
+			BlockHeight: tx.VoteInfo.Validation.Height,
+			Approve:     tx.VoteInfo.Validation.Validity,
+		}
+	}
+	return txn
+}
+
+// getMempoolOverview summarizes the transactions in mempool by type, fee rate,
+// and the blocks voted on.
+func (c *appContext) getMempoolOverview(w http.ResponseWriter, r *http.Request) {
+	short, txs := c.Mempool.MempoolSnapshot()
+
+	overview := apitypes.MempoolOverview{
+		BlockHeight:      short.LastBlockHeight,
+		BlockTime:        short.LastBlockTime,
+		NumTxns:          len(txs),
+		ByType:           make(map[string]apitypes.MempoolTypeTotals, len(mempoolTxTypes)),
+		FeeRateHistogram: make([]apitypes.FeeRateBin, len(feeRateBinEdges)),
+		Votes:            []apitypes.MempoolVotes{},
+	}
+	for apiType := range mempoolTxTypes {
+		overview.ByType[apiType] = apitypes.MempoolTypeTotals{}
+	}
+	for i, edge := range feeRateBinEdges {
+		overview.FeeRateHistogram[i].MinFeeRate = edge
+		if i+1 < len(feeRateBinEdges) {
+			max := feeRateBinEdges[i+1]
+			overview.FeeRateHistogram[i].MaxFeeRate = &max
+		}
+	}
+
+	votes := make(map[string]*apitypes.MempoolVotes)
+	for i := range txs {
+		tx := &txs[i]
+		overview.Size += tx.Size
+		overview.TotalOut += tx.TotalOut
+		overview.TotalFees += tx.Fees
+
+		apiType := strings.ToLower(tx.Type)
+		totals := overview.ByType[apiType]
+		totals.Count++
+		totals.Size += tx.Size
+		totals.TotalOut += tx.TotalOut
+		totals.Fees += tx.Fees
+		overview.ByType[apiType] = totals
+
+		bin := sort.SearchFloat64s(feeRateBinEdges, tx.FeeRate)
+		if bin == len(feeRateBinEdges) || feeRateBinEdges[bin] > tx.FeeRate {
+			bin--
+		}
+		if bin >= 0 {
+			overview.FeeRateHistogram[bin].Count++
+			overview.FeeRateHistogram[bin].Size += tx.Size
+		}
+
+		if tx.VoteInfo != nil {
+			validation := tx.VoteInfo.Validation
+			v, ok := votes[validation.Hash]
+			if !ok {
+				v = &apitypes.MempoolVotes{
+					BlockHash:   validation.Hash,
+					BlockHeight: validation.Height,
+				}
+				votes[validation.Hash] = v
+			}
+			v.NumVotes++
+			if validation.Validity {
+				v.NumApprove++
+			}
+		}
+	}
+
+	for _, v := range votes {
+		overview.Votes = append(overview.Votes, *v)
+	}
+	sort.Slice(overview.Votes, func(i, j int) bool {
+		return overview.Votes[i].BlockHeight > overview.Votes[j].BlockHeight
+	})
+
+	writeJSON(w, overview, c.getIndentQuery(r))
+}
+
+// getMempoolTxns lists the transactions in mempool. The type, min_fee_rate and
+// max_fee_rate URL query parameters filter the transactions, sort orders them
+// by time (default) or fee_rate, and count and skip page them.
+func (c *appContext) getMempoolTxns(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var txType string
+	if t := q.Get("type"); t != "" {
+		var ok bool
+		if txType, ok = mempoolTxTypes[t]; !ok {
+			http.Error(w, "type must be regular, ticket, vote or revocation", http.StatusBadRequest)
+			return
+		}
+	}
+
+	minFeeRate, maxFeeRate := 0.0, -1.0
+	for name, rate := range map[string]*float64{"min_fee_rate": &minFeeRate, "max_fee_rate": &maxFeeRate} {
+		if str := q.Get(name); str != "" {
+			f, err := strconv.ParseFloat(str, 64)
+			if err != nil || f < 0 {
+				http.Error(w, name+" must be a non-negative number", http.StatusBadRequest)
+				return
+			}
+			*rate = f
+		}
+	}
+
+	sortBy := q.Get("sort")
+	switch sortBy {
+	case "", "time", "fee_rate":
+	default:
+		http.Error(w, "sort must be time or fee_rate", http.StatusBadRequest)
+		return
+	}
+
+	N, skip := defaultMempoolTxnsCount, 0
+	for name, n := range map[string]*int{"count": &N, "skip": &skip} {
+		if str := q.Get(name); str != "" {
+			i, err := strconv.Atoi(str)
+			if err != nil || i < 0 {
+				http.Error(w, name+" must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+			*n = i
+		}
+	}
+	if N > maxMempoolTxnsCount {
+		N = maxMempoolTxnsCount
+	}
+
+	_, txs := c.Mempool.MempoolSnapshot()
+	matching := make([]*explorer.MempoolTx, 0, len(txs))
+	for i := range txs {
+		tx := &txs[i]
+		if txType != "" && tx.Type != txType {
+			continue
+		}
+		if tx.FeeRate < minFeeRate || (maxFeeRate >= 0 && tx.FeeRate > maxFeeRate) {
+			continue
+		}
+		matching = append(matching, tx)
+	}
+
+	if sortBy == "fee_rate" {
+		sort.SliceStable(matching, func(i, j int) bool {
+			return matching[i].FeeRate > matching[j].FeeRate
+		})
+	} else {
+		sort.SliceStable(matching, func(i, j int) bool {
+			return matching[i].Time > matching[j].Time
+		})
+	}
+
+	page := apitypes.MempoolTxns{
+		NumTxns:      len(matching),
+		Skip:         skip,
+		Transactions: []*apitypes.MempoolTxn{},
+	}
+	for i := skip; i < len(matching) && i < skip+N; i++ {
+		page.Transactions = append(page.Transactions, mempoolTxn(matching[i]))
+	}
+	page.Count = len(page.Transactions)
+
+	writeJSON(w, page, c.getIndentQuery(r))
+}
+
+// getMempoolTxn gets a transaction in mempool, including the time it was first
+// seen.
+func (c *appContext) getMempoolTxn(w http.ResponseWriter, r *http.Request) {
+	txid := m.GetTxIDCtx(r)
+	_, txs := c.Mempool.MempoolSnapshot()
+	for i := range txs {
+		if txs[i].Hash == txid {
+			writeJSON(w, mempoolTxn(&txs[i]), c.getIndentQuery(r))
+			return
+		}
+	}
+	http.Error(w, "transaction "+txid+" is not in mempool", http.StatusNotFound)
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	m "github.com/Legenddigital/lddldata/middleware"
+)
+
+// getBlockRangeCtx gets the block range of the idx0 and idx URL path
+// parameters, writing an error response if it is invalid.
+func getBlockRangeCtx(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	idx0 := m.GetBlockIndex0Ctx(r)
+	idx := m.GetBlockIndexCtx(r)
+	if idx0 < 0 || idx < idx0 {
+		http.Error(w, http.StatusText(422), 422)
+		return 0, 0, false
+	}
+	return int64(idx0), int64(idx), true
+}
+
+// getMissesRange lists the missed votes of each block in a range of heights.
+func (c *appContext) getMissesRange(w http.ResponseWriter, r *http.Request) {
+	idx0, idx, ok := getBlockRangeCtx(w, r)
+	if !ok {
+		return
+	}
+	if c.LiteMode {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	misses, err := c.AuxDataSource.MissesInBlockRange(idx0, idx)
+	if err != nil {
+		log.Warnf("failed to get misses of blocks %d to %d: %v", idx0, idx, err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, misses, c.getIndentQuery(r))
+}
+
+// getMissesByDay gets the votes, missed votes and expired tickets of each UTC
+// day of the blocks in a range of heights.
+func (c *appContext) getMissesByDay(w http.ResponseWriter, r *http.Request) {
+	idx0, idx, ok := getBlockRangeCtx(w, r)
+	if !ok {
+		return
+	}
+	if c.LiteMode {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	days, err := c.AuxDataSource.MissesByDay(idx0, idx)
+	if err != nil {
+		log.Warnf("failed to get daily misses of blocks %d to %d: %v", idx0, idx, err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, days, c.getIndentQuery(r))
+}
+
+// getMissesByAddress gets the votes, missed votes and expired tickets in a
+// range of heights of each stake submission address with missed votes. A
+// high miss rate indicates an unreliable voting wallet or stake pool.
+func (c *appContext) getMissesByAddress(w http.ResponseWriter, r *http.Request) {
+	idx0, idx, ok := getBlockRangeCtx(w, r)
+	if !ok {
+		return
+	}
+	if c.LiteMode {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	addresses, err := c.AuxDataSource.MissesByAddress(idx0, idx)
+	if err != nil {
+		log.Warnf("failed to get address misses of blocks %d to %d: %v", idx0, idx, err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, addresses, c.getIndentQuery(r))
+}
+
+// getTicketExpiries forecasts the expiry of the tickets in the live pool. The
+// bin URL query parameter sets the number of blocks in each bin, by default
+// about one day.
+func (c *appContext) getTicketExpiries(w http.ResponseWriter, r *http.Request) {
+	var binSize int64
+	if str := r.URL.Query().Get("bin"); str != "" {
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || n < 1 {
+			http.Error(w, "bin must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		binSize = n
+	}
+	writeJSON(w, c.BlockData.GetTicketExpiries(binSize), c.getIndentQuery(r))
+}
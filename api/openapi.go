@@ -0,0 +1,368 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/Legenddigital/lddld/lddljson"
+	"github.com/Legenddigital/lddldata/agendas"
+	"github.com/Legenddigital/lddldata/api/openapi"
+	apitypes "github.com/Legenddigital/lddldata/api/types"
+	"github.com/Legenddigital/lddldata/charts"
+	"github.com/Legenddigital/lddldata/stakecalc"
+	"github.com/Legenddigital/lddldata/stakediff"
+	appver "github.com/Legenddigital/lddldata/version"
+	"github.com/Legenddigital/lddldata/watcher"
+)
+
+// Schemas of path and query parameters.
+var (
+	minOne           = 1.0
+	blockIndexSchema = &openapi.Schema{Type: "integer", Format: "int64", Minimum: new(float64)}
+	hashSchema       = &openapi.Schema{Type: "string", Pattern: "^[0-9a-f]{64}$"}
+	flagSchema       = &openapi.Schema{Type: "boolean"}
+	amountSchema     = &openapi.Schema{Type: "number", Format: "double", Minimum: new(float64)}
+)
+
+// pathParams documents the path parameters of the lddldata API routes.
+var pathParams = map[string]openapi.Parameter{
+	"idx": {
+		Description: "Block index (height).",
+		Schema:      blockIndexSchema,
+	},
+	"idx0": {
+		Description: "Block index (height) of the start of the range.",
+		Schema:      blockIndexSchema,
+	},
+	"step": {
+		Description: "Number of blocks between the blocks of the range.",
+		Schema:      &openapi.Schema{Type: "integer", Format: "int64", Minimum: &minOne},
+	},
+	"blockhash": {
+		Description: "Block hash.",
+		Schema:      hashSchema,
+	},
+	"idxorhash": {
+		Description: "Block index (height) or block hash.",
+		Schema:      &openapi.Schema{Type: "string", Pattern: "^([0-9]+|[0-9a-f]{64})$"},
+	},
+	"txid": {
+		Description: "Transaction hash.",
+		Schema:      hashSchema,
+	},
+	"txinoutindex": {
+		Description: "Index of the transaction input or output.",
+		Schema:      &openapi.Schema{Type: "integer", Format: "int32", Minimum: new(float64)},
+	},
+	"address": {
+		Description: "Address.",
+		Schema:      &openapi.Schema{Type: "string"},
+	},
+	"agendaid": {
+		Description: "Agenda ID.",
+		Schema:      &openapi.Schema{Type: "string"},
+	},
+	"chartname": {
+		Description: "Chart name.",
+		Schema:      &openapi.Schema{Type: "string", Enum: charts.Names()},
+	},
+	"N": {
+		Description: "Number of results.",
+		Schema:      &openapi.Schema{Type: "integer", Format: "int32", Minimum: new(float64)},
+	},
+	"M": {
+		Description: "Number of results to skip.",
+		Schema:      &openapi.Schema{Type: "integer", Format: "int32", Minimum: new(float64)},
+	},
+}
+
+// streamQuery are the subscription parameters of the stream routes.
+var streamQuery = []openapi.Parameter{
+	{Name: "topics", Description: "Comma separated topics: block, reorg, mempool, tx and address. " +
+		"The default is block, reorg and mempool.", Schema: &openapi.Schema{Type: "string"}},
+	{Name: "addresses", Description: "Comma separated addresses for the address topic.",
+		Schema: &openapi.Schema{Type: "string"}},
+	{Name: "since", Description: "Replay the blocks after this height. SSE clients may use the " +
+		"Last-Event-ID header instead.", Schema: blockIndexSchema},
+}
+
+// indentQuery is the query parameter for indented JSON responses.
+var indentQuery = openapi.Parameter{
+	Name:        "indent",
+	Description: "Indent the JSON response.",
+	Schema:      flagSchema,
+}
+
+// apiRoutes documents the routes of the router created by NewAPIRouter. Routes
+// added to the router without documentation here are still listed in the
+// OpenAPI document, with an undocumented response.
+var apiRoutes = func() map[string]openapi.Route {
+	text := &openapi.Schema{Type: "string"}
+	routes := map[string]openapi.Route{
+		"GET /":                  {Summary: "API liveness message.", Response: text, ContentType: openapi.ContentText},
+		"GET /status":            {Summary: "Status of lddldata and the node.", Response: apitypes.Status{}},
+		"GET /supply":            {Summary: "Current coin supply.", Response: apitypes.CoinSupply{}},
+		"GET /list":              {Summary: "List of the API route patterns.", Response: []string{}},
+		"GET /directory":         {Summary: "Plain text list of the API operations.", Response: text, ContentType: openapi.ContentText},
+		"GET /openapi.json":      {Summary: "This OpenAPI document.", Response: &openapi.Schema{Type: "object"}},
+		"GET /block/best/height": {Summary: "Best block height.", Response: text, ContentType: openapi.ContentText},
+		"GET /block/range/{idx0}/{idx}": {Summary: "Block summaries for a range of blocks.",
+			Response: []apitypes.BlockDataBasic{}},
+		"GET /block/range/{idx0}/{idx}/size": {Summary: "Block sizes for a range of blocks.",
+			Response: []int32{}},
+		"GET /block/range/{idx0}/{idx}/{step}": {Summary: "Block summaries for every step-th block of a range.",
+			Response: []apitypes.BlockDataBasic{}},
+		"GET /block/range/{idx0}/{idx}/{step}/size": {Summary: "Block sizes for every step-th block of a range.",
+			Response: []int32{}},
+		"GET /block/range/{idx0}/{idx}/fees": {Summary: "Block fee rate statistics for a range of blocks.",
+			Response: []apitypes.BlockFeeStats{}},
+		"GET /block/range/{idx0}/{idx}/{step}/fees": {Summary: "Block fee rate statistics for every step-th block of a range.",
+			Response: []apitypes.BlockFeeStats{}},
+
+		"GET /stake/vote/info": {Summary: "Vote agenda information.",
+			Response: lddljson.GetVoteInfoResult{},
+			Query: []openapi.Parameter{{Name: "version", Description: "Stake version. The default is the latest.",
+				Schema: &openapi.Schema{Type: "integer", Format: "int32"}}}},
+		"GET /stake/pool":         {Summary: "Ticket pool information at the best block.", Response: apitypes.TicketPoolInfo{}},
+		"GET /stake/pool/b/{idx}": {Summary: "Ticket pool information at a block.", Response: apitypes.TicketPoolInfo{}},
+		"GET /stake/pool/r/{idx0}/{idx}": {Summary: "Ticket pool information for a range of blocks.",
+			Response: openapi.OneOf([]apitypes.TicketPoolInfo{}, apitypes.TicketPoolValsAndSizes{}),
+			Query: []openapi.Parameter{{Name: "arrays",
+				Description: "Return arrays of the pool values and sizes.", Schema: flagSchema}}},
+		"GET /stake/pool/r/{idx0}/{idx}/valsize": {Summary: "Ticket pool values and sizes for a range of blocks.",
+			Response: apitypes.TicketPoolValsAndSizes{}},
+		"GET /agendas": {Summary: "Consensus agendas with their voting status and current interval tally.",
+			Response: []agendas.Summary{}},
+		"GET /agenda/{agendaid}": {Summary: "Consensus agenda with its vote tally for each rule change interval.",
+			Response: agendas.Agenda{},
+			Query: []openapi.Parameter{{Name: "blocks",
+				Description: "Include the vote tally of each block.", Schema: flagSchema}}},
+		"GET /chart/{chartname}": {Summary: "Historical chart data. Amounts are in coins.",
+			Response: charts.Chart{},
+			Query: []openapi.Parameter{{Name: "bin",
+				Description: "Aggregate the data by block, day, week or month. The default is block.",
+				Schema:      &openapi.Schema{Type: "string", Enum: []string{charts.BinBlock, charts.BinDay, charts.BinWeek, charts.BinMonth}}}}},
+		"GET /supply/schedule": {Summary: "Projected coin supply of each subsidy reduction interval, by subsidy type. Amounts are in atoms.",
+			Response: apitypes.SupplySchedule{}},
+		"GET /supply/history": {Summary: "Mined coin supply by subsidy type, coins locked in tickets, and the dev fund balance. Amounts are in coins.",
+			Response: apitypes.SupplyHistory{},
+			Query: []openapi.Parameter{{Name: "bin",
+				Description: "Aggregate the data by day, week or month. The default is month.",
+				Schema:      &openapi.Schema{Type: "string", Enum: []string{charts.BinDay, charts.BinWeek, charts.BinMonth}}}}},
+		"GET /stake/diff":           {Summary: "Current and estimated stake difficulty.", Response: apitypes.StakeDiff{}},
+		"GET /stake/diff/current":   {Summary: "Current stake difficulty.", Response: lddljson.GetStakeDifficultyResult{}},
+		"GET /stake/diff/estimates": {Summary: "Stake difficulty estimates.", Response: lddljson.EstimateStakeDiffResult{}},
+		"GET /stake/diff/b/{idx}":   {Summary: "Stake difficulty at a block.", Response: []float64{}},
+		"GET /stake/diff/projection": {Summary: "Projected ticket price of the next windows for ticket purchase rate scenarios, with 95% confidence bands.",
+			Response: stakediff.Projection{},
+			Query: []openapi.Parameter{
+				{Name: "windows", Description: "Number of windows, at most 16. The default is 4.",
+					Schema: &openapi.Schema{Type: "integer", Format: "int32", Minimum: &minOne}},
+				{Name: "rate", Description: "Comma separated ticket purchase rates in tickets per block. " +
+					"The default is no purchases, the recent rate and the maximum rate.",
+					Schema: &openapi.Schema{Type: "string"}},
+			}},
+		"GET /stake/diff/r/{idx0}/{idx}": {Summary: "Stake difficulty for a range of blocks.",
+			Response: []float64{}},
+		"GET /stake/calculator": {Summary: "Simulated ticket purchases and votes starting at the best block, and the annual stake return. Amounts are in coins.",
+			Response: stakecalc.Calculation{},
+			Query: []openapi.Parameter{
+				{Name: "balance", Description: "Starting balance. The default is 1000.",
+					Schema: amountSchema},
+				{Name: "price", Description: "Ticket price. The default is the current price.",
+					Schema: amountSchema},
+				{Name: "price_change", Description: "Change of the ticket price in percent per year.",
+					Schema: &openapi.Schema{Type: "number", Format: "double"}},
+				{Name: "pool_size", Description: "Size of the live ticket pool. The default is the current size.",
+					Schema: &openapi.Schema{Type: "integer", Format: "int64", Minimum: &minOne}},
+				{Name: "vsp_fee", Description: "VSP fee in percent of the vote reward.",
+					Schema: amountSchema},
+				{Name: "compound", Description: "Reinvest the rewards.", Schema: flagSchema},
+				{Name: "integer", Description: "Buy whole tickets only.", Schema: flagSchema},
+				{Name: "days", Description: "Duration of the simulation, at most 3650 days. The default is 365.",
+					Schema: &openapi.Schema{Type: "integer", Format: "int64", Minimum: &minOne}},
+			}},
+		"GET /stake/misses/r/{idx0}/{idx}": {Summary: "Missed votes of each block in a range of blocks.",
+			Response: apitypes.MissesRange{}},
+		"GET /stake/misses/days/r/{idx0}/{idx}": {Summary: "Votes, missed votes and expired tickets by UTC day for a range of blocks.",
+			Response: []apitypes.DayMisses{}},
+		"GET /stake/misses/addresses/r/{idx0}/{idx}": {Summary: "Votes, missed votes and expired tickets by stake submission address for a range of blocks, most misses first.",
+			Response: []apitypes.AddressMisses{}},
+		"GET /stake/misses/expiries": {Summary: "Forecast of the expiry of the tickets in the live pool.",
+			Response: apitypes.TicketExpiries{},
+			Query: []openapi.Parameter{{Name: "bin", Description: "Number of blocks in each bin. The default is about one day.",
+				Schema: &openapi.Schema{Type: "integer", Format: "int64", Minimum: &minOne}}}},
+
+		"GET /tx/{txid}":                    {Summary: "Transaction.", Response: apitypes.Tx{}},
+		"GET /tx/{txid}/trimmed":            {Summary: "Decoded transaction without scripts.", Response: apitypes.TrimmedTx{}},
+		"GET /tx/{txid}/out":                {Summary: "Transaction outputs.", Response: []apitypes.TxOut{}},
+		"GET /tx/{txid}/out/{txinoutindex}": {Summary: "Transaction output.", Response: apitypes.TxOut{}},
+		"GET /tx/{txid}/in":                 {Summary: "Transaction inputs.", Response: []apitypes.TxIn{}},
+		"GET /tx/{txid}/in/{txinoutindex}":  {Summary: "Transaction input.", Response: apitypes.TxIn{}},
+		"GET /tx/{txid}/vinfo":              {Summary: "Vote information of a vote transaction.", Response: apitypes.VoteInfo{}},
+		"GET /tx/hex/{txid}": {Summary: "Serialized transaction.", Response: text,
+			ContentType: openapi.ContentText},
+		"GET /tx/decoded/{txid}": {Summary: "Decoded transaction without scripts.", Response: apitypes.TrimmedTx{}},
+		"POST /txs": {Summary: "Multiple transactions.", Request: apitypes.Txns{},
+			Response: []apitypes.Tx{}},
+		"POST /txs/trimmed": {Summary: "Multiple decoded transactions without scripts.", Request: apitypes.Txns{},
+			Response: []apitypes.TrimmedTx{}},
+
+		"GET /address/{address}/totals": {Summary: "Address transaction totals.", Response: apitypes.AddressTotals{}},
+		"GET /address/{address}/utxos": {Summary: "Unspent outputs of the address.", Response: []apitypes.AddressUTXO{},
+			Query: []openapi.Parameter{
+				{Name: "minconf", Description: "Minimum confirmations. The default is 1.",
+					Schema: &openapi.Schema{Type: "integer", Format: "int64", Minimum: new(float64)}},
+				{Name: "min_amount", Description: "Minimum amount in LDDL.", Schema: amountSchema},
+				{Name: "max_amount", Description: "Maximum amount in LDDL.", Schema: amountSchema},
+				{Name: "tree", Description: "Transaction tree. The default is both.",
+					Schema: &openapi.Schema{Type: "string", Enum: []string{"regular", "stake"}}},
+			}},
+		"POST /addresses/txs": {Summary: "Merged transaction history of up to 1000 addresses, most recent first.",
+			Request: apitypes.AddressesPost{}, Response: apitypes.AddressesTxns{}},
+		"POST /addresses/totals": {Summary: "Combined and per-address totals of up to 1000 addresses.",
+			Request: apitypes.AddressesPost{}, Response: apitypes.AddressesTotals{}},
+		"GET /address/{address}/balance": {Summary: "Address balance at a block height.", Response: apitypes.AddressBalance{},
+			Query: []openapi.Parameter{{Name: "height", Description: "Block height. The default is the best block.",
+				Schema: blockIndexSchema}}},
+		"GET /address/{address}/tickets": {Summary: "Tickets with the address as their stake submission address, and the return on investment.",
+			Response: apitypes.AddressTickets{}},
+		"GET /ticket/{txid}": {Summary: "Ticket purchase, pool status, vote or revocation, and vote reward.",
+			Response: apitypes.TicketInfo{}},
+
+		"GET /mempool": {Summary: "Mempool totals by transaction type and fee rate, and votes by block.",
+			Response: apitypes.MempoolOverview{}},
+		"GET /mempool/txs": {Summary: "Transactions in mempool.", Response: apitypes.MempoolTxns{},
+			Query: []openapi.Parameter{
+				{Name: "type", Description: "Transaction type.", Schema: &openapi.Schema{Type: "string",
+					Enum: []string{"regular", "ticket", "vote", "revocation"}}},
+				{Name: "min_fee_rate", Description: "Minimum fee rate in LDDL/kB.", Schema: amountSchema},
+				{Name: "max_fee_rate", Description: "Maximum fee rate in LDDL/kB.", Schema: amountSchema},
+				{Name: "sort", Description: "Order, most recent or highest fee rate first. The default is time.",
+					Schema: &openapi.Schema{Type: "string", Enum: []string{"time", "fee_rate"}}},
+				{Name: "count", Description: "Number of transactions. The default is 100.",
+					Schema: &openapi.Schema{Type: "integer", Format: "int32", Minimum: new(float64)}},
+				{Name: "skip", Description: "Number of transactions to skip.",
+					Schema: &openapi.Schema{Type: "integer", Format: "int32", Minimum: new(float64)}},
+			}},
+		"GET /mempool/tx/{txid}": {Summary: "Transaction in mempool, with the time it was first seen.",
+			Response: apitypes.MempoolTxn{}},
+		"GET /mempool/sstx":          {Summary: "Ticket fees in mempool.", Response: apitypes.MempoolTicketFeeInfo{}},
+		"GET /mempool/sstx/fees":     {Summary: "Ticket fee rates in mempool.", Response: apitypes.MempoolTicketFees{}},
+		"GET /mempool/sstx/fees/{N}": {Summary: "Highest N ticket fee rates in mempool.", Response: apitypes.MempoolTicketFees{}},
+		"GET /mempool/sstx/details":  {Summary: "Tickets in mempool.", Response: apitypes.MempoolTicketDetails{}},
+		"GET /mempool/sstx/details/{N}": {Summary: "N tickets with the highest fees in mempool.",
+			Response: apitypes.MempoolTicketDetails{}},
+
+		"GET /watch":  {Summary: "Watched addresses.", Response: []watcher.Watch{}},
+		"POST /watch": {Summary: "Watch an address.", Request: watchRequest{}, Response: watcher.Watch{}},
+		"GET /watch/deliveries": {Summary: "Most recent webhook deliveries.", Response: []watcher.Delivery{},
+			Query: []openapi.Parameter{
+				{Name: "status", Description: "Delivery status.", Schema: &openapi.Schema{Type: "string",
+					Enum: []string{watcher.DeliveryPending, watcher.DeliveryDelivered, watcher.DeliveryFailed}}},
+				{Name: "n", Description: "Number of deliveries.", Schema: &openapi.Schema{Type: "integer",
+					Format: "int32", Minimum: &minOne}},
+			}},
+		"GET /watch/{address}":    {Summary: "Watched address.", Response: watcher.Watch{}},
+		"PUT /watch/{address}":    {Summary: "Watch an address.", Request: watchRequest{}, Response: watcher.Watch{}},
+		"DELETE /watch/{address}": {Summary: "Stop watching an address."},
+
+		"GET /stream": {Summary: "Server-Sent Events stream of new blocks, reorgs, mempool and address activity.",
+			Response: apitypes.StreamEvent{}, ContentType: "text/event-stream", Query: streamQuery},
+		"GET /stream/ws": {Summary: "Websocket stream of new blocks, reorgs, mempool and address activity.",
+			Response: apitypes.StreamEvent{}, Query: streamQuery},
+	}
+
+	// The block routes are the same for the best block, a block hash and a
+	// block index.
+	for _, block := range []string{"/block/best", "/block/hash/{blockhash}", "/block/{idx}"} {
+		routes["GET "+block] = openapi.Route{Summary: "Block summary.", Response: apitypes.BlockDataBasic{}}
+		routes["GET "+block+"/header"] = openapi.Route{Summary: "Block header.",
+			Response: lddljson.GetBlockHeaderVerboseResult{}}
+		routes["GET "+block+"/size"] = openapi.Route{Summary: "Block size in bytes.", Response: int32(0)}
+		routes["GET "+block+"/verbose"] = openapi.Route{Summary: "Verbose block.",
+			Response: lddljson.GetBlockVerboseResult{}}
+		routes["GET "+block+"/pos"] = openapi.Route{Summary: "Block stake information.",
+			Response: apitypes.StakeInfoExtended{}}
+		routes["GET "+block+"/fees"] = openapi.Route{Summary: "Block fee rate statistics by transaction tree.",
+			Response: apitypes.BlockFeeStats{}}
+		routes["GET "+block+"/tx"] = openapi.Route{Summary: "Block transaction IDs.",
+			Response: apitypes.BlockTransactions{}}
+		routes["GET "+block+"/tx/count"] = openapi.Route{Summary: "Block transaction counts.",
+			Response: apitypes.BlockTransactionCounts{}}
+	}
+	routes["GET /block/best/hash"] = openapi.Route{Summary: "Best block hash.", Response: text,
+		ContentType: openapi.ContentText}
+	routes["GET /block/{idx}/hash"] = openapi.Route{Summary: "Block hash.", Response: text,
+		ContentType: openapi.ContentText}
+	routes["GET /block/hash/{blockhash}/height"] = openapi.Route{Summary: "Block height.", Response: text,
+		ContentType: openapi.ContentText}
+
+	// The ticket pool may be sorted.
+	sortQuery := []openapi.Parameter{{Name: "sort", Description: "Sort the ticket hashes.", Schema: flagSchema}}
+	routes["GET /stake/pool/full"] = openapi.Route{Summary: "Ticket hashes in the pool at the best block.",
+		Response: []string{}, Query: sortQuery}
+	routes["GET /stake/pool/b/{idxorhash}/full"] = openapi.Route{Summary: "Ticket hashes in the pool at a block.",
+		Response: []string{}, Query: sortQuery}
+
+	// Address transactions, optionally paginated.
+	for _, page := range []string{"", "/count/{N}", "/count/{N}/skip/{M}"} {
+		routes["GET /address/{address}"+page] = openapi.Route{Summary: "Address transactions.",
+			Response: apitypes.Address{}}
+		routes["GET /address/{address}"+page+"/raw"] = openapi.Route{Summary: "Raw address transactions.",
+			Response: []apitypes.AddressTxRaw{}}
+	}
+
+	return routes
+}()
+
+// OpenAPI describes the routes of the API router, mounted at prefix.
+func (mux apiMux) OpenAPI(prefix string) openapi.API {
+	return openapi.API{
+		Prefix:      prefix,
+		Tag:         "lddldata",
+		Description: "lddldata API",
+		Router:      mux.Mux,
+		Routes:      apiRoutes,
+		PathParams:  pathParams,
+		Query:       []openapi.Parameter{indentQuery},
+	}
+}
+
+// NewOpenAPIDocument creates the OpenAPI document for the APIs.
+func NewOpenAPIDocument(apis ...openapi.API) *openapi.Document {
+	return openapi.Generate(openapi.Info{
+		Title:       appver.AppName,
+		Description: "Legenddigital block explorer and data APIs.",
+		Version:     appver.Ver.String(),
+	}, apis...)
+}
+
+// NewDirectoryRouter creates an API router that is not backed by data sources,
+// for describing the API without connecting to the node or databases.
+func NewDirectoryRouter() apiMux {
+	return NewAPIRouter(&appContext{}, false)
+}
+
+// getOpenAPI writes the OpenAPI document describing the APIs.
+func (c *appContext) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if c.OpenAPI == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, c.OpenAPI, c.getIndentQuery(r))
+}
+
+// getDirectory writes a plain text list of the API operations.
+func (c *appContext) getDirectory(w http.ResponseWriter, r *http.Request) {
+	if c.OpenAPI == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := c.OpenAPI.WriteDirectory(w); err != nil {
+		apiLog.Errorf("Failed to write API directory: %v", err)
+	}
+}
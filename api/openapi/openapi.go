@@ -0,0 +1,350 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+// Package openapi generates OpenAPI 3 documents describing the routes of chi
+// routers, with response schemas derived from Go types by reflection.
+package openapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-chi/chi"
+)
+
+// Version is the version of the OpenAPI specification the documents conform
+// to.
+const Version = "3.0.0"
+
+// Content types of request and response bodies.
+const (
+	ContentJSON = "application/json"
+	ContentText = "text/plain"
+)
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Tag groups the operations of an API.
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem is the set of operations of a path, keyed by lower case HTTP
+// method.
+type PathItem map[string]*Operation
+
+// Operation describes a method of a path.
+type Operation struct {
+	Tags        []string            `json:"tags,omitempty"`
+	Summary     string              `json:"summary,omitempty"`
+	OperationID string              `json:"operationId"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter describes a path or query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a request or response body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas referenced by the operations.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Route documents a route of a router.
+type Route struct {
+	Summary string
+	// OperationID overrides the operation ID generated from the method and
+	// path.
+	OperationID string
+	// Response is a value of the type written by the handler, or a *Schema.
+	// If nil, the response has no content.
+	Response interface{}
+	// ContentType is the response content type. The default is ContentJSON.
+	ContentType string
+	// Request is a value of the type of the JSON request body, or a *Schema.
+	Request interface{}
+	// Query are the query parameters of the route.
+	Query []Parameter
+}
+
+// API describes a router and the routes it serves.
+type API struct {
+	// Prefix is the path at which the router is mounted, e.g. "/api".
+	Prefix      string
+	Tag         string
+	Description string
+	Router      chi.Routes
+	// Routes documents the routes, keyed by the method and pattern, e.g.
+	// "GET /block/{idx}/size".
+	Routes map[string]Route
+	// PathParams documents the path parameters by name. Undocumented path
+	// parameters are strings.
+	PathParams map[string]Parameter
+	// Query are the query parameters accepted by all routes with JSON
+	// responses.
+	Query []Parameter
+}
+
+// RouteKey is the key of a route in API.Routes.
+func RouteKey(method, pattern string) string {
+	return method + " " + pattern
+}
+
+// Generate creates the document describing the APIs.
+func Generate(info Info, apis ...API) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+		},
+	}
+	schemas := newSchemaGenerator(doc.Components.Schemas)
+	for _, api := range apis {
+		if api.Tag != "" {
+			doc.Tags = append(doc.Tags, Tag{Name: api.Tag, Description: api.Description})
+		}
+		for _, ep := range Endpoints(api.Router) {
+			path := api.Prefix + ep.Pattern
+			op := api.operation(ep, path, schemas)
+			item := doc.Paths[path]
+			if item == nil {
+				item = make(PathItem)
+				doc.Paths[path] = item
+			}
+			item[strings.ToLower(ep.Method)] = op
+		}
+	}
+	return doc
+}
+
+// operation creates the Operation for an endpoint of the API.
+func (api *API) operation(ep Endpoint, path string, schemas *schemaGenerator) *Operation {
+	route, documented := api.Routes[RouteKey(ep.Method, ep.Pattern)]
+	op := &Operation{
+		Summary:     route.Summary,
+		OperationID: route.OperationID,
+		Responses:   make(map[string]Response),
+	}
+	if api.Tag != "" {
+		op.Tags = []string{api.Tag}
+	}
+	if op.OperationID == "" {
+		op.OperationID = operationID(ep.Method, path)
+	}
+
+	for _, name := range pathParamNames(ep.Pattern) {
+		param, ok := api.PathParams[name]
+		if !ok {
+			param.Schema = &Schema{Type: "string"}
+		}
+		param.Name, param.In, param.Required = name, "path", true
+		op.Parameters = append(op.Parameters, param)
+	}
+	for _, param := range route.Query {
+		param.In = "query"
+		op.Parameters = append(op.Parameters, param)
+	}
+
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				ContentJSON: {Schema: schemas.schemaOf(route.Request)},
+			},
+		}
+	}
+
+	switch {
+	case !documented:
+		op.Responses["200"] = Response{Description: "Undocumented response."}
+	case route.Response == nil:
+		op.Responses["204"] = Response{Description: "No content."}
+	default:
+		contentType := route.ContentType
+		if contentType == "" {
+			contentType = ContentJSON
+		}
+		if contentType == ContentJSON {
+			for _, param := range api.Query {
+				param.In = "query"
+				op.Parameters = append(op.Parameters, param)
+			}
+		}
+		op.Responses["200"] = Response{
+			Description: "Success.",
+			Content: map[string]MediaType{
+				contentType: {Schema: schemas.schemaOf(route.Response)},
+			},
+		}
+	}
+	return op
+}
+
+// Endpoint is a method and path pattern served by a router.
+type Endpoint struct {
+	Method  string
+	Pattern string
+}
+
+// Endpoints lists the endpoints of a router, sorted by pattern and method.
+// Patterns of subrouters are joined, and regular expressions are removed
+// from path parameters. Routes registered for all methods, e.g. with
+// HandleFunc, are listed as GET only.
+func Endpoints(router chi.Routes) []Endpoint {
+	var endpoints []Endpoint
+	seen := make(map[Endpoint]bool)
+	var walk func(routes chi.Routes, prefix string)
+	walk = func(routes chi.Routes, prefix string) {
+		for _, rt := range routes.Routes() {
+			pattern := prefix + strings.Replace(rt.Pattern, "/*", "", -1)
+			if rt.SubRoutes != nil {
+				walk(rt.SubRoutes, pattern)
+				continue
+			}
+			pattern = cleanPattern(pattern)
+			var methods []string
+			if _, ok := rt.Handlers["*"]; ok {
+				methods = []string{http.MethodGet}
+			} else {
+				for method := range rt.Handlers {
+					methods = append(methods, method)
+				}
+			}
+			for _, method := range methods {
+				ep := Endpoint{Method: method, Pattern: pattern}
+				if !seen[ep] {
+					seen[ep] = true
+					endpoints = append(endpoints, ep)
+				}
+			}
+		}
+	}
+	walk(router, "")
+
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Pattern != endpoints[j].Pattern {
+			return endpoints[i].Pattern < endpoints[j].Pattern
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+	return endpoints
+}
+
+var (
+	paramRegexp    = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+	nonAlnumRegexp = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// cleanPattern removes duplicate and trailing slashes and the regular
+// expressions of path parameters.
+func cleanPattern(pattern string) string {
+	for strings.Contains(pattern, "//") {
+		pattern = strings.Replace(pattern, "//", "/", -1)
+	}
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if pattern == "" {
+		pattern = "/"
+	}
+	return paramRegexp.ReplaceAllString(pattern, "{$1}")
+}
+
+// pathParamNames lists the names of the path parameters in a pattern.
+func pathParamNames(pattern string) []string {
+	var names []string
+	for _, match := range paramRegexp.FindAllStringSubmatch(pattern, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// operationID creates an operation ID from the method and path, e.g.
+// getApiBlockByIdxSize for GET /api/block/{idx}/size.
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.Split(path, "/") {
+		by := strings.HasPrefix(part, "{")
+		for _, word := range nonAlnumRegexp.Split(part, -1) {
+			if word == "" {
+				continue
+			}
+			if by {
+				id += "By"
+				by = false
+			}
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return id
+}
+
+// WriteDirectory writes a plain text table of the operations of the document.
+func (doc *Document) WriteDirectory(w io.Writer) error {
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, path := range paths {
+		item := doc.Paths[path]
+		methods := make([]string, 0, len(item))
+		for method := range item {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.ToUpper(method), path,
+				item[method].Summary)
+		}
+	}
+	return tw.Flush()
+}
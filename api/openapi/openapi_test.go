@@ -0,0 +1,141 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+type testBlock struct {
+	Height int64       `json:"height"`
+	Hash   string      `json:"hash"`
+	Time   time.Time   `json:"time"`
+	Fees   []float64   `json:"fees,omitempty"`
+	Prev   *testBlock  `json:"prev,omitempty"`
+	Extra  interface{} `json:"-"`
+	hidden int
+	testEmbedded
+}
+
+type testEmbedded struct {
+	Votes uint16 `json:"votes"`
+}
+
+func testRouter() chi.Router {
+	nop := func(w http.ResponseWriter, r *http.Request) {}
+	mux := chi.NewRouter()
+	mux.Get("/", nop)
+	mux.Route("/block", func(r chi.Router) {
+		r.Route("/{idx:[0-9]+}", func(rd chi.Router) {
+			rd.Get("/", nop)
+			rd.Get("/size", nop)
+		})
+		r.Route("/", func(rd chi.Router) {
+			rd.Delete("/{blockhash}", nop)
+		})
+	})
+	mux.HandleFunc("/list", nop)
+	return mux
+}
+
+func TestEndpoints(t *testing.T) {
+	got := Endpoints(testRouter())
+	want := []Endpoint{
+		{"GET", "/"},
+		{"DELETE", "/block/{blockhash}"},
+		{"GET", "/block/{idx}"},
+		{"GET", "/block/{idx}/size"},
+		{"GET", "/list"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Endpoints: got %v, want %v", got, want)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	indent := Parameter{Name: "indent", Schema: &Schema{Type: "boolean"}}
+	api := API{
+		Prefix: "/api",
+		Tag:    "test",
+		Router: testRouter(),
+		Routes: map[string]Route{
+			"GET /":                     {Response: "", ContentType: ContentText},
+			"GET /block/{idx}":          {Summary: "Block", Response: testBlock{}},
+			"GET /block/{idx}/size":     {Response: OneOf(int32(0), testEmbedded{})},
+			"DELETE /block/{blockhash}": {},
+		},
+		PathParams: map[string]Parameter{
+			"idx": {Description: "block index", Schema: &Schema{Type: "integer"}},
+		},
+		Query: []Parameter{indent},
+	}
+	info := Info{Title: "test", Version: "1.0.0"}
+	doc := Generate(info, api)
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	op := doc.Paths["/api/block/{idx}"]["get"]
+	if op == nil {
+		t.Fatalf("no GET /api/block/{idx} operation")
+	}
+	if op.OperationID != "getApiBlockByIdx" {
+		t.Errorf("wrong operation ID %s", op.OperationID)
+	}
+	if len(op.Parameters) != 2 || op.Parameters[0].Name != "idx" ||
+		op.Parameters[0].In != "path" || !op.Parameters[0].Required ||
+		op.Parameters[0].Schema.Type != "integer" || op.Parameters[1].In != "query" {
+		t.Errorf("wrong parameters %+v", op.Parameters)
+	}
+	ref := op.Responses["200"].Content[ContentJSON].Schema.Ref
+	if ref != "#/components/schemas/testBlock" {
+		t.Errorf("wrong response schema reference %s", ref)
+	}
+
+	block := doc.Components.Schemas["testBlock"]
+	if block == nil {
+		t.Fatalf("testBlock schema missing")
+	}
+	var props []string
+	for name := range block.Properties {
+		props = append(props, name)
+	}
+	if len(props) != 6 {
+		t.Errorf("wrong properties %v", props)
+	}
+	if block.Properties["prev"].Ref != ref || block.Properties["votes"].Type != "integer" ||
+		block.Properties["time"].Format != "date-time" ||
+		block.Properties["fees"].Items.Type != "number" {
+		t.Errorf("wrong property schemas")
+	}
+	if strings.Join(block.Required, ",") != "height,hash,time,votes" {
+		t.Errorf("wrong required properties %v", block.Required)
+	}
+
+	// Text responses do not take the JSON query parameters.
+	if op := doc.Paths["/api/"]["get"]; op == nil || len(op.Parameters) != 0 ||
+		op.Responses["200"].Content[ContentText].Schema.Type != "string" {
+		t.Errorf("wrong GET /api/ operation")
+	}
+	if _, ok := doc.Paths["/api/block/{blockhash}"]["delete"].Responses["204"]; !ok {
+		t.Errorf("expected no content response")
+	}
+	if _, ok := doc.Paths["/api/list"]["get"].Responses["200"]; !ok {
+		t.Errorf("expected undocumented response")
+	}
+
+	// Route documentation can be reused for another document.
+	for _, d := range []*Document{doc, Generate(info, api)} {
+		size := d.Paths["/api/block/{idx}/size"]["get"].Responses["200"].Content[ContentJSON].Schema
+		if len(size.OneOf) != 2 || size.OneOf[1].Ref != "#/components/schemas/testEmbedded" ||
+			d.Components.Schemas["testEmbedded"] == nil {
+			t.Errorf("wrong oneOf schema %+v", size)
+		}
+	}
+}
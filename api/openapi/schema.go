@@ -0,0 +1,254 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI schema object.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+
+	// typ is a Go type to be described by the schema generator.
+	typ reflect.Type
+}
+
+// OneOf returns a schema matching any of the schemas of the values.
+func OneOf(vs ...interface{}) *Schema {
+	s := &Schema{}
+	for _, v := range vs {
+		s.OneOf = append(s.OneOf, toSchema(v))
+	}
+	return s
+}
+
+// toSchema returns v if it is a *Schema, or a wrapper of v's type that is
+// resolved by the generator.
+func toSchema(v interface{}) *Schema {
+	if s, ok := v.(*Schema); ok {
+		return s
+	}
+	return &Schema{typ: reflect.TypeOf(v)}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaGenerator creates schemas for Go types, adding named struct types to
+// the component schemas and referencing them.
+type schemaGenerator struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+// newSchemaGenerator creates a schemaGenerator adding to components.
+func newSchemaGenerator(components map[string]*Schema) *schemaGenerator {
+	return &schemaGenerator{
+		components: components,
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// schemaOf returns the schema of v's type, or v if it is a *Schema.
+func (g *schemaGenerator) schemaOf(v interface{}) *Schema {
+	return g.resolve(toSchema(v))
+}
+
+// resolve returns a copy of s with the Go types wrapped by toSchema replaced
+// by their schemas. The route documentation may be shared by several
+// documents, so s is not modified.
+func (g *schemaGenerator) resolve(s *Schema) *Schema {
+	if s == nil {
+		return nil
+	}
+	if s.typ != nil {
+		return g.schema(s.typ)
+	}
+	r := *s
+	if s.OneOf != nil {
+		r.OneOf = make([]*Schema, len(s.OneOf))
+		for i := range s.OneOf {
+			r.OneOf[i] = g.resolve(s.OneOf[i])
+		}
+	}
+	r.Items = g.resolve(s.Items)
+	r.AdditionalProperties = g.resolve(s.AdditionalProperties)
+	if s.Properties != nil {
+		r.Properties = make(map[string]*Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			r.Properties[name] = g.resolve(prop)
+		}
+	}
+	return &r
+}
+
+// schema returns the schema of the JSON encoding of t.
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Implements(jsonMarshalerType), reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	case t.Implements(textMarshalerType), reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32", Minimum: new(float64)}
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Format: "int64", Minimum: new(float64)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+	// Interfaces and types without a JSON encoding may be anything.
+	return &Schema{}
+}
+
+// structSchema returns the schema of a struct type. Named types are added to
+// the component schemas and referenced.
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return g.objectSchema(t)
+	}
+	name, ok := g.names[t]
+	if !ok {
+		name = g.componentName(t)
+		g.names[t] = name
+		// Register the name before describing the fields, in case the type
+		// is recursive.
+		g.components[name] = &Schema{}
+		*g.components[name] = *g.objectSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// componentName returns a unique component schema name for t, qualifying the
+// type name with its package name if another type has the same name.
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := g.components[name]; !taken {
+		return name
+	}
+	pkg := path.Base(t.PkgPath())
+	qualified := strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	name = qualified
+	for i := 2; ; i++ {
+		if _, taken := g.components[name]; !taken {
+			return name
+		}
+		name = fmt.Sprintf("%s%d", qualified, i)
+	}
+}
+
+// objectSchema describes the fields of a struct type as encoded by
+// encoding/json. Fields of embedded structs are promoted.
+func (g *schemaGenerator) objectSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *schemaGenerator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx != -1 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			g.addFields(s, fieldType)
+			continue
+		}
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		var prop *Schema
+		if hasOption(opts, "string") {
+			prop = &Schema{Type: "string"}
+		} else {
+			prop = g.schema(field.Type)
+		}
+		s.Properties[name] = prop
+		if !hasOption(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// hasOption checks for an option in the comma-separated json tag options.
+func hasOption(opts, option string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
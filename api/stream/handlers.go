@@ -0,0 +1,234 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	apitypes "github.com/Legenddigital/lddldata/api/types"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// sseMaxDuration ends SSE responses before the server's write timeout.
+	// EventSource clients reconnect with the Last-Event-ID header, resuming
+	// from the height of the last event.
+	sseMaxDuration = 50 * time.Second
+	sseRetry       = 2 * time.Second
+
+	wsWriteTimeout = 10 * time.Second
+	wsMaxPayload   = 1 << 16
+)
+
+// subscription is the initial subscription of a client, parsed from the URL
+// query.
+type subscription struct {
+	topics    []string
+	addresses []string
+	since     int64
+}
+
+// splitList splits a comma separated list, dropping empty elements.
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// parseSubscription parses the topics, addresses and since parameters of the
+// URL query. since defaults to the Last-Event-ID header, and to -1 (no
+// replay) if neither is set.
+func parseSubscription(r *http.Request) (*subscription, error) {
+	q := r.URL.Query()
+	sub := &subscription{
+		topics:    splitList(q.Get("topics")),
+		addresses: splitList(q.Get("addresses")),
+		since:     -1,
+	}
+	if len(sub.topics) == 0 && len(sub.addresses) == 0 {
+		sub.topics = DefaultTopics
+	}
+	if len(sub.addresses) > 0 {
+		sub.topics = append(sub.topics, TopicAddress)
+	}
+
+	since := q.Get("since")
+	if since == "" {
+		since = r.Header.Get("Last-Event-ID")
+	}
+	if since != "" {
+		height, err := strconv.ParseInt(since, 10, 64)
+		if err != nil || height < 0 {
+			return nil, fmt.Errorf("invalid since height %q", since)
+		}
+		sub.since = height
+	}
+	return sub, nil
+}
+
+// ServeSSE streams events as Server-Sent Events. The event name is the topic,
+// the data is the JSON encoded apitypes.StreamEvent, and the ID is the best
+// block height, so a reconnecting EventSource resumes with the blocks it
+// missed.
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	sub, err := parseSubscription(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c, height, err := h.register(sub.topics, sub.addresses)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer h.unregister(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry/time.Millisecond)
+
+	writeEvent := func(event *apitypes.StreamEvent) bool {
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Errorf("Failed to encode stream event: %v", err)
+			return true
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n",
+			event.Height, event.Topic, data)
+		return err == nil
+	}
+
+	for _, event := range h.replay(c, sub.since, height) {
+		if !writeEvent(event) {
+			return
+		}
+	}
+	flusher.Flush()
+
+	timeout := time.NewTimer(sseMaxDuration)
+	defer timeout.Stop()
+	for {
+		select {
+		case event, ok := <-c.events:
+			if !ok {
+				return
+			}
+			if !writeEvent(event) {
+				return
+			}
+			flusher.Flush()
+		case <-timeout.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// ServeWebsocket streams events as JSON encoded apitypes.StreamEvent messages
+// over a websocket. Clients change their subscriptions by sending
+// apitypes.StreamRequest messages.
+func (h *Hub) ServeWebsocket(w http.ResponseWriter, r *http.Request) {
+	sub, err := parseSubscription(r)
+	if err == nil {
+		err = h.validate(sub.topics, sub.addresses)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+		ws.MaxPayloadBytes = wsMaxPayload
+
+		c, height, err := h.register(sub.topics, sub.addresses)
+		if err != nil {
+			return
+		}
+		defer h.unregister(c)
+
+		send := func(event *apitypes.StreamEvent) bool {
+			ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := websocket.JSON.Send(ws, event); err != nil {
+				log.Debugf("Failed to send stream event: %v", err)
+				return false
+			}
+			return true
+		}
+
+		// Subscription changes and their errors are handled by the receive
+		// loop. Errors are queued for the send loop, which owns the writer.
+		done := make(chan struct{})
+		errs := make(chan string, 1)
+		go func() {
+			defer close(done)
+			for {
+				var req apitypes.StreamRequest
+				if err := websocket.JSON.Receive(ws, &req); err != nil {
+					return
+				}
+				var err error
+				switch req.Action {
+				case "subscribe":
+					topics := req.Topics
+					if len(req.Addresses) > 0 {
+						topics = append(topics, TopicAddress)
+					}
+					err = h.subscribe(c, topics, req.Addresses)
+				case "unsubscribe":
+					h.unsubscribe(c, req.Topics, req.Addresses)
+				default:
+					err = fmt.Errorf("unknown action %q", req.Action)
+				}
+				if err != nil {
+					select {
+					case errs <- err.Error():
+					default:
+					}
+				}
+			}
+		}()
+
+		for _, event := range h.replay(c, sub.since, height) {
+			if !send(event) {
+				return
+			}
+		}
+		for {
+			select {
+			case event, ok := <-c.events:
+				if !ok || !send(event) {
+					return
+				}
+			case msg := <-errs:
+				if !send(&apitypes.StreamEvent{
+					Version: Version,
+					Topic:   TopicError,
+					Height:  h.Height(),
+					Data:    msg,
+				}) {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}).ServeHTTP(w, r)
+}
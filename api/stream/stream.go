@@ -0,0 +1,457 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+// Package stream implements the streaming API, which pushes new blocks, chain
+// reorganizations, mempool ticket fee updates, new transactions and the
+// activity of addresses to clients over Server-Sent Events or a websocket.
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Legenddigital/lddld/chaincfg"
+	"github.com/Legenddigital/lddld/chaincfg/chainhash"
+	"github.com/Legenddigital/lddld/lddljson"
+	"github.com/Legenddigital/lddld/lddlutil"
+	"github.com/Legenddigital/lddld/txscript"
+	"github.com/Legenddigital/lddld/wire"
+	apitypes "github.com/Legenddigital/lddldata/api/types"
+	"github.com/Legenddigital/lddldata/blockdata"
+	"github.com/Legenddigital/lddldata/mempool"
+	"github.com/Legenddigital/lddldata/txhelpers"
+)
+
+// Version is the version of the stream protocol, sent with every event.
+const Version = 1
+
+// Event topics. Clients subscribe to topics, and to the address topic by
+// subscribing to addresses. Ping and error events are sent to all clients.
+const (
+	TopicBlock   = "block"
+	TopicReorg   = "reorg"
+	TopicMempool = "mempool"
+	TopicTx      = "tx"
+	TopicAddress = "address"
+	TopicPing    = "ping"
+	TopicError   = "error"
+)
+
+const (
+	// maxAddresses limits the addresses a client may subscribe to.
+	maxAddresses = 100
+
+	// maxReplayBlocks limits the blocks sent to a resuming client.
+	maxReplayBlocks = 100
+
+	// clientBufferSize is the number of events queued for a client. Clients
+	// that fall further behind are disconnected.
+	clientBufferSize = 64
+
+	pingInterval = 30 * time.Second
+)
+
+// DefaultTopics are the topics of a client that subscribes to neither topics
+// nor addresses.
+var DefaultTopics = []string{TopicBlock, TopicReorg, TopicMempool}
+
+var topics = map[string]bool{
+	TopicBlock:   true,
+	TopicReorg:   true,
+	TopicMempool: true,
+	TopicTx:      true,
+	TopicAddress: true,
+}
+
+var zeroHash chainhash.Hash
+
+// DataSource specifies the methods used to replay blocks to resuming clients
+// and to find the addresses spent from by a transaction.
+type DataSource interface {
+	GetHeight() int
+	GetSummary(idx int) *apitypes.BlockDataBasic
+	GetRawTransaction(txid string) *apitypes.Tx
+}
+
+// Hub tracks the stream clients and sends them the events of their topics. It
+// is a blockdata.BlockDataSaver and a mempool.MempoolDataSaver.
+type Hub struct {
+	source  DataSource
+	params  *chaincfg.Params
+	mtx     sync.Mutex
+	clients map[*client]struct{}
+	height  int64
+}
+
+// client is a stream connection's subscriptions and queued events. The
+// subscriptions are only accessed with the Hub's mutex held.
+type client struct {
+	topics    map[string]bool
+	addresses map[string]bool
+	events    chan *apitypes.StreamEvent
+}
+
+func (c *client) wants(topic, address string) bool {
+	switch topic {
+	case TopicPing, TopicError:
+		return true
+	case TopicAddress:
+		return c.addresses[address]
+	}
+	return c.topics[topic]
+}
+
+// NewHub creates a Hub. The data source provides the best block height until
+// a block is stored, since it is synchronized after the Hub is created.
+func NewHub(source DataSource, params *chaincfg.Params) *Hub {
+	return &Hub{
+		source:  source,
+		params:  params,
+		clients: make(map[*client]struct{}),
+		height:  -1,
+	}
+}
+
+// NumClients returns the number of connected clients.
+func (h *Hub) NumClients() int {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return len(h.clients)
+}
+
+// Height returns the best block height sent to the clients.
+func (h *Hub) Height() int64 {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.heightLocked()
+}
+
+// heightLocked must be called with the mutex held.
+func (h *Hub) heightLocked() int64 {
+	if h.height < 0 {
+		return int64(h.source.GetHeight())
+	}
+	return h.height
+}
+
+// validate checks the topics and addresses of a subscription.
+func (h *Hub) validate(topicList, addresses []string) error {
+	for _, topic := range topicList {
+		if !topics[topic] {
+			return fmt.Errorf("unknown topic %q", topic)
+		}
+	}
+	if len(addresses) > maxAddresses {
+		return fmt.Errorf("too many addresses (maximum %d)", maxAddresses)
+	}
+	for _, address := range addresses {
+		addr, err := lddlutil.DecodeAddress(address)
+		if err != nil || !addr.IsForNet(h.params) {
+			return fmt.Errorf("invalid address %q", address)
+		}
+	}
+	return nil
+}
+
+// register adds a client subscribed to the topics and addresses, returning
+// the best block height at registration.
+func (h *Hub) register(topicList, addresses []string) (*client, int64, error) {
+	if err := h.validate(topicList, addresses); err != nil {
+		return nil, 0, err
+	}
+	c := &client{
+		topics:    make(map[string]bool),
+		addresses: make(map[string]bool),
+		events:    make(chan *apitypes.StreamEvent, clientBufferSize),
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.addSubscriptions(c, topicList, addresses)
+	h.clients[c] = struct{}{}
+	log.Debugf("Registered stream client (%d).", len(h.clients))
+	return c, h.heightLocked(), nil
+}
+
+// unregister removes a client. Its events channel is closed if it was not
+// already removed for falling behind.
+func (h *Hub) unregister(c *client) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.removeClient(c)
+}
+
+// removeClient must be called with the mutex held.
+func (h *Hub) removeClient(c *client) {
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	delete(h.clients, c)
+	close(c.events)
+	log.Debugf("Unregistered stream client (%d).", len(h.clients))
+}
+
+// subscribe adds topics and addresses to a client's subscriptions.
+func (h *Hub) subscribe(c *client, topicList, addresses []string) error {
+	if err := h.validate(topicList, addresses); err != nil {
+		return err
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if len(c.addresses)+len(addresses) > maxAddresses {
+		return fmt.Errorf("too many addresses (maximum %d)", maxAddresses)
+	}
+	h.addSubscriptions(c, topicList, addresses)
+	return nil
+}
+
+// unsubscribe removes topics and addresses from a client's subscriptions.
+func (h *Hub) unsubscribe(c *client, topicList, addresses []string) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	for _, topic := range topicList {
+		delete(c.topics, topic)
+	}
+	for _, address := range addresses {
+		delete(c.addresses, address)
+	}
+}
+
+// addSubscriptions must be called with the mutex held.
+func (h *Hub) addSubscriptions(c *client, topicList, addresses []string) {
+	for _, topic := range topicList {
+		c.topics[topic] = true
+	}
+	for _, address := range addresses {
+		c.addresses[address] = true
+	}
+}
+
+// sendLocked queues an event for the clients subscribed to it. It must be
+// called with the mutex held.
+func (h *Hub) sendLocked(topic, address string, data interface{}) {
+	event := &apitypes.StreamEvent{
+		Version: Version,
+		Topic:   topic,
+		Height:  h.heightLocked(),
+		Data:    data,
+	}
+	for c := range h.clients {
+		if !c.wants(topic, address) {
+			continue
+		}
+		select {
+		case c.events <- event:
+		default:
+			log.Debugf("Disconnecting stream client with %d queued events.", len(c.events))
+			h.removeClient(c)
+		}
+	}
+}
+
+func (h *Hub) send(topic, address string, data interface{}) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.sendLocked(topic, address, data)
+}
+
+// replay returns the block events after height since, up to height, for a
+// resuming client. At most maxReplayBlocks blocks are replayed.
+func (h *Hub) replay(c *client, since, height int64) []*apitypes.StreamEvent {
+	h.mtx.Lock()
+	wantBlocks := c.topics[TopicBlock]
+	h.mtx.Unlock()
+	if since < 0 || !wantBlocks {
+		return nil
+	}
+
+	start := since + 1
+	if height-start >= maxReplayBlocks {
+		start = height - maxReplayBlocks + 1
+	}
+	var events []*apitypes.StreamEvent
+	for idx := start; idx <= height; idx++ {
+		summary := h.source.GetSummary(int(idx))
+		if summary == nil {
+			break
+		}
+		events = append(events, &apitypes.StreamEvent{
+			Version: Version,
+			Topic:   TopicBlock,
+			Height:  idx,
+			Data:    summary,
+		})
+	}
+	return events
+}
+
+// watchedAddresses returns the addresses subscribed to by any client.
+func (h *Hub) watchedAddresses() map[string]bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	addrs := make(map[string]bool)
+	for c := range h.clients {
+		for address := range c.addresses {
+			addrs[address] = true
+		}
+	}
+	return addrs
+}
+
+// Store sends the new block to the clients, followed by the activity of the
+// subscribed addresses in the block.
+func (h *Hub) Store(blockData *blockdata.BlockData, msgBlock *wire.MsgBlock) error {
+	summary := blockData.ToBlockSummary()
+	if msgBlock != nil {
+		summary.NumTx = uint32(len(msgBlock.Transactions) + len(msgBlock.STransactions))
+	}
+
+	h.mtx.Lock()
+	h.height = int64(summary.Height)
+	h.sendLocked(TopicBlock, "", &summary)
+	h.mtx.Unlock()
+
+	if msgBlock != nil {
+		txs := make([]*wire.MsgTx, 0, summary.NumTx)
+		txs = append(txs, msgBlock.Transactions...)
+		txs = append(txs, msgBlock.STransactions...)
+		// Finding the spent addresses requires a lookup for each input, so
+		// block processing does not wait.
+		go h.sendAddressActivity(txs, summary.Hash, int64(summary.Height))
+	}
+	return nil
+}
+
+// StoreMPData sends the mempool ticket fee info to the clients.
+func (h *Hub) StoreMPData(data *mempool.MempoolData, timestamp time.Time) error {
+	h.send(TopicMempool, "", data.TicketFeeInfo(timestamp))
+	return nil
+}
+
+// NtfnHandler sends new mempool transactions, the activity of subscribed
+// addresses in them, reorganizations and pings to the clients.
+func (h *Hub) NtfnHandler(wg *sync.WaitGroup, quit chan struct{},
+	newTxChan <-chan *lddljson.TxRawResult, reorgChan <-chan *blockdata.ReorgData) {
+	defer wg.Done()
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case tx, ok := <-newTxChan:
+			if !ok {
+				log.Warnf("New tx channel closed.")
+				return
+			}
+			h.processTx(tx)
+
+		case reorg, ok := <-reorgChan:
+			if !ok {
+				log.Warnf("Reorg channel closed.")
+				return
+			}
+			h.send(TopicReorg, "", &apitypes.StreamReorg{
+				OldHash:   reorg.OldChainHead.String(),
+				OldHeight: reorg.OldChainHeight,
+				NewHash:   reorg.NewChainHead.String(),
+				NewHeight: reorg.NewChainHeight,
+			})
+
+		case <-ticker.C:
+			h.send(TopicPing, "", nil)
+
+		case <-quit:
+			log.Debugf("Got quit signal. Exiting stream notification handler.")
+			h.close()
+			return
+		}
+	}
+}
+
+// close disconnects all clients.
+func (h *Hub) close() {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	for c := range h.clients {
+		h.removeClient(c)
+	}
+}
+
+func (h *Hub) processTx(tx *lddljson.TxRawResult) {
+	msgTx, err := txhelpers.MsgTxFromHex(tx.Hex)
+	if err != nil {
+		log.Errorf("Failed to decode transaction %s: %v", tx.Txid, err)
+		return
+	}
+	h.send(TopicTx, "", &apitypes.StreamTx{
+		TxID:     tx.Txid,
+		Type:     txhelpers.DetermineTxTypeString(msgTx),
+		Size:     len(tx.Hex) / 2,
+		TotalOut: txhelpers.TotalOutFromMsgTx(msgTx).ToCoin(),
+		Fee:      txhelpers.TxFee(msgTx).ToCoin(),
+	})
+	h.sendAddressActivity([]*wire.MsgTx{msgTx}, "", 0)
+}
+
+// sendAddressActivity sends the address events of transactions involving the
+// subscribed addresses. The block hash and height are empty for mempool
+// transactions.
+func (h *Hub) sendAddressActivity(txs []*wire.MsgTx, blockHash string, blockHeight int64) {
+	watched := h.watchedAddresses()
+	if len(watched) == 0 {
+		return
+	}
+	for _, msgTx := range txs {
+		for address, activity := range h.addressActivity(msgTx, watched) {
+			activity.BlockHash = blockHash
+			activity.BlockHeight = blockHeight
+			h.send(TopicAddress, address, activity)
+		}
+	}
+}
+
+// addressActivity returns the amounts received and sent by the watched
+// addresses in a transaction.
+func (h *Hub) addressActivity(msgTx *wire.MsgTx, watched map[string]bool) map[string]*apitypes.StreamAddressTx {
+	txid := msgTx.TxHash().String()
+	activity := make(map[string]*apitypes.StreamAddressTx)
+	activityOf := func(address string) *apitypes.StreamAddressTx {
+		a, ok := activity[address]
+		if !ok {
+			a = &apitypes.StreamAddressTx{Address: address, TxID: txid}
+			activity[address] = a
+		}
+		return a
+	}
+
+	for _, txOut := range msgTx.TxOut {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.Version,
+			txOut.PkScript, h.params)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if address := addr.EncodeAddress(); watched[address] {
+				activityOf(address).Received += lddlutil.Amount(txOut.Value).ToCoin()
+			}
+		}
+	}
+
+	for _, txIn := range msgTx.TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		// Coinbase and stakebase inputs spend no previous output.
+		if prevOut.Hash == zeroHash {
+			continue
+		}
+		prevTx := h.source.GetRawTransaction(prevOut.Hash.String())
+		if prevTx == nil || int(prevOut.Index) >= len(prevTx.Vout) {
+			continue
+		}
+		vout := &prevTx.Vout[prevOut.Index]
+		for _, address := range vout.ScriptPubKeyDecoded.Addresses {
+			if watched[address] {
+				activityOf(address).Sent += vout.Value
+			}
+		}
+	}
+	return activity
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package api
+
+import (
+	"net/http"
+)
+
+// StreamCtx makes the /stream routes unavailable if the stream server is not
+// set.
+func (c *appContext) StreamCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Stream == nil {
+			http.Error(w, "streaming is not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *appContext) streamSSE(w http.ResponseWriter, r *http.Request) {
+	c.Stream.ServeSSE(w, r)
+}
+
+func (c *appContext) streamWebsocket(w http.ResponseWriter, r *http.Request) {
+	c.Stream.ServeWebsocket(w, r)
+}
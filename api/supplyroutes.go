@@ -0,0 +1,84 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/Legenddigital/lddld/lddlutil"
+	apitypes "github.com/Legenddigital/lddldata/api/types"
+	"github.com/Legenddigital/lddldata/charts"
+)
+
+// getSupplySchedule gets the projected coin supply of each subsidy reduction
+// interval until the subsidy ends.
+func (c *appContext) getSupplySchedule(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, c.BlockData.SupplySchedule(), c.getIndentQuery(r))
+}
+
+// getSupplyHistory gets the mined coin supply and the coins locked in tickets
+// at the end of each day, week or month (the default), set by the bin URL
+// query, and the current dev fund balance.
+func (c *appContext) getSupplyHistory(w http.ResponseWriter, r *http.Request) {
+	bin := r.URL.Query().Get("bin")
+	switch bin {
+	case "":
+		bin = charts.BinMonth
+	case charts.BinDay, charts.BinWeek, charts.BinMonth:
+	default:
+		http.Error(w, "unknown bin "+bin, 422)
+		return
+	}
+
+	supply, err := c.Charts.Chart(charts.CoinSupply, bin)
+	if err != nil {
+		apiLog.Errorf("Unable to get the coin supply chart: %v", err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	pool, err := c.Charts.Chart(charts.TicketPool, bin)
+	if err != nil {
+		apiLog.Errorf("Unable to get the ticket pool chart: %v", err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	devBalance, err := c.AuxDataSource.DevBalance()
+	if err != nil {
+		apiLog.Errorf("Unable to get the dev fund balance: %v", err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	// Both charts are aggregated from the same blocks, so their bins match.
+	history := &apitypes.SupplyHistory{
+		Bin:        bin,
+		DevAddress: devBalance.Address,
+		DevFund:    lddlutil.Amount(devBalance.TotalUnspent).ToCoin(),
+		Points:     make([]apitypes.SupplyHistoryPoint, len(supply.X)),
+	}
+	values := func(chart *charts.Chart, name string) []float64 {
+		for _, s := range chart.Series {
+			if s.Name == name {
+				return s.Values
+			}
+		}
+		return nil
+	}
+	mined, pow := values(supply, "supply"), values(supply, "pow")
+	pos, dev := values(supply, "pos"), values(supply, "dev")
+	staked := values(pool, "value")
+	for i, t := range supply.X {
+		p := &history.Points[i]
+		p.Time = t
+		p.Mined, p.PoW, p.PoS, p.Dev = mined[i], pow[i], pos[i], dev[i]
+		if i < len(staked) {
+			p.Staked = staked[i]
+		}
+		if p.Mined > 0 {
+			p.StakedPercent = p.Staked / p.Mined * 100
+		}
+	}
+
+	writeJSON(w, history, c.getIndentQuery(r))
+}
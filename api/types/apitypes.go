@@ -59,6 +59,14 @@ type Txns struct {
 	Transactions []string `json:"transactions"`
 }
 
+// AddressesPost models the multi address post data structure. Count and Skip
+// page the transaction history.
+type AddressesPost struct {
+	Addresses []string `json:"addresses"`
+	Count     int64    `json:"count,omitempty"`
+	Skip      int64    `json:"skip,omitempty"`
+}
+
 // VoteInfo models data about a SSGen transaction (vote)
 type VoteInfo struct {
 	Validation BlockValidation         `json:"block_validation"`
@@ -252,6 +260,37 @@ type CoinSupply struct {
 	Ultimate int64  `json:"supply_ultimate"`
 }
 
+// SupplySchedule is the projected coin supply of each subsidy reduction
+// interval until the subsidy ends. Amounts are in atoms.
+type SupplySchedule struct {
+	BlockOne     int64                       `json:"block_one"`
+	IntervalSize int64                       `json:"interval_size"`
+	Ultimate     int64                       `json:"supply_ultimate"`
+	Intervals    []txhelpers.SubsidyInterval `json:"intervals"`
+}
+
+// SupplyHistoryPoint is the mined coin supply at the end of a bin, the coins
+// created by each subsidy, and the coins locked in tickets. Amounts are in
+// coins.
+type SupplyHistoryPoint struct {
+	Time          int64   `json:"time"`
+	Mined         float64 `json:"mined"`
+	PoW           float64 `json:"pow"`
+	PoS           float64 `json:"pos"`
+	Dev           float64 `json:"dev"`
+	Staked        float64 `json:"staked"`
+	StakedPercent float64 `json:"staked_percent"`
+}
+
+// SupplyHistory is the mined coin supply over time, and the current balance
+// of the dev fund in coins.
+type SupplyHistory struct {
+	Bin        string               `json:"bin"`
+	DevAddress string               `json:"dev_address"`
+	DevFund    float64              `json:"dev_fund"`
+	Points     []SupplyHistoryPoint `json:"points"`
+}
+
 // TicketPoolInfo models data about ticket pool
 type TicketPoolInfo struct {
 	Height  uint32   `json:"height"`
@@ -329,6 +368,15 @@ type StakeInfoExtended struct {
 	PoolInfo         TicketPoolInfo       `json:"ticket_pool"`
 }
 
+// BlockFeeStats models the fee rate statistics of the regular and stake
+// transaction trees of the block at height Height
+type BlockFeeStats struct {
+	Height  uint32                 `json:"height"`
+	Hash    string                 `json:"hash"`
+	Regular txhelpers.FeeRateStats `json:"regular"`
+	Stake   txhelpers.FeeRateStats `json:"stake"`
+}
+
 // StakeInfoExtendedEstimates is similar to StakeInfoExtended but includes stake
 // difficulty estimates with the stake difficulty
 type StakeInfoExtendedEstimates struct {
@@ -378,3 +426,287 @@ type MempoolTicketDetails struct {
 // TicketsDetails is an array of pointers of TicketDetails used in
 // MempoolTicketDetails
 type TicketsDetails []*TicketDetails
+
+// StreamEvent is a message of the /stream API. Height is the best block height
+// when the event was sent, from which a client may resume.
+type StreamEvent struct {
+	Version int         `json:"version"`
+	Topic   string      `json:"topic"`
+	Height  int64       `json:"height"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// StreamReorg describes a chain reorganization sent on the reorg topic of the
+// /stream API.
+type StreamReorg struct {
+	OldHash   string `json:"old_hash"`
+	OldHeight int32  `json:"old_height"`
+	NewHash   string `json:"new_hash"`
+	NewHeight int32  `json:"new_height"`
+}
+
+// StreamTx describes a transaction accepted into mempool, sent on the tx topic
+// of the /stream API.
+type StreamTx struct {
+	TxID     string  `json:"txid"`
+	Type     string  `json:"type"`
+	Size     int     `json:"size"`
+	TotalOut float64 `json:"total_out"`
+	Fee      float64 `json:"fee"`
+}
+
+// StreamAddressTx describes a transaction paying to or spending from an
+// address, sent on the address topic of the /stream API. The block is omitted
+// for mempool transactions.
+type StreamAddressTx struct {
+	Address     string  `json:"address"`
+	TxID        string  `json:"txid"`
+	Received    float64 `json:"received"`
+	Sent        float64 `json:"sent"`
+	BlockHash   string  `json:"block_hash,omitempty"`
+	BlockHeight int64   `json:"block_height,omitempty"`
+}
+
+// StreamRequest is a message from a websocket client of the /stream API,
+// changing its subscriptions. Action is "subscribe" or "unsubscribe".
+type StreamRequest struct {
+	Action    string   `json:"action"`
+	Topics    []string `json:"topics,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// AddressUTXO is an unspent transaction output paying to an address.
+type AddressUTXO struct {
+	Address       string  `json:"address"`
+	TxID          string  `json:"txid"`
+	Vout          uint32  `json:"vout"`
+	Tree          int8    `json:"tree"`
+	ScriptPubKey  string  `json:"scriptPubKey"`
+	Amount        float64 `json:"amount"`
+	Atoms         int64   `json:"atoms"`
+	BlockHash     string  `json:"block_hash"`
+	BlockHeight   int64   `json:"block_height"`
+	BlockTime     int64   `json:"block_time"`
+	Confirmations int64   `json:"confirmations"`
+}
+
+// AddressBalance is the balance of an address at a block height.
+type AddressBalance struct {
+	Address     string  `json:"address"`
+	BlockHeight int64   `json:"block_height"`
+	NumUnspent  int64   `json:"num_utxos"`
+	Balance     float64 `json:"balance"`
+	Atoms       int64   `json:"atoms"`
+}
+
+// AddressesTxn is a transaction funding or spending any of a set of addresses.
+// Received and Sent are the totals for the addresses in the set.
+type AddressesTxn struct {
+	TxID        string   `json:"txid"`
+	BlockHeight int64    `json:"block_height"`
+	Time        int64    `json:"time"`
+	Received    float64  `json:"received"`
+	Sent        float64  `json:"sent"`
+	Addresses   []string `json:"addresses"`
+}
+
+// AddressesTxns is the merged transaction history of a set of addresses, most
+// recent first. NumTxns is the total number of transactions.
+type AddressesTxns struct {
+	Addresses    []string        `json:"addresses"`
+	NumTxns      int64           `json:"num_txns"`
+	Count        int64           `json:"count"`
+	Skip         int64           `json:"skip"`
+	Transactions []*AddressesTxn `json:"transactions"`
+}
+
+// AddressesTotals represents the combined number and value of spent and
+// unspent outputs of a set of addresses, with the totals of each address.
+type AddressesTotals struct {
+	BlockHash    string           `json:"blockhash"`
+	BlockHeight  uint64           `json:"blockheight"`
+	NumSpent     int64            `json:"num_stxos"`
+	NumUnspent   int64            `json:"num_utxos"`
+	CoinsSpent   float64          `json:"lddl_spent"`
+	CoinsUnspent float64          `json:"lddl_unspent"`
+	Addresses    []*AddressTotals `json:"addresses"`
+}
+
+// MempoolTypeTotals are the number, size, value and fees of the mempool
+// transactions of one type.
+type MempoolTypeTotals struct {
+	Count    int     `json:"count"`
+	Size     int32   `json:"size"`
+	TotalOut float64 `json:"total_out"`
+	Fees     float64 `json:"fees"`
+}
+
+// FeeRateBin is a bin of a fee rate histogram. Rates are in LDDL/kB, and the
+// maximum is exclusive and omitted for the last bin.
+type FeeRateBin struct {
+	MinFeeRate float64  `json:"min_fee_rate"`
+	MaxFeeRate *float64 `json:"max_fee_rate,omitempty"`
+	Count      int      `json:"count"`
+	Size       int32    `json:"size"`
+}
+
+// MempoolVotes are the mempool votes on a block.
+type MempoolVotes struct {
+	BlockHash   string `json:"block_hash"`
+	BlockHeight int64  `json:"block_height"`
+	NumVotes    int    `json:"num_votes"`
+	NumApprove  int    `json:"num_approve"`
+}
+
+// MempoolOverview summarizes the transactions in mempool. The block is the
+// best block when mempool was last updated.
+type MempoolOverview struct {
+	BlockHeight      int64                        `json:"block_height"`
+	BlockTime        int64                        `json:"block_time"`
+	NumTxns          int                          `json:"num_txns"`
+	Size             int32                        `json:"size"`
+	TotalOut         float64                      `json:"total_out"`
+	TotalFees        float64                      `json:"total_fees"`
+	ByType           map[string]MempoolTypeTotals `json:"by_type"`
+	FeeRateHistogram []FeeRateBin                 `json:"fee_rate_histogram"`
+	Votes            []MempoolVotes               `json:"votes"`
+}
+
+// MempoolTxn is a transaction in mempool. FirstSeen is the time it entered
+// mempool, and the fee rate is in LDDL/kB.
+type MempoolTxn struct {
+	TxID      string  `json:"txid"`
+	Type      string  `json:"type"`
+	Size      int32   `json:"size"`
+	TotalOut  float64 `json:"total_out"`
+	Fees      float64 `json:"fees"`
+	FeeRate   float64 `json:"fee_rate"`
+	FirstSeen int64   `json:"first_seen"`
+	Vote      *TxVote `json:"vote,omitempty"`
+}
+
+// TxVote is a vote's decision on the block it validates.
+type TxVote struct {
+	BlockHash   string `json:"block_hash"`
+	BlockHeight int64  `json:"block_height"`
+	Approve     bool   `json:"approve"`
+}
+
+// MempoolTxns is a page of the mempool transactions matching a filter.
+// NumTxns is the total number matching.
+type MempoolTxns struct {
+	NumTxns      int           `json:"num_txns"`
+	Count        int           `json:"count"`
+	Skip         int           `json:"skip"`
+	Transactions []*MempoolTxn `json:"transactions"`
+}
+
+// TicketInfo describes the lifecycle of a ticket, from its purchase to its
+// vote or revocation. Amounts are in LDDL. BlocksWaited is the number of
+// blocks from the ticket's maturity until it was called to vote, missed, or
+// expired, or until the best block for a live ticket.
+type TicketInfo struct {
+	TxID                   string      `json:"txid"`
+	BlockHash              string      `json:"block_hash"`
+	BlockHeight            int64       `json:"block_height"`
+	MaturityHeight         int64       `json:"maturity_height"`
+	ExpirationHeight       int64       `json:"expiration_height"`
+	Price                  float64     `json:"price"`
+	Fee                    float64     `json:"fee"`
+	StakeSubmissionAddress string      `json:"stakesubmission_address"`
+	IsSplit                bool        `json:"is_split"`
+	IsMultisig             bool        `json:"is_multisig"`
+	PoolStatus             string      `json:"pool_status"`
+	SpendType              string      `json:"spend_type"`
+	SpendHeight            int64       `json:"spend_height,omitempty"`
+	SpendTxID              string      `json:"spend_txid,omitempty"`
+	MissHeight             int64       `json:"miss_height,omitempty"`
+	BlocksWaited           int64       `json:"blocks_waited"`
+	VoteReward             float64     `json:"vote_reward,omitempty"`
+	Vote                   *TicketVote `json:"vote,omitempty"`
+}
+
+// TicketVote is the decision of a ticket's vote on the previous block and on
+// the agendas of its vote version.
+type TicketVote struct {
+	Version    uint32                  `json:"version"`
+	VoteBits   uint16                  `json:"vote_bits"`
+	BlockValid bool                    `json:"block_valid"`
+	Choices    []*txhelpers.VoteChoice `json:"choices"`
+}
+
+// AddressTickets lists the tickets with an address as their stake submission
+// address, most recent first, with the number of tickets by status. ROI is
+// the total vote reward over the total price of the voted tickets.
+type AddressTickets struct {
+	Address      string        `json:"address"`
+	BlockHeight  int64         `json:"block_height"`
+	NumTickets   int           `json:"num_tickets"`
+	NumLive      int           `json:"num_live"`
+	NumVoted     int           `json:"num_voted"`
+	NumMissed    int           `json:"num_missed"`
+	NumExpired   int           `json:"num_expired"`
+	NumRevoked   int           `json:"num_revoked"`
+	TotalPrice   float64       `json:"total_price"`
+	TotalFees    float64       `json:"total_fees"`
+	TotalRewards float64       `json:"total_rewards"`
+	ROI          float64       `json:"roi"`
+	Tickets      []*TicketInfo `json:"tickets"`
+}
+
+// BlockMisses lists the tickets that missed their votes on a block.
+type BlockMisses struct {
+	Height  int64    `json:"height"`
+	Hash    string   `json:"hash"`
+	Tickets []string `json:"tickets"`
+}
+
+// MissesRange lists the blocks with missed votes in a range of heights.
+type MissesRange struct {
+	StartHeight int64          `json:"start_height"`
+	EndHeight   int64          `json:"end_height"`
+	NumMissed   int            `json:"num_missed"`
+	Blocks      []*BlockMisses `json:"blocks"`
+}
+
+// DayMisses are the votes, missed votes and expired tickets of the blocks of
+// a UTC day, starting at Time. MissRate is the fraction of the tickets called
+// to vote that missed.
+type DayMisses struct {
+	Time       int64   `json:"time"`
+	NumBlocks  int     `json:"num_blocks"`
+	NumVotes   int     `json:"num_votes"`
+	NumMissed  int     `json:"num_missed"`
+	NumExpired int     `json:"num_expired"`
+	MissRate   float64 `json:"miss_rate"`
+}
+
+// AddressMisses are the votes, missed votes and expired tickets of the
+// tickets with a stake submission address.
+type AddressMisses struct {
+	Address    string  `json:"address"`
+	NumVotes   int     `json:"num_votes"`
+	NumMissed  int     `json:"num_missed"`
+	NumExpired int     `json:"num_expired"`
+	MissRate   float64 `json:"miss_rate"`
+}
+
+// TicketExpiries forecasts the expiry of the tickets in the live pool, in bins
+// of BinSize blocks. NumTickets is the number of live tickets reaching their
+// expiration height in a bin, and Expected the number expected to expire
+// without being called to vote.
+type TicketExpiries struct {
+	BlockHeight int64               `json:"block_height"`
+	PoolSize    int                 `json:"pool_size"`
+	BinSize     int64               `json:"bin_size"`
+	Expected    float64             `json:"expected"`
+	Bins        []TicketExpiriesBin `json:"bins"`
+}
+
+// TicketExpiriesBin is a bin of a ticket expiry forecast.
+type TicketExpiriesBin struct {
+	StartHeight int64   `json:"start_height"`
+	EndHeight   int64   `json:"end_height"`
+	NumTickets  int     `json:"num_tickets"`
+	Expected    float64 `json:"expected"`
+}
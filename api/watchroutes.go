@@ -0,0 +1,146 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	m "github.com/Legenddigital/lddldata/middleware"
+	"github.com/Legenddigital/lddldata/watcher"
+)
+
+const (
+	defaultDeliveriesCount = 100
+	maxDeliveriesCount     = 1000
+)
+
+// watchRequest is the JSON body of a request to add or update a watch.
+type watchRequest struct {
+	Address    string `json:"address"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+// WatchAuthCtx requires the watch list API key for the /watch routes. The
+// routes are unavailable if address watching is not enabled.
+func (c *appContext) WatchAuthCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Watcher == nil {
+			http.Error(w, "address watching is not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		if !c.Watcher.Authorized(m.GetAPIKey(r)) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *appContext) getWatchList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, c.Watcher.List(), c.getIndentQuery(r))
+}
+
+func (c *appContext) getWatch(w http.ResponseWriter, r *http.Request) {
+	address := m.GetAddressCtx(r)
+	watch := c.Watcher.Get(address)
+	if watch == nil {
+		http.Error(w, "address "+address+" is not watched", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, watch, c.getIndentQuery(r))
+}
+
+// addWatch adds or updates a watch. The address is taken from the URL path if
+// present, otherwise from the JSON request body.
+func (c *appContext) addWatch(w http.ResponseWriter, r *http.Request) {
+	var req watchRequest
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<16))
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, "error reading JSON message", http.StatusBadRequest)
+		return
+	}
+	if len(body) > 0 {
+		if err = json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "failed to unmarshal JSON request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if address := m.GetAddressCtx(r); address != "" {
+		req.Address = address
+	}
+	if req.Address == "" {
+		http.Error(w, "address not specified", http.StatusBadRequest)
+		return
+	}
+	if req.WebhookURL != "" {
+		u, err := url.Parse(req.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "webhook_url must be an http or https URL", http.StatusBadRequest)
+			return
+		}
+	}
+
+	watch, err := c.Watcher.Add(req.Address, req.WebhookURL)
+	if err != nil {
+		apiLog.Debugf("failed to add watch for %s: %v", req.Address, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeJSON(w, watch, c.getIndentQuery(r))
+}
+
+func (c *appContext) removeWatch(w http.ResponseWriter, r *http.Request) {
+	address := m.GetAddressCtx(r)
+	watched, err := c.Watcher.Remove(address)
+	if err != nil {
+		apiLog.Errorf("failed to remove watch for %s: %v", address, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+		return
+	}
+	if !watched {
+		http.Error(w, "address "+address+" is not watched", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getWebhookDeliveries lists the most recent webhook deliveries. The status
+// and n URL query parameters filter by delivery status and limit the count.
+func (c *appContext) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", watcher.DeliveryPending, watcher.DeliveryDelivered, watcher.DeliveryFailed:
+	default:
+		http.Error(w, "invalid status "+status, http.StatusBadRequest)
+		return
+	}
+
+	N := defaultDeliveriesCount
+	if nStr := r.URL.Query().Get("n"); nStr != "" {
+		n, err := strconv.Atoi(nStr)
+		if err != nil || n < 1 {
+			http.Error(w, "n must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		N = n
+	}
+	if N > maxDeliveriesCount {
+		N = maxDeliveriesCount
+	}
+
+	deliveries, err := c.Watcher.Deliveries(status, N)
+	if err != nil {
+		apiLog.Errorf("failed to get webhook deliveries: %v", err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, deliveries, c.getIndentQuery(r))
+}
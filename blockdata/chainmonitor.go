@@ -23,18 +23,19 @@ type ReorgData struct {
 
 // for getblock, ticketfeeinfo, estimatestakediff, etc.
 type chainMonitor struct {
-	collector       *Collector
-	dataSavers      []BlockDataSaver
-	reorgDataSavers []BlockDataSaver
-	quit            chan struct{}
-	wg              *sync.WaitGroup
-	watchaddrs      map[string]txhelpers.TxAction
-	blockChan       chan *chainhash.Hash
-	recvTxBlockChan chan *txhelpers.BlockWatchedTx
-	reorgChan       chan *ReorgData
-	ConnectingLock  chan struct{}
-	DoneConnecting  chan struct{}
-	syncConnect     sync.Mutex
+	collector        *Collector
+	dataSavers       []BlockDataSaver
+	reorgDataSavers  []BlockDataSaver
+	quit             chan struct{}
+	wg               *sync.WaitGroup
+	watchaddrs       func() map[string]txhelpers.TxAction
+	blockChan        chan *chainhash.Hash
+	recvTxBlockChan  chan *txhelpers.BlockWatchedTx
+	spendTxBlockChan chan *txhelpers.BlockWatchedTx
+	reorgChan        chan *ReorgData
+	ConnectingLock   chan struct{}
+	DoneConnecting   chan struct{}
+	syncConnect      sync.Mutex
 
 	// reorg handling
 	reorgLock    sync.Mutex
@@ -43,25 +44,63 @@ type chainMonitor struct {
 	reorganizing bool
 }
 
-// NewChainMonitor creates a new chainMonitor
+// NewChainMonitor creates a new chainMonitor. The watched addresses function
+// may be nil if no addresses are watched. Otherwise, the transactions in each
+// connected block paying to or spending from the watched addresses are sent on
+// recvTxBlockChan and spendTxBlockChan.
 func NewChainMonitor(collector *Collector,
 	savers []BlockDataSaver, reorgSavers []BlockDataSaver,
 	quit chan struct{}, wg *sync.WaitGroup,
-	addrs map[string]txhelpers.TxAction, blockChan chan *chainhash.Hash,
-	recvTxBlockChan chan *txhelpers.BlockWatchedTx,
+	addrs func() map[string]txhelpers.TxAction, blockChan chan *chainhash.Hash,
+	recvTxBlockChan, spendTxBlockChan chan *txhelpers.BlockWatchedTx,
 	reorgChan chan *ReorgData) *chainMonitor {
 	return &chainMonitor{
-		collector:       collector,
-		dataSavers:      savers,
-		reorgDataSavers: reorgSavers,
-		quit:            quit,
-		wg:              wg,
-		watchaddrs:      addrs,
-		blockChan:       blockChan,
-		recvTxBlockChan: recvTxBlockChan,
-		reorgChan:       reorgChan,
-		ConnectingLock:  make(chan struct{}, 1),
-		DoneConnecting:  make(chan struct{}),
+		collector:        collector,
+		dataSavers:       savers,
+		reorgDataSavers:  reorgSavers,
+		quit:             quit,
+		wg:               wg,
+		watchaddrs:       addrs,
+		blockChan:        blockChan,
+		recvTxBlockChan:  recvTxBlockChan,
+		spendTxBlockChan: spendTxBlockChan,
+		reorgChan:        reorgChan,
+		ConnectingLock:   make(chan struct{}, 1),
+		DoneConnecting:   make(chan struct{}),
+	}
+}
+
+// sendWatchedTxns sends the transactions in the block involving the watched
+// addresses to the receiving and spending channels.
+func (p *chainMonitor) sendWatchedTxns(block *lddlutil.Block) {
+	addrs := p.watchaddrs()
+	if len(addrs) == 0 {
+		return
+	}
+
+	txsForOutpoints, amounts := txhelpers.BlockConsumesOutpointWithAddresses(block,
+		addrs, p.collector.lddldChainSvr, p.collector.netParams)
+	if len(txsForOutpoints) > 0 {
+		select {
+		case p.spendTxBlockChan <- &txhelpers.BlockWatchedTx{
+			BlockHeight:       block.Height(),
+			BlockHash:         *block.Hash(),
+			TxsForAddress:     txsForOutpoints,
+			AmountsForAddress: amounts}:
+		case <-p.quit:
+		}
+	}
+
+	txsForAddrs := txhelpers.BlockReceivesToAddresses(block, addrs,
+		p.collector.netParams)
+	if len(txsForAddrs) > 0 {
+		select {
+		case p.recvTxBlockChan <- &txhelpers.BlockWatchedTx{
+			BlockHeight:   block.Height(),
+			BlockHash:     *block.Hash(),
+			TxsForAddress: txsForAddrs}:
+		case <-p.quit:
+		}
 	}
 }
 
@@ -132,20 +171,8 @@ out:
 			height := block.Height()
 			log.Infof("Block height %v connected. Collecting data...", height)
 
-			if len(p.watchaddrs) > 0 {
-				// txsForOutpoints := blockConsumesOutpointWithAddresses(block, p.watchaddrs,
-				// 	p.collector.lddldChainSvr)
-				// if len(txsForOutpoints) > 0 {
-				// 	p.spendTxBlockChan <- &BlockWatchedTx{height, txsForOutpoints}
-				// }
-
-				txsForAddrs := txhelpers.BlockReceivesToAddresses(block,
-					p.watchaddrs, p.collector.netParams)
-				if len(txsForAddrs) > 0 {
-					p.recvTxBlockChan <- &txhelpers.BlockWatchedTx{
-						BlockHeight:   height,
-						TxsForAddress: txsForAddrs}
-				}
+			if p.watchaddrs != nil {
+				p.sendWatchedTxns(block)
 			}
 
 			var blockData *BlockData
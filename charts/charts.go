@@ -0,0 +1,392 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+// Package charts caches the historical chart data of the main chain blocks,
+// and aggregates it by block, day or week.
+package charts
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/Legenddigital/lddld/blockchain"
+	"github.com/Legenddigital/lddld/chaincfg"
+	"github.com/Legenddigital/lddld/lddlutil"
+	"github.com/Legenddigital/lddldata/db/dbtypes"
+	"github.com/Legenddigital/lddldata/txhelpers"
+)
+
+// The names of the charts.
+const (
+	TicketPrice = "ticket-price"
+	TicketPool  = "ticket-pool"
+	Fees        = "fees"
+	TxCount     = "tx-count"
+	BlockSize   = "block-size"
+	CoinSupply  = "coin-supply"
+	MissedVotes = "missed-votes"
+	ChainWork   = "chainwork"
+
+	VoteParticipation = "vote-participation"
+)
+
+// The bins by which the chart data is aggregated. Days, weeks and months are
+// in UTC, and weeks start on Monday.
+const (
+	BinBlock = "block"
+	BinDay   = "day"
+	BinWeek  = "week"
+	BinMonth = "month"
+)
+
+const (
+	daySeconds  = 86400
+	weekSeconds = 7 * daySeconds
+	// weekOffset is the time of the first Monday after the Unix epoch.
+	weekOffset = 4 * daySeconds
+
+	// reorgDepth is the number of cached blocks that are retrieved again on
+	// each update, in case they were replaced by a chain reorganization.
+	reorgDepth = 6
+)
+
+var (
+	// ErrUnknownChart is returned for a chart name that is not known.
+	ErrUnknownChart = errors.New("unknown chart")
+	// ErrUnknownBin is returned for a bin that is not known.
+	ErrUnknownBin = errors.New("unknown bin")
+)
+
+// BlockSource is the source of the chart data of the blocks, such as
+// lddlpg.ChainDB.
+type BlockSource interface {
+	ChartBlocks(height int64) ([]*dbtypes.ChartBlock, error)
+}
+
+// Series is a named series of values of a chart.
+type Series struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+}
+
+// Chart is the data of a chart aggregated by bin. For the block bin, X holds
+// the block heights. For the day, week and month bins, it holds the start
+// time of each bin in Unix seconds. Amounts are in coins.
+type Chart struct {
+	Name   string   `json:"name"`
+	Bin    string   `json:"bin"`
+	Axis   string   `json:"axis"`
+	X      []int64  `json:"x"`
+	Series []Series `json:"series"`
+}
+
+// aggregation is how the values of the blocks in a bin are combined.
+type aggregation int
+
+const (
+	aggLast aggregation = iota
+	aggSum
+	aggMean
+	// aggRatio divides the sum of a column by the sum of another one.
+	aggRatio
+)
+
+// column is one of the per-block columns of the cache.
+type column int
+
+const (
+	colTicketPrice column = iota
+	colPoolSize
+	colPoolValue
+	colFees
+	colRegularTxns
+	colTickets
+	colVotes
+	colRevocations
+	colSize
+	colSupply
+	colSupplyPoW
+	colSupplyPoS
+	colSupplyDev
+	colMissed
+	colTicketsCalled
+	colChainWork
+	numColumns
+)
+
+// seriesDef defines a series of a chart from a column of the cache, or from
+// two columns for a ratio.
+type seriesDef struct {
+	name   string
+	column column
+	agg    aggregation
+	denom  column
+}
+
+func series(name string, col column, agg aggregation) seriesDef {
+	return seriesDef{name: name, column: col, agg: agg}
+}
+
+func ratio(name string, num, denom column) seriesDef {
+	return seriesDef{name: name, column: num, agg: aggRatio, denom: denom}
+}
+
+var chartDefs = map[string][]seriesDef{
+	TicketPrice: {series("price", colTicketPrice, aggLast)},
+	TicketPool: {
+		series("size", colPoolSize, aggLast),
+		series("value", colPoolValue, aggLast),
+	},
+	Fees: {series("fees", colFees, aggSum)},
+	TxCount: {
+		series("regular", colRegularTxns, aggSum),
+		series("tickets", colTickets, aggSum),
+		series("votes", colVotes, aggSum),
+		series("revocations", colRevocations, aggSum),
+	},
+	BlockSize: {series("size", colSize, aggMean)},
+	CoinSupply: {
+		series("supply", colSupply, aggLast),
+		series("pow", colSupplyPoW, aggLast),
+		series("pos", colSupplyPoS, aggLast),
+		series("dev", colSupplyDev, aggLast),
+	},
+	MissedVotes:       {series("missed", colMissed, aggSum)},
+	ChainWork:         {series("work", colChainWork, aggLast)},
+	VoteParticipation: {ratio("participation", colVotes, colTicketsCalled)},
+}
+
+// Names returns the names of the charts.
+func Names() []string {
+	return []string{TicketPrice, TicketPool, Fees, TxCount, BlockSize,
+		CoinSupply, MissedVotes, ChainWork, VoteParticipation}
+}
+
+// Cache holds the chart data of every main chain block, and the charts
+// aggregated from it since the last update.
+type Cache struct {
+	source       BlockSource
+	params       *chaincfg.Params
+	subsidyCache *blockchain.SubsidyCache
+	updateMtx    sync.Mutex
+
+	mtx     sync.RWMutex
+	heights []int64
+	times   []int64
+	columns [numColumns][]float64
+	charts  map[string]*Chart
+}
+
+// NewCache creates an empty Cache for the blocks of the given source. Update
+// must be called to load the blocks.
+func NewCache(source BlockSource, params *chaincfg.Params) *Cache {
+	return &Cache{
+		source:       source,
+		params:       params,
+		subsidyCache: blockchain.NewSubsidyCache(0, params),
+		charts:       make(map[string]*Chart),
+	}
+}
+
+// Update retrieves the blocks that are not yet cached, as well as the last few
+// cached blocks in case of a reorganization, and clears the aggregated charts.
+func (c *Cache) Update() error {
+	c.updateMtx.Lock()
+	defer c.updateMtx.Unlock()
+
+	// Keep the cached blocks below the last few, and continue the cumulative
+	// columns from the last kept block.
+	c.mtx.RLock()
+	keep := len(c.heights) - reorgDepth
+	if keep < 0 {
+		keep = 0
+	}
+	from := int64(-1)
+	var poolValue, supply, pow, pos, dev, chainWork float64
+	if keep > 0 {
+		from = c.heights[keep-1]
+		poolValue = c.columns[colPoolValue][keep-1]
+		supply = c.columns[colSupply][keep-1]
+		pow = c.columns[colSupplyPoW][keep-1]
+		pos = c.columns[colSupplyPoS][keep-1]
+		dev = c.columns[colSupplyDev][keep-1]
+		chainWork = c.columns[colChainWork][keep-1]
+	}
+	c.mtx.RUnlock()
+
+	blocks, err := c.source.ChartBlocks(from)
+	if err != nil {
+		return err
+	}
+
+	heights := make([]int64, 0, len(blocks))
+	times := make([]int64, 0, len(blocks))
+	var columns [numColumns][]float64
+	for i := range columns {
+		columns[i] = make([]float64, 0, len(blocks))
+	}
+	for _, block := range blocks {
+		poolValue += block.PoolValueChange
+		supply += lddlutil.Amount(txhelpers.BlockSubsidy(c.subsidyCache,
+			block.Height, block.Voters, c.params)).ToCoin()
+		work, stake, tax := txhelpers.BlockSubsidyParts(c.subsidyCache,
+			block.Height, block.Voters, c.params)
+		pow += lddlutil.Amount(work).ToCoin()
+		pos += lddlutil.Amount(stake).ToCoin()
+		dev += lddlutil.Amount(tax).ToCoin()
+		blockWork, _ := new(big.Float).SetInt(blockchain.CalcWork(block.Bits)).Float64()
+		chainWork += blockWork
+
+		heights = append(heights, block.Height)
+		times = append(times, block.Time)
+		row := [numColumns]float64{
+			colTicketPrice:   lddlutil.Amount(block.TicketPrice).ToCoin(),
+			colPoolSize:      float64(block.PoolSize),
+			colPoolValue:     poolValue,
+			colFees:          lddlutil.Amount(block.Fees).ToCoin(),
+			colRegularTxns:   float64(block.RegularTxns),
+			colTickets:       float64(block.FreshStake),
+			colVotes:         float64(block.Voters),
+			colRevocations:   float64(block.Revocations),
+			colSize:          float64(block.Size),
+			colSupply:        supply,
+			colSupplyPoW:     pow,
+			colSupplyPoS:     pos,
+			colSupplyDev:     dev,
+			colMissed:        float64(block.Missed),
+			colTicketsCalled: float64(uint32(block.Voters) + block.Missed),
+			colChainWork:     chainWork,
+		}
+		for i := range columns {
+			columns[i] = append(columns[i], row[i])
+		}
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.heights = append(c.heights[:keep], heights...)
+	c.times = append(c.times[:keep], times...)
+	for i := range c.columns {
+		c.columns[i] = append(c.columns[i][:keep], columns[i]...)
+	}
+	c.charts = make(map[string]*Chart)
+
+	if len(blocks) > 0 {
+		log.Debugf("Updated the chart data of %d blocks to height %d.",
+			len(blocks), blocks[len(blocks)-1].Height)
+	}
+	return nil
+}
+
+// Chart returns the named chart aggregated by the given bin. The charts are
+// aggregated once after each update.
+func (c *Cache) Chart(name, bin string) (*Chart, error) {
+	defs, ok := chartDefs[name]
+	if !ok {
+		return nil, ErrUnknownChart
+	}
+	switch bin {
+	case BinBlock, BinDay, BinWeek, BinMonth:
+	default:
+		return nil, ErrUnknownBin
+	}
+
+	key := name + "/" + bin
+	c.mtx.RLock()
+	chart, ok := c.charts[key]
+	c.mtx.RUnlock()
+	if ok {
+		return chart, nil
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if chart, ok = c.charts[key]; ok {
+		return chart, nil
+	}
+	chart = c.aggregate(name, bin, defs)
+	c.charts[key] = chart
+	return chart, nil
+}
+
+// aggregate computes a chart from the cached blocks. The mutex must be locked.
+func (c *Cache) aggregate(name, bin string, defs []seriesDef) *Chart {
+	chart := &Chart{
+		Name:   name,
+		Bin:    bin,
+		Axis:   "time",
+		Series: make([]Series, len(defs)),
+	}
+	for i, def := range defs {
+		chart.Series[i].Name = def.name
+	}
+
+	binKey := func(b int) int64 {
+		t := c.times[b]
+		switch bin {
+		case BinBlock:
+			return c.heights[b]
+		case BinWeek:
+			return t - (t-weekOffset)%weekSeconds
+		case BinMonth:
+			y, m, _ := time.Unix(t, 0).UTC().Date()
+			return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC).Unix()
+		default:
+			return t - t%daySeconds
+		}
+	}
+	if bin == BinBlock {
+		chart.Axis = "height"
+	}
+
+	// The values of the current bin are accumulated, and appended to the
+	// series when the next bin starts.
+	sums := make([]float64, len(defs))
+	denoms := make([]float64, len(defs))
+	var count int
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		for i, def := range defs {
+			value := sums[i]
+			switch def.agg {
+			case aggMean:
+				value /= float64(count)
+			case aggRatio:
+				if denoms[i] > 0 {
+					value /= denoms[i]
+				}
+			}
+			chart.Series[i].Values = append(chart.Series[i].Values, value)
+			sums[i], denoms[i] = 0, 0
+		}
+		count = 0
+	}
+
+	for b := range c.heights {
+		key := binKey(b)
+		// Block times are not strictly increasing, so a block from an earlier
+		// bin is counted in the current one.
+		if n := len(chart.X); n == 0 || key > chart.X[n-1] {
+			flush()
+			chart.X = append(chart.X, key)
+		}
+		count++
+		for i, def := range defs {
+			value := c.columns[def.column][b]
+			if def.agg == aggLast {
+				sums[i] = value
+				continue
+			}
+			sums[i] += value
+			if def.agg == aggRatio {
+				denoms[i] += c.columns[def.denom][b]
+			}
+		}
+	}
+	flush()
+	return chart
+}
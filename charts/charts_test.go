@@ -0,0 +1,107 @@
+package charts
+
+import (
+	"testing"
+
+	"github.com/Legenddigital/lddld/chaincfg"
+	"github.com/Legenddigital/lddldata/db/dbtypes"
+)
+
+type testBlockSource struct {
+	blocks []*dbtypes.ChartBlock
+}
+
+func (s *testBlockSource) ChartBlocks(height int64) ([]*dbtypes.ChartBlock, error) {
+	var blocks []*dbtypes.ChartBlock
+	for _, block := range s.blocks {
+		if block.Height > height {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+func TestCacheBins(t *testing.T) {
+	// Ten blocks, five on each of two days, which are in different weeks.
+	const sunday = 4*weekSeconds + weekOffset - daySeconds
+	source := new(testBlockSource)
+	for i := int64(0); i < 10; i++ {
+		source.blocks = append(source.blocks, &dbtypes.ChartBlock{
+			Height:          i,
+			Time:            sunday + (i/5)*daySeconds + i,
+			Size:            uint32(100 * (i + 1)),
+			PoolValueChange: 1,
+			Voters:          4,
+			Missed:          1,
+		})
+	}
+	cache := NewCache(source, &chaincfg.Params{})
+	if err := cache.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	chart, err := cache.Chart(TicketPool, BinBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chart.Axis != "height" || len(chart.X) != 10 || chart.Series[1].Values[9] != 10 {
+		t.Errorf("unexpected block chart %v", chart)
+	}
+
+	chart, err = cache.Chart(BlockSize, BinDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chart.X) != 2 || chart.X[0] != sunday || chart.X[1] != sunday+daySeconds {
+		t.Fatalf("unexpected day bins %v", chart.X)
+	}
+	if chart.Series[0].Values[0] != 300 || chart.Series[0].Values[1] != 800 {
+		t.Errorf("unexpected mean block sizes %v", chart.Series[0].Values)
+	}
+
+	chart, err = cache.Chart(MissedVotes, BinWeek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chart.X) != 2 || chart.X[1] != sunday+daySeconds ||
+		chart.Series[0].Values[0] != 5 || chart.Series[0].Values[1] != 5 {
+		t.Errorf("unexpected week chart %v", chart)
+	}
+
+	chart, err = cache.Chart(CoinSupply, BinMonth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chart.X) != 1 || chart.X[0] != sunday ||
+		len(chart.Series) != 4 {
+		t.Errorf("unexpected month chart %v", chart)
+	}
+
+	chart, err = cache.Chart(VoteParticipation, BinDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chart.Series[0].Values[0] != 0.8 || chart.Series[0].Values[1] != 0.8 {
+		t.Errorf("unexpected participation %v", chart.Series[0].Values)
+	}
+
+	// A reorganization replaces the last block, and a new block is added.
+	source.blocks[9] = &dbtypes.ChartBlock{Height: 9, Time: sunday + daySeconds + 9,
+		PoolValueChange: -1}
+	source.blocks = append(source.blocks, &dbtypes.ChartBlock{Height: 10,
+		Time: sunday + daySeconds + 10, PoolValueChange: 1})
+	if err = cache.Update(); err != nil {
+		t.Fatal(err)
+	}
+	chart, _ = cache.Chart(TicketPool, BinBlock)
+	if len(chart.X) != 11 || chart.Series[1].Values[9] != 8 || chart.Series[1].Values[10] != 9 {
+		t.Errorf("unexpected pool values after update %v", chart.Series[1].Values)
+	}
+
+	if _, err = cache.Chart("unknown", BinDay); err != ErrUnknownChart {
+		t.Errorf("expected ErrUnknownChart, got %v", err)
+	}
+	if _, err = cache.Chart(Fees, "year"); err != ErrUnknownBin {
+		t.Errorf("expected ErrUnknownBin, got %v", err)
+	}
+}
@@ -61,7 +61,7 @@ type config struct {
 	DBUser                 string `long:"dbuser" description:"DB user"`
 	DBPass                 string `long:"dbpass" description:"DB pass"`
 	DBName                 string `long:"dbname" description:"DB name"`
-	DuplicateEntryRecovery bool   `short:"r" long:"recoverfromdups" description:"Remove duplicate entries from all tables which would be prevented by the unique indexes. May be necessary to recover from an ill-timed crash."`
+	DuplicateEntryRecovery bool   `short:"r" long:"recoverfromdups" description:"Remove duplicate entries from all tables which would be prevented by the unique indexes. Only needed for a database written by a version that did not store each block in a single database transaction, after an ill-timed crash."`
 	DropDBTables           bool   `short:"D" long:"droptables" description:"Drop/delete DB tables."`
 	ForceReindex           bool   `long:"reindex" short:"R" description:"Drop indexes prior to sync and recreate after sync, with insertion conflict checks disabled in absence of constraints."`
 	AddrSpendInfoOnline    bool   `short:"a" long:"addrspends-no-batch" description:"Continually update the address table spending transaction info during rebuild (instead of full table update at end).  SLOW if doing full rebuild!"`
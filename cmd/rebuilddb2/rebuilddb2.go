@@ -122,7 +122,20 @@ func mainCore() error {
 		Pass:   cfg.DBPass,
 		DBName: cfg.DBName,
 	}
-	// Construct a ChainDB without a stakeDB to allow quick dropping of tables.
+	// Drop the tables before constructing a ChainDB, which creates them and
+	// applies the schema migrations.
+	if cfg.DropDBTables {
+		var pgDB *sql.DB
+		pgDB, err = lddlpg.Connect(dbi.Host, dbi.Port, dbi.User, dbi.Pass, dbi.DBName)
+		if err != nil {
+			return err
+		}
+		defer pgDB.Close()
+		lddlpg.DropTables(pgDB)
+		return nil
+	}
+
+	// Construct a ChainDB without a stakeDB, which is loaded next.
 	db, err := lddlpg.NewChainDB(&dbi, activeChain, nil, false)
 	if db != nil {
 		defer db.Close()
@@ -131,11 +144,6 @@ func mainCore() error {
 		return err
 	}
 
-	if cfg.DropDBTables {
-		db.DropTables()
-		return nil
-	}
-
 	// Create/load stake database (which includes the separate ticket pool DB).
 	stakeDB, _, err := stakedb.NewStakeDatabase(client, activeChain, "rebuild_data")
 	if err != nil {
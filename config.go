@@ -7,6 +7,7 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -48,6 +49,12 @@ var (
 	defaultAPIListen          = "127.0.0.1:7777"
 	defaultIndentJSON         = "   "
 	defaultCacheControlMaxAge = 86400
+	defaultGraphQLMaxCost     = 5000
+	defaultRateLimitPage      = "sample-rate_limiting.html"
+	defaultWatchMaxAttempts   = 8
+	defaultSMTPTLS            = "auto"
+	defaultSMTPPort           = "587"
+	defaultEmailSubject       = "lddldata transaction notification"
 
 	defaultMonitorMempool     = true
 	defaultMempoolMinInterval = 2
@@ -85,6 +92,15 @@ type config struct {
 	IndentJSON         string `long:"indentjson" description:"String for JSON indentation (default is \"   \"), when indentation is requested via URL query."`
 	UseRealIP          bool   `long:"userealip" description:"Use the RealIP middleware from the pressly/chi/middleware package to get the client's real IP from the X-Forwarded-For or X-Real-IP headers, in that order."`
 	CacheControlMaxAge int    `long:"cachecontrol-maxage" description:"Set CacheControl in the HTTP response header to a value in seconds for clients to cache the response. This applies only to FileServer routes."`
+	PrintAPIDirectory  bool   `long:"print-api-directory" description:"Print the OpenAPI document describing the API and Insight API, and exit."`
+	GraphQLMaxCost     int    `long:"graphql-maxcost" description:"Maximum estimated cost of a query to the /graphql endpoint. A query costs one for each object it requests, multiplied by the lengths of the enclosing lists."`
+
+	// Rate limiting
+	RateLimit        float64  `long:"ratelimit" description:"Sustained requests per second allowed from each client IP for the API, Insight API and explorer pages (default 0, no limit). Route groups and API keys may have their own limits."`
+	RateLimitBurst   int      `long:"ratelimit-burst" description:"Number of requests a client may make in a burst before --ratelimit applies (default is the rate rounded up)."`
+	RateLimitRoutes  []string `long:"ratelimit-route" description:"Rate limit for a route group as path:rate[:burst]. Path segments may be * wildcards, and paths below the pattern are included (e.g. /api/address/*/raw:0.5:2). The first matching group applies. May be specified multiple times."`
+	RateLimitAPIKeys []string `long:"ratelimit-apikey" description:"API key with its own rate limit as key:rate[:burst]. Clients present the key in the X-API-Key header or the apikey URL query parameter, and are then not limited by IP. May be specified multiple times."`
+	RateLimitPage    string   `long:"ratelimit-page" description:"HTML page served with status 429 to browsers that exceed a rate limit."`
 
 	// Data I/O
 	MonitorMempool     bool   `short:"m" long:"mempool" description:"Monitor mempool for new transactions, and report ticketfee info when new tickets are added."`
@@ -101,13 +117,23 @@ type config struct {
 	PGHost        string `long:"pghost" description:"PostgreSQL server host:port or UNIX socket (e.g. /run/postgresql)."`
 	NoDevPrefetch bool   `long:"no-dev-prefetch" description:"Disable automatic dev fund balance query on new blocks. When true, the query will still be run on demand, but not automatically after new blocks are connected."`
 	SyncAndQuit   bool   `long:"sync-and-quit" description:"Sync to the best block and exit. Do not start the explorer or API."`
-
-	// WatchAddresses []string `short:"w" long:"watchaddress" description:"Watched address (receiving). One per line."`
-	// SMTPUser     string `long:"smtpuser" description:"SMTP user name"`
-	// SMTPPass     string `long:"smtppass" description:"SMTP password"`
-	// SMTPServer   string `long:"smtpserver" description:"SMTP host name"`
-	// EmailAddr    string `long:"emailaddr" description:"Destination email address for alerts"`
-	// EmailSubject string `long:"emailsubj" description:"Email subject. (default \"lddldataapi transaction notification\")"`
+	MigrateOnly   bool   `long:"migrate-only" description:"Apply the pending PostgreSQL schema migrations, including their data backfills, and exit."`
+
+	// Watched addresses
+	WatchAddresses     []string `short:"w" long:"watchaddress" description:"Address to watch for received and spent funds. May be specified multiple times."`
+	WatchWebhook       string   `long:"watchwebhook" description:"URL to which watched address events are POSTed as JSON, unless a watch has its own webhook URL."`
+	WatchWebhookSecret string   `long:"watchwebhooksecret" description:"Secret for the HMAC-SHA256 signature of webhook payloads, sent in the X-Lddldata-Signature header."`
+	WatchAPIKey        string   `long:"watchapikey" description:"API key required in the X-API-Key header to manage the watch list via /api/watch. The endpoints are disabled if not set."`
+	WatchMaxAttempts   int      `long:"watchmaxattempts" description:"Number of attempts to deliver a webhook before giving up."`
+
+	// Email alerts for watched addresses
+	SMTPUser     string `long:"smtpuser" description:"SMTP user name"`
+	SMTPPass     string `long:"smtppass" description:"SMTP password"`
+	SMTPServer   string `long:"smtpserver" description:"SMTP host name, with optional port (default port 587). Email alerts are enabled if this and emailaddr are set."`
+	SMTPTLS      string `long:"smtptls" description:"SMTP connection security: auto (TLS on port 465, otherwise STARTTLS if available), tls, starttls, or none."`
+	EmailAddr    string `long:"emailaddr" description:"Destination email address for alerts. Separate multiple addresses with commas."`
+	EmailFrom    string `long:"emailfrom" description:"Sender email address for alerts (default is the first emailaddr)"`
+	EmailSubject string `long:"emailsubj" description:"Email subject, to which the address is appended. (default \"lddldata transaction notification\")"`
 
 	// RPC client options
 	LddldUser        string `long:"lddlduser" description:"Daemon RPC user name"`
@@ -130,6 +156,11 @@ var (
 		APIListen:          defaultAPIListen,
 		IndentJSON:         defaultIndentJSON,
 		CacheControlMaxAge: defaultCacheControlMaxAge,
+		GraphQLMaxCost:     defaultGraphQLMaxCost,
+		RateLimitPage:      defaultRateLimitPage,
+		WatchMaxAttempts:   defaultWatchMaxAttempts,
+		SMTPTLS:            defaultSMTPTLS,
+		EmailSubject:       defaultEmailSubject,
 		LddldCert:          defaultDaemonRPCCertFile,
 		MonitorMempool:     defaultMonitorMempool,
 		MempoolMinInterval: defaultMempoolMinInterval,
@@ -298,6 +329,16 @@ func loadConfig() (*config, error) {
 		os.Exit(0)
 	}
 
+	// Print the API description and exit if requested. This does not need the
+	// config file, a node or databases.
+	if preCfg.PrintAPIDirectory {
+		if err = printAPIDirectory(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to print the API directory: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Load additional config from file.
 	var configFileError error
 	// Config file name for logging.
@@ -437,6 +478,29 @@ func loadConfig() (*config, error) {
 		return loadConfigError(fmt.Errorf("httpprofprefix must not be \"\" or \"/\""))
 	}
 
+	if cfg.RateLimit < 0 || cfg.RateLimitBurst < 0 {
+		return loadConfigError(fmt.Errorf("ratelimit and ratelimit-burst must not be negative"))
+	}
+
+	if cfg.WatchMaxAttempts < 1 {
+		return loadConfigError(fmt.Errorf("watchmaxattempts must be at least 1"))
+	}
+
+	// Email alerts need both a server and a destination.
+	if (cfg.SMTPServer == "") != (cfg.EmailAddr == "") {
+		return loadConfigError(fmt.Errorf("smtpserver and emailaddr must both be set for email alerts"))
+	}
+	if cfg.SMTPServer != "" {
+		if _, _, err := net.SplitHostPort(cfg.SMTPServer); err != nil {
+			cfg.SMTPServer = net.JoinHostPort(cfg.SMTPServer, defaultSMTPPort)
+		}
+	}
+	switch cfg.SMTPTLS {
+	case "auto", "tls", "starttls", "none":
+	default:
+		return loadConfigError(fmt.Errorf("smtptls must be one of auto, tls, starttls, or none"))
+	}
+
 	// Parse, validate, and set debug log level(s).
 	if cfg.Quiet {
 		cfg.DebugLevel = "error"
@@ -260,6 +260,34 @@ type AddressRow struct {
 	VinDbID            uint64
 }
 
+// VoteBitsCount is the number of votes with the same vote bits in a main chain
+// block.
+type VoteBitsCount struct {
+	Height    int64
+	BlockTime int64
+	VoteBits  uint16
+	Count     int64
+}
+
+// ChartBlock holds the data of a main chain block for the historical charts.
+// TicketPrice and Fees are in atoms, while PoolValueChange, the change in the
+// value of the live ticket pool at the block, is in coins.
+type ChartBlock struct {
+	Height          int64
+	Time            int64
+	Size            uint32
+	TicketPrice     int64
+	PoolSize        uint32
+	PoolValueChange float64
+	Fees            int64
+	Bits            uint32
+	Voters          uint16
+	FreshStake      uint8
+	Revocations     uint8
+	RegularTxns     uint32
+	Missed          uint32
+}
+
 // ScriptPubKeyData is part of the result of decodescript(ScriptPubKeyHex)
 type ScriptPubKeyData struct {
 	ReqSigs   uint32   `json:"reqSigs"`
@@ -0,0 +1,256 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package lddlpg
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Legenddigital/lddld/chaincfg/chainhash"
+	"github.com/Legenddigital/lddld/rpcclient"
+)
+
+// ReorgData contains the information from a reoranization notification
+type ReorgData struct {
+	OldChainHead   chainhash.Hash
+	OldChainHeight int32
+	NewChainHead   chainhash.Hash
+	NewChainHeight int32
+	WG             *sync.WaitGroup
+}
+
+// ChainMonitor responds to block connection and chain reorganization
+// notifications for the ChainDB. Outside of a reorganization, new blocks are
+// stored by the ChainDB's Store method (as a blockdata.BlockDataSaver).
+type ChainMonitor struct {
+	db             *ChainDB
+	client         *rpcclient.Client
+	quit           chan struct{}
+	wg             *sync.WaitGroup
+	blockChan      chan *chainhash.Hash
+	reorgChan      chan *ReorgData
+	ConnectingLock chan struct{}
+	DoneConnecting chan struct{}
+	syncConnect    sync.Mutex
+
+	// reorg handling
+	reorgLock    sync.Mutex
+	reorgData    *ReorgData
+	sideChain    []chainhash.Hash
+	reorganizing bool
+}
+
+// NewChainMonitor creates a new ChainMonitor
+func (db *ChainDB) NewChainMonitor(client *rpcclient.Client, quit chan struct{},
+	wg *sync.WaitGroup, blockChan chan *chainhash.Hash,
+	reorgChan chan *ReorgData) *ChainMonitor {
+	if db == nil {
+		return nil
+	}
+	return &ChainMonitor{
+		db:             db,
+		client:         client,
+		quit:           quit,
+		wg:             wg,
+		blockChan:      blockChan,
+		reorgChan:      reorgChan,
+		ConnectingLock: make(chan struct{}, 1),
+		DoneConnecting: make(chan struct{}),
+	}
+}
+
+// BlockConnectedSync is the synchronous (blocking call) handler for the newly
+// connected block given by the hash.
+func (p *ChainMonitor) BlockConnectedSync(hash *chainhash.Hash) {
+	// Connections go one at a time so signals cannot be mixed
+	p.syncConnect.Lock()
+	defer p.syncConnect.Unlock()
+	// lock with buffered channel
+	p.ConnectingLock <- struct{}{}
+	p.blockChan <- hash
+	// wait
+	<-p.DoneConnecting
+}
+
+// BlockConnectedHandler handles block connected notifications, which helps deal
+// with a chain reorganization.
+func (p *ChainMonitor) BlockConnectedHandler() {
+	defer p.wg.Done()
+out:
+	for {
+	keepon:
+		select {
+		case hash, ok := <-p.blockChan:
+			release := func() {}
+			select {
+			case <-p.ConnectingLock:
+				// send on unbuffered channel
+				release = func() { p.DoneConnecting <- struct{}{} }
+			default:
+			}
+
+			if !ok {
+				log.Warnf("Block connected channel closed.")
+				release()
+				break out
+			}
+
+			// If reorganizing, the block will first go to a side chain
+			p.reorgLock.Lock()
+			reorg, reorgData := p.reorganizing, p.reorgData
+			p.reorgLock.Unlock()
+
+			if reorg {
+				// As with lddlsqlite, stakedb must switch to the complete side
+				// chain before the blocks can be stored since the winning
+				// tickets for each block come from its PoolInfoCache.
+				p.sideChain = append(p.sideChain, *hash)
+				log.Infof("Adding block hash %v to PostgreSQL sidechain", *hash)
+
+				// Just append to side chain until the new main chain tip block is reached
+				if !reorgData.NewChainHead.IsEqual(hash) {
+					release()
+					break keepon
+				}
+
+				// Once all blocks in side chain are lined up, switch over
+				newHeight, newHash, err := p.switchToSideChain()
+				if err == nil && (!p.reorgData.NewChainHead.IsEqual(newHash) ||
+					p.reorgData.NewChainHeight != newHeight) {
+					err = fmt.Errorf("got to %v (height %d) instead", newHash, newHeight)
+				}
+
+				// The reorg is over, successful or not
+				p.sideChain = nil
+				p.reorgLock.Lock()
+				p.reorganizing = false
+				p.reorgLock.Unlock()
+				if err != nil {
+					log.Errorf("Failed to reorg to %v in PostgreSQL: %v",
+						p.reorgData.NewChainHead, err)
+					release()
+					break keepon
+				}
+				log.Infof("Reorganization to block %v (height %d) complete in PostgreSQL",
+					p.reorgData.NewChainHead, p.reorgData.NewChainHeight)
+			}
+			release()
+
+		case _, ok := <-p.quit:
+			if !ok {
+				log.Debugf("Got quit signal. Exiting block connected handler.")
+				break out
+			}
+		}
+	}
+
+}
+
+// switchToSideChain attempts to switch to a new side chain by: determining a
+// common ancestor block, moving the main chain blocks above this block to a side
+// chain, and storing the side chain blocks as the new main chain.
+func (p *ChainMonitor) switchToSideChain() (int32, *chainhash.Hash, error) {
+	if len(p.sideChain) == 0 {
+		return 0, nil, fmt.Errorf("no side chain")
+	}
+
+	// Determine highest common ancestor of side chain and main chain
+	msgBlock, err := p.client.GetBlock(&p.sideChain[0])
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to get block at root of side chain")
+	}
+	mainRoot := msgBlock.Header.PrevBlock
+
+	// Undo the data from the main chain blocks back to the common ancestor
+	orphaned, err := p.db.TipToSideChain(mainRoot.String())
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to move main chain tip to side chain: %v", err)
+	}
+	log.Infof("Moved %d blocks from main chain to side chain in PostgreSQL.",
+		len(orphaned))
+
+	// Transactions from the orphaned blocks may be mined again in the side
+	// chain blocks. StoreBlock checks for duplicates, as they are enabled at
+	// the end of SyncChainDB, before the reorg handler is started.
+
+	// Store blocks in side chain as the new main chain
+	log.Infof("Saving %d new blocks from previous side chain to PostgreSQL",
+		len(p.sideChain))
+	var height int32
+	var hash *chainhash.Hash
+	for i := range p.sideChain {
+		msgBlock, err = p.client.GetBlock(&p.sideChain[i])
+		if err != nil {
+			return height, hash, fmt.Errorf("unable to get side chain block %v: %v",
+				p.sideChain[i], err)
+		}
+
+		// Winning tickets from stakedb, which has already switched to the side
+		// chain, storing pool info for each of its blocks.
+		tpi, found := p.db.stakeDB.PoolInfo(p.sideChain[i])
+		if !found {
+			return height, hash, fmt.Errorf("stakedb.PoolInfo failed for block %v",
+				p.sideChain[i])
+		}
+
+		_, _, err = p.db.StoreBlock(msgBlock, tpi.Winners, true, true, true)
+		if err != nil {
+			return height, hash, fmt.Errorf("failed to store side chain block %v: %v",
+				p.sideChain[i], err)
+		}
+		height, hash = int32(msgBlock.Header.Height), &p.sideChain[i]
+		log.Infof("Stored block %v (height %d) from side chain.", hash, height)
+	}
+
+	return height, hash, nil
+}
+
+// ReorgHandler receives notification of a chain reorganization and initiates a
+// corresponding reorganization of the PostgreSQL tables.
+func (p *ChainMonitor) ReorgHandler() {
+	defer p.wg.Done()
+out:
+	for {
+	keepon:
+		select {
+		case reorgData, ok := <-p.reorgChan:
+			if !ok {
+				log.Warnf("Reorg channel closed.")
+				break out
+			}
+
+			newHeight, oldHeight := reorgData.NewChainHeight, reorgData.OldChainHeight
+			newHash, oldHash := reorgData.NewChainHead, reorgData.OldChainHead
+
+			p.reorgLock.Lock()
+			if p.reorganizing {
+				p.reorgLock.Unlock()
+				log.Errorf("Reorg notified for chain tip %v (height %v), but already "+
+					"processing a reorg to block %v", newHash, newHeight,
+					p.reorgData.NewChainHead)
+				break keepon
+			}
+
+			// Set the reorg flag so that when BlockConnectedHandler gets called
+			// for the side chain blocks, it knows to store them as main chain
+			// blocks once the new tip is reached.
+			p.reorganizing = true
+			p.reorgData = reorgData
+			p.reorgLock.Unlock()
+
+			log.Infof("Reorganize started in PostgreSQL. NEW head block %v at height %d.",
+				newHash, newHeight)
+			log.Infof("Reorganize started in PostgreSQL. OLD head block %v at height %d.",
+				oldHash, oldHeight)
+
+			reorgData.WG.Done()
+
+		case _, ok := <-p.quit:
+			if !ok {
+				log.Debugf("Got quit signal. Exiting reorg notification handler.")
+				break out
+			}
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package internal
 
+import "github.com/lib/pq"
+
 const (
 	insertAddressRow0 = `INSERT INTO addresses (address, funding_tx_row_id,
 		funding_tx_hash, funding_tx_vout_index, vout_row_id, value)
-		VALUES ($1, $2, $3, $4, $5, $6) `
+		VALUES ($1, $2, decode($3, 'hex'), $4, $5, $6) `
 	InsertAddressRow = insertAddressRow0 + `RETURNING id;`
 	// InsertAddressRowChecked = insertAddressRow0 +
 	// 	`ON CONFLICT (vout_row_id, address) DO NOTHING RETURNING id;`
@@ -24,7 +26,8 @@ const (
 	insertAddressRowFull = `INSERT INTO addresses (address, funding_tx_row_id, funding_tx_hash,
 		funding_tx_vout_index, vout_row_id, value, spending_tx_row_id, 
 		spending_tx_hash, spending_tx_vin_index, vin_row_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) `
+		VALUES ($1, $2, decode($3, 'hex'), $4, $5, $6, $7,
+			decode($8, 'hex'), $9, $10) `
 
 	// SelectSpendingTxsByPrevTx = `SELECT id, tx_hash, tx_index, prev_tx_index FROM vins WHERE prev_tx_hash=$1;`
 	// SelectSpendingTxByPrevOut = `SELECT id, tx_hash, tx_index FROM vins WHERE prev_tx_hash=$1 AND prev_tx_index=$2;`
@@ -35,37 +38,45 @@ const (
 		id SERIAL8 PRIMARY KEY,
 		address TEXT,
 		funding_tx_row_id INT8,
-		funding_tx_hash TEXT,
+		funding_tx_hash BYTEA,
 		funding_tx_vout_index INT8,
 		vout_row_id INT8,
 		value INT8,
 		spending_tx_row_id INT8,
-		spending_tx_hash TEXT,
+		spending_tx_hash BYTEA,
 		spending_tx_vin_index INT4,
 		vin_row_id INT8
 	);`
 
-	SelectAddressAllByAddress = `SELECT * FROM addresses WHERE address=$1 order by id desc;`
-	SelectAddressRecvCount    = `SELECT COUNT(*) FROM addresses WHERE address=$1;`
-	SelectAddressesAllTxn     = `WITH these as (SELECT funding_tx_hash as tx_hash, ftxd.time as tx_time, ftxd.block_height as height
+	// selectAddressColumns are the columns of the addresses table, with the
+	// hashes as hex strings.
+	selectAddressColumns = `id, address, funding_tx_row_id,
+		encode(funding_tx_hash, 'hex'), funding_tx_vout_index, vout_row_id, value,
+		spending_tx_row_id, encode(spending_tx_hash, 'hex'), spending_tx_vin_index,
+		vin_row_id`
+
+	SelectAddressAllByAddress = `SELECT ` + selectAddressColumns +
+		` FROM addresses WHERE address=$1 order by id desc;`
+	SelectAddressRecvCount = `SELECT COUNT(*) FROM addresses WHERE address=$1;`
+	SelectAddressesAllTxn  = `WITH these as (SELECT funding_tx_hash as tx_hash, ftxd.time as tx_time, ftxd.block_height as height
 		from addresses left join transactions as ftxd on funding_tx_row_id=ftxd.id
 		where address = ANY($1)
 		UNION
 		SELECT DISTINCT spending_tx_hash as tx_hash, stxd.time as tx_time, stxd.block_height as height from addresses  
 		left join transactions as stxd on spending_tx_hash=stxd.tx_hash  
-		where address = ANY($1) and spending_tx_hash IS NOT NULL) select tx_hash, height from these order by tx_time desc;`
+		where address = ANY($1) and spending_tx_hash IS NOT NULL) select encode(tx_hash, 'hex'), height from these order by tx_time desc;`
 
-	SelectAddressesTxnByFundingTx = `SELECT funding_tx_vout_index, spending_tx_hash, spending_tx_vin_index, 
+	SelectAddressesTxnByFundingTx = `SELECT funding_tx_vout_index, encode(spending_tx_hash, 'hex'), spending_tx_vin_index, 
 		block_height FROM addresses LEFT JOIN 
 		transactions on transactions.tx_hash=spending_tx_hash WHERE 
-		address = ANY ($1) and funding_tx_hash=$2;`
+		address = ANY ($1) and funding_tx_hash=decode($2, 'hex');`
 
 	SelectAddressUnspentCountAndValue = `SELECT COUNT(*), SUM(value) FROM addresses WHERE address=$1 and spending_tx_row_id IS NULL;`
 	SelectAddressSpentCountAndValue   = `SELECT COUNT(*), SUM(value) FROM addresses WHERE address=$1 and spending_tx_row_id IS NOT NULL;`
 
 	SelectAddressUnspentWithTxn = `SELECT
 									addresses.address,
-									addresses.funding_tx_hash,
+									encode(addresses.funding_tx_hash, 'hex'),
 									addresses.value,
 									transactions.block_height,
 									block_time,
@@ -80,26 +91,80 @@ const (
 									AND 
 									addresses.spending_tx_row_id IS NULL order by block_height desc`
 
-	SelectAddressLimitNByAddress = `SELECT * FROM addresses WHERE address=$1 order by id desc limit $2 offset $3;`
+	// SelectAddressUTXOs selects the unspent outputs of an address mined at or
+	// below a height ($2), with values in a range ($3, $4), and optionally
+	// from one transaction tree ($5, or -1 for both).
+	SelectAddressUTXOs = `SELECT encode(addresses.funding_tx_hash, 'hex'), addresses.funding_tx_vout_index,
+			addresses.value, transactions.tree, encode(transactions.block_hash, 'hex'),
+			transactions.block_height, transactions.block_time, vouts.pkscript
+		FROM addresses
+		JOIN transactions ON addresses.funding_tx_row_id = transactions.id
+		JOIN vouts ON addresses.vout_row_id = vouts.id
+		WHERE addresses.address = $1
+			AND addresses.spending_tx_row_id IS NULL
+			AND transactions.block_height <= $2
+			AND addresses.value >= $3 AND addresses.value <= $4
+			AND ($5 < 0 OR transactions.tree = $5)
+		ORDER BY transactions.block_height DESC, addresses.id DESC;`
+
+	// SelectAddressBalanceAtHeight selects the number and value of the
+	// outputs of an address mined at or below a height ($2) and not spent by
+	// a transaction mined at or below it.
+	SelectAddressBalanceAtHeight = `SELECT COUNT(*), COALESCE(SUM(addresses.value), 0)
+		FROM addresses
+		JOIN transactions AS ftx ON addresses.funding_tx_row_id = ftx.id
+		LEFT JOIN transactions AS stx ON addresses.spending_tx_hash = stx.tx_hash
+		WHERE addresses.address = $1
+			AND ftx.block_height <= $2
+			AND (stx.id IS NULL OR stx.block_height > $2);`
+
+	// SelectAddressesMergedTxns selects the transactions funding or spending
+	// any of a set of addresses ($1), most recent first, with the amounts
+	// received and sent by the addresses and the total number of transactions.
+	// Each addresses row is read once, as its funding and spending transaction.
+	SelectAddressesMergedTxns = `SELECT encode(io.tx_hash, 'hex'), transactions.block_height, transactions.time,
+			SUM(io.received), SUM(io.sent), array_agg(DISTINCT addresses.address),
+			COUNT(*) OVER ()
+		FROM addresses
+		CROSS JOIN LATERAL (VALUES
+			(addresses.funding_tx_hash, addresses.value, 0::INT8),
+			(addresses.spending_tx_hash, 0::INT8, addresses.value)
+		) AS io (tx_hash, received, sent)
+		JOIN transactions ON transactions.tx_hash = io.tx_hash
+		WHERE addresses.address = ANY($1) AND io.tx_hash IS NOT NULL
+		GROUP BY io.tx_hash, transactions.block_height, transactions.time
+		ORDER BY transactions.time DESC, io.tx_hash
+		LIMIT $2 OFFSET $3;`
+
+	// SelectAddressesTotals selects the number and value of all and of the
+	// spent outputs of each of a set of addresses.
+	SelectAddressesTotals = `SELECT address, COUNT(*), COUNT(spending_tx_row_id),
+			SUM(value), SUM(CASE WHEN spending_tx_row_id IS NULL THEN 0 ELSE value END)
+		FROM addresses
+		WHERE address = ANY($1)
+		GROUP BY address;`
+
+	SelectAddressLimitNByAddress = `SELECT ` + selectAddressColumns +
+		` FROM addresses WHERE address=$1 order by id desc limit $2 offset $3;`
 
 	SelectAddressLimitNByAddressSubQry = `WITH these as (SELECT * FROM addresses WHERE address=$1)
-		SELECT * FROM these order by id desc limit $2 offset $3;`
+		SELECT ` + selectAddressColumns + ` FROM these order by id desc limit $2 offset $3;`
 
 	// SelectAddressDebitsLimitNByAddress = `SELECT *
 	// 	FROM addresses
 	// 	WHERE address=$1 AND spending_tx_row_id IS NOT NULL
 	// 	ORDER BY id DESC LIMIT $2 OFFSET $3;`
 	SelectAddressDebitsLimitNByAddress = `WITH these as (SELECT * FROM addresses WHERE address=$1)
-		SELECT * FROM these WHERE spending_tx_row_id IS NOT NULL
+		SELECT ` + selectAddressColumns + ` FROM these WHERE spending_tx_row_id IS NOT NULL
 		ORDER BY id DESC LIMIT $2 OFFSET $3;`
-	SelectAddressCreditsLimitNByAddress = `SELECT id, funding_tx_row_id, funding_tx_hash, funding_tx_vout_index, vout_row_id, value
+	SelectAddressCreditsLimitNByAddress = `SELECT id, funding_tx_row_id, encode(funding_tx_hash, 'hex'), funding_tx_vout_index, vout_row_id, value
 		FROM addresses
 		WHERE address=$1
 		ORDER BY id DESC LIMIT $2 OFFSET $3;`
 
 	// Update Vin due to LDDLD AMOUNTIN - START
 	SelectAddressIDsByFundingOutpoint = `SELECT id, address, value FROM addresses
-		WHERE funding_tx_hash=$1 and funding_tx_vout_index=$2;`
+		WHERE funding_tx_hash=decode($1, 'hex') and funding_tx_vout_index=$2;`
 	// Update Vin due to LDDLD AMOUNTIN - END
 
 	SelectAddressIDByVoutIDAddress = `SELECT id FROM addresses
@@ -107,11 +172,18 @@ const (
 		ORDER BY id DESC;`
 
 	SetAddressSpendingForID = `UPDATE addresses SET spending_tx_row_id = $2, 
-		spending_tx_hash = $3, spending_tx_vin_index = $4, vin_row_id = $5 
+		spending_tx_hash = decode($3, 'hex'), spending_tx_vin_index = $4, vin_row_id = $5 
 		WHERE id=$1;`
 	SetAddressSpendingForOutpoint = `UPDATE addresses SET spending_tx_row_id = $3, 
-		spending_tx_hash = $4, spending_tx_vin_index = $5, vin_row_id = $6 
-		WHERE funding_tx_hash=$1 and funding_tx_vout_index=$2;`
+		spending_tx_hash = decode($4, 'hex'), spending_tx_vin_index = $5, vin_row_id = $6 
+		WHERE funding_tx_hash=decode($1, 'hex') and funding_tx_vout_index=$2;`
+
+	// Reorg rollback
+	UnsetAddressSpendingForTxHashes = `UPDATE addresses SET spending_tx_row_id = NULL,
+		spending_tx_hash = NULL, spending_tx_vin_index = NULL, vin_row_id = NULL
+		WHERE spending_tx_hash IN (SELECT decode(unnest($1::TEXT[]), 'hex'));`
+	DeleteAddressRowsForFundingTxDbIDs = `DELETE FROM addresses
+		WHERE funding_tx_row_id = ANY($1);`
 
 	IndexAddressTableOnAddress = `CREATE INDEX uix_addresses_address
 		ON addresses(address);`
@@ -126,6 +198,16 @@ const (
 	DeindexAddressTableOnFundingTx = `DROP INDEX uix_addresses_funding_tx;`
 )
 
+var addressRowCopyStmt = pq.CopyIn("addresses", "id", "address",
+	"funding_tx_row_id", "funding_tx_hash", "funding_tx_vout_index",
+	"vout_row_id", "value")
+
+// MakeAddressRowCopyInStatement returns the COPY statement for bulk loading
+// the addresses table without spending info, with the row IDs set explicitly.
+func MakeAddressRowCopyInStatement() string {
+	return addressRowCopyStmt
+}
+
 func MakeAddressRowInsertStatement(checked bool) string {
 	if checked {
 		return UpsertAddressRow
@@ -1,28 +1,22 @@
 package internal
 
-import (
-	"fmt"
-
-	"github.com/Legenddigital/lddldata/db/dbtypes"
-)
-
 const (
 	// Block insert
 	insertBlockRow0 = `INSERT INTO blocks (
 		hash, height, size, is_valid, version, merkle_root, stake_root,
-		numtx, num_rtx, tx, txDbIDs, num_stx, stx, stxDbIDs,
+		numtx, num_rtx, num_stx,
 		time, nonce, vote_bits, final_state, voters,
 		fresh_stake, revocations, pool_size, bits, sbits, 
-		difficulty, extra_data, stake_version, previous_hash)
-	VALUES ($1, $2, $3, $4, $5, $6, $7,
-		$8, $9, %s, %s, $10, %s, %s,
+		difficulty, extra_data, stake_version, previous_hash, is_mainchain)
+	VALUES (decode($1, 'hex'), $2, $3, $4, $5, decode($6, 'hex'), decode($7, 'hex'),
+		$8, $9, $10,
 		$11, $12, $13, $14, $15, 
 		$16, $17, $18, $19, $20,
-		$21, $22, $23, $24) `
+		$21, $22, $23, decode($24, 'hex'), $25) `
 	insertBlockRow = insertBlockRow0 + `RETURNING id;`
 	// insertBlockRowChecked  = insertBlockRow0 + `ON CONFLICT (hash) DO NOTHING RETURNING id;`
 	upsertBlockRow = insertBlockRow0 + `ON CONFLICT (hash) DO UPDATE 
-		SET hash = $1 RETURNING id;`
+		SET is_mainchain = $25 RETURNING id;`
 	insertBlockRowReturnId = `WITH ins AS (` +
 		insertBlockRow0 +
 		`ON CONFLICT (hash) DO UPDATE
@@ -32,34 +26,51 @@ const (
 	SELECT id FROM ins
 	UNION  ALL
 	SELECT id FROM blocks
-	WHERE  hash = $1
+	WHERE  hash = decode($1, 'hex')
 	LIMIT  1;`
 
 	UpdateLastBlockValid = `UPDATE blocks SET is_valid = $2 WHERE id = $1;`
 
-	SelectBlockByTimeRangeSQL = `SELECT hash, height, size, time, numtx
-		FROM blocks WHERE time BETWEEN $1 and $2 ORDER BY time DESC LIMIT $3;`
-	SelectBlockByTimeRangeSQLNoLimit = `SELECT hash, height, size, time, numtx
-		FROM blocks WHERE time BETWEEN $1 and $2 ORDER BY time DESC;`
-	SelectBlockHashByHeight = `SELECT hash FROM blocks WHERE height = $1;`
-	SelectBlockHeightByHash = `SELECT height FROM blocks WHERE hash = $1;`
+	// SetBlockValidForRemovedChild sets the block with hash $1 valid again if
+	// it was disapproved by the votes in its child, which is among the blocks
+	// with hashes $2 being removed from the main chain.
+	SetBlockValidForRemovedChild = `UPDATE blocks SET is_valid = TRUE
+		WHERE hash = decode($1, 'hex') AND EXISTS (SELECT 1 FROM blocks AS child
+			WHERE child.previous_hash = decode($1, 'hex')
+				AND child.hash IN (SELECT decode(unnest($2::TEXT[]), 'hex'))
+				AND child.vote_bits & 1 = 0);`
+
+	// UpdateBlocksMainchainByHashes flags the blocks with the given hashes as
+	// being on (or off of) the main chain.
+	UpdateBlocksMainchainByHashes = `UPDATE blocks SET is_mainchain = $2
+		WHERE hash IN (SELECT decode(unnest($1::TEXT[]), 'hex'));`
+
+	SelectBlockByTimeRangeSQL = `SELECT encode(hash, 'hex'), height, size, time, numtx
+		FROM blocks WHERE time BETWEEN $1 and $2 AND is_mainchain
+		ORDER BY time DESC LIMIT $3;`
+	SelectBlockByTimeRangeSQLNoLimit = `SELECT encode(hash, 'hex'), height, size, time, numtx
+		FROM blocks WHERE time BETWEEN $1 and $2 AND is_mainchain
+		ORDER BY time DESC;`
+	SelectBlockHashByHeight = `SELECT encode(hash, 'hex') FROM blocks WHERE height = $1 AND is_mainchain;`
+	SelectBlockHeightByHash = `SELECT height FROM blocks WHERE hash = decode($1, 'hex');`
+
+	// SelectMainchainBlocksAboveHeight lists the main chain blocks with height
+	// greater than the given height, in order of increasing height.
+	SelectMainchainBlocksAboveHeight = `SELECT encode(hash, 'hex'), height FROM blocks
+		WHERE height > $1 AND is_mainchain ORDER BY height;`
 
 	CreateBlockTable = `CREATE TABLE IF NOT EXISTS blocks (  
 		id SERIAL PRIMARY KEY,
-		hash TEXT NOT NULL, -- UNIQUE
+		hash BYTEA NOT NULL, -- UNIQUE
 		height INT4,
 		size INT4,
 		is_valid BOOLEAN,
 		version INT4,
-		merkle_root TEXT,
-		stake_root TEXT,
+		merkle_root BYTEA,
+		stake_root BYTEA,
 		numtx INT4,
 		num_rtx INT4,
-		tx TEXT[],
-		txDbIDs INT8[],
 		num_stx INT4,
-		stx TEXT[],
-		stxDbIDs INT8[],
 		time INT8,
 		nonce INT8,
 		vote_bits INT2,
@@ -73,7 +84,8 @@ const (
 		difficulty FLOAT8,
 		extra_data BYTEA,
 		stake_version INT4,
-		previous_hash TEXT
+		previous_hash BYTEA,
+		is_mainchain BOOLEAN
 	);`
 
 	IndexBlockTableOnHash = `CREATE UNIQUE INDEX uix_block_hash
@@ -81,43 +93,101 @@ const (
 	DeindexBlockTableOnHash = `DROP INDEX uix_block_hash;`
 
 	RetrieveBestBlock       = `SELECT * FROM blocks ORDER BY height DESC LIMIT 0, 1;`
-	RetrieveBestBlockHeight = `SELECT id, hash, height FROM blocks ORDER BY height DESC LIMIT 1;`
+	RetrieveBestBlockHeight = `SELECT id, encode(hash, 'hex'), height FROM blocks
+		WHERE is_mainchain ORDER BY height DESC LIMIT 1;`
+
+	// AddBlocksMainchainColumn is the blocks table upgrade from v2.0.0, which
+	// had no is_mainchain column. All existing rows are assumed to be on the
+	// main chain since reorganizations were not previously handled.
+	AddBlocksMainchainColumn = `ALTER TABLE blocks
+		ADD COLUMN IF NOT EXISTS is_mainchain BOOLEAN DEFAULT TRUE;`
+
+	// DropBlocksTxColumns is the blocks table upgrade from v2.1.0, which stored
+	// the hashes and row IDs of a block's transactions in arrays, now in the
+	// block_transactions table.
+	DropBlocksTxColumns = `ALTER TABLE blocks
+		DROP COLUMN IF EXISTS tx, DROP COLUMN IF EXISTS txDbIDs,
+		DROP COLUMN IF EXISTS stx, DROP COLUMN IF EXISTS stxDbIDs;`
+
+	// block_transactions, linking the blocks to their transactions, in order
+	// of tree and index in the tree.
+	CreateBlockTxnsTable = `CREATE TABLE IF NOT EXISTS block_transactions (
+		block_db_id INT4 NOT NULL REFERENCES blocks (id) ON DELETE CASCADE,
+		tx_db_id INT8 NOT NULL REFERENCES transactions (id) ON DELETE CASCADE,
+		tree INT2 NOT NULL,
+		block_index INT4 NOT NULL,
+		PRIMARY KEY (block_db_id, tree, block_index)
+	);`
+
+	// InsertBlockTxnsTree stores the transactions of one tree of a block, with
+	// the array of transaction row IDs in block order.
+	InsertBlockTxnsTree = `INSERT INTO block_transactions (
+		block_db_id, tx_db_id, tree, block_index)
+	SELECT $1, t.tx_db_id, $3, t.ord - 1
+	FROM unnest($2::INT8[]) WITH ORDINALITY AS t (tx_db_id, ord)
+	ON CONFLICT (block_db_id, tree, block_index) DO NOTHING;`
+
+	// IndexBlockTxnsTableOnTxDbID may be executed by the schema migration that
+	// creates the table, so it must not fail if the index exists.
+	IndexBlockTxnsTableOnTxDbID = `CREATE INDEX IF NOT EXISTS uix_block_txns_tx_db_id
+		ON block_transactions(tx_db_id);`
+	DeindexBlockTxnsTableOnTxDbID = `DROP INDEX uix_block_txns_tx_db_id;`
+
+	// SelectBlocksIDRangeEnd gets the last ID of the next range of up to $2
+	// blocks after the ID $1, or NULL if there are none.
+	SelectBlocksIDRangeEnd = `SELECT MAX(id) FROM (
+		SELECT id FROM blocks WHERE id > $1 ORDER BY id LIMIT $2
+	) b;`
+
+	// InsertBlockTxnsFromArrays fills the block_transactions table from the
+	// txDbIDs and stxDbIDs columns of the blocks with ID in ($1, $2]. Row IDs
+	// no longer in the transactions table, as removed by a duplicate purge,
+	// are skipped.
+	InsertBlockTxnsFromArrays = `INSERT INTO block_transactions (
+		block_db_id, tx_db_id, tree, block_index)
+	SELECT b.id, t.tx_db_id, t.tree, t.ord - 1
+	FROM blocks b CROSS JOIN LATERAL (
+		SELECT r.tx_db_id, 0 AS tree, r.ord FROM unnest(b.txDbIDs) WITH ORDINALITY AS r (tx_db_id, ord)
+		UNION ALL
+		SELECT s.tx_db_id, 1 AS tree, s.ord FROM unnest(b.stxDbIDs) WITH ORDINALITY AS s (tx_db_id, ord)
+	) t
+	JOIN transactions ON transactions.id = t.tx_db_id
+	WHERE b.id > $1 AND b.id <= $2
+	ON CONFLICT (block_db_id, tree, block_index) DO NOTHING;`
+
+	// SelectBlocksHasTxDbIDs indicates if the blocks table still has the
+	// txDbIDs column of tables created before the block_transactions table.
+	SelectBlocksHasTxDbIDs = `SELECT EXISTS (SELECT 1
+		FROM information_schema.columns
+		WHERE table_name = 'blocks' AND column_name = 'txdbids');`
 
 	// block_chain, with primary key that is not a SERIAL
 	CreateBlockPrevNextTable = `CREATE TABLE IF NOT EXISTS block_chain (
 		block_db_id INT8 PRIMARY KEY,
-		prev_hash TEXT NOT NULL,
-		this_hash TEXT UNIQUE NOT NULL, -- UNIQUE
-		next_hash TEXT
+		prev_hash BYTEA NOT NULL,
+		this_hash BYTEA UNIQUE NOT NULL, -- UNIQUE
+		next_hash BYTEA
 	);`
 
 	// Insert includes the primary key, which should be from the blocks table
 	InsertBlockPrevNext = `INSERT INTO block_chain (
 		block_db_id, prev_hash, this_hash, next_hash)
-	VALUES ($1, $2, $3, $4)
+	VALUES ($1, decode($2, 'hex'), decode($3, 'hex'), decode($4, 'hex'))
 	ON CONFLICT (this_hash) DO NOTHING;`
 
-	SelectBlockChainRowIDByHash = `select block_db_id from block_chain where this_hash = $1;`
+	SelectBlockChainRowIDByHash = `select block_db_id from block_chain where this_hash = decode($1, 'hex');`
 
-	UpdateBlockNext = `UPDATE block_chain set next_hash = $2 WHERE block_db_id = $1;`
-)
+	UpdateBlockNext = `UPDATE block_chain set next_hash = decode($2, 'hex') WHERE block_db_id = $1;`
 
-func MakeBlockInsertStatement(block *dbtypes.Block, checked bool) string {
-	return makeBlockInsertStatement(block.TxDbIDs, block.STxDbIDs,
-		block.Tx, block.STx, checked)
-}
+	// UpdateBlockNextByHash sets the next block hash for the block_chain row of
+	// the block with the given hash.
+	UpdateBlockNextByHash = `UPDATE block_chain set next_hash = decode($2, 'hex')
+		WHERE this_hash = decode($1, 'hex');`
+)
 
-func makeBlockInsertStatement(txDbIDs, stxDbIDs []uint64, rtxs, stxs []string, checked bool) string {
-	rtxDbIDsARRAY := makeARRAYOfBIGINTs(txDbIDs)
-	stxDbIDsARRAY := makeARRAYOfBIGINTs(stxDbIDs)
-	rtxTEXTARRAY := makeARRAYOfTEXT(rtxs)
-	stxTEXTARRAY := makeARRAYOfTEXT(stxs)
-	var insert string
+func MakeBlockInsertStatement(checked bool) string {
 	if checked {
-		insert = upsertBlockRow
-	} else {
-		insert = insertBlockRow
+		return upsertBlockRow
 	}
-	return fmt.Sprintf(insert, rtxTEXTARRAY, rtxDbIDsARRAY,
-		stxTEXTARRAY, stxDbIDsARRAY)
+	return insertBlockRow
 }
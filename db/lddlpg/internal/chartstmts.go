@@ -0,0 +1,58 @@
+package internal
+
+const (
+	// Charts. Each statement selects the data of the main chain blocks above a
+	// height ($1).
+
+	SelectChartBlocks = `SELECT height, time, size, sbits, pool_size, bits,
+			voters, fresh_stake, revocations, num_rtx
+		FROM blocks
+		WHERE is_mainchain AND height > $1
+		ORDER BY height;`
+
+	// SelectChartFees selects the total fees of the transactions in each
+	// block, excluding the coinbase.
+	SelectChartFees = `SELECT blocks.height, SUM(transactions.fees)
+		FROM transactions
+		JOIN blocks ON transactions.block_hash = blocks.hash
+		WHERE blocks.is_mainchain AND blocks.height > $1
+			AND NOT (transactions.tree = 0 AND transactions.block_index = 0)
+		GROUP BY blocks.height;`
+
+	SelectChartMisses = `SELECT misses.height, COUNT(*)
+		FROM misses
+		JOIN blocks ON misses.block_hash = blocks.hash
+		WHERE blocks.is_mainchain AND misses.height > $1
+		GROUP BY misses.height;`
+
+	// SelectChartPoolValueChanges selects the change in the value of the live
+	// ticket pool at each height. Tickets enter the pool after the ticket
+	// maturity ($2), and leave it when they vote, miss, or expire after the
+	// ticket expiry ($3) with the expired pool status ($4).
+	SelectChartPoolValueChanges = `SELECT height, SUM(value) FROM (
+			SELECT tickets.block_height + $2::INT4 AS height, tickets.price AS value
+			FROM tickets
+			JOIN blocks ON tickets.block_hash = blocks.hash
+			WHERE blocks.is_mainchain AND tickets.block_height > $1::INT4 - $2::INT4
+		UNION ALL
+			SELECT votes.height, -votes.ticket_price
+			FROM votes
+			JOIN blocks ON votes.block_hash = blocks.hash
+			WHERE blocks.is_mainchain AND votes.height > $1::INT4
+		UNION ALL
+			SELECT misses.height, -tickets.price
+			FROM misses
+			JOIN blocks ON misses.block_hash = blocks.hash
+			JOIN tickets ON misses.ticket_hash = tickets.tx_hash
+			JOIN blocks AS ticket_blocks ON tickets.block_hash = ticket_blocks.hash
+			WHERE blocks.is_mainchain AND ticket_blocks.is_mainchain
+				AND misses.height > $1::INT4
+		UNION ALL
+			SELECT tickets.block_height + $2::INT4 + $3::INT4, -tickets.price
+			FROM tickets
+			JOIN blocks ON tickets.block_hash = blocks.hash
+			WHERE blocks.is_mainchain AND tickets.pool_status = $4
+				AND tickets.block_height > $1::INT4 - $2::INT4 - $3::INT4
+		) AS changes
+		GROUP BY height;`
+)
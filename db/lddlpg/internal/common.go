@@ -47,18 +47,15 @@ func makeARRAYOfUnquotedTEXT(text []string) string {
 	return sTEXTARRAY
 }
 
-func makeARRAYOfBIGINTs(ints []uint64) string {
-	if len(ints) == 0 {
-		return "ARRAY[]::BIGINT[]"
-	}
-	ARRAY := "ARRAY["
-	for i, v := range ints {
-		if i == len(ints)-1 {
-			ARRAY += fmt.Sprintf(`%d`, v)
-			break
-		}
-		ARRAY += fmt.Sprintf(`%d, `, v)
-	}
-	ARRAY += "]"
-	return ARRAY
+// MakeSelectMaxIDStatement returns a query for the largest row ID in the
+// table, or 0 if the table is empty.
+func MakeSelectMaxIDStatement(table string) string {
+	return fmt.Sprintf(`SELECT COALESCE(MAX(id), 0) FROM %s;`, table)
+}
+
+// MakeSetSerialIDStatement returns a statement setting the sequence of the
+// table's id column to the row ID given as $1, so that the next row inserted
+// without an explicit ID gets the following ID.
+func MakeSetSerialIDStatement(table string) string {
+	return fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%s', 'id'), $1);`, table)
 }
@@ -0,0 +1,41 @@
+package internal
+
+const (
+	// CreateMetaTable creates the meta table, which has a single row recording
+	// the last block whose data was fully committed to the other tables.
+	CreateMetaTable = `CREATE TABLE IF NOT EXISTS meta (
+		id INT4 PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+		best_block_height INT8 NOT NULL,
+		best_block_hash BYTEA NOT NULL
+	);`
+
+	// CreateMetaTableV1 is the meta table as first created by a schema
+	// migration, with the block hash stored as hex TEXT like the blocks table
+	// of the time. It is a no-op for a meta table created by CreateMetaTable.
+	CreateMetaTableV1 = `CREATE TABLE IF NOT EXISTS meta (
+		id INT4 PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+		best_block_height INT8 NOT NULL,
+		best_block_hash TEXT NOT NULL
+	);`
+
+	// SetMetaBestBlock sets the last committed block. It is executed in the
+	// same database transaction that stores (or removes) the block.
+	SetMetaBestBlock = `INSERT INTO meta (id, best_block_height, best_block_hash)
+		VALUES (1, $1, decode($2, 'hex'))
+		ON CONFLICT (id) DO UPDATE
+		SET best_block_height = $1, best_block_hash = decode($2, 'hex');`
+
+	SelectMetaBestBlock = `SELECT best_block_height, encode(best_block_hash, 'hex')
+		FROM meta WHERE id = 1;`
+
+	// SetMetaBestBlockFromBlocks initializes the meta table of a database
+	// created before it was added with the best main chain block. The hash is
+	// copied as is. The migration runs before the hashes are converted to
+	// BYTEA in an existing database, where it creates the meta table with
+	// CreateMetaTableV1, while a new database has both tables created by
+	// CreateTables.
+	SetMetaBestBlockFromBlocks = `INSERT INTO meta (id, best_block_height, best_block_hash)
+		SELECT 1, height, hash FROM blocks
+		WHERE is_mainchain ORDER BY height DESC LIMIT 1
+		ON CONFLICT (id) DO NOTHING;`
+)
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// CreateSchemaVersionsTable creates the table of applied schema migrations.
+	// backfill_cursor is the progress of a migration's data backfill, which is
+	// complete when backfill_done is set.
+	CreateSchemaVersionsTable = `CREATE TABLE IF NOT EXISTS schema_versions (
+		version INT4 PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		backfill_cursor INT8 NOT NULL DEFAULT 0,
+		backfill_done BOOLEAN NOT NULL
+	);`
+
+	InsertSchemaVersion = `INSERT INTO schema_versions (version, description, backfill_done)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (version) DO NOTHING;`
+
+	SelectSchemaVersions = `SELECT version, backfill_cursor, backfill_done
+		FROM schema_versions;`
+
+	UpdateSchemaVersionBackfill = `UPDATE schema_versions
+		SET backfill_cursor = $2, backfill_done = $3
+		WHERE version = $1;`
+)
+
+// Tables created before the hash columns were changed to BYTEA stored the
+// hashes as hex TEXT. These statements convert such columns in place, and skip
+// those that are already BYTEA, as created by CreateTables.
+var (
+	ConvertBlocksHashesToBytea = makeHashColumnsByteaStatement("blocks",
+		"hash", "merkle_root", "stake_root", "previous_hash")
+	ConvertBlockChainHashesToBytea = makeHashColumnsByteaStatement("block_chain",
+		"prev_hash", "this_hash", "next_hash")
+	ConvertMetaHashesToBytea = makeHashColumnsByteaStatement("meta",
+		"best_block_hash")
+	ConvertTransactionsHashesToBytea = makeHashColumnsByteaStatement("transactions",
+		"block_hash", "tx_hash")
+	ConvertVinsHashesToBytea = makeHashColumnsByteaStatement("vins",
+		"tx_hash", "prev_tx_hash")
+	ConvertVoutsHashesToBytea = makeHashColumnsByteaStatement("vouts",
+		"tx_hash")
+	ConvertAddressesHashesToBytea = makeHashColumnsByteaStatement("addresses",
+		"funding_tx_hash", "spending_tx_hash")
+	ConvertTicketsHashesToBytea = makeHashColumnsByteaStatement("tickets",
+		"tx_hash", "block_hash")
+	ConvertVotesHashesToBytea = makeHashColumnsByteaStatement("votes",
+		"tx_hash", "block_hash", "candidate_block_hash", "ticket_hash")
+	ConvertMissesHashesToBytea = makeHashColumnsByteaStatement("misses",
+		"block_hash", "candidate_block_hash", "ticket_hash")
+)
+
+// makeHashColumnsByteaStatement makes a statement that converts the given hex
+// TEXT columns of a table to BYTEA, rewriting the existing rows and indexes.
+// Columns that are not TEXT, and tables that do not exist, are left as is.
+func makeHashColumnsByteaStatement(table string, columns ...string) string {
+	return fmt.Sprintf(`DO $$
+	DECLARE alters TEXT;
+	BEGIN
+		SELECT string_agg(format('ALTER COLUMN %%I TYPE BYTEA USING decode(%%I, ''hex'')',
+			column_name, column_name), ', ')
+		INTO alters
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = '%s'
+			AND column_name IN ('%s') AND data_type = 'text';
+		IF alters IS NOT NULL THEN
+			EXECUTE 'ALTER TABLE %s ' || alters;
+		END IF;
+	END $$;`, table, strings.Join(columns, "', '"), table)
+}
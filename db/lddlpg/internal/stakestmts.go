@@ -5,8 +5,8 @@ const (
 
 	CreateTicketsTable = `CREATE TABLE IF NOT EXISTS tickets (  
 		id SERIAL PRIMARY KEY,
-		tx_hash TEXT NOT NULL,
-		block_hash TEXT NOT NULL,
+		tx_hash BYTEA NOT NULL,
+		block_hash BYTEA NOT NULL,
 		block_height INT4,
 		purchase_tx_db_id INT8,
 		stakesubmission_address TEXT,
@@ -27,13 +27,13 @@ const (
 		stakesubmission_address, is_multisig, is_split,
 		num_inputs, price, fee, spend_type, pool_status)
 	VALUES (
-		$1, $2, $3,	$4,
+		decode($1, 'hex'), decode($2, 'hex'), $3,	$4,
 		$5, $6, $7,
 		$8, $9, $10, $11, $12) `
 	insertTicketRow = insertTicketRow0 + `RETURNING id;`
 	// insertTicketRowChecked = insertTicketRow0 + `ON CONFLICT (tx_hash, block_hash) DO NOTHING RETURNING id;`
 	upsertTicketRow = insertTicketRow0 + `ON CONFLICT (tx_hash, block_hash) DO UPDATE 
-		SET tx_hash = $1, block_hash = $2 RETURNING id;`
+		SET tx_hash = decode($1, 'hex'), block_hash = decode($2, 'hex') RETURNING id;`
 	insertTicketRowReturnId = `WITH ins AS (` +
 		insertTicketRow0 +
 		`ON CONFLICT (tx_hash, block_hash) DO UPDATE
@@ -43,23 +43,46 @@ const (
 	SELECT id FROM ins
 	UNION  ALL
 	SELECT id FROM tickets
-	WHERE  tx_hash = $1 AND block_hash = $2
+	WHERE  tx_hash = decode($1, 'hex') AND block_hash = decode($2, 'hex')
 	LIMIT  1;`
 
-	SelectTicketsInBlock         = `SELECT * FROM tickets WHERE block_hash = $1;`
-	SelectTicketsTxDbIDsInBlock  = `SELECT purchase_tx_db_id FROM tickets WHERE block_hash = $1;`
+	SelectTicketsInBlock         = `SELECT * FROM tickets WHERE block_hash = decode($1, 'hex');`
+	SelectTicketsTxDbIDsInBlock  = `SELECT purchase_tx_db_id FROM tickets WHERE block_hash = decode($1, 'hex');`
 	SelectTicketsForAddress      = `SELECT * FROM tickets WHERE stakesubmission_address = $1;`
 	SelectTicketsForPriceAtLeast = `SELECT * FROM tickets WHERE price >= $1;`
 	SelectTicketsForPriceAtMost  = `SELECT * FROM tickets WHERE price <= $1;`
-	SelectTicketIDHeightByHash   = `SELECT id, block_height FROM tickets WHERE tx_hash = $1;`
-	SelectTicketIDByHash         = `SELECT id FROM tickets WHERE tx_hash = $1;`
-	SelectTicketStatusByHash     = `SELECT id, spend_type, pool_status FROM tickets WHERE tx_hash = $1;`
-	SelectUnspentTickets         = `SELECT id, tx_hash FROM tickets WHERE spend_type = 0 OR spend_type = -1;`
+	SelectTicketIDHeightByHash   = `SELECT id, block_height FROM tickets WHERE tx_hash = decode($1, 'hex');`
+	SelectTicketIDByHash         = `SELECT id FROM tickets WHERE tx_hash = decode($1, 'hex');`
+	SelectTicketStatusByHash     = `SELECT id, spend_type, pool_status FROM tickets WHERE tx_hash = decode($1, 'hex');`
+	SelectUnspentTickets         = `SELECT id, encode(tx_hash, 'hex') FROM tickets WHERE spend_type = 0 OR spend_type = -1;`
+
+	// selectTicketInfo selects the lifecycle of main chain tickets: the
+	// purchase, the spending transaction, the vote if the ticket voted, and the
+	// height at which the ticket missed its vote, if it did.
+	selectTicketInfo = `SELECT encode(tickets.tx_hash, 'hex'), encode(tickets.block_hash, 'hex'), tickets.block_height,
+			tickets.stakesubmission_address, tickets.is_multisig, tickets.is_split,
+			tickets.price, tickets.fee, tickets.spend_type, tickets.pool_status,
+			tickets.spend_height, encode(spends.tx_hash, 'hex'), votes.vote_reward, votes.version,
+			votes.vote_bits, votes.block_valid,
+			(SELECT MIN(misses.height)
+				FROM misses
+				JOIN blocks AS miss_blocks ON misses.block_hash = miss_blocks.hash
+				WHERE misses.ticket_hash = tickets.tx_hash AND miss_blocks.is_mainchain)
+		FROM tickets
+		JOIN blocks ON tickets.block_hash = blocks.hash
+		LEFT JOIN transactions AS spends ON tickets.spend_tx_db_id = spends.id
+		LEFT JOIN votes ON votes.tx_hash = spends.tx_hash
+			AND votes.block_hash = spends.block_hash
+		WHERE blocks.is_mainchain AND `
+	SelectTicketInfoByHash      = selectTicketInfo + `tickets.tx_hash = decode($1, 'hex');`
+	SelectTicketsInfoForAddress = selectTicketInfo +
+		`tickets.stakesubmission_address = $1
+		ORDER BY tickets.block_height DESC, tickets.tx_hash;`
 
 	// Update
 	SetTicketSpendingInfoForHash = `UPDATE tickets
 		SET spend_type = $5, spend_height = $3, spend_tx_db_id = $4, pool_status = $6
-		WHERE tx_hash = $1 and block_hash = $2;`
+		WHERE tx_hash = decode($1, 'hex') and block_hash = decode($2, 'hex');`
 	SetTicketSpendingInfoForTicketDbID = `UPDATE tickets
 		SET spend_type = $4, spend_height = $2, spend_tx_db_id = $3, pool_status = $5
 		WHERE id = $1;`
@@ -67,7 +90,29 @@ const (
 		SET spend_type = $4, spend_height = $2, spend_tx_db_id = $3, pool_status = $5
 		WHERE purchase_tx_db_id = $1;`
 	SetTicketPoolStatusForTicketDbID = `UPDATE tickets SET pool_status = $2 WHERE id = $1;`
-	SetTicketPoolStatusForHash       = `UPDATE tickets SET pool_status = $2 WHERE tx_hash = $1;`
+	SetTicketPoolStatusForHash       = `UPDATE tickets SET pool_status = $2 WHERE tx_hash = decode($1, 'hex');`
+
+	// Reorg rollback
+
+	// UnsetTicketSpendingInfoForSpendTxDbIDs removes the spending info set by
+	// the transactions with the given row IDs. A ticket whose vote is removed
+	// is live ($3) again, while a revoked ticket stays expired ($6) if it was,
+	// or missed ($5) otherwise. $4 is the voted spend type.
+	UnsetTicketSpendingInfoForSpendTxDbIDs = `UPDATE tickets
+		SET spend_type = $2, spend_height = NULL, spend_tx_db_id = NULL,
+			pool_status = CASE
+				WHEN spend_type = $4 THEN $3
+				WHEN pool_status = $6 THEN $6
+				ELSE $5
+			END
+		WHERE spend_tx_db_id = ANY($1);`
+	SetTicketsLiveForMissesInBlocks = `UPDATE tickets SET pool_status = $2
+		WHERE spend_type = $3 AND tx_hash IN (
+			SELECT ticket_hash FROM misses WHERE block_hash IN (SELECT decode(unnest($1::TEXT[]), 'hex')));`
+	SetTicketsLiveForExpiresAboveHeight = `UPDATE tickets SET pool_status = $2
+		WHERE spend_type = $3 AND pool_status = $4 AND block_height + $5 > $1;`
+	DeleteTicketsInBlocks = `DELETE FROM tickets WHERE block_hash IN (SELECT decode(unnest($1::TEXT[]), 'hex'))
+		RETURNING encode(tx_hash, 'hex');`
 
 	// Index
 	IndexTicketsTableOnHashes = `CREATE UNIQUE INDEX uix_ticket_hashes_index
@@ -78,6 +123,12 @@ const (
 		ON tickets(purchase_tx_db_id);`
 	DeindexTicketsTableOnTxDbID = `DROP INDEX uix_ticket_ticket_db_id;`
 
+	// IndexTicketsTableOnStakeSubmissionAddress is also executed by the schema
+	// migration that adds the index, so it must not fail if the index exists.
+	IndexTicketsTableOnStakeSubmissionAddress = `CREATE INDEX IF NOT EXISTS uix_ticket_stakesubmission_address
+		ON tickets(stakesubmission_address);`
+	DeindexTicketsTableOnStakeSubmissionAddress = `DROP INDEX uix_ticket_stakesubmission_address;`
+
 	DeleteTicketsDuplicateRows = `DELETE FROM tickets
 		WHERE id IN (SELECT id FROM (
 				SELECT id, ROW_NUMBER()
@@ -90,13 +141,13 @@ const (
 	CreateVotesTable = `CREATE TABLE IF NOT EXISTS votes (
 		id SERIAL PRIMARY KEY,
 		height INT4,
-		tx_hash TEXT NOT NULL,
-		block_hash TEXT NOT NULL,
-		candidate_block_hash TEXT NOT NULL,
+		tx_hash BYTEA NOT NULL,
+		block_hash BYTEA NOT NULL,
+		candidate_block_hash BYTEA NOT NULL,
 		version INT2,
 		vote_bits INT2,
 		block_valid BOOLEAN,
-		ticket_hash TEXT,
+		ticket_hash BYTEA,
 		ticket_tx_db_id INT8,
 		ticket_price FLOAT8,
 		vote_reward FLOAT8
@@ -109,14 +160,14 @@ const (
 		version, vote_bits, block_valid,
 		ticket_hash, ticket_tx_db_id, ticket_price, vote_reward)
 	VALUES (
-		$1, $2,
-		$3, $4,
+		$1, decode($2, 'hex'),
+		decode($3, 'hex'), decode($4, 'hex'),
 		$5, $6, $7,
-		$8, $9, $10, $11) `
+		decode($8, 'hex'), $9, $10, $11) `
 	insertVoteRow = insertVoteRow0 + `RETURNING id;`
 	// insertVoteRowChecked = insertVoteRow0 + `ON CONFLICT (tx_hash, block_hash) DO NOTHING RETURNING id;`
 	upsertVoteRow = insertVoteRow0 + `ON CONFLICT (tx_hash, block_hash) DO UPDATE 
-		SET tx_hash = $2, block_hash = $3 RETURNING id;`
+		SET tx_hash = decode($2, 'hex'), block_hash = decode($3, 'hex') RETURNING id;`
 	insertVoteRowReturnId = `WITH ins AS (` +
 		insertVoteRow0 +
 		`ON CONFLICT (tx_hash, block_hash) DO UPDATE
@@ -126,10 +177,21 @@ const (
 	SELECT id FROM ins
 	UNION  ALL
 	SELECT id FROM votes
-	WHERE  tx_hash = $2 AND block_hash = $3
+	WHERE  tx_hash = decode($2, 'hex') AND block_hash = decode($3, 'hex')
 	LIMIT  1;`
 
-	SelectAllVoteDbIDsHeightsTicketHashes = `SELECT id, height, ticket_hash FROM votes;`
+	DeleteVotesInBlocks = `DELETE FROM votes WHERE block_hash IN (SELECT decode(unnest($1::TEXT[]), 'hex'));`
+
+	// SelectVoteBitsCountsByVersion selects the number of votes of a vote
+	// version ($1) with each vote bits in each main chain block.
+	SelectVoteBitsCountsByVersion = `SELECT votes.height, blocks.time, votes.vote_bits, COUNT(*)
+		FROM votes
+		JOIN blocks ON votes.block_hash = blocks.hash
+		WHERE votes.version = $1 AND blocks.is_mainchain
+		GROUP BY votes.height, blocks.time, votes.vote_bits
+		ORDER BY votes.height;`
+
+	SelectAllVoteDbIDsHeightsTicketHashes = `SELECT id, height, encode(ticket_hash, 'hex') FROM votes;`
 	SelectAllVoteDbIDsHeightsTicketDbIDs  = `SELECT id, height, ticket_tx_db_id FROM votes;`
 
 	// Index
@@ -157,20 +219,20 @@ const (
 	CreateMissesTable = `CREATE TABLE IF NOT EXISTS misses (
 		id SERIAL PRIMARY KEY,
 		height INT4,
-		block_hash TEXT NOT NULL,
-		candidate_block_hash TEXT NOT NULL,
-		ticket_hash TEXT NOT NULL
+		block_hash BYTEA NOT NULL,
+		candidate_block_hash BYTEA NOT NULL,
+		ticket_hash BYTEA NOT NULL
 	);`
 
 	// Insert
 	insertMissRow0 = `INSERT INTO misses (
 		height, block_hash, candidate_block_hash, ticket_hash)
 	VALUES (
-		$1, $2, $3, $4) `
+		$1, decode($2, 'hex'), decode($3, 'hex'), decode($4, 'hex')) `
 	insertMissRow = insertMissRow0 + `RETURNING id;`
 	// insertVoteRowChecked = insertMissRow0 + `ON CONFLICT (ticket_hash, block_hash) DO NOTHING RETURNING id;`
 	upsertMissRow = insertMissRow0 + `ON CONFLICT (ticket_hash, block_hash) DO UPDATE 
-		SET ticket_hash = $4, block_hash = $2 RETURNING id;`
+		SET ticket_hash = decode($4, 'hex'), block_hash = decode($2, 'hex') RETURNING id;`
 	insertMissRowReturnId = `WITH ins AS (` +
 		insertMissRow0 +
 		`ON CONFLICT (ticket_hash, block_hash) DO UPDATE
@@ -180,10 +242,81 @@ const (
 	SELECT id FROM ins
 	UNION  ALL
 	SELECT id FROM misses
-	WHERE  ticket_hash = $4 AND block_hash = $2
+	WHERE  ticket_hash = decode($4, 'hex') AND block_hash = decode($2, 'hex')
 	LIMIT  1;`
 
-	SelectMissesInBlock = `SELECT ticket_hash FROM misses WHERE block_hash = $1;`
+	SelectMissesInBlock = `SELECT encode(ticket_hash, 'hex') FROM misses
+		WHERE block_hash = decode($1, 'hex');`
+
+	// Missed vote analytics. Each statement selects the misses of the main
+	// chain blocks in a range of heights ($1 to $2).
+
+	SelectMissesInBlockRange = `SELECT blocks.height, encode(blocks.hash, 'hex'),
+			encode(misses.ticket_hash, 'hex')
+		FROM misses
+		JOIN blocks ON misses.block_hash = blocks.hash
+		WHERE blocks.is_mainchain AND blocks.height BETWEEN $1 AND $2
+		ORDER BY blocks.height, misses.ticket_hash;`
+
+	// SelectMissesByDay selects the number of blocks, votes, misses and
+	// expired tickets of each UTC day. Tickets expire the ticket maturity plus
+	// the ticket expiry ($3) after their purchase, with the expired pool
+	// status ($4).
+	SelectMissesByDay = `SELECT blocks.time / 86400 * 86400 AS day, COUNT(*),
+			SUM(blocks.voters), COALESCE(SUM(missed.count), 0),
+			COALESCE(SUM(expired.count), 0)
+		FROM blocks
+		LEFT JOIN (
+			SELECT misses.block_hash, COUNT(*) AS count
+			FROM misses
+			WHERE misses.height BETWEEN $1 AND $2
+			GROUP BY misses.block_hash
+		) AS missed ON missed.block_hash = blocks.hash
+		LEFT JOIN (
+			SELECT tickets.block_height + $3::INT4 AS height, COUNT(*) AS count
+			FROM tickets
+			JOIN blocks ON tickets.block_hash = blocks.hash
+			WHERE blocks.is_mainchain AND tickets.pool_status = $4
+				AND tickets.block_height + $3::INT4 BETWEEN $1 AND $2
+			GROUP BY tickets.block_height
+		) AS expired ON expired.height = blocks.height
+		WHERE blocks.is_mainchain AND blocks.height BETWEEN $1 AND $2
+		GROUP BY day
+		ORDER BY day;`
+
+	// SelectMissesByAddress selects the number of votes, misses and expired
+	// tickets of the tickets of each stake submission address with misses.
+	// The arguments are as for SelectMissesByDay.
+	SelectMissesByAddress = `SELECT address, SUM(voted), SUM(missed), SUM(expired)
+		FROM (
+				SELECT tickets.stakesubmission_address AS address,
+					1 AS voted, 0 AS missed, 0 AS expired
+				FROM votes
+				JOIN blocks ON votes.block_hash = blocks.hash
+				JOIN tickets ON votes.ticket_hash = tickets.tx_hash
+				JOIN blocks AS ticket_blocks ON tickets.block_hash = ticket_blocks.hash
+				WHERE blocks.is_mainchain AND ticket_blocks.is_mainchain
+					AND votes.height BETWEEN $1 AND $2
+			UNION ALL
+				SELECT tickets.stakesubmission_address, 0, 1, 0
+				FROM misses
+				JOIN blocks ON misses.block_hash = blocks.hash
+				JOIN tickets ON misses.ticket_hash = tickets.tx_hash
+				JOIN blocks AS ticket_blocks ON tickets.block_hash = ticket_blocks.hash
+				WHERE blocks.is_mainchain AND ticket_blocks.is_mainchain
+					AND misses.height BETWEEN $1 AND $2
+			UNION ALL
+				SELECT tickets.stakesubmission_address, 0, 0, 1
+				FROM tickets
+				JOIN blocks ON tickets.block_hash = blocks.hash
+				WHERE blocks.is_mainchain AND tickets.pool_status = $4
+					AND tickets.block_height + $3::INT4 BETWEEN $1 AND $2
+		) AS called
+		GROUP BY address
+		HAVING SUM(missed) > 0
+		ORDER BY SUM(missed) DESC, address;`
+
+	DeleteMissesInBlocks = `DELETE FROM misses WHERE block_hash IN (SELECT decode(unnest($1::TEXT[]), 'hex'));`
 
 	// Index
 	IndexMissesTableOnHashes = `CREATE UNIQUE INDEX uix_misses_hashes_index
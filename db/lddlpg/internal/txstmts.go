@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/Legenddigital/lddld/blockchain/stake"
+	"github.com/lib/pq"
 )
 
 const (
@@ -12,12 +13,12 @@ const (
 		block_hash, block_height, block_time, time,
 		tx_type, version, tree, tx_hash, block_index, 
 		lock_time, expiry, size, spent, sent, fees, 
-		num_vin, vin_db_ids, num_vout, vout_db_ids)
+		num_vin, num_vout)
 	VALUES (
-		$1, $2, $3, $4, 
-		$5, $6, $7, $8, $9,
+		decode($1, 'hex'), $2, $3, $4, 
+		$5, $6, $7, decode($8, 'hex'), $9,
 		$10, $11, $12, $13, $14, $15,
-		$16, $17, $18, $19) `
+		$16, $17) `
 	insertTxRow = insertTxRow0 + `RETURNING id;`
 	//insertTxRowChecked = insertTxRow0 + `ON CONFLICT (tx_hash, block_hash) DO NOTHING RETURNING id;`
 	upsertTxRow = insertTxRow0 + `ON CONFLICT (tx_hash, block_hash) DO UPDATE 
@@ -31,20 +32,20 @@ const (
 	SELECT id FROM ins
 	UNION  ALL
 	SELECT id FROM transactions
-	WHERE  tx_hash = $8 AND block_hash = $1
+	WHERE  tx_hash = decode($8, 'hex') AND block_hash = decode($1, 'hex')
 	LIMIT  1;`
 
 	CreateTransactionTable = `CREATE TABLE IF NOT EXISTS transactions (
 		id SERIAL8 PRIMARY KEY,
 		/*block_db_id INT4,*/
-		block_hash TEXT,
+		block_hash BYTEA,
 		block_height INT8,
 		block_time INT8,
 		time INT8,
 		tx_type INT4,
 		version INT4,
 		tree INT2,
-		tx_hash TEXT,
+		tx_hash BYTEA,
 		block_index INT4,
 		lock_time INT4,
 		expiry INT4,
@@ -53,23 +54,40 @@ const (
 		sent INT8,
 		fees INT8,
 		num_vin INT4,
-		vin_db_ids INT8[],
-		num_vout INT4,
-		vout_db_ids INT8[]
+		num_vout INT4
 	);`
 
-	SelectTxByHash       = `SELECT id, block_hash, block_index, tree FROM transactions WHERE tx_hash = $1;`
-	SelectTxsByBlockHash = `SELECT id, tx_hash, block_index, tree FROM transactions WHERE block_hash = $1;`
-
-	SelectTxIDHeightByHash = `SELECT id, block_height FROM transactions WHERE tx_hash = $1;`
-
-	SelectFullTxByHash = `SELECT id, block_hash, block_height, block_time, 
-		time, tx_type, version, tree, tx_hash, block_index, lock_time, expiry, 
-		size, spent, sent, fees, num_vin, vin_db_ids, num_vout, vout_db_ids 
-		FROM transactions WHERE tx_hash = $1;`
-
-	SelectRegularTxByHash = `SELECT id, block_hash, block_index FROM transactions WHERE tx_hash = $1 and tree=0;`
-	SelectStakeTxByHash   = `SELECT id, block_hash, block_index FROM transactions WHERE tx_hash = $1 and tree=1;`
+	// DropTransactionsVinVoutDbIDsColumns is the transactions table upgrade
+	// from v2.0.0, which stored the row IDs of a transaction's vins and vouts
+	// in arrays. They are found with the vins and vouts table indexes on
+	// transaction hash and index.
+	DropTransactionsVinVoutDbIDsColumns = `ALTER TABLE transactions
+		DROP COLUMN IF EXISTS vin_db_ids, DROP COLUMN IF EXISTS vout_db_ids;`
+
+	SelectTxByHash = `SELECT id, encode(block_hash, 'hex'), block_index, tree
+		FROM transactions WHERE tx_hash = decode($1, 'hex');`
+	SelectTxsByBlockHash = `SELECT id, encode(tx_hash, 'hex'), block_index, tree
+		FROM transactions WHERE block_hash = decode($1, 'hex');`
+
+	SelectTxIDHeightByHash = `SELECT id, block_height FROM transactions WHERE tx_hash = decode($1, 'hex');`
+
+	SelectTxDbIDsHashesByBlockHashes = `SELECT id, encode(tx_hash, 'hex') FROM transactions
+		WHERE block_hash IN (SELECT decode(unnest($1::TEXT[]), 'hex'));`
+
+	SelectFullTxByHash = `SELECT id, encode(block_hash, 'hex'), block_height, block_time, 
+		time, tx_type, version, tree, encode(tx_hash, 'hex'), block_index, lock_time, expiry, 
+		size, spent, sent, fees, num_vin,
+		ARRAY(SELECT id FROM vins WHERE vins.tx_hash = transactions.tx_hash
+			AND vins.tx_tree = transactions.tree ORDER BY tx_index),
+		num_vout,
+		ARRAY(SELECT id FROM vouts WHERE vouts.tx_hash = transactions.tx_hash
+			AND vouts.tx_tree = transactions.tree ORDER BY tx_index)
+		FROM transactions WHERE tx_hash = decode($1, 'hex');`
+
+	SelectRegularTxByHash = `SELECT id, encode(block_hash, 'hex'), block_index FROM transactions
+		WHERE tx_hash = decode($1, 'hex') and tree=0;`
+	SelectStakeTxByHash = `SELECT id, encode(block_hash, 'hex'), block_index FROM transactions
+		WHERE tx_hash = decode($1, 'hex') and tree=1;`
 
 	IndexTransactionTableOnBlockIn = `CREATE UNIQUE INDEX uix_tx_block_in
 		ON transactions(block_hash, block_index, tree)
@@ -102,12 +120,22 @@ const (
 			FROM transactions) t
 		WHERE t.rnum > 1);`
 
-	RetrieveVoutDbIDs = `SELECT unnest(vout_db_ids) FROM transactions WHERE id = $1;`
-	RetrieveVoutDbID  = `SELECT vout_db_ids[$2] FROM transactions WHERE id = $1;`
+	RetrieveVoutDbIDs = `SELECT vouts.id FROM vouts JOIN transactions
+		ON vouts.tx_hash = transactions.tx_hash AND vouts.tx_tree = transactions.tree
+		WHERE transactions.id = $1 ORDER BY vouts.tx_index;`
+	RetrieveVoutDbID = `SELECT vouts.id FROM vouts JOIN transactions
+		ON vouts.tx_hash = transactions.tx_hash AND vouts.tx_tree = transactions.tree
+		WHERE transactions.id = $1 AND vouts.tx_index = $2;`
 )
 
 var (
-	SelectAllRevokes = fmt.Sprintf(`SELECT id, tx_hash, block_height, vin_db_ids[0] FROM transactions WHERE tx_type = %d;`, stake.TxTypeSSRtx)
+	// SelectAllRevokes gets the revocations with the row ID of their vin,
+	// which spends the revoked ticket.
+	SelectAllRevokes = fmt.Sprintf(`SELECT transactions.id, encode(transactions.tx_hash, 'hex'),
+		block_height, vins.id
+		FROM transactions JOIN vins ON vins.tx_hash = transactions.tx_hash
+			AND vins.tx_index = 0 AND vins.tx_tree = transactions.tree
+		WHERE tx_type = %d;`, stake.TxTypeSSRtx)
 )
 
 // func makeTxInsertStatement(voutDbIDs, vinDbIDs []uint64, vouts []*dbtypes.Vout, checked bool) string {
@@ -123,6 +151,18 @@ var (
 // 	return fmt.Sprintf(insert, voutDbIDsBIGINT, voutCompositeARRAY, vinDbIDsBIGINT)
 // }
 
+var txCopyStmt = pq.CopyIn("transactions", "id",
+	"block_hash", "block_height", "block_time", "time",
+	"tx_type", "version", "tree", "tx_hash", "block_index",
+	"lock_time", "expiry", "size", "spent", "sent", "fees",
+	"num_vin", "num_vout")
+
+// MakeTxCopyInStatement returns the COPY statement for bulk loading the
+// transactions table, with the row IDs set explicitly.
+func MakeTxCopyInStatement() string {
+	return txCopyStmt
+}
+
 func MakeTxInsertStatement(checked bool) string {
 	if checked {
 		return upsertTxRow
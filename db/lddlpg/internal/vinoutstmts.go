@@ -13,21 +13,21 @@ const (
 
 	CreateVinTable = `CREATE TABLE IF NOT EXISTS vins (
 		id SERIAL8 PRIMARY KEY,
-		tx_hash TEXT,
+		tx_hash BYTEA,
 		tx_index INT4,
 		tx_tree INT2,
-		prev_tx_hash TEXT,
+		prev_tx_hash BYTEA,
 		prev_tx_index INT8,
 		prev_tx_tree INT2
 	);`
 
 	InsertVinRow0 = `INSERT INTO vins (tx_hash, tx_index, tx_tree, prev_tx_hash, prev_tx_index, prev_tx_tree)
-		VALUES ($1, $2, $3, $4, $5, $6) `
+		VALUES (decode($1, 'hex'), $2, $3, decode($4, 'hex'), $5, $6) `
 	InsertVinRow = InsertVinRow0 + `RETURNING id;`
 	// InsertVinRowChecked = InsertVinRow0 +
 	// 	`ON CONFLICT (tx_hash, tx_index, tx_tree) DO NOTHING RETURNING id;`
 	UpsertVinRow = InsertVinRow0 + `ON CONFLICT (tx_hash, tx_index, tx_tree) DO UPDATE 
-		SET tx_hash = $1, tx_index = $2, tx_tree = $3 RETURNING id;`
+		SET tx_hash = decode($1, 'hex'), tx_index = $2, tx_tree = $3 RETURNING id;`
 
 	DeleteVinsDuplicateRows = `DELETE FROM vins
 		WHERE id IN (SELECT id FROM (
@@ -48,17 +48,22 @@ const (
 	SelectVinIDsALL = `SELECT id FROM vins;`
 	CountVinsRows   = `SELECT reltuples::BIGINT AS estimate FROM pg_class WHERE relname='vins';`
 
-	SelectSpendingTxsByPrevTx = `SELECT id, tx_hash, tx_index, prev_tx_index FROM vins WHERE prev_tx_hash=$1;`
-	SelectSpendingTxByPrevOut = `SELECT id, tx_hash, tx_index FROM vins 
-		WHERE prev_tx_hash=$1 AND prev_tx_index=$2;`
-	SelectFundingTxsByTx        = `SELECT id, prev_tx_hash FROM vins WHERE tx_hash=$1;`
-	SelectFundingTxByTxIn       = `SELECT id, prev_tx_hash FROM vins WHERE tx_hash=$1 AND tx_index=$2;`
-	SelectFundingOutpointByTxIn = `SELECT id, prev_tx_hash, prev_tx_index, prev_tx_tree FROM vins 
-		WHERE tx_hash=$1 AND tx_index=$2;`
-	SelectFundingOutpointByVinID = `SELECT prev_tx_hash, prev_tx_index, prev_tx_tree FROM vins WHERE id=$1;`
-	SelectFundingTxByVinID       = `SELECT prev_tx_hash FROM vins WHERE id=$1;`
-	SelectSpendingTxByVinID      = `SELECT tx_hash, tx_index, tx_tree FROM vins WHERE id=$1;`
-	SelectAllVinInfoByID         = `SELECT * FROM vins WHERE id=$1;`
+	SelectSpendingTxsByPrevTx = `SELECT id, encode(tx_hash, 'hex'), tx_index, prev_tx_index FROM vins
+		WHERE prev_tx_hash=decode($1, 'hex');`
+	SelectSpendingTxByPrevOut = `SELECT id, encode(tx_hash, 'hex'), tx_index FROM vins 
+		WHERE prev_tx_hash=decode($1, 'hex') AND prev_tx_index=$2;`
+	SelectFundingTxsByTx = `SELECT id, encode(prev_tx_hash, 'hex') FROM vins
+		WHERE tx_hash=decode($1, 'hex');`
+	SelectFundingTxByTxIn = `SELECT id, encode(prev_tx_hash, 'hex') FROM vins
+		WHERE tx_hash=decode($1, 'hex') AND tx_index=$2;`
+	SelectFundingOutpointByTxIn = `SELECT id, encode(prev_tx_hash, 'hex'), prev_tx_index, prev_tx_tree FROM vins 
+		WHERE tx_hash=decode($1, 'hex') AND tx_index=$2;`
+	SelectFundingOutpointByVinID = `SELECT encode(prev_tx_hash, 'hex'), prev_tx_index, prev_tx_tree
+		FROM vins WHERE id=$1;`
+	SelectFundingTxByVinID  = `SELECT encode(prev_tx_hash, 'hex') FROM vins WHERE id=$1;`
+	SelectSpendingTxByVinID = `SELECT encode(tx_hash, 'hex'), tx_index, tx_tree FROM vins WHERE id=$1;`
+	SelectAllVinInfoByID    = `SELECT id, encode(tx_hash, 'hex'), tx_index, tx_tree,
+		encode(prev_tx_hash, 'hex'), prev_tx_index, prev_tx_tree FROM vins WHERE id=$1;`
 
 	CreateVinType = `CREATE TYPE vin_t AS (
 		prev_tx_hash TEXT,
@@ -73,7 +78,7 @@ const (
 
 	CreateVoutTable = `CREATE TABLE IF NOT EXISTS vouts (
 		id SERIAL8 PRIMARY KEY,
-		tx_hash TEXT,
+		tx_hash BYTEA,
 		tx_index INT4,
 		tx_tree INT2,
 		value INT8,
@@ -86,11 +91,11 @@ const (
 
 	insertVoutRow0 = `INSERT INTO vouts (tx_hash, tx_index, tx_tree, value, 
 		version, pkscript, script_req_sigs, script_type, script_addresses)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) `
+	VALUES (decode($1, 'hex'), $2, $3, $4, $5, $6, $7, $8, $9) `
 	insertVoutRow = insertVoutRow0 + `RETURNING id;`
 	//insertVoutRowChecked  = insertVoutRow0 + `ON CONFLICT (tx_hash, tx_index, tx_tree) DO NOTHING RETURNING id;`
 	upsertVoutRow = insertVoutRow0 + `ON CONFLICT (tx_hash, tx_index, tx_tree) DO UPDATE 
-		SET tx_hash = $1, tx_index = $2, tx_tree = $3 RETURNING id;`
+		SET tx_hash = decode($1, 'hex'), tx_index = $2, tx_tree = $3 RETURNING id;`
 	insertVoutRowReturnId = `WITH inserting AS (` +
 		insertVoutRow0 +
 		`ON CONFLICT (tx_hash, tx_index, tx_tree) DO UPDATE
@@ -100,7 +105,7 @@ const (
 	 SELECT id FROM inserting
 	 UNION  ALL
 	 SELECT id FROM vouts
-	 WHERE  tx_hash = $1 AND tx_index = $2 AND tx_tree = $3
+	 WHERE  tx_hash = decode($1, 'hex') AND tx_index = $2 AND tx_tree = $3
 	 LIMIT  1;`
 
 	DeleteVoutDuplicateRows = `DELETE FROM vouts
@@ -111,11 +116,11 @@ const (
 			WHERE t.rnum > 1);`
 
 	SelectPkScriptByID     = `SELECT pkscript FROM vouts WHERE id=$1;`
-	SelectVoutIDByOutpoint = `SELECT id FROM vouts WHERE tx_hash=$1 and tx_index=$2;`
+	SelectVoutIDByOutpoint = `SELECT id FROM vouts WHERE tx_hash=decode($1, 'hex') and tx_index=$2;`
 	SelectVoutByID         = `SELECT * FROM vouts WHERE id=$1;`
 
-	RetrieveVoutValue  = `SELECT value FROM vouts WHERE tx_hash=$1 and tx_index=$2;`
-	RetrieveVoutValues = `SELECT value, tx_index, tx_tree FROM vouts WHERE tx_hash=$1;`
+	RetrieveVoutValue  = `SELECT value FROM vouts WHERE tx_hash=decode($1, 'hex') and tx_index=$2;`
+	RetrieveVoutValues = `SELECT value, tx_index, tx_tree FROM vouts WHERE tx_hash=decode($1, 'hex');`
 
 	IndexVoutTableOnTxHashIdx = `CREATE UNIQUE INDEX uix_vout_txhash_ind
 		ON vouts(tx_hash, tx_index, tx_tree);`
@@ -143,11 +148,12 @@ func MakeVinInsertStatement(checked bool) string {
 }
 
 var (
-	voutCopyStmt = pq.CopyIn("vouts",
+	voutCopyStmt = pq.CopyIn("vouts", "id",
 		"tx_hash", "tx_index", "tx_tree", "value", "version",
-		"pkscript", "script_req_sigs", " script_type", "script_addresses")
-	vinCopyStmt = pq.CopyIn("vins",
-		"tx_hash", "tx_index", "prev_tx_hash", "prev_tx_index")
+		"pkscript", "script_req_sigs", "script_type", "script_addresses")
+	vinCopyStmt = pq.CopyIn("vins", "id",
+		"tx_hash", "tx_index", "tx_tree",
+		"prev_tx_hash", "prev_tx_index", "prev_tx_tree")
 )
 
 func MakeVoutCopyInStatement() string {
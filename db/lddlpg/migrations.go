@@ -0,0 +1,317 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package lddlpg
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Legenddigital/lddldata/db/lddlpg/internal"
+)
+
+// migration is a versioned change of the database schema. The up statements
+// must be idempotent, as in CREATE TABLE IF NOT EXISTS and ADD COLUMN IF NOT
+// EXISTS, since tables created by CreateTables already have the latest schema,
+// and a database created before the schema_versions table has no record of the
+// migrations it already has. They are executed in a single database
+// transaction with the record of the migration.
+type migration struct {
+	version     uint32
+	description string
+	up          []string
+	// backfill, if set, populates existing rows after the up statements. See
+	// backfillFunc.
+	backfill backfillFunc
+}
+
+// backfillFunc processes one batch of a migration's data backfill, with the
+// rows after cursor, in the given database transaction. It returns the cursor
+// of the next batch, and whether the backfill is complete. The cursor, such as
+// the last processed row ID, is stored with the batch's changes, so that an
+// interrupted backfill resumes after the last committed batch.
+type backfillFunc func(dbtx *sql.Tx, cursor int64) (next int64, done bool, err error)
+
+// migrations lists the schema migrations, in order of version. Versions must
+// not be changed or reused once released. When a migration changes a table,
+// its version in requiredVersions should be bumped too.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create the blocks, transactions, vins, vouts, block_chain, addresses, tickets, votes and misses tables",
+		up: []string{
+			internal.CreateBlockTable,
+			internal.CreateTransactionTable,
+			internal.CreateVinTable,
+			internal.CreateVoutTable,
+			internal.CreateBlockPrevNextTable,
+			internal.CreateAddressTable,
+			internal.CreateTicketsTable,
+			internal.CreateVotesTable,
+			internal.CreateMissesTable,
+		},
+	},
+	{
+		version:     2,
+		description: "add the is_mainchain column to the blocks table",
+		up:          []string{internal.AddBlocksMainchainColumn},
+	},
+	{
+		version:     3,
+		description: "create the meta table with the last committed block",
+		up: []string{
+			internal.CreateMetaTableV1,
+			internal.SetMetaBestBlockFromBlocks,
+		},
+	},
+	{
+		version:     4,
+		description: "create the block_transactions table from the blocks table's transaction arrays",
+		up: []string{
+			internal.CreateBlockTxnsTable,
+			internal.IndexBlockTxnsTableOnTxDbID,
+		},
+		backfill: backfillBlockTxns,
+	},
+	{
+		version:     5,
+		description: "drop the transaction arrays of the blocks and transactions tables",
+		up: []string{
+			internal.DropBlocksTxColumns,
+			internal.DropTransactionsVinVoutDbIDsColumns,
+		},
+	},
+	{
+		version:     6,
+		description: "index the tickets table on stake submission address",
+		up:          []string{internal.IndexTicketsTableOnStakeSubmissionAddress},
+	},
+	{
+		version:     7,
+		description: "store the hashes of the blocks, block_chain and meta tables as BYTEA",
+		up: []string{
+			internal.ConvertBlocksHashesToBytea,
+			internal.ConvertBlockChainHashesToBytea,
+			internal.ConvertMetaHashesToBytea,
+		},
+	},
+	{
+		version:     8,
+		description: "store the hashes of the transactions table as BYTEA",
+		up:          []string{internal.ConvertTransactionsHashesToBytea},
+	},
+	{
+		version:     9,
+		description: "store the hashes of the vins and vouts tables as BYTEA",
+		up: []string{
+			internal.ConvertVinsHashesToBytea,
+			internal.ConvertVoutsHashesToBytea,
+		},
+	},
+	{
+		version:     10,
+		description: "store the transaction hashes of the addresses table as BYTEA",
+		up:          []string{internal.ConvertAddressesHashesToBytea},
+	},
+	{
+		version:     11,
+		description: "store the hashes of the tickets, votes and misses tables as BYTEA",
+		up: []string{
+			internal.ConvertTicketsHashesToBytea,
+			internal.ConvertVotesHashesToBytea,
+			internal.ConvertMissesHashesToBytea,
+		},
+	},
+}
+
+// backfillBlocksBatchSize is the number of blocks processed in each batch of
+// a blocks table backfill.
+const backfillBlocksBatchSize = 2000
+
+// backfillBlockTxns fills the block_transactions table from the txDbIDs and
+// stxDbIDs arrays of the next batch of blocks after the cursor block row ID.
+// A blocks table created without the arrays has nothing to backfill.
+func backfillBlockTxns(dbtx *sql.Tx, cursor int64) (int64, bool, error) {
+	var hasArrays bool
+	if err := dbtx.QueryRow(internal.SelectBlocksHasTxDbIDs).Scan(&hasArrays); err != nil {
+		return cursor, false, err
+	}
+	if !hasArrays {
+		return cursor, true, nil
+	}
+
+	var last sql.NullInt64
+	err := dbtx.QueryRow(internal.SelectBlocksIDRangeEnd, cursor,
+		backfillBlocksBatchSize).Scan(&last)
+	if err != nil {
+		return cursor, false, err
+	}
+	if !last.Valid {
+		return cursor, true, nil
+	}
+
+	_, err = dbtx.Exec(internal.InsertBlockTxnsFromArrays, cursor, last.Int64)
+	return last.Int64, false, err
+}
+
+// schemaVersion is the record of an applied migration.
+type schemaVersion struct {
+	backfillCursor int64
+	backfillDone   bool
+}
+
+// migrate applies the schema migrations not yet recorded in the schema_versions
+// table, in order, and resumes any incomplete data backfills. A migration's
+// backfill is completed before the next migration is applied. It is run by
+// setupTables, which then updates the versions in the table comments.
+func migrate(db *sql.DB) error {
+	if err := CreateTable(db, "schema_versions"); err != nil {
+		return fmt.Errorf("failed to create the schema_versions table: %v", err)
+	}
+
+	applied, err := retrieveSchemaVersions(db)
+	if err != nil {
+		return err
+	}
+
+	for i := range migrations {
+		m := &migrations[i]
+		sv, ok := applied[m.version]
+		if !ok {
+			log.Infof("Applying schema migration %d: %s.", m.version, m.description)
+			if err = applyMigration(db, m); err != nil {
+				return fmt.Errorf("schema migration %d failed: %v", m.version, err)
+			}
+			sv = &schemaVersion{backfillDone: m.backfill == nil}
+		}
+
+		if !sv.backfillDone {
+			if err = runBackfill(db, m, sv.backfillCursor); err != nil {
+				return fmt.Errorf("backfill of schema migration %d failed: %v",
+					m.version, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the version of the last applied schema migration, or 0
+// if none are recorded.
+func (pgb *ChainDB) SchemaVersion() (uint32, error) {
+	applied, err := retrieveSchemaVersions(pgb.db)
+	if err != nil {
+		return 0, err
+	}
+	var version uint32
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+func retrieveSchemaVersions(db *sql.DB) (map[uint32]*schemaVersion, error) {
+	rows, err := db.Query(internal.SelectSchemaVersions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the schema versions: %v", err)
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	applied := make(map[uint32]*schemaVersion)
+	for rows.Next() {
+		var version uint32
+		sv := new(schemaVersion)
+		if err = rows.Scan(&version, &sv.backfillCursor, &sv.backfillDone); err != nil {
+			return nil, fmt.Errorf("failed to scan the schema versions: %v", err)
+		}
+		applied[version] = sv
+	}
+	return applied, rows.Err()
+}
+
+// applyMigration executes the up statements of a migration and records it in a
+// single database transaction.
+func applyMigration(db *sql.DB, m *migration) error {
+	dbtx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to begin database transaction: %v", err)
+	}
+
+	for _, stmt := range m.up {
+		if _, err = dbtx.Exec(stmt); err != nil {
+			if errRoll := dbtx.Rollback(); errRoll != nil {
+				log.Errorf("Rollback failed: %v", errRoll)
+			}
+			return err
+		}
+	}
+
+	_, err = dbtx.Exec(internal.InsertSchemaVersion, m.version, m.description,
+		m.backfill == nil)
+	if err != nil {
+		if errRoll := dbtx.Rollback(); errRoll != nil {
+			log.Errorf("Rollback failed: %v", errRoll)
+		}
+		return fmt.Errorf("failed to record the schema version: %v", err)
+	}
+
+	return dbtx.Commit()
+}
+
+// runBackfill runs the batches of a migration's backfill from the cursor until
+// it is complete, committing each batch with the new cursor.
+func runBackfill(db *sql.DB, m *migration, cursor int64) error {
+	log.Infof("Backfilling schema migration %d (from %d). This may take a while...",
+		m.version, cursor)
+	for {
+		dbtx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("unable to begin database transaction: %v", err)
+		}
+
+		next, done, err := m.backfill(dbtx, cursor)
+		if err == nil {
+			_, err = dbtx.Exec(internal.UpdateSchemaVersionBackfill,
+				m.version, next, done)
+		}
+		if err != nil {
+			if errRoll := dbtx.Rollback(); errRoll != nil {
+				log.Errorf("Rollback failed: %v", errRoll)
+			}
+			return err
+		}
+		if err = dbtx.Commit(); err != nil {
+			return err
+		}
+
+		if done {
+			log.Infof("Backfill of schema migration %d complete.", m.version)
+			return nil
+		}
+		log.Debugf("Backfill of schema migration %d at %d.", m.version, next)
+		cursor = next
+	}
+}
+
+// setTableVersions sets the version in the comment of each table to its
+// required version.
+func setTableVersions(db *sql.DB) error {
+	for tableName := range createTableStatements {
+		tableVersion, ok := requiredVersions[tableName]
+		if !ok {
+			return fmt.Errorf("no version assigned to table %s", tableName)
+		}
+		_, err := db.Exec(fmt.Sprintf(`COMMENT ON TABLE %s IS 'v%s';`,
+			tableName, tableVersion))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
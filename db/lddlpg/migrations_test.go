@@ -0,0 +1,272 @@
+// +build pgonline
+
+package lddlpg
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/Legenddigital/lddld/chaincfg"
+)
+
+// The tables of a database created before the schema migrations, at v2.0.0,
+// with the hashes stored as hex TEXT and the transaction row IDs in arrays.
+var baselineTables = []string{
+	`CREATE TABLE blocks (
+		id SERIAL PRIMARY KEY,
+		hash TEXT NOT NULL,
+		height INT4,
+		size INT4,
+		is_valid BOOLEAN,
+		version INT4,
+		merkle_root TEXT,
+		stake_root TEXT,
+		numtx INT4,
+		num_rtx INT4,
+		tx TEXT[],
+		txDbIDs INT8[],
+		num_stx INT4,
+		stx TEXT[],
+		stxDbIDs INT8[],
+		time INT8,
+		nonce INT8,
+		vote_bits INT2,
+		final_state BYTEA,
+		voters INT2,
+		fresh_stake INT2,
+		revocations INT2,
+		pool_size INT4,
+		bits INT4,
+		sbits INT8,
+		difficulty FLOAT8,
+		extra_data BYTEA,
+		stake_version INT4,
+		previous_hash TEXT
+	);`,
+	`CREATE TABLE block_chain (
+		block_db_id INT8 PRIMARY KEY,
+		prev_hash TEXT NOT NULL,
+		this_hash TEXT UNIQUE NOT NULL,
+		next_hash TEXT
+	);`,
+	`CREATE TABLE transactions (
+		id SERIAL8 PRIMARY KEY,
+		block_hash TEXT,
+		block_height INT8,
+		block_time INT8,
+		time INT8,
+		tx_type INT4,
+		version INT4,
+		tree INT2,
+		tx_hash TEXT,
+		block_index INT4,
+		lock_time INT4,
+		expiry INT4,
+		size INT4,
+		spent INT8,
+		sent INT8,
+		fees INT8,
+		num_vin INT4,
+		vin_db_ids INT8[],
+		num_vout INT4,
+		vout_db_ids INT8[]
+	);`,
+	`CREATE TABLE vins (
+		id SERIAL8 PRIMARY KEY,
+		tx_hash TEXT,
+		tx_index INT4,
+		tx_tree INT2,
+		prev_tx_hash TEXT,
+		prev_tx_index INT8,
+		prev_tx_tree INT2
+	);`,
+	`CREATE TABLE vouts (
+		id SERIAL8 PRIMARY KEY,
+		tx_hash TEXT,
+		tx_index INT4,
+		tx_tree INT2,
+		value INT8,
+		version INT2,
+		pkscript BYTEA,
+		script_req_sigs INT4,
+		script_type TEXT,
+		script_addresses TEXT[]
+	);`,
+	`CREATE TABLE addresses (
+		id SERIAL8 PRIMARY KEY,
+		address TEXT,
+		funding_tx_row_id INT8,
+		funding_tx_hash TEXT,
+		funding_tx_vout_index INT8,
+		vout_row_id INT8,
+		value INT8,
+		spending_tx_row_id INT8,
+		spending_tx_hash TEXT,
+		spending_tx_vin_index INT4,
+		vin_row_id INT8
+	);`,
+	`CREATE TABLE tickets (
+		id SERIAL PRIMARY KEY,
+		tx_hash TEXT NOT NULL,
+		block_hash TEXT NOT NULL,
+		block_height INT4,
+		purchase_tx_db_id INT8,
+		stakesubmission_address TEXT,
+		is_multisig BOOLEAN,
+		is_split BOOLEAN,
+		num_inputs INT2,
+		price FLOAT8,
+		fee FLOAT8,
+		spend_type INT2,
+		pool_status INT2,
+		spend_height INT4,
+		spend_tx_db_id INT8
+	);`,
+	`CREATE TABLE votes (
+		id SERIAL PRIMARY KEY,
+		height INT4,
+		tx_hash TEXT NOT NULL,
+		block_hash TEXT NOT NULL,
+		candidate_block_hash TEXT NOT NULL,
+		version INT2,
+		vote_bits INT2,
+		block_valid BOOLEAN,
+		ticket_hash TEXT,
+		ticket_tx_db_id INT8,
+		ticket_price FLOAT8,
+		vote_reward FLOAT8
+	);`,
+	`CREATE TABLE misses (
+		id SERIAL PRIMARY KEY,
+		height INT4,
+		block_hash TEXT NOT NULL,
+		candidate_block_hash TEXT NOT NULL,
+		ticket_hash TEXT NOT NULL
+	);`,
+}
+
+const (
+	baselineBlockHash  = "000000000000022173bcd0e354bb3b68f33af459cb68b8dd1f2831172c499c0b"
+	baselinePrevHash   = "00000000000003c55c4e2b3ab5a3bf8d9b1cbbf6d3a1f6de58d4af3c32e0fb4f"
+	baselineTxHash     = "f4a44e6916f9ee5a2e41558e0662c1d26206780078dc0a426b3607fd43e34145"
+	baselineTicketHash = "ce6a41aa545af4dfc3b6d9c31f15d0be28b890f24f4344be90a55eda96418cad"
+)
+
+// baselineRows are a block with one transaction, and a live ticket.
+var baselineRows = []string{
+	`INSERT INTO blocks (id, hash, height, previous_hash, tx, txDbIDs, stx, stxDbIDs)
+		VALUES (1, '` + baselineBlockHash + `', 100, '` + baselinePrevHash + `',
+			ARRAY['` + baselineTxHash + `'], ARRAY[1], ARRAY['` + baselineTicketHash + `'], ARRAY[2]);`,
+	`INSERT INTO block_chain (block_db_id, prev_hash, this_hash)
+		VALUES (1, '` + baselinePrevHash + `', '` + baselineBlockHash + `');`,
+	`INSERT INTO transactions (id, block_hash, block_height, tree, tx_hash, block_index, vin_db_ids, vout_db_ids)
+		VALUES (1, '` + baselineBlockHash + `', 100, 0, '` + baselineTxHash + `', 0, ARRAY[1], ARRAY[1]),
+			(2, '` + baselineBlockHash + `', 100, 1, '` + baselineTicketHash + `', 0, ARRAY[]::INT8[], ARRAY[]::INT8[]);`,
+	`INSERT INTO vins (id, tx_hash, tx_index, tx_tree, prev_tx_hash, prev_tx_index, prev_tx_tree)
+		VALUES (1, '` + baselineTxHash + `', 0, 0, '` + baselinePrevHash + `', 0, 0);`,
+	`INSERT INTO vouts (id, tx_hash, tx_index, tx_tree, value)
+		VALUES (1, '` + baselineTxHash + `', 0, 0, 12345);`,
+	`INSERT INTO addresses (id, address, funding_tx_row_id, funding_tx_hash, funding_tx_vout_index, vout_row_id, value)
+		VALUES (1, 'Dsa3yVGJK9XFx6L5cC8YzcW3M5Q85wdEXcz', 1, '` + baselineTxHash + `', 0, 1, 12345);`,
+	`INSERT INTO tickets (id, tx_hash, block_hash, block_height, purchase_tx_db_id, spend_type, pool_status)
+		VALUES (1, '` + baselineTicketHash + `', '` + baselineBlockHash + `', 100, 2, 0, 0);`,
+}
+
+func TestUpgradeBaselineSchema(t *testing.T) {
+	dbi := DBInfo{
+		Host:   "localhost",
+		Port:   "5432",
+		User:   "lddldata",
+		Pass:   "lddldata",
+		DBName: "lddldata_test",
+	}
+	db, err := Connect(dbi.Host, dbi.Port, dbi.User, dbi.Pass, dbi.DBName)
+	if err != nil {
+		t.Fatalf("no db for testing: %v", err)
+	}
+	DropTables(db)
+	for _, stmt := range append(baselineTables, baselineRows...) {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatalf("failed to create the baseline tables: %v", err)
+		}
+	}
+	for tableName := range requiredVersions {
+		if _, err = db.Exec(`COMMENT ON TABLE ` + tableName + ` IS 'v2.0.0';`); err != nil {
+			if exists, _ := TableExists(db, tableName); exists {
+				t.Fatalf("failed to set the table version: %v", err)
+			}
+		}
+	}
+	db.Close()
+
+	pgb, err := NewChainDB(&dbi, &chaincfg.MainNetParams, nil, false)
+	if err != nil {
+		t.Fatalf("NewChainDB failed to upgrade the baseline tables: %v", err)
+	}
+	defer pgb.Close()
+
+	if err = pgb.VersionCheck(); err != nil {
+		t.Errorf("VersionCheck: %v", err)
+	}
+	version, err := pgb.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if last := migrations[len(migrations)-1].version; version != last {
+		t.Errorf("Schema version is %d, wanted %d.", version, last)
+	}
+
+	if pgb.bestBlock != 100 {
+		t.Errorf("Best block height is %d, wanted 100.", pgb.bestBlock)
+	}
+	height, hash, err := RetrieveMetaBestBlock(pgb.db)
+	if err != nil {
+		t.Fatalf("RetrieveMetaBestBlock: %v", err)
+	}
+	if height != 100 || hash != baselineBlockHash {
+		t.Errorf("Meta best block is %d (%s), wanted 100 (%s).", height, hash,
+			baselineBlockHash)
+	}
+
+	if id, err := pgb.unspentTicketCache.TxnDbID(baselineTicketHash, false); err != nil || id != 1 {
+		t.Errorf("Unspent ticket row ID is %d (%v), wanted 1.", id, err)
+	}
+
+	value, err := RetrieveVoutValue(pgb.db, baselineTxHash, 0)
+	if err != nil || value != 12345 {
+		t.Errorf("Vout value is %d (%v), wanted 12345.", value, err)
+	}
+
+	var blockTxns int
+	err = pgb.db.QueryRow(`SELECT COUNT(*) FROM block_transactions WHERE block_db_id = 1;`).
+		Scan(&blockTxns)
+	if err != nil || blockTxns != 2 {
+		t.Errorf("Block has %d (%v) rows in block_transactions, wanted 2.",
+			blockTxns, err)
+	}
+
+	hashColumns := map[string][]string{
+		"blocks":       {"hash", "merkle_root", "stake_root", "previous_hash"},
+		"block_chain":  {"prev_hash", "this_hash", "next_hash"},
+		"meta":         {"best_block_hash"},
+		"transactions": {"block_hash", "tx_hash"},
+		"vins":         {"tx_hash", "prev_tx_hash"},
+		"vouts":        {"tx_hash"},
+		"addresses":    {"funding_tx_hash", "spending_tx_hash"},
+		"tickets":      {"tx_hash", "block_hash"},
+		"votes":        {"tx_hash", "block_hash", "candidate_block_hash", "ticket_hash"},
+		"misses":       {"block_hash", "candidate_block_hash", "ticket_hash"},
+	}
+	for tableName, columns := range hashColumns {
+		for _, column := range columns {
+			var dataType string
+			err = pgb.db.QueryRow(`SELECT data_type FROM information_schema.columns
+				WHERE table_name = $1 AND column_name = $2;`, tableName, column).
+				Scan(&dataType)
+			if err == sql.ErrNoRows || dataType != "bytea" {
+				t.Errorf("Column %s.%s is %q (%v), wanted bytea.", tableName,
+					column, dataType, err)
+			}
+		}
+	}
+}
@@ -6,7 +6,6 @@ package lddlpg
 import (
 	"bytes"
 	"database/sql"
-	"errors"
 	"fmt"
 	"runtime"
 	"strings"
@@ -72,6 +71,7 @@ type ChainDB struct {
 	devAddress         string
 	dupChecks          bool
 	bestBlock          int64
+	bestBlockMtx       sync.RWMutex
 	lastBlock          map[chainhash.Hash]uint64
 	addressCounts      *addressCounter
 	stakeDB            *stakedb.StakeDatabase
@@ -159,6 +159,18 @@ func (t *TicketTxnIDGetter) SetN(txid []string, txDbID []uint64) {
 	}
 }
 
+// Delete removes the cached DB row IDs for the given ticket hashes.
+func (t *TicketTxnIDGetter) Delete(txids []string) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	for i := range txids {
+		delete(t.idCache, txids[i])
+	}
+}
+
 // NewTicketTxnIDGetter constructs a new TicketTxnIDGetter with an empty cache.
 func NewTicketTxnIDGetter(db *sql.DB) *TicketTxnIDGetter {
 	return &TicketTxnIDGetter{
@@ -181,11 +193,15 @@ func NewChainDB(dbi *DBInfo, params *chaincfg.Params, stakeDB *stakedb.StakeData
 	if err != nil {
 		return nil, err
 	}
-	// Attempt to get DB best block height from tables, but if the tables are
-	// empty or not yet created, it is not an error.
+
+	// Bring the tables to the latest schema before loading any state from them.
+	if err = setupTables(db); err != nil {
+		return nil, err
+	}
+
+	// The blocks table is empty in a new database, which is not an error.
 	bestHeight, _, _, err := RetrieveBestBlockHeight(db)
-	if err != nil && !(err == sql.ErrNoRows ||
-		strings.HasSuffix(err.Error(), "does not exist")) {
+	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
 
@@ -195,16 +211,10 @@ func NewChainDB(dbi *DBInfo, params *chaincfg.Params, stakeDB *stakedb.StakeData
 		log.Warnf("ChainDB.NewChainDB: %v", err)
 	}
 
-	if err = setupTables(db); err != nil {
-		log.Warnf("ATTENTION! %v", err)
-		// TODO: Actually handle the upgrades/reindexing somewhere.
-		return nil, err
-	}
-
 	log.Infof("Pre-loading unspent ticket info for InsertVote optimization.")
 	unspentTicketCache := NewTicketTxnIDGetter(db)
 	unspentTicketDbIDs, unspentTicketHashes, err := RetrieveUnspentTickets(db)
-	if err != nil && err != sql.ErrNoRows && !strings.HasSuffix(err.Error(), "does not exist") {
+	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
 	if len(unspentTicketDbIDs) != 0 {
@@ -245,23 +255,79 @@ func (pgb *ChainDB) EnableDuplicateCheckOnInsert(dupCheck bool) {
 	pgb.dupChecks = dupCheck
 }
 
-// SetupTables creates the required tables and type, and prints table versions
-// stored in the table comments when debug level logging is enabled.
+// SetupTables creates the required tables and types, and applies the pending
+// schema migrations (see setupTables).
 func (pgb *ChainDB) SetupTables() error {
 	return setupTables(pgb.db)
 }
 
+// setupTables brings the tables to the latest schema. The pending migrations of
+// an existing database are applied before the tables it lacks are created,
+// since they upgrade its tables from the schema they were created with. A new
+// database is created with the latest schema, and the migrations, which are
+// then no-ops, are only recorded. An error is returned when a table must be
+// rebuilt, which no migration can do.
 func setupTables(db *sql.DB) error {
 	if err := CreateTypes(db); err != nil {
 		return err
 	}
 
-	return CreateTables(db)
+	existing, err := TableExists(db, "blocks")
+	if err != nil {
+		return err
+	}
+	if existing {
+		if err = checkTableVersions(db); err != nil {
+			log.Warnf("ATTENTION! %v", err)
+			return err
+		}
+		if err = migrate(db); err != nil {
+			return err
+		}
+		if err = CreateTables(db); err != nil {
+			return err
+		}
+	} else {
+		if err = CreateTables(db); err != nil {
+			return err
+		}
+		if err = migrate(db); err != nil {
+			return err
+		}
+	}
+
+	return setTableVersions(db)
 }
 
-// VersionCheck checks the current version of all known tables and notifies when
-// an upgrade is required. Since there is presently no automatic upgrade, an
-// error is returned when any table is not of the correct version.
+// checkTableVersions logs the versions of the existing tables, and returns an
+// error if any must be rebuilt.
+func checkTableVersions(db *sql.DB) error {
+	var rebuild bool
+	for tab, ver := range TableVersions(db) {
+		log.Debugf("Table %s: v%s", tab, ver)
+		u := TableUpgrade{
+			TableName:   tab,
+			UpgradeType: TableVersionCompatible(requiredVersions[tab], ver),
+			CurrentVer:  ver,
+			RequiredVer: requiredVersions[tab],
+		}
+		switch u.UpgradeType {
+		case "ok":
+		case "upgrade", "reindex":
+			log.Infof(u.String())
+		default:
+			log.Warnf(u.String())
+			rebuild = true
+		}
+	}
+	if rebuild {
+		return fmt.Errorf("tables must be rebuilt")
+	}
+	return nil
+}
+
+// VersionCheck checks that all known tables are at their required versions,
+// as they are once NewChainDB has applied the schema migrations.
 func (pgb *ChainDB) VersionCheck() error {
 	vers := TableVersions(pgb.db)
 	for tab, ver := range vers {
@@ -281,23 +347,44 @@ func (pgb *ChainDB) DropTables() {
 	DropTables(pgb.db)
 }
 
-// HeightDB queries the DB for the best block height.
+// HeightDB queries the DB for the best block height. This is the last block
+// fully committed, from which a sync resumes.
 func (pgb *ChainDB) HeightDB() (uint64, error) {
-	bestHeight, _, _, err := RetrieveBestBlockHeight(pgb.db)
+	bestHeight, _, err := retrieveCommittedBestBlock(pgb.db)
 	return bestHeight, err
 }
 
 // HashDB queries the DB for the best block's hash.
 func (pgb *ChainDB) HashDB() (string, error) {
-	_, bestHash, _, err := RetrieveBestBlockHeight(pgb.db)
+	_, bestHash, err := retrieveCommittedBestBlock(pgb.db)
 	return bestHash, err
 }
 
+// retrieveCommittedBestBlock gets the last committed block from the meta
+// table. A database without this record, as one created before the meta table
+// was added, falls back to the best main chain block in the blocks table.
+func retrieveCommittedBestBlock(db *sql.DB) (uint64, string, error) {
+	height, hash, err := RetrieveMetaBestBlock(db)
+	if err == sql.ErrNoRows {
+		height, hash, _, err = RetrieveBestBlockHeight(db)
+	}
+	return height, hash, err
+}
+
 // Height uses the last stored height.
 func (pgb *ChainDB) Height() uint64 {
+	pgb.bestBlockMtx.RLock()
+	defer pgb.bestBlockMtx.RUnlock()
 	return uint64(pgb.bestBlock)
 }
 
+// setHeight sets the last stored height returned by Height.
+func (pgb *ChainDB) setHeight(height int64) {
+	pgb.bestBlockMtx.Lock()
+	pgb.bestBlock = height
+	pgb.bestBlockMtx.Unlock()
+}
+
 // SpendingTransactions retrieves all transactions spending outpoints from the
 // specified funding transaction. The spending transaction hashes, the spending
 // tx input indexes, and the corresponding funding tx output indexes, and an
@@ -329,6 +416,43 @@ func (pgb *ChainDB) BlockMissedVotes(blockHash string) ([]string, error) {
 	return RetrieveMissedVotesInBlock(pgb.db, blockHash)
 }
 
+// VoteBitsCounts retrieves the number of votes of the given vote version with
+// each vote bits in each main chain block, by increasing height.
+func (pgb *ChainDB) VoteBitsCounts(version uint32) ([]*dbtypes.VoteBitsCount, error) {
+	return RetrieveVoteBitsCounts(pgb.db, version)
+}
+
+// ChartBlocks retrieves the chart data of the main chain blocks above the
+// given height, by increasing height.
+func (pgb *ChainDB) ChartBlocks(height int64) ([]*dbtypes.ChartBlock, error) {
+	return RetrieveChartBlocks(pgb.db, height, int64(pgb.chainParams.TicketMaturity),
+		int64(pgb.chainParams.TicketExpiry))
+}
+
+// MissesInBlockRange retrieves the missed votes of the main chain blocks from
+// height idx0 to idx.
+func (pgb *ChainDB) MissesInBlockRange(idx0, idx int64) (*apitypes.MissesRange, error) {
+	return RetrieveMissesInBlockRange(pgb.db, idx0, idx)
+}
+
+// MissesByDay retrieves the votes, missed votes and expired tickets of the
+// main chain blocks from height idx0 to idx, by UTC day.
+func (pgb *ChainDB) MissesByDay(idx0, idx int64) ([]*apitypes.DayMisses, error) {
+	return RetrieveMissesByDay(pgb.db, idx0, idx, pgb.ticketLifetime())
+}
+
+// MissesByAddress retrieves the votes, missed votes and expired tickets from
+// height idx0 to idx of each stake submission address with missed votes.
+func (pgb *ChainDB) MissesByAddress(idx0, idx int64) ([]*apitypes.AddressMisses, error) {
+	return RetrieveMissesByAddress(pgb.db, idx0, idx, pgb.ticketLifetime())
+}
+
+// ticketLifetime is the number of blocks after its purchase that an unspent
+// ticket expires.
+func (pgb *ChainDB) ticketLifetime() int64 {
+	return int64(pgb.chainParams.TicketMaturity) + int64(pgb.chainParams.TicketExpiry)
+}
+
 // PoolStatusForTicket retrieves the specified ticket's spend status and ticket
 // pool status, and an error value.
 func (pgb *ChainDB) PoolStatusForTicket(txid string) (dbtypes.TicketSpendType, dbtypes.TicketPoolStatus, error) {
@@ -336,6 +460,68 @@ func (pgb *ChainDB) PoolStatusForTicket(txid string) (dbtypes.TicketSpendType, d
 	return spendType, poolStatus, err
 }
 
+// TicketInfo retrieves the lifecycle of the specified main chain ticket.
+func (pgb *ChainDB) TicketInfo(txid string) (*apitypes.TicketInfo, error) {
+	bestHeight, _, _, err := RetrieveBestBlockHeight(pgb.db)
+	if err != nil {
+		return nil, err
+	}
+	return RetrieveTicketInfo(pgb.db, txid, int64(bestHeight), pgb.chainParams)
+}
+
+// AddressTickets retrieves the main chain tickets with the given stake
+// submission address, with the number of tickets by status and the return on
+// investment of the voted tickets.
+func (pgb *ChainDB) AddressTickets(address string) (*apitypes.AddressTickets, error) {
+	bestHeight, _, _, err := RetrieveBestBlockHeight(pgb.db)
+	if err != nil {
+		return nil, err
+	}
+	tickets, err := RetrieveAddressTickets(pgb.db, address, int64(bestHeight),
+		pgb.chainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	at := &apitypes.AddressTickets{
+		Address:     address,
+		BlockHeight: int64(bestHeight),
+		NumTickets:  len(tickets),
+		Tickets:     tickets,
+	}
+	if tickets == nil {
+		at.Tickets = []*apitypes.TicketInfo{}
+	}
+	voted := strings.ToLower(dbtypes.PoolStatusVoted.String())
+	missed := strings.ToLower(dbtypes.PoolStatusMissed.String())
+	expired := strings.ToLower(dbtypes.PoolStatusExpired.String())
+	revoked := strings.ToLower(dbtypes.TicketRevoked.String())
+	var votedPrice float64
+	for _, ticket := range tickets {
+		at.TotalPrice += ticket.Price
+		at.TotalFees += ticket.Fee
+		switch ticket.PoolStatus {
+		case voted:
+			at.NumVoted++
+			at.TotalRewards += ticket.VoteReward
+			votedPrice += ticket.Price
+		case missed:
+			at.NumMissed++
+		case expired:
+			at.NumExpired++
+		default:
+			at.NumLive++
+		}
+		if ticket.SpendType == revoked {
+			at.NumRevoked++
+		}
+	}
+	if votedPrice > 0 {
+		at.ROI = at.TotalRewards / votedPrice
+	}
+	return at, nil
+}
+
 // VoutValue retrieves the value of the specified transaction outpoint in atoms.
 func (pgb *ChainDB) VoutValue(txID string, vout uint32) (uint64, error) {
 	// txDbID, _, _, err := RetrieveTxByHash(pgb.db, txID)
@@ -689,6 +875,101 @@ func (pgb *ChainDB) AddressTotals(address string) (*apitypes.AddressTotals, erro
 	}, nil
 }
 
+// AddressesTxns returns the merged transaction history of a set of addresses,
+// up to N transactions skipping the offset most recent.
+func (pgb *ChainDB) AddressesTxns(addresses []string, N, offset int64) (*apitypes.AddressesTxns, error) {
+	txns, numTxns, err := RetrieveAddressesMergedTxns(pgb.db, addresses, N, offset)
+	if err != nil {
+		return nil, err
+	}
+	if txns == nil {
+		txns = []*apitypes.AddressesTxn{}
+	}
+	return &apitypes.AddressesTxns{
+		Addresses:    addresses,
+		NumTxns:      numTxns,
+		Count:        int64(len(txns)),
+		Skip:         offset,
+		Transactions: txns,
+	}, nil
+}
+
+// AddressesTotals returns the combined spent and unspent output totals of a
+// set of addresses, and the totals of each address.
+func (pgb *ChainDB) AddressesTotals(addresses []string) (*apitypes.AddressesTotals, error) {
+	bestHeight, bestHash, _, err := RetrieveBestBlockHeight(pgb.db)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := RetrieveAddressesTotals(pgb.db, addresses)
+	if err != nil {
+		return nil, err
+	}
+	byAddress := make(map[string]*explorer.AddressBalance, len(balances))
+	for _, ab := range balances {
+		byAddress[ab.Address] = ab
+	}
+
+	totals := &apitypes.AddressesTotals{
+		BlockHash:   bestHash,
+		BlockHeight: bestHeight,
+		Addresses:   make([]*apitypes.AddressTotals, 0, len(addresses)),
+	}
+	var totalSpent, totalUnspent int64
+	for _, address := range addresses {
+		ab, ok := byAddress[address]
+		if !ok {
+			ab = &explorer.AddressBalance{Address: address}
+		}
+		totals.NumSpent += ab.NumSpent
+		totals.NumUnspent += ab.NumUnspent
+		totalSpent += ab.TotalSpent
+		totalUnspent += ab.TotalUnspent
+		totals.Addresses = append(totals.Addresses, &apitypes.AddressTotals{
+			Address:      address,
+			BlockHeight:  bestHeight,
+			BlockHash:    bestHash,
+			NumSpent:     ab.NumSpent,
+			NumUnspent:   ab.NumUnspent,
+			CoinsSpent:   lddlutil.Amount(ab.TotalSpent).ToCoin(),
+			CoinsUnspent: lddlutil.Amount(ab.TotalUnspent).ToCoin(),
+		})
+	}
+	totals.CoinsSpent = lddlutil.Amount(totalSpent).ToCoin()
+	totals.CoinsUnspent = lddlutil.Amount(totalUnspent).ToCoin()
+	return totals, nil
+}
+
+// AddressUTXOs returns the unspent outputs of an address with at least minConf
+// confirmations and values between minAtoms and maxAtoms. If tree is negative,
+// outputs of both the regular and stake trees are returned.
+func (pgb *ChainDB) AddressUTXOs(address string, minConf, minAtoms, maxAtoms int64,
+	tree int8) ([]*apitypes.AddressUTXO, error) {
+	bestHeight, _, _, err := RetrieveBestBlockHeight(pgb.db)
+	if err != nil {
+		return nil, err
+	}
+	maxHeight := int64(bestHeight) - minConf + 1
+	return RetrieveAddressUTXOs(pgb.db, address, maxHeight, int64(bestHeight),
+		minAtoms, maxAtoms, tree)
+}
+
+// AddressBalanceAtHeight returns the balance of an address as of the block at
+// the given height.
+func (pgb *ChainDB) AddressBalanceAtHeight(address string, height int64) (*apitypes.AddressBalance, error) {
+	numUnspent, atoms, err := RetrieveAddressBalanceAtHeight(pgb.db, address, height)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.AddressBalance{
+		Address:     address,
+		BlockHeight: height,
+		NumUnspent:  numUnspent,
+		Balance:     lddlutil.Amount(atoms).ToCoin(),
+		Atoms:       atoms,
+	}, nil
+}
+
 func (pgb *ChainDB) addressInfo(addr string, count, skip int64,
 	txnType dbtypes.AddrTxnType) (*explorer.AddressInfo, *explorer.AddressBalance, error) {
 	address, err := lddlutil.DecodeAddress(addr)
@@ -932,6 +1213,10 @@ func (pgb *ChainDB) DeindexAll() error {
 		warnUnlessNotExists(err)
 		errAny = err
 	}
+	if err = DeindexBlockTxnsTableOnTxDbID(pgb.db); err != nil {
+		warnUnlessNotExists(err)
+		errAny = err
+	}
 	if err = DeindexVinTableOnVins(pgb.db); err != nil {
 		warnUnlessNotExists(err)
 		errAny = err
@@ -997,6 +1282,10 @@ func (pgb *ChainDB) IndexAll() error {
 	if err := IndexTransactionTableOnBlockIn(pgb.db); err != nil {
 		return err
 	}
+	log.Infof("Indexing block_transactions table on tx id...")
+	if err := IndexBlockTxnsTableOnTxDbID(pgb.db); err != nil {
+		return err
+	}
 	log.Infof("Indexing vins table on txin...")
 	if err := IndexVinTableOnVins(pgb.db); err != nil {
 		return err
@@ -1035,19 +1324,23 @@ func (pgb *ChainDB) IndexAll() error {
 	return IndexAddressTableOnTxHash(pgb.db)
 }
 
-// IndexTicketsTable creates the indexes on the tickets table on ticket hash and
-// tx DB ID columns, separately.
+// IndexTicketsTable creates the indexes on the tickets table on ticket hash,
+// tx DB ID and stake submission address columns, separately.
 func (pgb *ChainDB) IndexTicketsTable() error {
 	log.Infof("Indexing tickets table on ticket hash...")
 	if err := IndexTicketsTableOnHashes(pgb.db); err != nil {
 		return err
 	}
 	log.Infof("Indexing tickets table on transaction Db ID...")
-	return IndexTicketsTableOnTxDbID(pgb.db)
+	if err := IndexTicketsTableOnTxDbID(pgb.db); err != nil {
+		return err
+	}
+	log.Infof("Indexing tickets table on stake submission address...")
+	return IndexTicketsTableOnStakeSubmissionAddress(pgb.db)
 }
 
-// DeindexTicketsTable drops the ticket hash and tx DB ID column indexes for the
-// tickets table.
+// DeindexTicketsTable drops the ticket hash, tx DB ID and stake submission
+// address column indexes for the tickets table.
 func (pgb *ChainDB) DeindexTicketsTable() error {
 	var errAny error
 	if err := DeindexTicketsTableOnHash(pgb.db); err != nil {
@@ -1058,6 +1351,10 @@ func (pgb *ChainDB) DeindexTicketsTable() error {
 		warnUnlessNotExists(err)
 		errAny = err
 	}
+	if err := DeindexTicketsTableOnStakeSubmissionAddress(pgb.db); err != nil {
+		warnUnlessNotExists(err)
+		errAny = err
+	}
 	return errAny
 }
 
@@ -1106,7 +1403,9 @@ func (pgb *ChainDB) ExistsIndexAddressesVoutIDAddress() (bool, error) {
 }
 
 // StoreBlock processes the input wire.MsgBlock, and saves to the data tables.
-// The number of vins, and vouts stored are also returned.
+// The block is stored in a single database transaction, which also records it
+// in the meta table as the last committed block. The number of vins, and vouts
+// stored are also returned.
 func (pgb *ChainDB) StoreBlock(msgBlock *wire.MsgBlock, winningTickets []string,
 	isValid, updateAddressesSpendingInfo, updateTicketsSpendingInfo bool) (numVins int64, numVouts int64, err error) {
 	// Convert the wire.MsgBlock to a dbtypes.Block
@@ -1131,64 +1430,74 @@ func (pgb *ChainDB) StoreBlock(msgBlock *wire.MsgBlock, winningTickets []string,
 		Validators:     winners,
 	}
 
+	// All of the block's data is stored in a single database transaction, so
+	// that a block is either stored completely, along with the meta table's
+	// record of the last committed block, or not at all.
+	dbtx, err := pgb.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("unable to begin database transaction: %v", err)
+		return
+	}
+
+	// newTickets are added to the unspent ticket cache with their row IDs in
+	// this database transaction, and must be removed if it is not committed.
+	var newTickets []string
+	bail := func() {
+		if errRoll := dbtx.Rollback(); errRoll != nil {
+			log.Errorf("Rollback failed: %v", errRoll)
+		}
+		pgb.unspentTicketCache.Delete(newTickets)
+	}
+
 	// Extract transactions and their vouts, and insert vouts into their pg table,
 	// returning their DB PKs, which are stored in the corresponding transaction
 	// data struct. Insert each transaction once they are updated with their
 	// vouts' IDs, returning the transaction PK ID, which are stored in the
-	// containing block data struct.
+	// containing block data struct. The statements of a database transaction
+	// are executed in sequence, so the transaction trees are stored one after
+	// the other.
 
 	// regular transactions
-	resChanReg := make(chan storeTxnsResult)
-	go func() {
-		resChanReg <- pgb.storeTxns(MsgBlockPG, wire.TxTreeRegular,
-			pgb.chainParams, &dbBlock.TxDbIDs, updateAddressesSpendingInfo,
-			updateTicketsSpendingInfo)
-	}()
+	resReg := pgb.storeTxns(dbtx, MsgBlockPG, wire.TxTreeRegular,
+		pgb.chainParams, &dbBlock.TxDbIDs, updateAddressesSpendingInfo,
+		updateTicketsSpendingInfo)
+	if resReg.err != nil {
+		err = resReg.err
+		bail()
+		return
+	}
 
 	// stake transactions
-	resChanStake := make(chan storeTxnsResult)
-	go func() {
-		resChanStake <- pgb.storeTxns(MsgBlockPG, wire.TxTreeStake,
-			pgb.chainParams, &dbBlock.STxDbIDs, updateAddressesSpendingInfo,
-			updateTicketsSpendingInfo)
-	}()
-
-	errReg := <-resChanReg
-	errStk := <-resChanStake
-	if errStk.err != nil {
-		if errReg.err == nil {
-			err = errStk.err
-			numVins = errReg.numVins
-			numVouts = errReg.numVouts
-			return
-		}
-		err = errors.New(errReg.Error() + ", " + errStk.Error())
-		return
-	} else if errReg.err != nil {
-		err = errReg.err
-		numVins = errStk.numVins
-		numVouts = errStk.numVouts
+	resStk := pgb.storeTxns(dbtx, MsgBlockPG, wire.TxTreeStake,
+		pgb.chainParams, &dbBlock.STxDbIDs, updateAddressesSpendingInfo,
+		updateTicketsSpendingInfo)
+	newTickets = resStk.tickets
+	if resStk.err != nil {
+		err = resStk.err
+		bail()
 		return
 	}
 
-	numVins = errStk.numVins + errReg.numVins
-	numVouts = errStk.numVouts + errReg.numVouts
-
 	// Store the block now that it has all it's transaction PK IDs
 	var blockDbID uint64
-	blockDbID, err = InsertBlock(pgb.db, dbBlock, isValid, pgb.dupChecks)
+	blockDbID, err = insertBlock(dbtx, dbBlock, isValid, true, pgb.dupChecks)
 	if err != nil {
 		log.Error("InsertBlock:", err)
+		bail()
 		return
 	}
-	pgb.lastBlock[msgBlock.BlockHash()] = blockDbID
 
-	pgb.bestBlock = int64(dbBlock.Height)
+	if err = insertBlockTxns(dbtx, blockDbID, dbBlock); err != nil {
+		log.Error("insertBlockTxns:", err)
+		bail()
+		return
+	}
 
-	err = InsertBlockPrevNext(pgb.db, blockDbID, dbBlock.Hash,
+	err = insertBlockPrevNext(dbtx, blockDbID, dbBlock.Hash,
 		dbBlock.PreviousHash, "")
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil {
 		log.Error("InsertBlockPrevNext:", err)
+		bail()
 		return
 	}
 
@@ -1205,6 +1514,7 @@ func (pgb *ChainDB) StoreBlock(msgBlock *wire.MsgBlock, winningTickets []string,
 			if err != nil {
 				log.Criticalf("Unable to locate block %s in block_chain table: %v",
 					lastBlockHash, err)
+				bail()
 				return
 			}
 		}
@@ -1213,21 +1523,41 @@ func (pgb *ChainDB) StoreBlock(msgBlock *wire.MsgBlock, winningTickets []string,
 		lastIsValid := dbBlock.VoteBits&1 != 0
 		if !lastIsValid {
 			log.Infof("Setting last block %s as INVALID", lastBlockHash)
-			err = UpdateLastBlock(pgb.db, lastBlockDbID, lastIsValid)
+			err = updateLastBlock(dbtx, lastBlockDbID, lastIsValid)
 			if err != nil {
 				log.Error("UpdateLastBlock:", err)
+				bail()
 				return
 			}
 		}
 
 		// Update the previous block's next block hash
-		err = UpdateBlockNext(pgb.db, lastBlockDbID, dbBlock.Hash)
+		err = updateBlockNext(dbtx, lastBlockDbID, dbBlock.Hash)
 		if err != nil {
 			log.Error("UpdateBlockNext:", err)
+			bail()
 			return
 		}
 	}
 
+	// Record the block as the last one committed.
+	if err = setMetaBestBlock(dbtx, int64(dbBlock.Height), dbBlock.Hash); err != nil {
+		bail()
+		return
+	}
+
+	if err = dbtx.Commit(); err != nil {
+		err = fmt.Errorf("failed to commit block %s: %v", dbBlock.Hash, err)
+		pgb.unspentTicketCache.Delete(newTickets)
+		return
+	}
+
+	pgb.lastBlock[msgBlock.BlockHash()] = blockDbID
+	pgb.setHeight(int64(dbBlock.Height))
+
+	numVins = resReg.numVins + resStk.numVins
+	numVouts = resReg.numVouts + resStk.numVouts
+
 	// If not in batch sync, lazy update the dev fund balance
 	if !pgb.InBatchSync {
 		pgb.addressCounts.Lock()
@@ -1249,17 +1579,66 @@ func (pgb *ChainDB) StoreBlock(msgBlock *wire.MsgBlock, winningTickets []string,
 	return
 }
 
-// storeTxnsResult is the type of object sent back from the goroutines wrapping
-// storeTxns in StoreBlock.
+// TipToSideChain moves the main chain blocks above the block with hash mainRoot
+// to a side chain, undoing the changes made to the addresses, tickets, votes
+// and misses tables when they were stored. mainRoot should be the common
+// ancestor of the current main chain and the new main chain. The hashes of the
+// blocks moved to the side chain are returned, tip last.
+func (pgb *ChainDB) TipToSideChain(mainRoot string) ([]string, error) {
+	rootHeight, err := RetrieveBlockHeight(pgb.db, mainRoot)
+	if err != nil {
+		return nil, fmt.Errorf("unable to locate main chain root block %s: %v",
+			mainRoot, err)
+	}
+
+	orphanedHashes, _, err := RetrieveMainchainBlocksAboveHeight(pgb.db, rootHeight)
+	if err != nil {
+		return nil, err
+	}
+	if len(orphanedHashes) == 0 {
+		return nil, nil
+	}
+
+	log.Infof("Moving %d blocks above height %d (%s) to side chain.",
+		len(orphanedHashes), rootHeight, mainRoot)
+
+	expiryDepth := int64(pgb.chainParams.TicketMaturity) +
+		int64(pgb.chainParams.TicketExpiry)
+	deletedTickets, err := RollbackBlocks(pgb.db, orphanedHashes, mainRoot,
+		rootHeight, expiryDepth)
+	if err != nil {
+		return nil, err
+	}
+	pgb.unspentTicketCache.Delete(deletedTickets)
+
+	for _, hash := range orphanedHashes {
+		h, err := chainhash.NewHashFromStr(hash)
+		if err != nil {
+			log.Warnf("Invalid block hash %s: %v", hash, err)
+			continue
+		}
+		delete(pgb.lastBlock, *h)
+	}
+
+	pgb.setHeight(rootHeight)
+
+	// Address balances cached for the old main chain are stale.
+	pgb.addressCounts.Lock()
+	pgb.addressCounts.validHeight = rootHeight
+	pgb.addressCounts.balance = map[string]explorer.AddressBalance{}
+	pgb.addressCounts.Unlock()
+
+	return orphanedHashes, nil
+}
+
+// storeTxnsResult is the result of storeTxns, with the tickets it added to the
+// unspent ticket cache.
 type storeTxnsResult struct {
 	numVins, numVouts, numAddresses int64
+	tickets                         []string
 	err                             error
 }
 
-func (r *storeTxnsResult) Error() string {
-	return r.err.Error()
-}
-
 // MsgBlockPG extends wire.MsgBlock with the winning tickets from the block,
 // WinningTickets, and the tickets from the previous block that may vote on this
 // block's validity, Validators.
@@ -1269,7 +1648,10 @@ type MsgBlockPG struct {
 	Validators     []string
 }
 
-func (pgb *ChainDB) storeTxns(msgBlock *MsgBlockPG, txTree int8,
+// storeTxns stores the transactions of one tree of a block, with their vins,
+// vouts, address rows, and for the stake tree, the tickets, votes and misses.
+// The caller must commit or roll back the database transaction.
+func (pgb *ChainDB) storeTxns(dbtx *sql.Tx, msgBlock *MsgBlockPG, txTree int8,
 	chainParams *chaincfg.Params, TxDbIDs *[]uint64,
 	updateAddressesSpendingInfo, updateTicketsSpendingInfo bool) storeTxnsResult {
 	// For the given block, transaction tree, and network, extract the
@@ -1287,37 +1669,37 @@ func (pgb *ChainDB) storeTxns(msgBlock *MsgBlockPG, txTree int8,
 	var totalAddressRows int
 
 	var err error
-	for it, dbtx := range dbTransactions {
+	for it, dbTx := range dbTransactions {
 		// Insert vouts, and collect rows to add to address table
-		dbtx.VoutDbIds, dbAddressRows[it], err = InsertVouts(pgb.db, dbTxVouts[it], pgb.dupChecks)
+		dbTx.VoutDbIds, dbAddressRows[it], err = insertVouts(dbtx, dbTxVouts[it], pgb.dupChecks)
 		if err != nil && err != sql.ErrNoRows {
 			log.Error("InsertVouts:", err)
 			txRes.err = err
 			return txRes
 		}
 		totalAddressRows += len(dbAddressRows[it])
-		txRes.numVouts += int64(len(dbtx.VoutDbIds))
-		if err == sql.ErrNoRows || len(dbTxVouts[it]) != len(dbtx.VoutDbIds) {
+		txRes.numVouts += int64(len(dbTx.VoutDbIds))
+		if err == sql.ErrNoRows || len(dbTxVouts[it]) != len(dbTx.VoutDbIds) {
 			log.Warnf("Incomplete Vout insert.")
 		}
 
 		// Insert vins
-		dbtx.VinDbIds, err = InsertVins(pgb.db, dbTxVins[it], pgb.dupChecks)
+		dbTx.VinDbIds, err = insertVins(dbtx, dbTxVins[it], pgb.dupChecks)
 		if err != nil && err != sql.ErrNoRows {
 			log.Error("InsertVins:", err)
 			txRes.err = err
 			return txRes
 		}
-		txRes.numVins += int64(len(dbtx.VinDbIds))
+		txRes.numVins += int64(len(dbTx.VinDbIds))
 
 		// return the transactions vout slice if processing stake tree
 		if txTree == wire.TxTreeStake {
-			dbtx.Vouts = dbTxVouts[it]
+			dbTx.Vouts = dbTxVouts[it]
 		}
 	}
 
 	// Get the tx PK IDs for storage in the blocks, tickets, and votes table
-	*TxDbIDs, err = InsertTxns(pgb.db, dbTransactions, pgb.dupChecks)
+	*TxDbIDs, err = insertTxns(dbtx, dbTransactions, pgb.dupChecks)
 	if err != nil && err != sql.ErrNoRows {
 		log.Error("InsertTxns:", err)
 		txRes.err = err
@@ -1327,7 +1709,7 @@ func (pgb *ChainDB) storeTxns(msgBlock *MsgBlockPG, txTree int8,
 	// If processing stake tree, insert tickets, votes, misses
 	if txTree == wire.TxTreeStake {
 		// Tickets
-		newTicketDbIDs, newTicketTx, err := InsertTickets(pgb.db, dbTransactions, *TxDbIDs, pgb.dupChecks)
+		newTicketDbIDs, newTicketTx, err := insertTickets(dbtx, dbTransactions, *TxDbIDs, pgb.dupChecks)
 		if err != nil && err != sql.ErrNoRows {
 			log.Error("InsertTickets:", err)
 			txRes.err = err
@@ -1340,6 +1722,7 @@ func (pgb *ChainDB) storeTxns(msgBlock *MsgBlockPG, txTree int8,
 		if updateTicketsSpendingInfo {
 			for it, tdbid := range newTicketDbIDs {
 				pgb.unspentTicketCache.Set(newTicketTx[it].TxID, tdbid)
+				txRes.tickets = append(txRes.tickets, newTicketTx[it].TxID)
 			}
 			unspentTicketCache = pgb.unspentTicketCache
 		}
@@ -1357,8 +1740,8 @@ func (pgb *ChainDB) storeTxns(msgBlock *MsgBlockPG, txTree int8,
 		// Votes
 		// voteDbIDs, voteTxns, spentTicketHashes, ticketDbIDs, missDbIDs, err := ...
 		var missesHashIDs map[string]uint64
-		_, _, _, _, missesHashIDs, err = InsertVotes(pgb.db,
-			dbTransactions, *TxDbIDs, unspentTicketCache, msgBlock, pgb.dupChecks)
+		_, _, _, _, missesHashIDs, err = insertVotes(dbtx,
+			dbTransactions, unspentTicketCache, msgBlock, pgb.dupChecks)
 		if err != nil && err != sql.ErrNoRows {
 			log.Error("InsertVotes:", err)
 			txRes.err = err
@@ -1368,84 +1751,30 @@ func (pgb *ChainDB) storeTxns(msgBlock *MsgBlockPG, txTree int8,
 		if updateTicketsSpendingInfo {
 			// Get a consistent view of the stake node at its present height
 			pgb.stakeDB.LockStakeNode()
+			expiries := pgb.blockTicketExpiries(msgBlock.MsgBlock)
+			// Release the stake node
+			pgb.stakeDB.UnlockStakeNode()
 
-			// To update spending info in tickets table, get the spent tickets' DB
-			// row IDs and block heights.
-			//ticketDbIDs := make([]uint64, len(spentTicketHashes))
-			revokes := make(map[string]uint64)
-			blockHeights := make([]int64, len(spentTicketHashes))
-			poolStatuses := make([]dbtypes.TicketPoolStatus, len(spentTicketHashes))
-			for iv := range spentTicketHashes {
-				blockHeights[iv] = int64(msgBlock.Header.Height) /* voteDbTxns[iv].BlockHeight */
-
-				switch spendTypes[iv] {
-				case dbtypes.TicketVoted:
-					poolStatuses[iv] = dbtypes.PoolStatusVoted
-				case dbtypes.TicketRevoked:
-					revokes[spentTicketHashes[iv]] = ticketDbIDs[iv]
-					// Revoke reason
-					h, err0 := chainhash.NewHashFromStr(spentTicketHashes[iv])
-					if err0 != nil {
-						log.Warnf("Invalid hash %v", spentTicketHashes[iv])
-					}
-					expired := pgb.stakeDB.BestNode.ExistsExpiredTicket(*h)
-					if !expired {
-						poolStatuses[iv] = dbtypes.PoolStatusMissed
-					} else {
-						poolStatuses[iv] = dbtypes.PoolStatusExpired
-					}
-				}
-			}
+			updates := makeTicketPoolUpdates(int64(msgBlock.Header.Height),
+				spendTypes, spentTicketHashes, missesHashIDs, expiries)
 
-			// Update tickets table with spending info from new votes
-			// _, err = SetSpendingForTickets(pgb.db, ticketDbIDs, voteDbIDs, blockHeights, spendTypes)
-			_, err = SetSpendingForTickets(pgb.db, ticketDbIDs, spendingTxDbIDs,
-				blockHeights, spendTypes, poolStatuses)
+			// Update tickets table with spending info from new votes. A failed
+			// statement aborts the database transaction, so these errors are
+			// returned rather than just logged.
+			err = setSpendingForTickets(dbtx, ticketDbIDs, spendingTxDbIDs,
+				updates.blockHeights, spendTypes, updates.poolStatuses)
 			if err != nil {
-				log.Warn("SetSpendingForTickets:", err)
-			}
-
-			// Missed but not revoked
-			//var unspentMissedTicketDbIDs []uint64
-			var unspentMissedTicketHashes []string
-			var missStatuses []dbtypes.TicketPoolStatus
-			unspentMisses := make(map[string]struct{})
-			for miss := range missesHashIDs {
-				if _, ok := revokes[miss]; !ok {
-					// unrevoked miss
-					//unspentMissedTicketDbIDs = append(unspentMissedTicketDbIDs, missedTicketID)
-					unspentMissedTicketHashes = append(unspentMissedTicketHashes, miss)
-					unspentMisses[miss] = struct{}{}
-					missStatuses = append(missStatuses, dbtypes.PoolStatusMissed)
-				}
+				log.Error("SetSpendingForTickets:", err)
+				txRes.err = err
+				return txRes
 			}
 
-			// Expired but not revoked
-			unspentEnM := make([]string, len(unspentMissedTicketHashes))
-			copy(unspentEnM, unspentMissedTicketHashes)
-			unspentExpiresAndMisses := pgb.stakeDB.BestNode.MissedByBlock()
-			for _, missHash := range unspentExpiresAndMisses {
-				// MissedByBlock includes tickets that missed votes or expired;
-				// we just want the expires, and not the revoked ones.
-				if pgb.stakeDB.BestNode.ExistsExpiredTicket(missHash) {
-					emHash := missHash.String()
-					// Next check should not be unnecessary. Make sure not in
-					// unspent misses from above and not just revoked.
-					_, justMissed := unspentMisses[emHash]
-					_, justRevoked := revokes[emHash]
-					if !justMissed && !justRevoked {
-						unspentEnM = append(unspentEnM, emHash)
-						missStatuses = append(missStatuses, dbtypes.PoolStatusExpired)
-					}
-				}
-			}
-
-			// Release the stake node
-			pgb.stakeDB.UnlockStakeNode()
-
-			numUnrevokedMisses, err := SetPoolStatusForTicketsByHash(pgb.db, unspentEnM, missStatuses)
+			numUnrevokedMisses, err := setPoolStatusForTicketsByHash(dbtx,
+				updates.unspent, updates.unspentStatuses)
 			if err != nil {
-				log.Warn("SetPoolStatusForTickets", err)
+				log.Error("SetPoolStatusForTickets", err)
+				txRes.err = err
+				return txRes
 			} else if numUnrevokedMisses > 0 {
 				log.Tracef("Noted %d unrevoked newly-missed tickets.", numUnrevokedMisses)
 			}
@@ -1467,7 +1796,7 @@ func (pgb *ChainDB) storeTxns(msgBlock *MsgBlockPG, txTree int8,
 	}
 
 	// Insert each new AddressRow, absent spending fields
-	_, err = InsertAddressOuts(pgb.db, dbAddressRowsFlat, pgb.dupChecks)
+	_, err = insertAddressOuts(dbtx, dbAddressRowsFlat, pgb.dupChecks)
 	if err != nil {
 		log.Error("InsertAddressOuts:", err)
 		txRes.err = err
@@ -1511,11 +1840,13 @@ func (pgb *ChainDB) storeTxns(msgBlock *MsgBlockPG, txTree int8,
 			}
 
 			var numAddressRowsSet int64
-			numAddressRowsSet, err = SetSpendingForFundingOP(pgb.db,
+			numAddressRowsSet, err = setSpendingForFundingOP(dbtx,
 				vin.PrevTxHash, vin.PrevTxIndex, // funding
 				txDbID, vin.TxID, vin.TxIndex, vinDbID) // spending
 			if err != nil {
 				log.Errorf("SetSpendingForFundingOP: %v", err)
+				txRes.err = err
+				return txRes
 			}
 			txRes.numAddresses += numAddressRowsSet
 
@@ -1549,6 +1880,104 @@ func (pgb *ChainDB) storeTxns(msgBlock *MsgBlockPG, txTree int8,
 	return txRes
 }
 
+// ticketExpiries records, from the stake node after connecting a block, which
+// of the tickets revoked in the block had expired rather than missed a vote,
+// and the tickets that expired in the block.
+type ticketExpiries struct {
+	revokedExpired map[string]bool
+	expired        []string
+}
+
+// blockTicketExpiries gets the ticketExpiries of a block from the stake node,
+// which must be at the block's height. The caller must lock the stake node.
+func (pgb *ChainDB) blockTicketExpiries(msgBlock *wire.MsgBlock) *ticketExpiries {
+	node := pgb.stakeDB.BestNode
+	expiries := &ticketExpiries{
+		revokedExpired: make(map[string]bool),
+	}
+	for _, stx := range msgBlock.STransactions {
+		if stake.DetermineTxType(stx) != stake.TxTypeSSRtx || len(stx.TxIn) == 0 {
+			continue
+		}
+		ticket := stx.TxIn[0].PreviousOutPoint.Hash
+		expiries.revokedExpired[ticket.String()] = node.ExistsExpiredTicket(ticket)
+	}
+
+	// MissedByBlock includes tickets that missed votes or expired; we just want
+	// the expires.
+	for _, missHash := range node.MissedByBlock() {
+		if node.ExistsExpiredTicket(missHash) {
+			expiries.expired = append(expiries.expired, missHash.String())
+		}
+	}
+	return expiries
+}
+
+// ticketPoolUpdates are the changes to the tickets table for the votes,
+// revokes and misses in a block. blockHeights and poolStatuses correspond to
+// the spent tickets, while unspent lists the tickets newly missed or expired,
+// but not revoked, with their unspentStatuses.
+type ticketPoolUpdates struct {
+	blockHeights    []int64
+	poolStatuses    []dbtypes.TicketPoolStatus
+	unspent         []string
+	unspentStatuses []dbtypes.TicketPoolStatus
+}
+
+// makeTicketPoolUpdates determines the ticketPoolUpdates of the block at the
+// given height from its ticket spends (as from CollectTicketSpendDBInfo), the
+// tickets that missed votes on the block, and the block's ticketExpiries.
+func makeTicketPoolUpdates(height int64, spendTypes []dbtypes.TicketSpendType,
+	spentTicketHashes []string, misses map[string]uint64,
+	expiries *ticketExpiries) *ticketPoolUpdates {
+	updates := &ticketPoolUpdates{
+		blockHeights: make([]int64, len(spentTicketHashes)),
+		poolStatuses: make([]dbtypes.TicketPoolStatus, len(spentTicketHashes)),
+	}
+	revokes := make(map[string]struct{})
+	for iv := range spentTicketHashes {
+		updates.blockHeights[iv] = height
+
+		switch spendTypes[iv] {
+		case dbtypes.TicketVoted:
+			updates.poolStatuses[iv] = dbtypes.PoolStatusVoted
+		case dbtypes.TicketRevoked:
+			revokes[spentTicketHashes[iv]] = struct{}{}
+			// Revoke reason
+			if expiries.revokedExpired[spentTicketHashes[iv]] {
+				updates.poolStatuses[iv] = dbtypes.PoolStatusExpired
+			} else {
+				updates.poolStatuses[iv] = dbtypes.PoolStatusMissed
+			}
+		}
+	}
+
+	// Missed but not revoked
+	unspentMisses := make(map[string]struct{})
+	for miss := range misses {
+		if _, ok := revokes[miss]; !ok {
+			updates.unspent = append(updates.unspent, miss)
+			updates.unspentStatuses = append(updates.unspentStatuses,
+				dbtypes.PoolStatusMissed)
+			unspentMisses[miss] = struct{}{}
+		}
+	}
+
+	// Expired but not revoked. Make sure not in unspent misses from above and
+	// not just revoked.
+	for _, expired := range expiries.expired {
+		_, justMissed := unspentMisses[expired]
+		_, justRevoked := revokes[expired]
+		if !justMissed && !justRevoked {
+			updates.unspent = append(updates.unspent, expired)
+			updates.unspentStatuses = append(updates.unspentStatuses,
+				dbtypes.PoolStatusExpired)
+		}
+	}
+
+	return updates
+}
+
 func (pgb *ChainDB) CollectTicketSpendDBInfo(dbTxns []*dbtypes.Tx, txDbIDs []uint64,
 	msgBlock *wire.MsgBlock) (spendingTxDbIDs []uint64, spendTypes []dbtypes.TicketSpendType,
 	ticketHashes []string, ticketDbIDs []uint64, err error) {
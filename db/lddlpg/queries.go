@@ -8,14 +8,17 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"github.com/Legenddigital/lddld/blockchain/stake"
+	"github.com/Legenddigital/lddld/chaincfg"
 	"github.com/Legenddigital/lddld/lddlutil"
 	"github.com/Legenddigital/lddld/txscript"
 	"github.com/Legenddigital/lddld/wire"
 	apitypes "github.com/Legenddigital/lddldata/api/types"
 	"github.com/Legenddigital/lddldata/db/dbtypes"
 	"github.com/Legenddigital/lddldata/db/lddlpg/internal"
+	"github.com/Legenddigital/lddldata/explorer"
 	"github.com/Legenddigital/lddldata/txhelpers"
 	"github.com/lib/pq"
 )
@@ -66,6 +69,108 @@ func RetrieveMissedVotesInBlock(db *sql.DB, blockHash string) (ticketHashes []st
 	return
 }
 
+// RetrieveMissesInBlockRange retrieves the missed votes of the main chain
+// blocks from height idx0 to idx, by block.
+func RetrieveMissesInBlockRange(db *sql.DB, idx0, idx int64) (*apitypes.MissesRange, error) {
+	rows, err := db.Query(internal.SelectMissesInBlockRange, idx0, idx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	mr := &apitypes.MissesRange{
+		StartHeight: idx0,
+		EndHeight:   idx,
+		Blocks:      []*apitypes.BlockMisses{},
+	}
+	var block *apitypes.BlockMisses
+	for rows.Next() {
+		var height int64
+		var hash, ticket string
+		if err = rows.Scan(&height, &hash, &ticket); err != nil {
+			return nil, err
+		}
+		if block == nil || block.Hash != hash {
+			block = &apitypes.BlockMisses{Height: height, Hash: hash}
+			mr.Blocks = append(mr.Blocks, block)
+		}
+		block.Tickets = append(block.Tickets, ticket)
+		mr.NumMissed++
+	}
+	return mr, rows.Err()
+}
+
+// RetrieveMissesByDay retrieves the votes, missed votes and expired tickets of
+// the main chain blocks from height idx0 to idx, by UTC day. Tickets expire
+// ticketLifetime blocks, the ticket maturity plus the ticket expiry, after
+// their purchase.
+func RetrieveMissesByDay(db *sql.DB, idx0, idx, ticketLifetime int64) ([]*apitypes.DayMisses, error) {
+	rows, err := db.Query(internal.SelectMissesByDay, idx0, idx, ticketLifetime,
+		dbtypes.PoolStatusExpired)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	days := []*apitypes.DayMisses{}
+	for rows.Next() {
+		day := new(apitypes.DayMisses)
+		err = rows.Scan(&day.Time, &day.NumBlocks, &day.NumVotes,
+			&day.NumMissed, &day.NumExpired)
+		if err != nil {
+			return nil, err
+		}
+		day.MissRate = missRate(day.NumVotes, day.NumMissed)
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// RetrieveMissesByAddress retrieves the votes, missed votes and expired
+// tickets from height idx0 to idx of the tickets of each stake submission
+// address with missed votes, most misses first. ticketLifetime is as for
+// RetrieveMissesByDay.
+func RetrieveMissesByAddress(db *sql.DB, idx0, idx, ticketLifetime int64) ([]*apitypes.AddressMisses, error) {
+	rows, err := db.Query(internal.SelectMissesByAddress, idx0, idx, ticketLifetime,
+		dbtypes.PoolStatusExpired)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	addresses := []*apitypes.AddressMisses{}
+	for rows.Next() {
+		am := new(apitypes.AddressMisses)
+		err = rows.Scan(&am.Address, &am.NumVotes, &am.NumMissed, &am.NumExpired)
+		if err != nil {
+			return nil, err
+		}
+		am.MissRate = missRate(am.NumVotes, am.NumMissed)
+		addresses = append(addresses, am)
+	}
+	return addresses, rows.Err()
+}
+
+// missRate is the fraction of the tickets called to vote that missed.
+func missRate(votes, missed int) float64 {
+	if votes+missed == 0 {
+		return 0
+	}
+	return float64(missed) / float64(votes+missed)
+}
+
 func RetrieveAllRevokesDbIDHashHeight(db *sql.DB) (ids []uint64,
 	hashes []string, heights []int64, vinDbIDs []uint64, err error) {
 	rows, err := db.Query(internal.SelectAllRevokes)
@@ -122,6 +227,113 @@ func RetrieveAllVotesDbIDsHeightsTicketDbIDs(db *sql.DB) (ids []uint64, heights
 	return
 }
 
+// RetrieveVoteBitsCounts retrieves the number of votes of the given vote
+// version with each vote bits in each main chain block, by increasing height.
+func RetrieveVoteBitsCounts(db *sql.DB, version uint32) ([]*dbtypes.VoteBitsCount, error) {
+	rows, err := db.Query(internal.SelectVoteBitsCountsByVersion, version)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	var counts []*dbtypes.VoteBitsCount
+	for rows.Next() {
+		var voteBits int64
+		count := new(dbtypes.VoteBitsCount)
+		if err = rows.Scan(&count.Height, &count.BlockTime, &voteBits,
+			&count.Count); err != nil {
+			return nil, err
+		}
+		// vote_bits is a signed INT2 column.
+		count.VoteBits = uint16(voteBits)
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// RetrieveChartBlocks retrieves the chart data of the main chain blocks above
+// the given height, by increasing height. The ticket maturity and expiry are
+// those of the network, and determine when tickets enter and leave the live
+// ticket pool.
+func RetrieveChartBlocks(db *sql.DB, height, ticketMaturity, ticketExpiry int64) ([]*dbtypes.ChartBlock, error) {
+	rows, err := db.Query(internal.SelectChartBlocks, height)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	var blocks []*dbtypes.ChartBlock
+	for rows.Next() {
+		block := new(dbtypes.ChartBlock)
+		if err = rows.Scan(&block.Height, &block.Time, &block.Size,
+			&block.TicketPrice, &block.PoolSize, &block.Bits, &block.Voters,
+			&block.FreshStake, &block.Revocations, &block.RegularTxns); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return blocks, nil
+	}
+
+	fees, err := retrieveChartHeightValues(db, internal.SelectChartFees, height)
+	if err != nil {
+		return nil, fmt.Errorf("fees query failed: %v", err)
+	}
+	misses, err := retrieveChartHeightValues(db, internal.SelectChartMisses, height)
+	if err != nil {
+		return nil, fmt.Errorf("misses query failed: %v", err)
+	}
+	poolValueChanges, err := retrieveChartHeightValues(db, internal.SelectChartPoolValueChanges,
+		height, ticketMaturity, ticketExpiry, dbtypes.PoolStatusExpired)
+	if err != nil {
+		return nil, fmt.Errorf("pool value query failed: %v", err)
+	}
+
+	for _, block := range blocks {
+		block.Fees = int64(fees[block.Height])
+		block.Missed = uint32(misses[block.Height])
+		block.PoolValueChange = poolValueChanges[block.Height]
+	}
+	return blocks, nil
+}
+
+// retrieveChartHeightValues runs a query for a value at each height, such as
+// the chart statements selecting a sum for each block.
+func retrieveChartHeightValues(db *sql.DB, query string, args ...interface{}) (map[int64]float64, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	values := make(map[int64]float64)
+	for rows.Next() {
+		var height int64
+		var value float64
+		if err = rows.Scan(&height, &value); err != nil {
+			return nil, err
+		}
+		values[height] = value
+	}
+	return values, rows.Err()
+}
+
 // func RetrieveAllVotesDbIDsHeightsTicketHashes(db *sql.DB) (ids []uint64, heights []int64,
 // 	ticketHashes []string, err error) {
 // 	rows, err := db.Query(internal.SelectAllVoteDbIDsHeightsTicketHashes)
@@ -192,6 +404,105 @@ func RetrieveTicketStatusByHash(db *sql.DB, ticketHash string) (id uint64, spend
 	return
 }
 
+// RetrieveTicketInfo retrieves the lifecycle of the main chain ticket with the
+// given hash. Blocks waited by a live ticket are counted to bestHeight.
+func RetrieveTicketInfo(db *sql.DB, ticketHash string, bestHeight int64,
+	params *chaincfg.Params) (*apitypes.TicketInfo, error) {
+	return scanTicketInfo(db.QueryRow(internal.SelectTicketInfoByHash, ticketHash),
+		bestHeight, params)
+}
+
+// RetrieveAddressTickets retrieves the lifecycles of the main chain tickets
+// with the given stake submission address, most recent first.
+func RetrieveAddressTickets(db *sql.DB, address string, bestHeight int64,
+	params *chaincfg.Params) ([]*apitypes.TicketInfo, error) {
+	rows, err := db.Query(internal.SelectTicketsInfoForAddress, address)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	var tickets []*apitypes.TicketInfo
+	for rows.Next() {
+		ticket, err := scanTicketInfo(rows, bestHeight, params)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, ticket)
+	}
+	return tickets, rows.Err()
+}
+
+// sqlQueryer is implemented by both *sql.DB and *sql.Tx, allowing queries to
+// run either on their own or within a database transaction.
+type sqlQueryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTicketInfo scans a row selected by a ticket info statement, and
+// computes the ticket's maturity and expiration heights, blocks waited, and
+// vote choices.
+func scanTicketInfo(row rowScanner, bestHeight int64, params *chaincfg.Params) (*apitypes.TicketInfo, error) {
+	ticket := new(apitypes.TicketInfo)
+	var spendType dbtypes.TicketSpendType
+	var poolStatus dbtypes.TicketPoolStatus
+	var spendHeight, missHeight, voteVersion, voteBits sql.NullInt64
+	var spendTxID sql.NullString
+	var voteReward sql.NullFloat64
+	var blockValid sql.NullBool
+	err := row.Scan(&ticket.TxID, &ticket.BlockHash, &ticket.BlockHeight,
+		&ticket.StakeSubmissionAddress, &ticket.IsMultisig, &ticket.IsSplit,
+		&ticket.Price, &ticket.Fee, &spendType, &poolStatus, &spendHeight,
+		&spendTxID, &voteReward, &voteVersion, &voteBits, &blockValid,
+		&missHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket.MaturityHeight = ticket.BlockHeight + int64(params.TicketMaturity)
+	ticket.ExpirationHeight = ticket.MaturityHeight + int64(params.TicketExpiry)
+	ticket.PoolStatus = strings.ToLower(poolStatus.String())
+	ticket.SpendType = strings.ToLower(spendType.String())
+	ticket.SpendHeight = spendHeight.Int64
+	ticket.SpendTxID = spendTxID.String
+	ticket.MissHeight = missHeight.Int64
+	ticket.VoteReward = voteReward.Float64
+	if voteVersion.Valid {
+		ticket.Vote = &apitypes.TicketVote{
+			Version:    uint32(voteVersion.Int64),
+			VoteBits:   uint16(voteBits.Int64),
+			BlockValid: blockValid.Bool,
+			Choices: txhelpers.VoteBitsChoices(uint32(voteVersion.Int64),
+				uint16(voteBits.Int64), params),
+		}
+	}
+
+	// A ticket waits from its maturity until it leaves the live pool.
+	exitHeight := bestHeight
+	switch poolStatus {
+	case dbtypes.PoolStatusVoted:
+		exitHeight = ticket.SpendHeight
+	case dbtypes.PoolStatusMissed:
+		exitHeight = ticket.MissHeight
+	case dbtypes.PoolStatusExpired:
+		exitHeight = ticket.ExpirationHeight
+	}
+	if exitHeight > ticket.MaturityHeight {
+		ticket.BlocksWaited = exitHeight - ticket.MaturityHeight
+	}
+	return ticket, nil
+}
+
 func RetrieveTicketIDsByHashes(db *sql.DB, ticketHashes []string) (ids []uint64, err error) {
 	dbtx, err := db.Begin()
 	if err != nil {
@@ -304,6 +615,36 @@ func SetPoolStatusForTicketsByHash(db *sql.DB, tickets []string,
 	return totalTicketsUpdated, dbtx.Commit()
 }
 
+// setPoolStatusForTicketsByHash is SetPoolStatusForTicketsByHash within an
+// existing database transaction.
+func setPoolStatusForTicketsByHash(dbtx *sql.Tx, tickets []string,
+	poolStatuses []dbtypes.TicketPoolStatus) (int64, error) {
+	if len(tickets) == 0 {
+		return 0, nil
+	}
+	stmt, err := dbtx.Prepare(internal.SetTicketPoolStatusForHash)
+	if err != nil {
+		return 0, fmt.Errorf("tickets SELECT prepare failed: %v", err)
+	}
+
+	var totalTicketsUpdated int64
+	for i, ticket := range tickets {
+		rowsAffected, err := sqlExecStmt(stmt, "failed to set ticket pool status: ",
+			ticket, poolStatuses[i])
+		if err != nil {
+			_ = stmt.Close()
+			return 0, err
+		}
+		totalTicketsUpdated += rowsAffected
+		if rowsAffected != 1 {
+			log.Warnf("Updated pool status for %d tickets, expecting just 1 (%s, %v)!",
+				rowsAffected, ticket, poolStatuses[i])
+		}
+	}
+
+	return totalTicketsUpdated, stmt.Close()
+}
+
 func SetSpendingForTickets(db *sql.DB, ticketDbIDs, spendDbIDs []uint64,
 	blockHeights []int64, spendTypes []dbtypes.TicketSpendType,
 	poolStatuses []dbtypes.TicketPoolStatus) (int64, error) {
@@ -497,7 +838,15 @@ func SetSpendingForFundingOP(db *sql.DB,
 	fundingTxHash string, fundingTxVoutIndex uint32,
 	spendingTxDbID uint64, spendingTxHash string, spendingTxVinIndex uint32,
 	vinDbID uint64) (int64, error) {
-	res, err := db.Exec(internal.SetAddressSpendingForOutpoint,
+	return setSpendingForFundingOP(db, fundingTxHash, fundingTxVoutIndex,
+		spendingTxDbID, spendingTxHash, spendingTxVinIndex, vinDbID)
+}
+
+func setSpendingForFundingOP(q sqlQueryer,
+	fundingTxHash string, fundingTxVoutIndex uint32,
+	spendingTxDbID uint64, spendingTxHash string, spendingTxVinIndex uint32,
+	vinDbID uint64) (int64, error) {
+	res, err := q.Exec(internal.SetAddressSpendingForOutpoint,
 		fundingTxHash, fundingTxVoutIndex,
 		spendingTxDbID, spendingTxHash, spendingTxVinIndex, vinDbID)
 	if err != nil || res == nil {
@@ -1144,6 +1493,107 @@ func RetrieveAddressTxnOutputWithTransaction(db *sql.DB, address string, current
 	return outputs, nil
 }
 
+// RetrieveAddressUTXOs retrieves the unspent outputs of an address mined at or
+// below maxHeight, with values between minAtoms and maxAtoms. If tree is
+// negative, outputs of both the regular and stake trees are retrieved.
+// Confirmations are relative to bestHeight.
+func RetrieveAddressUTXOs(db *sql.DB, address string, maxHeight, bestHeight,
+	minAtoms, maxAtoms int64, tree int8) ([]*apitypes.AddressUTXO, error) {
+	rows, err := db.Query(internal.SelectAddressUTXOs, address, maxHeight,
+		minAtoms, maxAtoms, tree)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	var utxos []*apitypes.AddressUTXO
+	for rows.Next() {
+		utxo := &apitypes.AddressUTXO{Address: address}
+		var pkScript []byte
+		err = rows.Scan(&utxo.TxID, &utxo.Vout, &utxo.Atoms, &utxo.Tree,
+			&utxo.BlockHash, &utxo.BlockHeight, &utxo.BlockTime, &pkScript)
+		if err != nil {
+			return nil, err
+		}
+		utxo.ScriptPubKey = hex.EncodeToString(pkScript)
+		utxo.Amount = lddlutil.Amount(utxo.Atoms).ToCoin()
+		utxo.Confirmations = bestHeight - utxo.BlockHeight + 1
+		utxos = append(utxos, utxo)
+	}
+	return utxos, rows.Err()
+}
+
+// RetrieveAddressBalanceAtHeight retrieves the number and value of the unspent
+// outputs of an address as of the block at the given height.
+func RetrieveAddressBalanceAtHeight(db *sql.DB, address string, height int64) (numUnspent, totalUnspent int64, err error) {
+	err = db.QueryRow(internal.SelectAddressBalanceAtHeight, address, height).
+		Scan(&numUnspent, &totalUnspent)
+	return
+}
+
+// RetrieveAddressesMergedTxns retrieves up to N transactions funding or
+// spending any of the addresses, skipping the offset most recent. The total
+// number of transactions is zero if offset is beyond the last.
+func RetrieveAddressesMergedTxns(db *sql.DB, addresses []string, N, offset int64) (txns []*apitypes.AddressesTxn, numTxns int64, err error) {
+	rows, err := db.Query(internal.SelectAddressesMergedTxns, pq.Array(addresses),
+		N, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	for rows.Next() {
+		txn := new(apitypes.AddressesTxn)
+		var received, sent int64
+		err = rows.Scan(&txn.TxID, &txn.BlockHeight, &txn.Time, &received,
+			&sent, pq.Array(&txn.Addresses), &numTxns)
+		if err != nil {
+			return nil, 0, err
+		}
+		txn.Received = lddlutil.Amount(received).ToCoin()
+		txn.Sent = lddlutil.Amount(sent).ToCoin()
+		txns = append(txns, txn)
+	}
+	return txns, numTxns, rows.Err()
+}
+
+// RetrieveAddressesTotals retrieves the spent and unspent output totals of
+// each of the addresses that has any outputs.
+func RetrieveAddressesTotals(db *sql.DB, addresses []string) ([]*explorer.AddressBalance, error) {
+	rows, err := db.Query(internal.SelectAddressesTotals, pq.Array(addresses))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	var balances []*explorer.AddressBalance
+	for rows.Next() {
+		var numAll, valueAll int64
+		balance := new(explorer.AddressBalance)
+		err = rows.Scan(&balance.Address, &numAll, &balance.NumSpent,
+			&valueAll, &balance.TotalSpent)
+		if err != nil {
+			return nil, err
+		}
+		balance.NumUnspent = numAll - balance.NumSpent
+		balance.TotalUnspent = valueAll - balance.TotalSpent
+		balances = append(balances, balance)
+	}
+	return balances, rows.Err()
+}
+
 // RetrieveAddressTxnsOrdered will get all transactions for addresses provided
 // and return them sorted by time in descending order. It will also return a
 // short list of recently (defined as greater than recentBlockHeight) confirmed
@@ -1248,10 +1698,14 @@ func RetrieveBlockSummaryByTimeRange(db *sql.DB, minTime, maxTime int64, limit i
 	return blocks, nil
 }
 
-func InsertBlock(db *sql.DB, dbBlock *dbtypes.Block, isValid, checked bool) (uint64, error) {
-	insertStatement := internal.MakeBlockInsertStatement(dbBlock, checked)
+func InsertBlock(db *sql.DB, dbBlock *dbtypes.Block, isValid, isMainchain, checked bool) (uint64, error) {
+	return insertBlock(db, dbBlock, isValid, isMainchain, checked)
+}
+
+func insertBlock(q sqlQueryer, dbBlock *dbtypes.Block, isValid, isMainchain, checked bool) (uint64, error) {
+	insertStatement := internal.MakeBlockInsertStatement(checked)
 	var id uint64
-	err := db.QueryRow(insertStatement,
+	err := q.QueryRow(insertStatement,
 		dbBlock.Hash, dbBlock.Height, dbBlock.Size, isValid, dbBlock.Version,
 		dbBlock.MerkleRoot, dbBlock.StakeRoot,
 		dbBlock.NumTx, dbBlock.NumRegTx, dbBlock.NumStakeTx,
@@ -1259,15 +1713,175 @@ func InsertBlock(db *sql.DB, dbBlock *dbtypes.Block, isValid, checked bool) (uin
 		dbBlock.FinalState, dbBlock.Voters, dbBlock.FreshStake,
 		dbBlock.Revocations, dbBlock.PoolSize, dbBlock.Bits,
 		dbBlock.SBits, dbBlock.Difficulty, dbBlock.ExtraData,
-		dbBlock.StakeVersion, dbBlock.PreviousHash).Scan(&id)
+		dbBlock.StakeVersion, dbBlock.PreviousHash, isMainchain).Scan(&id)
 	return id, err
 }
 
+// RetrieveMainchainBlocksAboveHeight retrieves the hashes and heights of the
+// main chain blocks above the given height, in order of increasing height.
+func RetrieveMainchainBlocksAboveHeight(db *sql.DB, height int64) (hashes []string, heights []int64, err error) {
+	rows, err := db.Query(internal.SelectMainchainBlocksAboveHeight, height)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			log.Errorf("Close of Query failed: %v", e)
+		}
+	}()
+
+	for rows.Next() {
+		var hash string
+		var h int64
+		err = rows.Scan(&hash, &h)
+		if err != nil {
+			break
+		}
+
+		hashes = append(hashes, hash)
+		heights = append(heights, h)
+	}
+	return
+}
+
+// RollbackBlocks undoes the effects of storing the blocks with the given hashes
+// as main chain blocks. In a single database transaction, the blocks are
+// flagged as side chain blocks, the common ancestor's next block hash is
+// cleared and its validity restored if its child disapproved it, spending info
+// set by the blocks' transactions is removed from the addresses and tickets
+// tables, and the address rows, tickets, votes and misses created by the blocks
+// are deleted. The blocks' transactions, vins and vouts are kept with the side
+// chain blocks. Tickets that voted in the blocks, were marked as missed by the
+// blocks, or expired above rootHeight, are set live again, while tickets
+// revoked in the blocks remain missed or expired. expiryDepth is the number of
+// blocks after purchase at which a ticket expires (ticket maturity plus
+// expiry). The root block is recorded as the last committed block in the meta
+// table. The hashes of the deleted tickets are returned.
+func RollbackBlocks(db *sql.DB, blockHashes []string, rootHash string,
+	rootHeight, expiryDepth int64) (deletedTickets []string, err error) {
+	if len(blockHashes) == 0 {
+		return nil, nil
+	}
+
+	dbtx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin database transaction: %v", err)
+	}
+
+	bail := func(context string, err error) ([]string, error) {
+		if errRoll := dbtx.Rollback(); errRoll != nil {
+			log.Errorf("Rollback failed: %v", errRoll)
+		}
+		return nil, fmt.Errorf("%s: %v", context, err)
+	}
+
+	blocks := pq.Array(blockHashes)
+
+	// Transactions in the blocks being removed from the main chain.
+	rows, err := dbtx.Query(internal.SelectTxDbIDsHashesByBlockHashes, blocks)
+	if err != nil {
+		return bail("failed to select block transactions", err)
+	}
+	var txDbIDs []int64
+	var txHashes []string
+	for rows.Next() {
+		var id int64
+		var hash string
+		if err = rows.Scan(&id, &hash); err != nil {
+			_ = rows.Close()
+			return bail("failed to scan block transactions", err)
+		}
+		txDbIDs = append(txDbIDs, id)
+		txHashes = append(txHashes, hash)
+	}
+	if err = rows.Close(); err != nil {
+		return bail("failed to close block transactions query", err)
+	}
+
+	// Blocks and block_chain
+	if _, err = dbtx.Exec(internal.UpdateBlocksMainchainByHashes, blocks, false); err != nil {
+		return bail("failed to set is_mainchain for blocks", err)
+	}
+	if _, err = dbtx.Exec(internal.UpdateBlockNextByHash, rootHash, ""); err != nil {
+		return bail("failed to reset next block hash", err)
+	}
+	if _, err = dbtx.Exec(internal.SetBlockValidForRemovedChild, rootHash, blocks); err != nil {
+		return bail("failed to reset root block validity", err)
+	}
+
+	// Addresses
+	if _, err = dbtx.Exec(internal.UnsetAddressSpendingForTxHashes,
+		pq.Array(txHashes)); err != nil {
+		return bail("failed to unset address spending info", err)
+	}
+	if _, err = dbtx.Exec(internal.DeleteAddressRowsForFundingTxDbIDs,
+		pq.Array(txDbIDs)); err != nil {
+		return bail("failed to delete address rows", err)
+	}
+
+	// Tickets voted, missed or expired in the removed blocks are live again.
+	// Tickets revoked in the removed blocks remain missed or expired.
+	if _, err = dbtx.Exec(internal.UnsetTicketSpendingInfoForSpendTxDbIDs,
+		pq.Array(txDbIDs), dbtypes.TicketUnspent, dbtypes.PoolStatusLive,
+		dbtypes.TicketVoted, dbtypes.PoolStatusMissed,
+		dbtypes.PoolStatusExpired); err != nil {
+		return bail("failed to unset ticket spending info", err)
+	}
+	if _, err = dbtx.Exec(internal.SetTicketsLiveForMissesInBlocks, blocks,
+		dbtypes.PoolStatusLive, dbtypes.TicketUnspent); err != nil {
+		return bail("failed to reset missed ticket status", err)
+	}
+	if _, err = dbtx.Exec(internal.SetTicketsLiveForExpiresAboveHeight, rootHeight,
+		dbtypes.PoolStatusLive, dbtypes.TicketUnspent, dbtypes.PoolStatusExpired,
+		expiryDepth); err != nil {
+		return bail("failed to reset expired ticket status", err)
+	}
+
+	// Tickets purchased in the removed blocks.
+	rows, err = dbtx.Query(internal.DeleteTicketsInBlocks, blocks)
+	if err != nil {
+		return bail("failed to delete tickets", err)
+	}
+	for rows.Next() {
+		var hash string
+		if err = rows.Scan(&hash); err != nil {
+			_ = rows.Close()
+			return bail("failed to scan deleted tickets", err)
+		}
+		deletedTickets = append(deletedTickets, hash)
+	}
+	if err = rows.Close(); err != nil {
+		return bail("failed to close deleted tickets query", err)
+	}
+
+	// Votes and misses
+	if _, err = dbtx.Exec(internal.DeleteVotesInBlocks, blocks); err != nil {
+		return bail("failed to delete votes", err)
+	}
+	if _, err = dbtx.Exec(internal.DeleteMissesInBlocks, blocks); err != nil {
+		return bail("failed to delete misses", err)
+	}
+
+	// The common ancestor is now the last committed main chain block.
+	if err = setMetaBestBlock(dbtx, rootHeight, rootHash); err != nil {
+		return bail("failed to update meta table", err)
+	}
+
+	return deletedTickets, dbtx.Commit()
+}
+
 // UpdateLastBlock updates the is_valid column of the block specified by the row
 // id for the blocks table.
 func UpdateLastBlock(db *sql.DB, blockDbID uint64, isValid bool) error {
-	numRows, err := sqlExec(db, internal.UpdateLastBlockValid,
-		"failed to update last block validity: ", blockDbID, isValid)
+	return updateLastBlock(db, blockDbID, isValid)
+}
+
+func updateLastBlock(q sqlQueryer, blockDbID uint64, isValid bool) error {
+	res, err := q.Exec(internal.UpdateLastBlockValid, blockDbID, isValid)
+	if err != nil {
+		return fmt.Errorf("failed to update last block validity: %v", err)
+	}
+	numRows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
@@ -1282,6 +1896,24 @@ func RetrieveBestBlockHeight(db *sql.DB) (height uint64, hash string, id uint64,
 	return
 }
 
+// RetrieveMetaBestBlock gets the last block recorded in the meta table as fully
+// committed. sql.ErrNoRows is returned if no block was stored since the meta
+// table was created.
+func RetrieveMetaBestBlock(db *sql.DB) (height uint64, hash string, err error) {
+	err = db.QueryRow(internal.SelectMetaBestBlock).Scan(&height, &hash)
+	return
+}
+
+// setMetaBestBlock records the last committed block in the meta table. It
+// should be executed in the database transaction that stores the block.
+func setMetaBestBlock(q sqlQueryer, height int64, hash string) error {
+	_, err := q.Exec(internal.SetMetaBestBlock, height, hash)
+	if err != nil {
+		return fmt.Errorf("failed to set the best block in the meta table: %v", err)
+	}
+	return nil
+}
+
 func RetrieveVoutValue(db *sql.DB, txHash string, voutIndex uint32) (value uint64, err error) {
 	err = db.QueryRow(internal.RetrieveVoutValue, txHash, voutIndex).Scan(&value)
 	return
@@ -1325,8 +1957,31 @@ func InsertBlockPrevNext(db *sql.DB, blockDbID uint64,
 	return err
 }
 
+func insertBlockPrevNext(q sqlQueryer, blockDbID uint64,
+	hash, prev, next string) error {
+	_, err := q.Exec(internal.InsertBlockPrevNext, blockDbID, prev, hash, next)
+	return err
+}
+
+// insertBlockTxns stores the block_transactions rows linking the block with
+// the given row ID to its regular and stake transactions, in block order.
+func insertBlockTxns(q sqlQueryer, blockDbID uint64, dbBlock *dbtypes.Block) error {
+	for tree, txDbIDs := range [2][]uint64{dbBlock.TxDbIDs, dbBlock.STxDbIDs} {
+		_, err := q.Exec(internal.InsertBlockTxnsTree, blockDbID,
+			dbtypes.UInt64Array(txDbIDs), tree)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func UpdateBlockNext(db *sql.DB, blockDbID uint64, next string) error {
-	res, err := db.Exec(internal.UpdateBlockNext, blockDbID, next)
+	return updateBlockNext(db, blockDbID, next)
+}
+
+func updateBlockNext(q sqlQueryer, blockDbID uint64, next string) error {
+	res, err := q.Exec(internal.UpdateBlockNext, blockDbID, next)
 	if err != nil {
 		return err
 	}
@@ -1353,10 +2008,23 @@ func InsertVins(db *sql.DB, dbVins dbtypes.VinTxPropertyARRAY, checked bool) ([]
 		return nil, fmt.Errorf("unable to begin database transaction: %v", err)
 	}
 
+	ids, err := insertVins(dbtx, dbVins, checked)
+	if err != nil {
+		if errRoll := dbtx.Rollback(); errRoll != nil {
+			log.Errorf("Rollback failed: %v", errRoll)
+		}
+		return ids, err
+	}
+
+	return ids, dbtx.Commit()
+}
+
+// insertVins is InsertVins within an existing database transaction, which the
+// caller must commit or roll back.
+func insertVins(dbtx *sql.Tx, dbVins dbtypes.VinTxPropertyARRAY, checked bool) ([]uint64, error) {
 	stmt, err := dbtx.Prepare(internal.MakeVinInsertStatement(checked))
 	if err != nil {
 		log.Errorf("Vin INSERT prepare: %v", err)
-		_ = dbtx.Rollback() // try, but we want the Prepare error back
 		return nil, err
 	}
 
@@ -1369,9 +2037,6 @@ func InsertVins(db *sql.DB, dbVins dbtypes.VinTxPropertyARRAY, checked bool) ([]
 			vin.PrevTxHash, vin.PrevTxIndex, vin.PrevTxTree).Scan(&id)
 		if err != nil {
 			_ = stmt.Close() // try, but we want the QueryRow error back
-			if errRoll := dbtx.Rollback(); errRoll != nil {
-				log.Errorf("Rollback failed: %v", errRoll)
-			}
 			return ids, fmt.Errorf("InsertVins INSERT exec failed: %v", err)
 		}
 		ids = append(ids, id)
@@ -1380,7 +2045,7 @@ func InsertVins(db *sql.DB, dbVins dbtypes.VinTxPropertyARRAY, checked bool) ([]
 	// Close prepared statement. Ignore errors as we'll Commit regardless.
 	_ = stmt.Close()
 
-	return ids, dbtx.Commit()
+	return ids, nil
 }
 
 func InsertVout(db *sql.DB, dbVout *dbtypes.Vout, checked bool) (uint64, error) {
@@ -1396,16 +2061,29 @@ func InsertVout(db *sql.DB, dbVout *dbtypes.Vout, checked bool) (uint64, error)
 }
 
 func InsertVouts(db *sql.DB, dbVouts []*dbtypes.Vout, checked bool) ([]uint64, []dbtypes.AddressRow, error) {
-	addressRows := make([]dbtypes.AddressRow, 0, len(dbVouts)*2)
 	dbtx, err := db.Begin()
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to begin database transaction: %v", err)
 	}
 
+	ids, addressRows, err := insertVouts(dbtx, dbVouts, checked)
+	if err != nil {
+		if errRoll := dbtx.Rollback(); errRoll != nil {
+			log.Errorf("Rollback failed: %v", errRoll)
+		}
+		return nil, nil, err
+	}
+
+	return ids, addressRows, dbtx.Commit()
+}
+
+// insertVouts is InsertVouts within an existing database transaction, which
+// the caller must commit or roll back.
+func insertVouts(dbtx *sql.Tx, dbVouts []*dbtypes.Vout, checked bool) ([]uint64, []dbtypes.AddressRow, error) {
+	addressRows := make([]dbtypes.AddressRow, 0, len(dbVouts)*2)
 	stmt, err := dbtx.Prepare(internal.MakeVoutInsertStatement(checked))
 	if err != nil {
 		log.Errorf("Vout INSERT prepare: %v", err)
-		_ = dbtx.Rollback() // try, but we want the Prepare error back
 		return nil, nil, err
 	}
 
@@ -1422,9 +2100,6 @@ func InsertVouts(db *sql.DB, dbVouts []*dbtypes.Vout, checked bool) ([]uint64, [
 				continue
 			}
 			_ = stmt.Close() // try, but we want the QueryRow error back
-			if errRoll := dbtx.Rollback(); errRoll != nil {
-				log.Errorf("Rollback failed: %v", errRoll)
-			}
 			return nil, nil, err
 		}
 		for _, addr := range vout.ScriptPubKeyData.Addresses {
@@ -1442,7 +2117,7 @@ func InsertVouts(db *sql.DB, dbVouts []*dbtypes.Vout, checked bool) ([]uint64, [
 	// Close prepared statement. Ignore errors as we'll Commit regardless.
 	_ = stmt.Close()
 
-	return ids, addressRows, dbtx.Commit()
+	return ids, addressRows, nil
 }
 
 // InsertAddressOut inserts an AddressRow (input or output), returning the row
@@ -1470,12 +2145,26 @@ func InsertAddressOuts(db *sql.DB, dbAs []*dbtypes.AddressRow, dupCheck bool) ([
 		return nil, fmt.Errorf("unable to begin database transaction: %v", err)
 	}
 
+	ids, err := insertAddressOuts(dbtx, dbAs, dupCheck)
+	if err != nil {
+		if errRoll := dbtx.Rollback(); errRoll != nil {
+			log.Errorf("Rollback failed: %v", errRoll)
+		}
+		return nil, err
+	}
+
+	return ids, dbtx.Commit()
+}
+
+// insertAddressOuts is InsertAddressOuts within an existing database
+// transaction, which the caller must commit or roll back. The addresses table
+// must exist.
+func insertAddressOuts(dbtx *sql.Tx, dbAs []*dbtypes.AddressRow, dupCheck bool) ([]uint64, error) {
 	sqlStmt := internal.MakeAddressRowInsertStatement(dupCheck)
 
 	stmt, err := dbtx.Prepare(sqlStmt)
 	if err != nil {
 		log.Errorf("AddressRow INSERT prepare: %v", err)
-		_ = dbtx.Rollback() // try, but we want the Prepare error back
 		return nil, err
 	}
 
@@ -1489,9 +2178,6 @@ func InsertAddressOuts(db *sql.DB, dbAs []*dbtypes.AddressRow, dupCheck bool) ([
 				continue
 			}
 			_ = stmt.Close() // try, but we want the QueryRow error back
-			if errRoll := dbtx.Rollback(); errRoll != nil {
-				log.Errorf("Rollback failed: %v", errRoll)
-			}
 			return nil, err
 		}
 		ids = append(ids, id)
@@ -1500,7 +2186,7 @@ func InsertAddressOuts(db *sql.DB, dbAs []*dbtypes.AddressRow, dupCheck bool) ([
 	// Close prepared statement. Ignore errors as we'll Commit regardless.
 	_ = stmt.Close()
 
-	return ids, dbtx.Commit()
+	return ids, nil
 }
 
 // InsertTickets takes a slice of *dbtypes.Tx and corresponding DB row IDs for
@@ -1513,10 +2199,23 @@ func InsertTickets(db *sql.DB, dbTxns []*dbtypes.Tx, txDbIDs []uint64, checked b
 		return nil, nil, fmt.Errorf("unable to begin database transaction: %v", err)
 	}
 
+	ids, ticketTx, err := insertTickets(dbtx, dbTxns, txDbIDs, checked)
+	if err != nil {
+		if errRoll := dbtx.Rollback(); errRoll != nil {
+			log.Errorf("Rollback failed: %v", errRoll)
+		}
+		return nil, nil, err
+	}
+
+	return ids, ticketTx, dbtx.Commit()
+}
+
+// insertTickets is InsertTickets within an existing database transaction,
+// which the caller must commit or roll back.
+func insertTickets(dbtx *sql.Tx, dbTxns []*dbtypes.Tx, txDbIDs []uint64, checked bool) ([]uint64, []*dbtypes.Tx, error) {
 	stmt, err := dbtx.Prepare(internal.MakeTicketInsertStatement(checked))
 	if err != nil {
 		log.Errorf("Ticket INSERT prepare: %v", err)
-		_ = dbtx.Rollback() // try, but we want the Prepare error back
 		return nil, nil, err
 	}
 
@@ -1560,19 +2259,15 @@ func InsertTickets(db *sql.DB, dbTxns []*dbtypes.Tx, txDbIDs []uint64, checked b
 				continue
 			}
 			_ = stmt.Close() // try, but we want the QueryRow error back
-			if errRoll := dbtx.Rollback(); errRoll != nil {
-				log.Errorf("Rollback failed: %v", errRoll)
-			}
 			return nil, nil, err
 		}
 		ids = append(ids, id)
 	}
 
-	// Close prepared statement. Ignore errors as we'll Commit regardless.
+	// Close prepared statement. Ignore errors as the caller commits regardless.
 	_ = stmt.Close()
 
-	return ids, ticketTx, dbtx.Commit()
-
+	return ids, ticketTx, nil
 }
 
 // InsertVotes takes a slice of *dbtypes.Tx, which must contain all the stake
@@ -1589,6 +2284,29 @@ func InsertTickets(db *sql.DB, dbTxns []*dbtypes.Tx, txDbIDs []uint64, checked b
 func InsertVotes(db *sql.DB, dbTxns []*dbtypes.Tx, _ /*txDbIDs*/ []uint64,
 	fTx *TicketTxnIDGetter, msgBlock *MsgBlockPG, checked bool) ([]uint64,
 	[]*dbtypes.Tx, []string, []uint64, map[string]uint64, error) {
+	// Start DB transaction
+	dbtx, err := db.Begin()
+	if err != nil {
+		return nil, nil, nil, nil, nil, fmt.Errorf("unable to begin database transaction: %v", err)
+	}
+
+	ids, voteTxs, spentTicketHashes, spentTicketDbIDs, missHashMap, err :=
+		insertVotes(dbtx, dbTxns, fTx, msgBlock, checked)
+	if err != nil {
+		if errRoll := dbtx.Rollback(); errRoll != nil {
+			log.Errorf("Rollback failed: %v", errRoll)
+		}
+		return nil, nil, nil, nil, nil, err
+	}
+
+	return ids, voteTxs, spentTicketHashes, spentTicketDbIDs, missHashMap, dbtx.Commit()
+}
+
+// insertVotes is InsertVotes within an existing database transaction, which the
+// caller must commit or roll back.
+func insertVotes(dbtx *sql.Tx, dbTxns []*dbtypes.Tx, fTx *TicketTxnIDGetter,
+	msgBlock *MsgBlockPG, checked bool) ([]uint64, []*dbtypes.Tx, []string,
+	[]uint64, map[string]uint64, error) {
 	// Choose only SSGen txns
 	msgTxs := msgBlock.STransactions
 	var voteTxs []*dbtypes.Tx
@@ -1609,17 +2327,11 @@ func InsertVotes(db *sql.DB, dbTxns []*dbtypes.Tx, _ /*txDbIDs*/ []uint64,
 		return nil, nil, nil, nil, nil, nil
 	}
 
-	// Start DB transaction and prepare vote insert statement
-	dbtx, err := db.Begin()
-	if err != nil {
-		return nil, nil, nil, nil, nil, fmt.Errorf("unable to begin database transaction: %v", err)
-	}
-
+	// Prepare vote insert statement
 	voteInsert := internal.MakeVoteInsertStatement(checked)
 	stmt, err := dbtx.Prepare(voteInsert)
 	if err != nil {
 		log.Errorf("Votes INSERT prepare: %v", err)
-		_ = dbtx.Rollback() // try, but we want the Prepare error back
 		return nil, nil, nil, nil, nil, err
 	}
 
@@ -1648,9 +2360,6 @@ func InsertVotes(db *sql.DB, dbTxns []*dbtypes.Tx, _ /*txDbIDs*/ []uint64,
 			t, err := fTx.TxnDbID(stakeSubmissionTxHash, true)
 			if err != nil {
 				_ = stmt.Close() // try, but we want the QueryRow error back
-				if errRoll := dbtx.Rollback(); errRoll != nil {
-					log.Errorf("Rollback failed: %v", errRoll)
-				}
 				return nil, nil, nil, nil, nil, err
 			}
 			ticketTxDbID.Int64 = int64(t)
@@ -1678,21 +2387,17 @@ func InsertVotes(db *sql.DB, dbTxns []*dbtypes.Tx, _ /*txDbIDs*/ []uint64,
 				continue
 			}
 			_ = stmt.Close() // try, but we want the QueryRow error back
-			if errRoll := dbtx.Rollback(); errRoll != nil {
-				log.Errorf("Rollback failed: %v", errRoll)
-			}
 			return nil, nil, nil, nil, nil, err
 		}
 		ids = append(ids, id)
 	}
 
-	// Close prepared statement. Ignore errors as we'll Commit regardless.
+	// Close prepared statement. Ignore errors as the caller commits regardless.
 	_ = stmt.Close()
 
 	if len(ids)+len(misses) != 5 {
 		fmt.Println(misses)
 		fmt.Println(voteTxs)
-		_ = dbtx.Rollback()
 		panic(fmt.Sprintf("votes (%d) + misses (%d) != 5", len(ids), len(misses)))
 	}
 
@@ -1702,7 +2407,6 @@ func InsertVotes(db *sql.DB, dbTxns []*dbtypes.Tx, _ /*txDbIDs*/ []uint64,
 		stmtMissed, err := dbtx.Prepare(internal.MakeMissInsertStatement(checked))
 		if err != nil {
 			log.Errorf("Miss INSERT prepare: %v", err)
-			_ = dbtx.Rollback() // try, but we want the Prepare error back
 			return nil, nil, nil, nil, nil, err
 		}
 
@@ -1717,9 +2421,6 @@ func InsertVotes(db *sql.DB, dbTxns []*dbtypes.Tx, _ /*txDbIDs*/ []uint64,
 					continue
 				}
 				_ = stmtMissed.Close() // try, but we want the QueryRow error back
-				if errRoll := dbtx.Rollback(); errRoll != nil {
-					log.Errorf("Rollback failed: %v", errRoll)
-				}
 				return nil, nil, nil, nil, nil, err
 			}
 			missHashMap[misses[i]] = id
@@ -1727,7 +2428,7 @@ func InsertVotes(db *sql.DB, dbTxns []*dbtypes.Tx, _ /*txDbIDs*/ []uint64,
 		_ = stmtMissed.Close()
 	}
 
-	return ids, voteTxs, spentTicketHashes, spentTicketDbIDs, missHashMap, dbtx.Commit()
+	return ids, voteTxs, spentTicketHashes, spentTicketDbIDs, missHashMap, nil
 }
 
 func InsertTx(db *sql.DB, dbTx *dbtypes.Tx, checked bool) (uint64, error) {
@@ -1737,8 +2438,7 @@ func InsertTx(db *sql.DB, dbTx *dbtypes.Tx, checked bool) (uint64, error) {
 		dbTx.BlockHash, dbTx.BlockHeight, dbTx.BlockTime, dbTx.Time,
 		dbTx.TxType, dbTx.Version, dbTx.Tree, dbTx.TxID, dbTx.BlockIndex,
 		dbTx.Locktime, dbTx.Expiry, dbTx.Size, dbTx.Spent, dbTx.Sent, dbTx.Fees,
-		dbTx.NumVin, dbtypes.UInt64Array(dbTx.VinDbIds),
-		dbTx.NumVout, dbtypes.UInt64Array(dbTx.VoutDbIds)).Scan(&id)
+		dbTx.NumVin, dbTx.NumVout).Scan(&id)
 	return id, err
 }
 
@@ -1748,10 +2448,23 @@ func InsertTxns(db *sql.DB, dbTxns []*dbtypes.Tx, checked bool) ([]uint64, error
 		return nil, fmt.Errorf("unable to begin database transaction: %v", err)
 	}
 
+	ids, err := insertTxns(dbtx, dbTxns, checked)
+	if err != nil {
+		if errRoll := dbtx.Rollback(); errRoll != nil {
+			log.Errorf("Rollback failed: %v", errRoll)
+		}
+		return nil, err
+	}
+
+	return ids, dbtx.Commit()
+}
+
+// insertTxns is InsertTxns within an existing database transaction, which the
+// caller must commit or roll back.
+func insertTxns(dbtx *sql.Tx, dbTxns []*dbtypes.Tx, checked bool) ([]uint64, error) {
 	stmt, err := dbtx.Prepare(internal.MakeTxInsertStatement(checked))
 	if err != nil {
 		log.Errorf("Transaction INSERT prepare: %v", err)
-		_ = dbtx.Rollback() // try, but we want the Prepare error back
 		return nil, err
 	}
 
@@ -1762,16 +2475,12 @@ func InsertTxns(db *sql.DB, dbTxns []*dbtypes.Tx, checked bool) ([]uint64, error
 			tx.BlockHash, tx.BlockHeight, tx.BlockTime, tx.Time,
 			tx.TxType, tx.Version, tx.Tree, tx.TxID, tx.BlockIndex,
 			tx.Locktime, tx.Expiry, tx.Size, tx.Spent, tx.Sent, tx.Fees,
-			tx.NumVin, dbtypes.UInt64Array(tx.VinDbIds),
-			tx.NumVout, dbtypes.UInt64Array(tx.VoutDbIds)).Scan(&id)
+			tx.NumVin, tx.NumVout).Scan(&id)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				continue
 			}
 			_ = stmt.Close() // try, but we want the QueryRow error back
-			if errRoll := dbtx.Rollback(); errRoll != nil {
-				log.Errorf("Rollback failed: %v", errRoll)
-			}
 			return nil, err
 		}
 		ids = append(ids, id)
@@ -1780,5 +2489,5 @@ func InsertTxns(db *sql.DB, dbTxns []*dbtypes.Tx, checked bool) ([]uint64, error
 	// Close prepared statement. Ignore errors as we'll Commit regardless.
 	_ = stmt.Close()
 
-	return ids, dbtx.Commit()
+	return ids, nil
 }
@@ -104,7 +104,35 @@ func (db *ChainDB) SyncChainDB(client rpcutils.MasterBlockGetter, quit chan stru
 
 	// Start rebuilding
 	startHeight := lastBlock + 1
-	for ib := startHeight; ib <= nodeHeight; ib++ {
+	nextHeight := startHeight
+
+	// A bulk load that defers the address spending info to the batch update
+	// below uses the pipelined importer. The loop below then stores any blocks
+	// mined since it finished.
+	if reindexing && updateAllAddresses {
+		log.Infof("Importing blocks %d to %d with the pipelined sync.",
+			startHeight, nodeHeight)
+		height, totals, err := db.pipelineSync(client, quit, startHeight,
+			nodeHeight, !updateAllVotes)
+		totalTxs, totalVins, totalVouts = totals.txns, totals.vins, totals.vouts
+		if err != nil {
+			return height, fmt.Errorf("pipelined sync failed: %v", err)
+		}
+		select {
+		case <-quit:
+			log.Infof("Rescan cancelled at height %d.", height+1)
+			return height, nil
+		default:
+		}
+		lastBlock, lastTxs = height, totalTxs
+		lastVins, lastVouts = totalVins, totalVouts
+		nextHeight = height + 1
+		if height > nodeHeight {
+			nodeHeight = height
+		}
+	}
+
+	for ib := nextHeight; ib <= nodeHeight; ib++ {
 		// check for quit signal
 		select {
 		case <-quit:
@@ -254,6 +282,10 @@ func (db *ChainDB) SyncChainDB(client rpcutils.MasterBlockGetter, quit chan stru
 		}
 	}
 
+	// Blocks stored after the sync, including those of a side chain during a
+	// reorg, may contain transactions that are already in the tables.
+	db.EnableDuplicateCheckOnInsert(true)
+
 	log.Infof("Sync finished at height %d. Delta: %d blocks, %d transactions, %d ins, %d outs",
 		nodeHeight, nodeHeight-startHeight+1, totalTxs, totalVins, totalVouts)
 
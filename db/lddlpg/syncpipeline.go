@@ -0,0 +1,841 @@
+// Copyright (c) 2018, The lddldata developers
+// See LICENSE for details.
+
+package lddlpg
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/Legenddigital/lddld/chaincfg"
+	"github.com/Legenddigital/lddld/chaincfg/chainhash"
+	"github.com/Legenddigital/lddld/wire"
+	"github.com/Legenddigital/lddldata/db/dbtypes"
+	"github.com/Legenddigital/lddldata/db/lddlpg/internal"
+	"github.com/Legenddigital/lddldata/rpcutils"
+	"github.com/lib/pq"
+)
+
+const (
+	// syncFetchers is the number of goroutines retrieving blocks from lddld
+	// ahead of the other stages of a pipelined sync.
+	syncFetchers = 4
+	// syncReadAhead is the number of blocks that may be fetched and extracted
+	// ahead of the block being written.
+	syncReadAhead = 256
+	// syncBatchBlocks and syncBatchTxns limit the size of a batch of blocks
+	// written in one database transaction.
+	syncBatchBlocks = 500
+	syncBatchTxns   = 50000
+	// syncReportInterval is the time between progress reports.
+	syncReportInterval = 20 * time.Second
+)
+
+// syncTotals counts the data stored by a sync.
+type syncTotals struct {
+	txns, vins, vouts int64
+}
+
+// fetchJob is the retrieval of the block at height by a fetcher, which sends
+// the result on done.
+type fetchJob struct {
+	height int64
+	done   chan error
+}
+
+// syncBlock is a block passing through the stages of a pipelined sync. A
+// syncBlock with err set ends the sync.
+type syncBlock struct {
+	height int64
+	err    error
+
+	// Set when the stake database has connected the block.
+	msgBlock   *wire.MsgBlock
+	hash       chainhash.Hash
+	winners    []string
+	validators []string
+	expiries   *ticketExpiries
+
+	// Set by an extractor, which then closes extracted.
+	dbBlock   *dbtypes.Block
+	trees     [2]syncTxTree
+	extracted chan struct{}
+}
+
+// syncTxTree is the data extracted from one transaction tree of a block.
+type syncTxTree struct {
+	txns  []*dbtypes.Tx
+	vouts [][]*dbtypes.Vout
+	vins  []dbtypes.VinTxPropertyARRAY
+}
+
+// extract converts the block and its regular and stake transactions to their
+// dbtypes, and closes extracted.
+func (sb *syncBlock) extract(params *chaincfg.Params) {
+	sb.dbBlock = dbtypes.MsgBlockToDBBlock(sb.msgBlock, params)
+	for i, txTree := range []int8{wire.TxTreeRegular, wire.TxTreeStake} {
+		tree := &sb.trees[i]
+		tree.txns, tree.vouts, tree.vins = dbtypes.ExtractBlockTransactions(
+			sb.msgBlock, txTree, params)
+	}
+	// The tickets table is populated from the stake transactions' vouts.
+	stake := &sb.trees[1]
+	for it, tx := range stake.txns {
+		tx.Vouts = stake.vouts[it]
+	}
+	close(sb.extracted)
+}
+
+// pipelineSync stores the main chain blocks from startHeight to the best block
+// of the node, running the stages of the import concurrently:
+//
+//  1. syncFetchers goroutines retrieve the blocks from lddld ahead of the other
+//     stages with the MasterBlockGetter's PrefetchBlock.
+//  2. The blocks are made available to the stake database in order with
+//     UpdateToBlock, and the winning tickets and ticket expiries are recorded
+//     as each one is connected.
+//  3. One goroutine per CPU extracts the blocks' transactions, vins and vouts.
+//  4. The blocks are written in batches, each in a single database transaction
+//     using COPY for the transactions, vins, vouts and addresses tables.
+//
+// The writer assigns the row IDs of the COPY tables in the order of the blocks,
+// their transactions, and the transactions' inputs and outputs, so the tables
+// are the same as if the blocks were stored one at a time. As a batch includes
+// the rows of the blocks table, an interrupted sync leaves the tables at the
+// end of the last batch, and resumes after the best block in the blocks table.
+//
+// Spending info in the addresses table is not set, and must be updated after
+// the sync. The height of the last block stored and the totals of the data
+// stored are returned.
+func (db *ChainDB) pipelineSync(client rpcutils.MasterBlockGetter, quit chan struct{},
+	startHeight, nodeHeight int64, updateTicketsSpendingInfo bool) (int64, syncTotals, error) {
+	// stop ends the stages when the writer returns.
+	stop := make(chan struct{})
+	defer close(stop)
+
+	fetched := db.syncFetch(client, startHeight, nodeHeight, stop)
+	connected, extract := db.syncConnect(client, fetched, quit, stop,
+		updateTicketsSpendingInfo)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		go func() {
+			for sb := range extract {
+				sb.extract(db.chainParams)
+			}
+		}()
+	}
+
+	w, err := db.newSyncWriter(startHeight-1, updateTicketsSpendingInfo)
+	if err != nil {
+		return startHeight - 1, syncTotals{}, err
+	}
+
+	ticker := time.NewTicker(syncReportInterval)
+	defer ticker.Stop()
+	reporter := newSyncReporter(startHeight-1, nodeHeight)
+
+	for {
+		var sb *syncBlock
+		var ok bool
+		select {
+		case sb, ok = <-connected:
+		default:
+			// Write the pending blocks rather than wait for more.
+			if err = w.write(); err != nil {
+				return w.last.height, w.totals, err
+			}
+			sb, ok = <-connected
+		}
+		if !ok {
+			break
+		}
+		if sb.err != nil {
+			// Keep the blocks before the failure, so a new sync resumes
+			// after them.
+			if err = w.write(); err != nil {
+				log.Errorf("Failed to store blocks before height %d: %v",
+					sb.height, err)
+			}
+			return w.last.height, w.totals, sb.err
+		}
+
+		<-sb.extracted
+		w.add(sb)
+		if w.full() {
+			if err = w.write(); err != nil {
+				return w.last.height, w.totals, err
+			}
+		}
+
+		select {
+		case <-ticker.C:
+			reporter.report(w.last.height, w.totals)
+		default:
+		}
+	}
+
+	err = w.write()
+	return w.last.height, w.totals, err
+}
+
+// syncFetch starts the fetchers of a pipelined sync, returning their jobs in
+// block order. Blocks are fetched up to the best block of the node, which is
+// checked again on reaching it.
+func (db *ChainDB) syncFetch(client rpcutils.MasterBlockGetter, startHeight,
+	nodeHeight int64, stop chan struct{}) chan *fetchJob {
+	jobs := make(chan *fetchJob)
+	fetched := make(chan *fetchJob, syncReadAhead)
+	for i := 0; i < syncFetchers; i++ {
+		go func() {
+			for job := range jobs {
+				job.done <- client.PrefetchBlock(job.height)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		defer close(fetched)
+		for height := startHeight; ; height++ {
+			job := &fetchJob{
+				height: height,
+				done:   make(chan error, 1),
+			}
+			if height > nodeHeight {
+				// Blocks may have been mined since the sync started.
+				var err error
+				if nodeHeight, err = client.NodeHeight(); err != nil {
+					job.done <- fmt.Errorf("GetBestBlock failed: %v", err)
+					select {
+					case fetched <- job:
+					case <-stop:
+					}
+					return
+				}
+				if height > nodeHeight {
+					return
+				}
+			}
+
+			select {
+			case fetched <- job:
+			case <-stop:
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return fetched
+}
+
+// syncConnect starts the stage of a pipelined sync that connects the fetched
+// blocks to the stake database in order. The connected blocks are sent in
+// order on the first returned channel, and to the extractors on the second.
+// Both channels are closed when the fetched blocks run out, on error, or if
+// the sync is quit.
+func (db *ChainDB) syncConnect(client rpcutils.MasterBlockGetter, fetched chan *fetchJob,
+	quit, stop chan struct{}, updateTicketsSpendingInfo bool) (chan *syncBlock, chan *syncBlock) {
+	connected := make(chan *syncBlock, syncReadAhead)
+	extract := make(chan *syncBlock, syncReadAhead)
+	go func() {
+		defer close(connected)
+		defer close(extract)
+		for job := range fetched {
+			sb, err := db.connectSyncBlock(client, job, quit, stop,
+				updateTicketsSpendingInfo)
+			if err != nil {
+				sb = &syncBlock{height: job.height, err: err}
+			} else if sb == nil {
+				return
+			} else {
+				select {
+				case extract <- sb:
+				case <-stop:
+					return
+				}
+			}
+
+			select {
+			case connected <- sb:
+			case <-stop:
+				return
+			}
+			if sb.err != nil {
+				return
+			}
+		}
+	}()
+
+	return connected, extract
+}
+
+// connectSyncBlock waits for the job's block to be fetched, makes it available
+// to the stake database with UpdateToBlock, and waits for the stake database to
+// connect it. The ticket info of the block is recorded before the next block is
+// connected. A nil syncBlock and error are returned if the sync is quit.
+func (db *ChainDB) connectSyncBlock(client rpcutils.MasterBlockGetter, job *fetchJob,
+	quit, stop chan struct{}, updateTicketsSpendingInfo bool) (*syncBlock, error) {
+	select {
+	case err := <-job.done:
+		if err != nil {
+			return nil, err
+		}
+	case <-quit:
+		return nil, nil
+	case <-stop:
+		return nil, nil
+	}
+
+	// Register for notification from stakedb when it connects this block.
+	waitChan := db.stakeDB.WaitForHeight(job.height)
+
+	// Make the prefetched block available to stakedb, which will signal on the
+	// above channel when it is done connecting it.
+	block, err := client.UpdateToBlock(job.height)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateToBlock (%d) failed: %v", job.height, err)
+	}
+
+	var blockHash *chainhash.Hash
+	select {
+	case blockHash = <-waitChan:
+	case <-quit:
+		return nil, nil
+	case <-stop:
+		return nil, nil
+	}
+	if blockHash == nil {
+		return nil, fmt.Errorf("stakedb says that block %d has come and gone", job.height)
+	}
+
+	// Winning tickets from StakeDatabase, which just connected the block, and
+	// the tickets called to vote on the previous block.
+	tpi, ok := db.stakeDB.PoolInfo(*blockHash)
+	if !ok {
+		return nil, fmt.Errorf("stakeDB.PoolInfo could not locate block %s", blockHash)
+	}
+	msgBlock := block.MsgBlock()
+	sb := &syncBlock{
+		height:    job.height,
+		msgBlock:  msgBlock,
+		hash:      *blockHash,
+		winners:   tpi.Winners,
+		extracted: make(chan struct{}),
+	}
+	if prevHash := msgBlock.Header.PrevBlock; prevHash != zeroHash {
+		prevTPI, ok := db.stakeDB.PoolInfo(prevHash)
+		if !ok {
+			return nil, fmt.Errorf("stakeDB.PoolInfo could not locate block %s", prevHash)
+		}
+		sb.validators = prevTPI.Winners
+	}
+
+	if updateTicketsSpendingInfo {
+		db.stakeDB.LockStakeNode()
+		sb.expiries = db.blockTicketExpiries(msgBlock)
+		db.stakeDB.UnlockStakeNode()
+	}
+
+	return sb, nil
+}
+
+// syncWriter stores batches of blocks for a pipelined sync. It assigns the row
+// IDs of the transactions, vins, vouts and addresses tables, starting after the
+// largest IDs in the tables.
+type syncWriter struct {
+	db                        *ChainDB
+	updateTicketsSpendingInfo bool
+
+	// The row IDs of the next rows of the COPY tables.
+	nextTxID, nextVinID, nextVoutID, nextAddressID uint64
+
+	// The last block committed, and the last block of the batch being
+	// written, which becomes the last committed once the batch is.
+	last, pending syncTip
+
+	// The pending batch.
+	blocks  []*syncBlock
+	numTxns int
+
+	// The tickets of the batch being written that were added to the unspent
+	// ticket cache, which are removed if the batch is not committed.
+	newTickets []string
+
+	totals syncTotals
+}
+
+// syncTip is the last block written by a syncWriter.
+type syncTip struct {
+	height    int64
+	hash      chainhash.Hash
+	blockDbID uint64
+}
+
+func (db *ChainDB) newSyncWriter(height int64, updateTicketsSpendingInfo bool) (*syncWriter, error) {
+	w := &syncWriter{
+		db:                        db,
+		updateTicketsSpendingInfo: updateTicketsSpendingInfo,
+		last:                      syncTip{height: height},
+	}
+
+	for _, next := range []struct {
+		table string
+		id    *uint64
+	}{
+		{"transactions", &w.nextTxID},
+		{"vins", &w.nextVinID},
+		{"vouts", &w.nextVoutID},
+		{"addresses", &w.nextAddressID},
+	} {
+		var maxID uint64
+		err := db.db.QueryRow(internal.MakeSelectMaxIDStatement(next.table)).Scan(&maxID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the largest row ID of the %s table: %v",
+				next.table, err)
+		}
+		*next.id = maxID + 1
+	}
+
+	return w, nil
+}
+
+func (w *syncWriter) add(sb *syncBlock) {
+	w.blocks = append(w.blocks, sb)
+	w.numTxns += len(sb.msgBlock.Transactions) + len(sb.msgBlock.STransactions)
+}
+
+func (w *syncWriter) full() bool {
+	return len(w.blocks) >= syncBatchBlocks || w.numTxns >= syncBatchTxns
+}
+
+// write stores the pending batch of blocks in a single database transaction.
+// The last committed block and the next row IDs are only advanced once the
+// batch is committed.
+func (w *syncWriter) write() error {
+	if len(w.blocks) == 0 {
+		return nil
+	}
+
+	dbtx, err := w.db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to begin database transaction: %v", err)
+	}
+
+	nextTxID, nextVinID, nextVoutID, nextAddressID := w.nextTxID, w.nextVinID,
+		w.nextVoutID, w.nextAddressID
+	w.pending = w.last
+	w.newTickets = w.newTickets[:0]
+	bail := func() {
+		w.nextTxID, w.nextVinID, w.nextVoutID, w.nextAddressID = nextTxID,
+			nextVinID, nextVoutID, nextAddressID
+		w.db.unspentTicketCache.Delete(w.newTickets)
+	}
+
+	if err = w.writeBatch(dbtx); err != nil {
+		if errRoll := dbtx.Rollback(); errRoll != nil {
+			log.Errorf("Rollback failed: %v", errRoll)
+		}
+		bail()
+		return err
+	}
+	if err = dbtx.Commit(); err != nil {
+		bail()
+		return fmt.Errorf("failed to commit blocks %d to %d: %v",
+			w.blocks[0].height, w.pending.height, err)
+	}
+
+	w.last = w.pending
+	w.db.lastBlock[w.last.hash] = w.last.blockDbID
+	w.db.setHeight(w.last.height)
+
+	for _, sb := range w.blocks {
+		for _, tree := range sb.trees {
+			w.totals.txns += int64(len(tree.txns))
+			for it := range tree.txns {
+				w.totals.vins += int64(len(tree.vins[it]))
+				w.totals.vouts += int64(len(tree.vouts[it]))
+			}
+		}
+	}
+	w.blocks = w.blocks[:0]
+	w.numTxns = 0
+
+	return nil
+}
+
+func (w *syncWriter) writeBatch(dbtx *sql.Tx) error {
+	w.assignIDs()
+
+	if err := w.copyVouts(dbtx); err != nil {
+		return fmt.Errorf("vouts COPY failed: %v", err)
+	}
+	if err := w.copyVins(dbtx); err != nil {
+		return fmt.Errorf("vins COPY failed: %v", err)
+	}
+	if err := w.copyTxns(dbtx); err != nil {
+		return fmt.Errorf("transactions COPY failed: %v", err)
+	}
+	if err := w.copyAddresses(dbtx); err != nil {
+		return fmt.Errorf("addresses COPY failed: %v", err)
+	}
+
+	// Rows inserted after the sync take the IDs following the batch.
+	for _, last := range []struct {
+		table string
+		id    uint64
+	}{
+		{"transactions", w.nextTxID - 1},
+		{"vins", w.nextVinID - 1},
+		{"vouts", w.nextVoutID - 1},
+		{"addresses", w.nextAddressID - 1},
+	} {
+		if last.id == 0 {
+			continue
+		}
+		if _, err := dbtx.Exec(internal.MakeSetSerialIDStatement(last.table), last.id); err != nil {
+			return fmt.Errorf("failed to set the %s table's id sequence: %v",
+				last.table, err)
+		}
+	}
+
+	for _, sb := range w.blocks {
+		if err := w.writeBlock(dbtx, sb); err != nil {
+			return fmt.Errorf("failed to store block %d: %v", sb.height, err)
+		}
+	}
+
+	// Record the batch's last block as the last one committed.
+	return setMetaBestBlock(dbtx, w.pending.height, w.pending.hash.String())
+}
+
+// assignIDs sets the row IDs of the pending blocks' transactions, vins and
+// vouts. The addresses table IDs are assigned by copyAddresses.
+func (w *syncWriter) assignIDs() {
+	for _, sb := range w.blocks {
+		sb.dbBlock.TxDbIDs = w.assignTreeIDs(&sb.trees[0])
+		sb.dbBlock.STxDbIDs = w.assignTreeIDs(&sb.trees[1])
+	}
+}
+
+func (w *syncWriter) assignTreeIDs(tree *syncTxTree) []uint64 {
+	txDbIDs := make([]uint64, len(tree.txns))
+	for it, tx := range tree.txns {
+		tx.VoutDbIds = make([]uint64, len(tree.vouts[it]))
+		for iv := range tx.VoutDbIds {
+			tx.VoutDbIds[iv] = w.nextVoutID
+			w.nextVoutID++
+		}
+		tx.VinDbIds = make([]uint64, len(tree.vins[it]))
+		for iv := range tx.VinDbIds {
+			tx.VinDbIds[iv] = w.nextVinID
+			w.nextVinID++
+		}
+		txDbIDs[it] = w.nextTxID
+		w.nextTxID++
+	}
+	return txDbIDs
+}
+
+// copyIn runs the COPY statement in the database transaction, with the rows
+// sent by the rows function.
+func copyIn(dbtx *sql.Tx, copyStmt string, rows func(row func(args ...interface{}) error) error) error {
+	stmt, err := dbtx.Prepare(copyStmt)
+	if err != nil {
+		return err
+	}
+
+	err = rows(func(args ...interface{}) error {
+		_, err := stmt.Exec(args...)
+		return err
+	})
+	if err == nil {
+		// Flush the buffered rows.
+		_, err = stmt.Exec()
+	}
+	if err != nil {
+		_ = stmt.Close() // try, but we want the Exec error back
+		return err
+	}
+	return stmt.Close()
+}
+
+// hashBytes decodes a hex hash for a BYTEA column of a COPY row. Unlike the
+// INSERT statements, which decode the hashes in SQL, COPY takes the column
+// values as is.
+func hashBytes(hash string) ([]byte, error) {
+	b, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hash %q: %v", hash, err)
+	}
+	return b, nil
+}
+
+func (w *syncWriter) copyVouts(dbtx *sql.Tx) error {
+	return copyIn(dbtx, internal.MakeVoutCopyInStatement(), func(row func(...interface{}) error) error {
+		for _, sb := range w.blocks {
+			for _, tree := range sb.trees {
+				for it, tx := range tree.txns {
+					for iv, vout := range tree.vouts[it] {
+						txHash, err := hashBytes(vout.TxHash)
+						if err != nil {
+							return err
+						}
+						err = row(tx.VoutDbIds[iv], txHash, vout.TxIndex,
+							vout.TxTree, vout.Value, vout.Version,
+							vout.ScriptPubKey, vout.ScriptPubKeyData.ReqSigs,
+							vout.ScriptPubKeyData.Type,
+							pq.Array(vout.ScriptPubKeyData.Addresses))
+						if err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (w *syncWriter) copyVins(dbtx *sql.Tx) error {
+	return copyIn(dbtx, internal.MakeVinCopyInStatement(), func(row func(...interface{}) error) error {
+		for _, sb := range w.blocks {
+			for _, tree := range sb.trees {
+				for it, tx := range tree.txns {
+					for iv, vin := range tree.vins[it] {
+						txHash, err := hashBytes(vin.TxID)
+						if err != nil {
+							return err
+						}
+						prevTxHash, err := hashBytes(vin.PrevTxHash)
+						if err != nil {
+							return err
+						}
+						err = row(tx.VinDbIds[iv], txHash, vin.TxIndex,
+							vin.TxTree, prevTxHash, vin.PrevTxIndex,
+							vin.PrevTxTree)
+						if err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (w *syncWriter) copyTxns(dbtx *sql.Tx) error {
+	return copyIn(dbtx, internal.MakeTxCopyInStatement(), func(row func(...interface{}) error) error {
+		for _, sb := range w.blocks {
+			txDbIDs := [2][]uint64{sb.dbBlock.TxDbIDs, sb.dbBlock.STxDbIDs}
+			for i, tree := range sb.trees {
+				for it, tx := range tree.txns {
+					blockHash, err := hashBytes(tx.BlockHash)
+					if err != nil {
+						return err
+					}
+					txHash, err := hashBytes(tx.TxID)
+					if err != nil {
+						return err
+					}
+					err = row(txDbIDs[i][it], blockHash, tx.BlockHeight,
+						tx.BlockTime, tx.Time, tx.TxType, tx.Version, tx.Tree,
+						txHash, tx.BlockIndex, tx.Locktime, tx.Expiry,
+						tx.Size, tx.Spent, tx.Sent, tx.Fees,
+						tx.NumVin, tx.NumVout)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// copyAddresses stores a row in the addresses table for each address paid by
+// the pending blocks' vouts, without the spending info.
+func (w *syncWriter) copyAddresses(dbtx *sql.Tx) error {
+	return copyIn(dbtx, internal.MakeAddressRowCopyInStatement(), func(row func(...interface{}) error) error {
+		for _, sb := range w.blocks {
+			txDbIDs := [2][]uint64{sb.dbBlock.TxDbIDs, sb.dbBlock.STxDbIDs}
+			for i, tree := range sb.trees {
+				for it, tx := range tree.txns {
+					for iv, vout := range tree.vouts[it] {
+						txHash, err := hashBytes(vout.TxHash)
+						if err != nil {
+							return err
+						}
+						for _, addr := range vout.ScriptPubKeyData.Addresses {
+							err = row(w.nextAddressID, addr, txDbIDs[i][it],
+								txHash, vout.TxIndex, tx.VoutDbIds[iv],
+								vout.Value)
+							if err != nil {
+								return err
+							}
+							w.nextAddressID++
+						}
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// writeBlock stores the tickets, votes and misses of a block whose
+// transactions were copied, with the spending info of the tickets if
+// requested, and then the block itself.
+func (w *syncWriter) writeBlock(dbtx *sql.Tx, sb *syncBlock) error {
+	pgb := w.db
+	stakeTree := &sb.trees[1]
+	stxDbIDs := sb.dbBlock.STxDbIDs
+
+	ticketDbIDs, ticketTxns, err := insertTickets(dbtx, stakeTree.txns, stxDbIDs, false)
+	if err != nil {
+		return fmt.Errorf("insertTickets: %v", err)
+	}
+
+	// The DB row IDs of tickets spent in the batch are found in the cache,
+	// as they are not visible outside of the database transaction.
+	var unspentTicketCache *TicketTxnIDGetter
+	var spendingTxDbIDs, spentTicketDbIDs []uint64
+	var spendTypes []dbtypes.TicketSpendType
+	var spentTicketHashes []string
+	if w.updateTicketsSpendingInfo {
+		for it, ticketDbID := range ticketDbIDs {
+			pgb.unspentTicketCache.Set(ticketTxns[it].TxID, ticketDbID)
+			w.newTickets = append(w.newTickets, ticketTxns[it].TxID)
+		}
+		unspentTicketCache = pgb.unspentTicketCache
+
+		spendingTxDbIDs, spendTypes, spentTicketHashes, spentTicketDbIDs, err =
+			pgb.CollectTicketSpendDBInfo(stakeTree.txns, stxDbIDs, sb.msgBlock)
+		if err != nil {
+			return fmt.Errorf("CollectTicketSpendDBInfo: %v", err)
+		}
+	}
+
+	msgBlockPG := &MsgBlockPG{
+		MsgBlock:       sb.msgBlock,
+		WinningTickets: sb.winners,
+		Validators:     sb.validators,
+	}
+	_, _, _, _, misses, err := insertVotes(dbtx, stakeTree.txns,
+		unspentTicketCache, msgBlockPG, false)
+	if err != nil {
+		return fmt.Errorf("insertVotes: %v", err)
+	}
+
+	if w.updateTicketsSpendingInfo {
+		updates := makeTicketPoolUpdates(sb.height, spendTypes,
+			spentTicketHashes, misses, sb.expiries)
+		err = setSpendingForTickets(dbtx, spentTicketDbIDs, spendingTxDbIDs,
+			updates.blockHeights, spendTypes, updates.poolStatuses)
+		if err != nil {
+			return fmt.Errorf("setSpendingForTickets: %v", err)
+		}
+		_, err = setPoolStatusForTicketsByHash(dbtx, updates.unspent,
+			updates.unspentStatuses)
+		if err != nil {
+			return fmt.Errorf("setPoolStatusForTicketsByHash: %v", err)
+		}
+	}
+
+	// Store the block now that it has all its transaction row IDs.
+	blockDbID, err := insertBlock(dbtx, sb.dbBlock, true, true, false)
+	if err != nil {
+		return fmt.Errorf("insertBlock: %v", err)
+	}
+	if err = insertBlockTxns(dbtx, blockDbID, sb.dbBlock); err != nil {
+		return fmt.Errorf("insertBlockTxns: %v", err)
+	}
+	err = insertBlockPrevNext(dbtx, blockDbID, sb.dbBlock.Hash,
+		sb.dbBlock.PreviousHash, "")
+	if err != nil {
+		return fmt.Errorf("insertBlockPrevNext: %v", err)
+	}
+
+	// Update the previous block's next block hash, and its validity if votes
+	// in this block invalidated it. Genesis is not in the block_chain table.
+	if prevHash := sb.msgBlock.Header.PrevBlock; prevHash != zeroHash {
+		prevDbID := w.pending.blockDbID
+		if prevHash != w.pending.hash {
+			prevDbID, err = RetrieveBlockChainDbID(pgb.db, prevHash.String())
+			if err != nil {
+				return fmt.Errorf("unable to locate block %s in block_chain table: %v",
+					prevHash, err)
+			}
+		}
+
+		if sb.dbBlock.VoteBits&1 == 0 {
+			log.Infof("Setting last block %s as INVALID", prevHash)
+			if err = updateLastBlock(dbtx, prevDbID, false); err != nil {
+				return fmt.Errorf("updateLastBlock: %v", err)
+			}
+		}
+		if err = updateBlockNext(dbtx, prevDbID, sb.dbBlock.Hash); err != nil {
+			return fmt.Errorf("updateBlockNext: %v", err)
+		}
+	}
+
+	w.pending = syncTip{sb.height, sb.hash, blockDbID}
+	return nil
+}
+
+// syncReporter logs the progress of a sync.
+type syncReporter struct {
+	start        time.Time
+	startHeight  int64
+	targetHeight int64
+
+	last       time.Time
+	lastHeight int64
+	lastTotals syncTotals
+}
+
+func newSyncReporter(height, targetHeight int64) *syncReporter {
+	now := time.Now()
+	return &syncReporter{
+		start:        now,
+		startHeight:  height,
+		targetHeight: targetHeight,
+		last:         now,
+		lastHeight:   height,
+	}
+}
+
+// report logs the height reached, the rates since the last report, and an
+// estimate of the time remaining at the average block rate of the sync.
+func (r *syncReporter) report(height int64, totals syncTotals) {
+	now := time.Now()
+	elapsed := now.Sub(r.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	// The node may have new blocks since the sync started.
+	if height > r.targetHeight {
+		r.targetHeight = height
+	}
+	log.Infof("Stored block %d of %d (%.1f%%): %d blk/s, %d tx/s, %d vin/s, %d vout/s",
+		height, r.targetHeight, 100*float64(height+1)/float64(r.targetHeight+1),
+		int64(float64(height-r.lastHeight)/elapsed),
+		int64(float64(totals.txns-r.lastTotals.txns)/elapsed),
+		int64(float64(totals.vins-r.lastTotals.vins)/elapsed),
+		int64(float64(totals.vouts-r.lastTotals.vouts)/elapsed))
+
+	if done := height - r.startHeight; done > 0 && height < r.targetHeight {
+		perBlock := now.Sub(r.start) / time.Duration(done)
+		remaining := perBlock * time.Duration(r.targetHeight-height)
+		log.Infof("About %v remaining.", remaining.Truncate(time.Second))
+	}
+
+	r.last, r.lastHeight, r.lastTotals = now, height, totals
+}
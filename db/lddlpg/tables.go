@@ -13,15 +13,24 @@ import (
 )
 
 var createTableStatements = map[string]string{
-	"blocks":       internal.CreateBlockTable,
-	"transactions": internal.CreateTransactionTable,
-	"vins":         internal.CreateVinTable,
-	"vouts":        internal.CreateVoutTable,
-	"block_chain":  internal.CreateBlockPrevNextTable,
-	"addresses":    internal.CreateAddressTable,
-	"tickets":      internal.CreateTicketsTable,
-	"votes":        internal.CreateVotesTable,
-	"misses":       internal.CreateMissesTable,
+	"blocks":             internal.CreateBlockTable,
+	"transactions":       internal.CreateTransactionTable,
+	"vins":               internal.CreateVinTable,
+	"vouts":              internal.CreateVoutTable,
+	"block_chain":        internal.CreateBlockPrevNextTable,
+	"block_transactions": internal.CreateBlockTxnsTable,
+	"addresses":          internal.CreateAddressTable,
+	"tickets":            internal.CreateTicketsTable,
+	"votes":              internal.CreateVotesTable,
+	"misses":             internal.CreateMissesTable,
+	"meta":               internal.CreateMetaTable,
+	"schema_versions":    internal.CreateSchemaVersionsTable,
+}
+
+// referencingTables are the tables with foreign keys to other tables, which
+// must be created after them.
+var referencingTables = map[string]bool{
+	"block_transactions": true,
 }
 
 var createTypeStatements = map[string]string{
@@ -32,22 +41,25 @@ var createTypeStatements = map[string]string{
 // The tables are versioned as follows. The major version is the same for all
 // the tables. A bump of this version is used to signal that all tables should
 // be dropped and rebuilt. The minor versions may be different, and they are
-// used to indicate a change requiring a table upgrade, which would be handled
-// by lddldata or rebuilddb2. The patch versions may also be different. They
-// indicate a change of a table's index or constraint, which may require
+// used to indicate a change requiring a table upgrade, which is applied by a
+// schema migration (see migrations). The patch versions may also be different.
+// They indicate a change of a table's index or constraint, which may require
 // re-indexing and a duplicate scan/purge.
 const tableMajor = 2
 
 var requiredVersions = map[string]TableVersion{
-	"blocks":       NewTableVersion(tableMajor, 0, 0),
-	"transactions": NewTableVersion(tableMajor, 0, 0),
-	"vins":         NewTableVersion(tableMajor, 0, 0),
-	"vouts":        NewTableVersion(tableMajor, 0, 0),
-	"block_chain":  NewTableVersion(tableMajor, 0, 0),
-	"addresses":    NewTableVersion(tableMajor, 0, 0),
-	"tickets":      NewTableVersion(tableMajor, 0, 0),
-	"votes":        NewTableVersion(tableMajor, 0, 0),
-	"misses":       NewTableVersion(tableMajor, 0, 0),
+	"blocks":             NewTableVersion(tableMajor, 3, 0),
+	"transactions":       NewTableVersion(tableMajor, 2, 0),
+	"vins":               NewTableVersion(tableMajor, 1, 0),
+	"vouts":              NewTableVersion(tableMajor, 1, 0),
+	"block_chain":        NewTableVersion(tableMajor, 1, 0),
+	"block_transactions": NewTableVersion(tableMajor, 0, 0),
+	"addresses":          NewTableVersion(tableMajor, 1, 0),
+	"tickets":            NewTableVersion(tableMajor, 1, 0),
+	"votes":              NewTableVersion(tableMajor, 1, 0),
+	"misses":             NewTableVersion(tableMajor, 1, 0),
+	"meta":               NewTableVersion(tableMajor, 1, 0),
+	"schema_versions":    NewTableVersion(tableMajor, 0, 0),
 }
 
 // TableVersion models a table version by major.minor.patch
@@ -119,8 +131,10 @@ func DropTables(db *sql.DB) {
 	}
 }
 
+// dropTable drops the table, and any foreign keys of other tables referencing
+// it.
 func dropTable(db *sql.DB, tableName string) error {
-	_, err := db.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %s;`, tableName))
+	_, err := db.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %s CASCADE;`, tableName))
 	return err
 }
 
@@ -165,36 +179,20 @@ func TypeExists(db *sql.DB, tableName string) (bool, error) {
 	return false, err
 }
 
+// CreateTables creates the known tables that do not exist, the tables they
+// reference first.
 func CreateTables(db *sql.DB) error {
-	var err error
-	for tableName, createCommand := range createTableStatements {
-		var exists bool
-		exists, err = TableExists(db, tableName)
-		if err != nil {
-			return err
-		}
-
-		tableVersion, ok := requiredVersions[tableName]
-		if !ok {
-			return fmt.Errorf("no version assigned to table %s", tableName)
-		}
-
-		if !exists {
-			log.Infof("Creating the \"%s\" table.", tableName)
-			_, err = db.Exec(createCommand)
-			if err != nil {
-				return err
+	for _, referencing := range []bool{false, true} {
+		for tableName := range createTableStatements {
+			if referencingTables[tableName] != referencing {
+				continue
 			}
-			_, err = db.Exec(fmt.Sprintf(`COMMENT ON TABLE %s IS 'v%s';`,
-				tableName, tableVersion))
-			if err != nil {
+			if err := CreateTable(db, tableName); err != nil {
 				return err
 			}
-		} else {
-			log.Tracef("Table \"%s\" exist.", tableName)
 		}
 	}
-	return err
+	return nil
 }
 
 // CreateTable creates one of the known tables by name
@@ -271,6 +269,15 @@ func TableUpgradesRequired(versions map[string]TableVersion) []TableUpgrade {
 func TableVersions(db *sql.DB) map[string]TableVersion {
 	versions := map[string]TableVersion{}
 	for tableName := range createTableStatements {
+		// A table that does not exist has no version.
+		exists, err := TableExists(db, tableName)
+		if err != nil {
+			log.Errorf("TableExists failed: %v", err)
+			continue
+		}
+		if !exists {
+			continue
+		}
 		Result := db.QueryRow(`select obj_description($1::regclass);`, tableName)
 		var s string
 		var v, m, p int
@@ -350,6 +357,18 @@ func DeindexTransactionTableOnBlockIn(db *sql.DB) (err error) {
 	return
 }
 
+// Block transactions table indexes
+
+func IndexBlockTxnsTableOnTxDbID(db *sql.DB) (err error) {
+	_, err = db.Exec(internal.IndexBlockTxnsTableOnTxDbID)
+	return
+}
+
+func DeindexBlockTxnsTableOnTxDbID(db *sql.DB) (err error) {
+	_, err = db.Exec(internal.DeindexBlockTxnsTableOnTxDbID)
+	return
+}
+
 // Blocks table indexes
 
 func IndexBlockTableOnHash(db *sql.DB) (err error) {
@@ -470,6 +489,16 @@ func DeindexTicketsTableOnTxDbID(db *sql.DB) (err error) {
 	return
 }
 
+func IndexTicketsTableOnStakeSubmissionAddress(db *sql.DB) (err error) {
+	_, err = db.Exec(internal.IndexTicketsTableOnStakeSubmissionAddress)
+	return
+}
+
+func DeindexTicketsTableOnStakeSubmissionAddress(db *sql.DB) (err error) {
+	_, err = db.Exec(internal.DeindexTicketsTableOnStakeSubmissionAddress)
+	return
+}
+
 // Missed votes table indexes
 
 func IndexMissesTableOnHashes(db *sql.DB) (err error) {
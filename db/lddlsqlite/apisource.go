@@ -8,6 +8,7 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"sort"
 	"strings"
 	"sync"
@@ -26,7 +27,9 @@ import (
 	"github.com/Legenddigital/lddldata/explorer"
 	"github.com/Legenddigital/lddldata/mempool"
 	"github.com/Legenddigital/lddldata/rpcutils"
+	"github.com/Legenddigital/lddldata/stakecalc"
 	"github.com/Legenddigital/lddldata/stakedb"
+	"github.com/Legenddigital/lddldata/stakediff"
 	"github.com/Legenddigital/lddldata/txhelpers"
 	humanize "github.com/dustin/go-humanize"
 )
@@ -40,6 +43,14 @@ type wiredDB struct {
 	params   *chaincfg.Params
 	sDB      *stakedb.StakeDatabase
 	waitChan chan chainhash.Hash
+	schedule *supplyScheduleCache
+}
+
+// supplyScheduleCache holds the supply schedule, which depends only on the
+// network parameters, once it is computed.
+type supplyScheduleCache struct {
+	once     sync.Once
+	schedule *apitypes.SupplySchedule
 }
 
 func newWiredDB(DB *DB, statusC chan uint32, cl *rpcclient.Client,
@@ -49,6 +60,7 @@ func newWiredDB(DB *DB, statusC chan uint32, cl *rpcclient.Client,
 		MPC:         new(mempool.MempoolDataCache),
 		client:      cl,
 		params:      p,
+		schedule:    new(supplyScheduleCache),
 	}
 
 	var err error
@@ -266,10 +278,29 @@ func (db *wiredDB) CoinSupply() (supply *apitypes.CoinSupply) {
 		Height:   height,
 		Hash:     hash.String(),
 		Mined:    int64(coinSupply),
-		Ultimate: txhelpers.UltimateSubsidy(db.params),
+		Ultimate: db.SupplySchedule().Ultimate,
 	}
 }
 
+// SupplySchedule returns the projected coin supply of each subsidy reduction
+// interval of the network. The schedule is computed on the first call, and the
+// same one is returned by later calls, so it must not be modified.
+func (db *wiredDB) SupplySchedule() *apitypes.SupplySchedule {
+	db.schedule.once.Do(func() {
+		intervals := txhelpers.SupplySchedule(db.params)
+		db.schedule.schedule = &apitypes.SupplySchedule{
+			BlockOne:     db.params.BlockOneSubsidy(),
+			IntervalSize: db.params.SubsidyReductionInterval,
+			Ultimate:     db.params.BlockOneSubsidy(),
+			Intervals:    intervals,
+		}
+		if len(intervals) > 0 {
+			db.schedule.schedule.Ultimate = intervals[len(intervals)-1].Supply
+		}
+	})
+	return db.schedule.schedule
+}
+
 func (db *wiredDB) BlockSubsidy(height int64, voters uint16) *lddljson.GetBlockSubsidyResult {
 	blockSubsidy, err := db.client.GetBlockSubsidy(height, voters)
 	if err != nil {
@@ -590,6 +621,37 @@ func (db *wiredDB) GetStakeDiffEstimates() *apitypes.StakeDiff {
 	return sd
 }
 
+// GetStakeDiffProjection projects the ticket price of the next windows from
+// the recent stake info and the tickets in mempool. See stakediff.Project.
+func (db *wiredDB) GetStakeDiffProjection(windows int, rates []float64) (*stakediff.Projection, error) {
+	height, err := db.GetStakeInfoHeight()
+	if err != nil {
+		return nil, err
+	}
+	start := height - stakediff.HistorySize(db.params) + 1
+	if start < 0 {
+		start = 0
+	}
+	blocks, err := db.RetrieveStakeDiffHistory(start, height)
+	if err != nil {
+		return nil, err
+	}
+
+	// The mempool tickets are only valid for the block after the best block.
+	var mempoolTickets int64
+	if mpHeight, numTickets := db.MPC.GetNumTickets(); int64(mpHeight) == height {
+		mempoolTickets = int64(numTickets)
+	}
+
+	return stakediff.Project(db.params, blocks, mempoolTickets, windows, rates)
+}
+
+// StakeCalculation simulates staking with the given inputs and the chain
+// parameters. See stakecalc.Calculate.
+func (db *wiredDB) StakeCalculation(in stakecalc.Inputs) (*stakecalc.Calculation, error) {
+	return stakecalc.Calculate(db.params, in)
+}
+
 func (db *wiredDB) GetFeeInfo(idx int) *lddljson.FeeInfoBlock {
 	stakeInfo, err := db.RetrieveStakeInfoExtended(int64(idx))
 	if err != nil {
@@ -600,6 +662,24 @@ func (db *wiredDB) GetFeeInfo(idx int) *lddljson.FeeInfoBlock {
 	return &stakeInfo.Feeinfo
 }
 
+func (db *wiredDB) GetBlockFeeStats(idx int) *apitypes.BlockFeeStats {
+	feeStats, err := db.RetrieveBlockFeeStats(int64(idx))
+	if err != nil {
+		log.Errorf("Unable to retrieve block fees: %v", err)
+		return nil
+	}
+	return feeStats
+}
+
+func (db *wiredDB) GetBlockFeeStatsRange(idx0, idx1, step int) ([]*apitypes.BlockFeeStats, error) {
+	feeStats, err := db.RetrieveBlockFeeStatsRange(int64(idx0), int64(idx1), int64(step))
+	if err != nil {
+		log.Errorf("Unable to retrieve block fees range: %v", err)
+		return nil, err
+	}
+	return feeStats, nil
+}
+
 func (db *wiredDB) GetStakeInfoExtended(idx int) *apitypes.StakeInfoExtended {
 	stakeInfo, err := db.RetrieveStakeInfoExtended(int64(idx))
 	if err != nil {
@@ -698,6 +778,56 @@ func (db *wiredDB) GetPoolByHash(hash string) ([]string, error) {
 	return hss, nil
 }
 
+// GetTicketExpiries forecasts the expiry of the tickets in the live pool, in
+// bins of binSize blocks, or about one day of blocks if binSize is not
+// positive. The expected number of expiries assumes a constant pool size, so
+// that a live ticket is called to vote in each block with probability
+// TicketsPerBlock / pool size.
+func (db *wiredDB) GetTicketExpiries(binSize int64) *apitypes.TicketExpiries {
+	if binSize <= 0 {
+		binSize = int64(24 * time.Hour / db.params.TargetTimePerBlock)
+	}
+	height, expiries := db.sDB.LiveTicketExpiries()
+	var poolSize int
+	for _, n := range expiries {
+		poolSize += n
+	}
+
+	te := &apitypes.TicketExpiries{
+		BlockHeight: height,
+		PoolSize:    poolSize,
+		BinSize:     binSize,
+		Bins:        []apitypes.TicketExpiriesBin{},
+	}
+	if poolSize == 0 {
+		return te
+	}
+	missProb := 1 - float64(db.params.TicketsPerBlock)/float64(poolSize)
+	if missProb < 0 {
+		missProb = 0
+	}
+	for i, n := range expiries {
+		bin := int64(i) / binSize
+		if bin == int64(len(te.Bins)) {
+			te.Bins = append(te.Bins, apitypes.TicketExpiriesBin{
+				StartHeight: height + 1 + bin*binSize,
+				EndHeight:   height + (bin+1)*binSize,
+			})
+		}
+		// A ticket expiring i+1 blocks from now must not be called in any of
+		// those blocks.
+		expected := float64(n) * math.Pow(missProb, float64(i+1))
+		te.Bins[bin].NumTickets += n
+		te.Bins[bin].Expected += expected
+		te.Expected += expected
+	}
+	last := &te.Bins[len(te.Bins)-1]
+	if maxHeight := height + int64(len(expiries)); last.EndHeight > maxHeight {
+		last.EndHeight = maxHeight
+	}
+	return te
+}
+
 // GetBlockSummaryTimeRange returns the blocks created within a specified time
 // range min, max time
 func (db *wiredDB) GetBlockSummaryTimeRange(min, max int64, limit int) []apitypes.BlockDataBasic {
@@ -1421,11 +1551,14 @@ func (db *wiredDB) GetMempool() []explorer.MempoolTx {
 				}
 			}
 		}
+		fees, feeRate := txhelpers.TxFeeRate(msgTx)
 		txs = append(txs, explorer.MempoolTx{
 			Hash:     hash,
 			Time:     tx.Time,
 			Size:     tx.Size,
 			TotalOut: total,
+			Fees:     fees.ToCoin(),
+			FeeRate:  feeRate.ToCoin(),
 			Type:     txhelpers.DetermineTxTypeString(msgTx),
 			VoteInfo: voteInfo,
 		})
@@ -193,6 +193,11 @@ func (p *ChainMonitor) switchToSideChain() (int32, *chainhash.Hash, error) {
 		if err := p.db.StoreStakeInfoExtended(stakeInfoSummaryExtended); err != nil {
 			log.Errorf("Failed to store stake info data: %v", err)
 		}
+		if msgBlock, err := p.db.client.GetBlock(&p.sideChain[i]); err != nil {
+			log.Errorf("Failed to get block %v: %v", p.sideChain[i], err)
+		} else if err = p.db.StoreBlockFeeStats(BlockFeeStats(msgBlock)); err != nil {
+			log.Errorf("Failed to store block fees data: %v", err)
+		}
 		log.Infof("Stored block %v (height %d) from side chain.",
 			blockDataSummary.Hash, blockDataSummary.Height)
 	}
@@ -12,9 +12,12 @@ import (
 
 	"github.com/Legenddigital/slog"
 
+	"github.com/Legenddigital/lddld/lddlutil"
 	"github.com/Legenddigital/lddld/wire"
 	apitypes "github.com/Legenddigital/lddldata/api/types"
 	"github.com/Legenddigital/lddldata/blockdata"
+	"github.com/Legenddigital/lddldata/stakediff"
+	"github.com/Legenddigital/lddldata/txhelpers"
 	_ "github.com/mattn/go-sqlite3" // register sqlite driver with database/sql
 )
 
@@ -40,8 +43,19 @@ const (
 	TableNameSummaries = "lddldata_block_summary"
 	// TableNameStakeInfo is name of the table used to store extended stake info
 	TableNameStakeInfo = "lddldata_stakeinfo_extended"
+	// TableNameBlockFees is name of the table used to store block fee rate
+	// statistics
+	TableNameBlockFees = "lddldata_block_fees"
+	// TableNameSchemaVersion is name of the table used to store the version of
+	// the database schema
+	TableNameSchemaVersion = "lddldata_schema_version"
 )
 
+// summaryColumns are the columns of the block summary table, in the order
+// scanned by scanBlockSummary.
+const summaryColumns = `height, size, hash, diff, sdiff, time, poolsize,
+	poolval, poolavg, winners, numtx`
+
 // DB is a wrapper around sql.DB that adds methods for storing and retrieving
 // chain data. Use InitDB to get a new instance. This may be unexported in the
 // future.
@@ -50,6 +64,7 @@ type DB struct {
 	sync.RWMutex
 	dbSummaryHeight                                              int64
 	dbStakeInfoHeight                                            int64
+	dbBlockFeesHeight                                            int64
 	getPoolSQL, getPoolRangeSQL, getPoolValSizeRangeSQL          string
 	getPoolByHashSQL                                             string
 	getWinnersByHashSQL, getWinnersSQL                           string
@@ -63,6 +78,11 @@ type DB struct {
 	getLatestStakeInfoExtendedSQL                                string
 	getStakeInfoExtendedSQL, insertStakeInfoExtendedSQL          string
 	getStakeInfoWinnersSQL                                       string
+	getStakeDiffHistorySQL                                       string
+	getBlockFeesSQL, getBlockFeesRangeSQL, insertBlockFeesSQL    string
+	getBlockFeesHeightSQL                                        string
+	getSummariesNoNumTxSQL, setSummaryNumTxSQL                   string
+	getSummariesNoFeesSQL                                        string
 }
 
 // NewDB creates a new DB instance with pre-generated sql statements from an
@@ -73,6 +93,7 @@ func NewDB(db *sql.DB) (*DB, error) {
 		DB:                db,
 		dbSummaryHeight:   -1,
 		dbStakeInfoHeight: -1,
+		dbBlockFeesHeight: -1,
 	}
 
 	// Ticket pool queries
@@ -95,23 +116,37 @@ func NewDB(db *sql.DB) (*DB, error) {
 		TableNameSummaries)
 
 	// Block queries
-	d.getBlockSQL = fmt.Sprintf(`select * from %s where height = ?`, TableNameSummaries)
-	d.getBlockByHashSQL = fmt.Sprintf(`select * from %s where hash = ?`, TableNameSummaries)
-	d.getLatestBlockSQL = fmt.Sprintf(`SELECT * FROM %s ORDER BY height DESC LIMIT 0, 1`,
-		TableNameSummaries)
+	d.getBlockSQL = fmt.Sprintf(`select %s from %s where height = ?`,
+		summaryColumns, TableNameSummaries)
+	d.getBlockByHashSQL = fmt.Sprintf(`select %s from %s where hash = ?`,
+		summaryColumns, TableNameSummaries)
+	d.getLatestBlockSQL = fmt.Sprintf(`SELECT %s FROM %s ORDER BY height DESC LIMIT 0, 1`,
+		summaryColumns, TableNameSummaries)
 	d.insertBlockSQL = fmt.Sprintf(`
-        INSERT OR REPLACE INTO %s(
-            height, size, hash, diff, sdiff, time, poolsize, poolval, poolavg, winners
-        ) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`, TableNameSummaries)
+        INSERT OR REPLACE INTO %s(%s)
+		values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, TableNameSummaries, summaryColumns)
 
 	d.getBlockSizeRangeSQL = fmt.Sprintf(`select size from %s where height between ? and ?`,
 		TableNameSummaries)
-	d.getBlockByTimeRangeSQL = fmt.Sprintf(`select * from %s where time between ? and ? ORDER BY time LIMIT ?`,
-		TableNameSummaries)
-	d.getBlockByTimeSQL = fmt.Sprintf(`select * from %s where time = ?`,
+	d.getBlockByTimeRangeSQL = fmt.Sprintf(`select %s from %s where time between ? and ? ORDER BY time LIMIT ?`,
+		summaryColumns, TableNameSummaries)
+	d.getBlockByTimeSQL = fmt.Sprintf(`select %s from %s where time = ?`,
+		summaryColumns, TableNameSummaries)
+
+	// Block summaries stored before the numtx column was added
+	d.getSummariesNoNumTxSQL = fmt.Sprintf(`select height, hash from %s
+		where numtx is null ORDER BY height LIMIT ?`, TableNameSummaries)
+	d.setSummaryNumTxSQL = fmt.Sprintf(`update %s set numtx = ? where height = ? and hash = ?`,
 		TableNameSummaries)
 
+	// Block summaries without fee statistics for the same block, as those
+	// stored before the block fees table was added
+	d.getSummariesNoFeesSQL = fmt.Sprintf(`select s.height, s.hash from %s s
+		left join %s f on f.height = s.height and f.hash = s.hash
+		where f.height is null ORDER BY s.height LIMIT ?`,
+		TableNameSummaries, TableNameBlockFees)
+
 	d.getBestBlockHashSQL = fmt.Sprintf(`select hash from %s ORDER BY height DESC LIMIT 0, 1`, TableNameSummaries)
 	d.getBestBlockHeightSQL = fmt.Sprintf(`select height from %s ORDER BY height DESC LIMIT 0, 1`, TableNameSummaries)
 
@@ -125,6 +160,8 @@ func NewDB(db *sql.DB) (*DB, error) {
 		TableNameStakeInfo)
 	d.getLatestStakeInfoExtendedSQL = fmt.Sprintf(
 		`SELECT * FROM %s ORDER BY height DESC LIMIT 0, 1`, TableNameStakeInfo)
+	d.getStakeDiffHistorySQL = fmt.Sprintf(`select height, num_tickets, pool_size, sdiff
+		from %s where height between ? and ? ORDER BY height`, TableNameStakeInfo)
 	d.insertStakeInfoExtendedSQL = fmt.Sprintf(`
         INSERT OR REPLACE INTO %s(
             height, num_tickets, fee_min, fee_max, fee_mean, fee_med, fee_std,
@@ -132,6 +169,21 @@ func NewDB(db *sql.DB) (*DB, error) {
         ) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
         `, TableNameStakeInfo)
 
+	// Block fee queries
+	blockFeesColumns := `height, hash, reg_num, reg_fees, reg_rate_min,
+			reg_rate_max, reg_rate_mean, reg_rate_med, stake_num, stake_fees,
+			stake_rate_min, stake_rate_max, stake_rate_mean, stake_rate_med`
+	d.getBlockFeesSQL = fmt.Sprintf(`select %s from %s where height = ?`,
+		blockFeesColumns, TableNameBlockFees)
+	d.getBlockFeesRangeSQL = fmt.Sprintf(`select %s from %s where height between ? and ?
+		and (height - ?) %% ? = 0 ORDER BY height`, blockFeesColumns, TableNameBlockFees)
+	d.getBlockFeesHeightSQL = fmt.Sprintf(`select height from %s ORDER BY height DESC LIMIT 0, 1`,
+		TableNameBlockFees)
+	d.insertBlockFeesSQL = fmt.Sprintf(`
+        INSERT OR REPLACE INTO %s(%s)
+		values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        `, TableNameBlockFees, blockFeesColumns)
+
 	var err error
 	if d.dbSummaryHeight, err = d.GetBlockSummaryHeight(); err != nil {
 		return nil, err
@@ -139,6 +191,9 @@ func NewDB(db *sql.DB) (*DB, error) {
 	if d.dbStakeInfoHeight, err = d.GetStakeInfoHeight(); err != nil {
 		return nil, err
 	}
+	if d.dbBlockFeesHeight, err = d.GetBlockFeesHeight(); err != nil {
+		return nil, err
+	}
 
 	return &d, nil
 }
@@ -194,6 +249,12 @@ func InitDB(dbInfo *DBInfo) (*DB, error) {
 		return nil, err
 	}
 
+	// The tables are created with the first version of the schema, and then
+	// upgraded to the current version.
+	if err = upgradeSchema(db); err != nil {
+		return nil, err
+	}
+
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
@@ -207,8 +268,11 @@ type DBDataSaver struct {
 }
 
 // Store satisfies the blockdata.BlockDataSaver interface
-func (db *DBDataSaver) Store(data *blockdata.BlockData, _ *wire.MsgBlock) error {
+func (db *DBDataSaver) Store(data *blockdata.BlockData, msgBlock *wire.MsgBlock) error {
 	summary := data.ToBlockSummary()
+	if msgBlock != nil {
+		summary.NumTx = uint32(len(msgBlock.Transactions) + len(msgBlock.STransactions))
+	}
 	err := db.DB.StoreBlockSummary(&summary)
 	if err != nil {
 		return err
@@ -219,12 +283,19 @@ func (db *DBDataSaver) Store(data *blockdata.BlockData, _ *wire.MsgBlock) error
 	default:
 	}
 
+	if msgBlock != nil {
+		if err = db.DB.StoreBlockFeeStats(BlockFeeStats(msgBlock)); err != nil {
+			return err
+		}
+	}
+
 	stakeInfoExtended := data.ToStakeInfoExtended()
 	return db.DB.StoreStakeInfoExtended(&stakeInfoExtended)
 }
 
 // StoreBlockSummary attempts to stores the block data in the database and
-// returns an error on failure
+// returns an error on failure. A zero NumTx is stored as unknown, to be filled
+// in by the numtx backfill, since every block has a coinbase transaction.
 func (db *DB) StoreBlockSummary(bd *apitypes.BlockDataBasic) error {
 	stmt, err := db.Prepare(db.insertBlockSQL)
 	if err != nil {
@@ -233,11 +304,12 @@ func (db *DB) StoreBlockSummary(bd *apitypes.BlockDataBasic) error {
 	defer stmt.Close()
 
 	winners := strings.Join(bd.PoolInfo.Winners, ";")
+	numTx := sql.NullInt64{Int64: int64(bd.NumTx), Valid: bd.NumTx > 0}
 
 	res, err := stmt.Exec(&bd.Height, &bd.Size, &bd.Hash,
 		&bd.Difficulty, &bd.StakeDiff, &bd.Time,
 		&bd.PoolInfo.Size, &bd.PoolInfo.Value, &bd.PoolInfo.ValAvg,
-		&winners)
+		&winners, numTx)
 	if err != nil {
 		return err
 	}
@@ -516,6 +588,26 @@ func (db *DB) RetrieveSDiffRange(ind0, ind1 int64) ([]float64, error) {
 	return sdiffs, nil
 }
 
+// scanBlockSummary scans a row of the summaryColumns of the block summary
+// table.
+func scanBlockSummary(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*apitypes.BlockDataBasic, error) {
+	bd := new(apitypes.BlockDataBasic)
+	var winners string
+	var numTx sql.NullInt64
+	err := scanner.Scan(&bd.Height, &bd.Size, &bd.Hash,
+		&bd.Difficulty, &bd.StakeDiff, &bd.Time,
+		&bd.PoolInfo.Size, &bd.PoolInfo.Value, &bd.PoolInfo.ValAvg,
+		&winners, &numTx)
+	if err != nil {
+		return nil, err
+	}
+	bd.PoolInfo.Winners = strings.Split(winners, ";")
+	bd.NumTx = uint32(numTx.Int64)
+	return bd, nil
+}
+
 func (db *DB) RetrieveBlockSummaryByTimeRange(minTime, maxTime int64, limit int) ([]apitypes.BlockDataBasic, error) {
 	blocks := make([]apitypes.BlockDataBasic, 0, limit)
 
@@ -534,13 +626,12 @@ func (db *DB) RetrieveBlockSummaryByTimeRange(minTime, maxTime int64, limit int)
 	defer rows.Close()
 
 	for rows.Next() {
-		var bd apitypes.BlockDataBasic
-		if err = rows.Scan(&bd.Height, &bd.Size, &bd.Hash,
-			&bd.Difficulty, &bd.StakeDiff, &bd.Time,
-			&bd.PoolInfo.Size, &bd.PoolInfo.Value, &bd.PoolInfo.ValAvg); err != nil {
+		bd, err := scanBlockSummary(rows)
+		if err != nil {
 			log.Errorf("Unable to scan for block fields")
+			continue
 		}
-		blocks = append(blocks, bd)
+		blocks = append(blocks, *bd)
 	}
 	if err = rows.Err(); err != nil {
 		log.Error(err)
@@ -569,19 +660,7 @@ func stringSliceToBoolSlice(ss []string) ([]bool, error) {
 
 // RetrieveLatestBlockSummary returns the block summary for the best block
 func (db *DB) RetrieveLatestBlockSummary() (*apitypes.BlockDataBasic, error) {
-	bd := new(apitypes.BlockDataBasic)
-
-	var winners string
-	err := db.QueryRow(db.getLatestBlockSQL).Scan(&bd.Height, &bd.Size,
-		&bd.Hash, &bd.Difficulty, &bd.StakeDiff, &bd.Time,
-		&bd.PoolInfo.Size, &bd.PoolInfo.Value, &bd.PoolInfo.ValAvg,
-		&winners)
-	if err != nil {
-		return nil, err
-	}
-	bd.PoolInfo.Winners = strings.Split(winners, ";")
-
-	return bd, nil
+	return scanBlockSummary(db.QueryRow(db.getLatestBlockSQL))
 }
 
 // RetrieveBlockHash returns the block hash for block ind
@@ -614,37 +693,18 @@ func (db *DB) RetrieveBestBlockHeight() (int64, error) {
 
 // RetrieveBlockSummaryByHash returns basic block data for a block given its hash
 func (db *DB) RetrieveBlockSummaryByHash(hash string) (*apitypes.BlockDataBasic, error) {
-	bd := new(apitypes.BlockDataBasic)
-
-	var winners string
-	err := db.QueryRow(db.getBlockByHashSQL, hash).Scan(&bd.Height, &bd.Size, &bd.Hash,
-		&bd.Difficulty, &bd.StakeDiff, &bd.Time,
-		&bd.PoolInfo.Size, &bd.PoolInfo.Value, &bd.PoolInfo.ValAvg,
-		&winners)
-	if err != nil {
-		return nil, err
-	}
-	bd.PoolInfo.Winners = strings.Split(winners, ";")
-
-	return bd, nil
+	return scanBlockSummary(db.QueryRow(db.getBlockByHashSQL, hash))
 }
 
 // RetrieveBlockSummary returns basic block data for block ind
 func (db *DB) RetrieveBlockSummary(ind int64) (*apitypes.BlockDataBasic, error) {
-	bd := new(apitypes.BlockDataBasic)
-
 	// Three different ways
 
 	// 1. chained QueryRow/Scan only
-	var winners string
-	err := db.QueryRow(db.getBlockSQL, ind).Scan(&bd.Height, &bd.Size, &bd.Hash,
-		&bd.Difficulty, &bd.StakeDiff, &bd.Time,
-		&bd.PoolInfo.Size, &bd.PoolInfo.Value, &bd.PoolInfo.ValAvg,
-		&winners)
+	bd, err := scanBlockSummary(db.QueryRow(db.getBlockSQL, ind))
 	if err != nil {
 		return nil, err
 	}
-	bd.PoolInfo.Winners = strings.Split(winners, ";")
 
 	// 2. Prepare + chained QueryRow/Scan
 	// stmt, err := db.Prepare(getBlockSQL)
@@ -801,6 +861,185 @@ func (db *DB) RetrieveStakeInfoExtended(ind int64) (*apitypes.StakeInfoExtended,
 	return si, nil
 }
 
+// RetrieveStakeDiffHistory returns the fresh stake, pool size and ticket
+// price of the blocks in the range [ind0, ind1] from the extended stake info,
+// for a ticket price projection.
+func (db *DB) RetrieveStakeDiffHistory(ind0, ind1 int64) ([]stakediff.Block, error) {
+	if ind1 < ind0 {
+		return nil, fmt.Errorf("Cannot retrieve stake diff history (%d<%d)",
+			ind1, ind0)
+	}
+	db.RLock()
+	if ind1 > db.dbStakeInfoHeight || ind0 < 0 {
+		defer db.RUnlock()
+		return nil, fmt.Errorf("Cannot retrieve stake diff history [%d,%d], have height %d",
+			ind0, ind1, db.dbStakeInfoHeight)
+	}
+	db.RUnlock()
+
+	stmt, err := db.Prepare(db.getStakeDiffHistorySQL)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(ind0, ind1)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := make([]stakediff.Block, 0, ind1-ind0+1)
+	for rows.Next() {
+		var b stakediff.Block
+		var sdiff float64
+		if err = rows.Scan(&b.Height, &b.FreshStake, &b.PoolSize, &sdiff); err != nil {
+			log.Errorf("Unable to scan for stake diff history fields: %v", err)
+			return nil, err
+		}
+		var amt lddlutil.Amount
+		if amt, err = lddlutil.NewAmount(sdiff); err != nil {
+			return nil, err
+		}
+		b.StakeDiff = int64(amt)
+		blocks = append(blocks, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// BlockFeeStats computes the fee rate statistics of the regular and stake
+// transaction trees of a block.
+func BlockFeeStats(msgBlock *wire.MsgBlock) *apitypes.BlockFeeStats {
+	regular, stake := txhelpers.BlockFeeRateStats(msgBlock)
+	return &apitypes.BlockFeeStats{
+		Height:  msgBlock.Header.Height,
+		Hash:    msgBlock.BlockHash().String(),
+		Regular: regular,
+		Stake:   stake,
+	}
+}
+
+// StoreBlockFeeStats stores the block fee rate statistics in the database
+func (db *DB) StoreBlockFeeStats(fs *apitypes.BlockFeeStats) error {
+	stmt, err := db.Prepare(db.insertBlockFeesSQL)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(&fs.Height, &fs.Hash,
+		&fs.Regular.Number, &fs.Regular.Fees, &fs.Regular.Min,
+		&fs.Regular.Max, &fs.Regular.Mean, &fs.Regular.Median,
+		&fs.Stake.Number, &fs.Stake.Fees, &fs.Stake.Min,
+		&fs.Stake.Max, &fs.Stake.Mean, &fs.Stake.Median)
+	if err != nil {
+		return err
+	}
+
+	db.Lock()
+	defer db.Unlock()
+	if err = logDBResult(res); err == nil {
+		height := int64(fs.Height)
+		if height > db.dbBlockFeesHeight {
+			db.dbBlockFeesHeight = height
+		}
+	}
+	return err
+}
+
+// GetBlockFeesHeight returns the largest block height for which the database
+// can provide block fee rate statistics
+func (db *DB) GetBlockFeesHeight() (int64, error) {
+	db.RLock()
+	defer db.RUnlock()
+	if db.dbBlockFeesHeight < 0 {
+		var height int64
+		err := db.QueryRow(db.getBlockFeesHeightSQL).Scan(&height)
+		// No rows returned is not considered an error
+		if err != nil && err != sql.ErrNoRows {
+			return -1, fmt.Errorf("RetrieveBlockFeesHeight failed: %v", err)
+		}
+		if err == sql.ErrNoRows {
+			log.Warn("Block fees DB is empty.")
+			return -1, nil
+		}
+		db.dbBlockFeesHeight = height
+	}
+	return db.dbBlockFeesHeight, nil
+}
+
+func scanBlockFeeStats(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*apitypes.BlockFeeStats, error) {
+	fs := new(apitypes.BlockFeeStats)
+	err := scanner.Scan(&fs.Height, &fs.Hash,
+		&fs.Regular.Number, &fs.Regular.Fees, &fs.Regular.Min,
+		&fs.Regular.Max, &fs.Regular.Mean, &fs.Regular.Median,
+		&fs.Stake.Number, &fs.Stake.Fees, &fs.Stake.Min,
+		&fs.Stake.Max, &fs.Stake.Mean, &fs.Stake.Median)
+	if err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// RetrieveBlockFeeStats returns the block fee rate statistics for block ind
+func (db *DB) RetrieveBlockFeeStats(ind int64) (*apitypes.BlockFeeStats, error) {
+	return scanBlockFeeStats(db.QueryRow(db.getBlockFeesSQL, ind))
+}
+
+// RetrieveBlockFeeStatsRange returns the block fee rate statistics for every
+// step-th block in the range ind0 to ind1
+func (db *DB) RetrieveBlockFeeStatsRange(ind0, ind1, step int64) ([]*apitypes.BlockFeeStats, error) {
+	if step < 1 {
+		return nil, fmt.Errorf("Cannot retrieve block fees range with step %d", step)
+	}
+	N := (ind1-ind0)/step + 1
+	if N < 1 {
+		return nil, fmt.Errorf("Cannot retrieve block fees range (%d<%d)",
+			ind1, ind0)
+	}
+	db.RLock()
+	if ind1 > db.dbBlockFeesHeight || ind0 < 0 {
+		defer db.RUnlock()
+		return nil, fmt.Errorf("Cannot retrieve block fees range [%d,%d], have height %d",
+			ind0, ind1, db.dbBlockFeesHeight)
+	}
+	db.RUnlock()
+
+	stmt, err := db.Prepare(db.getBlockFeesRangeSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(ind0, ind1, ind0, step)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeStats := make([]*apitypes.BlockFeeStats, 0, N)
+	for rows.Next() {
+		fs, err := scanBlockFeeStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeStats = append(feeStats, fs)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return feeStats, nil
+}
+
 // RetrieveWinners returns the winners for block ind
 // func (db *DB) RetrieveWinners(ind int64) ([]string, error) {
 // 	var winners string
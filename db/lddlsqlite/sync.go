@@ -235,6 +235,7 @@ func (db *wiredDB) resyncDB(quit chan struct{}, blockGetter rpcutils.BlockGetter
 			StakeDiff:  lddlutil.Amount(header.SBits).ToCoin(),
 			Time:       header.Timestamp.Unix(),
 			PoolInfo:   *tpi,
+			NumTx:      uint32(len(block.Transactions()) + len(block.STransactions())),
 		}
 
 		if i > summaryHeight {
@@ -243,6 +244,12 @@ func (db *wiredDB) resyncDB(quit chan struct{}, blockGetter rpcutils.BlockGetter
 			}
 		}
 
+		if i > blockFeesHeight {
+			if err = db.StoreBlockFeeStats(BlockFeeStats(block.MsgBlock())); err != nil {
+				return i - 1, fmt.Errorf("Unable to store block fees in database: %v", err)
+			}
+		}
+
 		if i <= stakeInfoHeight {
 			// update height, the end condition for the loop
 			if _, height, err = db.client.GetBestBlock(); err != nil {
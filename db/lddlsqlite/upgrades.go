@@ -0,0 +1,274 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package lddlsqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/Legenddigital/lddld/chaincfg/chainhash"
+)
+
+// schemaUpgrade changes the schema of the database from the previous version
+// to version. Columns added by an upgrade are NULL for the rows stored before
+// it, and tables added by an upgrade lack the rows of the blocks stored before
+// it. These are filled in by the background backfill (see BackfillHandler), so
+// the database remains usable while the backfill is in progress.
+type schemaUpgrade struct {
+	version     int
+	description string
+	stmts       []string
+}
+
+// schemaUpgrades lists the schema upgrades, in order of version. The tables
+// created by InitDB are version 1, and a database created before the schema
+// version was recorded is also version 1. Versions must not be changed or
+// reused once released.
+var schemaUpgrades = []schemaUpgrade{
+	{
+		version:     2,
+		description: "add the numtx column to the block summary table",
+		stmts: []string{
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN numtx INTEGER`,
+				TableNameSummaries),
+		},
+	},
+	{
+		version:     3,
+		description: "create the block fees table",
+		stmts: []string{
+			fmt.Sprintf(`create table if not exists %s(
+				height INTEGER PRIMARY KEY,
+				hash TEXT,
+				reg_num INTEGER, reg_fees FLOAT,
+				reg_rate_min FLOAT, reg_rate_max FLOAT,
+				reg_rate_mean FLOAT, reg_rate_med FLOAT,
+				stake_num INTEGER, stake_fees FLOAT,
+				stake_rate_min FLOAT, stake_rate_max FLOAT,
+				stake_rate_mean FLOAT, stake_rate_med FLOAT
+			)`, TableNameBlockFees),
+		},
+	},
+}
+
+// schemaVersion is the version of the schema with all upgrades applied.
+func schemaVersion() int {
+	return schemaUpgrades[len(schemaUpgrades)-1].version
+}
+
+// upgradeSchema creates the schema version table if needed, and applies the
+// upgrades after the recorded version. Each upgrade is applied in a database
+// transaction with the new version.
+func upgradeSchema(db *sql.DB) error {
+	createSchemaVersionStmt := fmt.Sprintf(`
+        create table if not exists %s(
+            id INTEGER PRIMARY KEY CHECK (id = 1),
+            version INTEGER NOT NULL
+        );
+        insert or ignore into %s(id, version) values(1, 1);
+        `, TableNameSchemaVersion, TableNameSchemaVersion)
+
+	_, err := db.Exec(createSchemaVersionStmt)
+	if err != nil {
+		log.Errorf("%q: %s\n", err, createSchemaVersionStmt)
+		return err
+	}
+
+	var version int
+	err = db.QueryRow(fmt.Sprintf(`select version from %s where id = 1`,
+		TableNameSchemaVersion)).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the schema version: %v", err)
+	}
+
+	if version > schemaVersion() {
+		return fmt.Errorf("database schema version %d is newer than the "+
+			"supported version %d", version, schemaVersion())
+	}
+
+	setVersionSQL := fmt.Sprintf(`update %s set version = ? where id = 1`,
+		TableNameSchemaVersion)
+	for _, u := range schemaUpgrades {
+		if u.version <= version {
+			continue
+		}
+		log.Infof("Upgrading the database schema to version %d: %s.",
+			u.version, u.description)
+
+		dbtx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("unable to begin database transaction: %v", err)
+		}
+		for _, stmt := range u.stmts {
+			if _, err = dbtx.Exec(stmt); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			_, err = dbtx.Exec(setVersionSQL, u.version)
+		}
+		if err != nil {
+			if errRoll := dbtx.Rollback(); errRoll != nil {
+				log.Errorf("Rollback failed: %v", errRoll)
+			}
+			return fmt.Errorf("schema upgrade to version %d failed: %v",
+				u.version, err)
+		}
+		if err = dbtx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backfillBatchSize is the number of rows read per query of the backfill.
+const backfillBatchSize = 500
+
+// BackfillHandler fills in the columns and tables added by schema upgrades for
+// the blocks stored before the upgrade, fetching the blocks from the node. Since only the
+// rows still missing data are selected, an interrupted backfill resumes on the
+// next start. It should be run as a goroutine.
+func (db *wiredDB) BackfillHandler(wg *sync.WaitGroup, quit chan struct{}) {
+	defer wg.Done()
+
+	n, err := db.backfillSummaryNumTx(quit)
+	if err != nil {
+		log.Errorf("Block summary numtx backfill failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Infof("Block summary numtx backfill stored %d blocks.", n)
+	}
+
+	n, err = db.backfillBlockFees(quit)
+	if err != nil {
+		log.Errorf("Block fees backfill failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Infof("Block fees backfill stored %d blocks.", n)
+	}
+}
+
+// backfillSummaryNumTx sets the numtx column of the block summaries where it
+// is NULL. The number of updated rows is returned.
+func (db *wiredDB) backfillSummaryNumTx(quit chan struct{}) (int64, error) {
+	var count int64
+	for {
+		heights, hashes, err := db.retrieveSummariesNoNumTx(backfillBatchSize)
+		if err != nil {
+			return count, err
+		}
+		if len(heights) == 0 {
+			return count, nil
+		}
+		if count == 0 {
+			log.Infof("Backfilling numtx of block summaries from height %d...",
+				heights[0])
+		}
+
+		for i := range heights {
+			select {
+			case <-quit:
+				log.Infof("Block summary numtx backfill cancelled at height %d.",
+					heights[i])
+				return count, nil
+			default:
+			}
+
+			blockhash, err := chainhash.NewHashFromStr(hashes[i])
+			if err != nil {
+				return count, fmt.Errorf("invalid block hash %s: %v", hashes[i], err)
+			}
+			msgBlock, err := db.client.GetBlock(blockhash)
+			if err != nil {
+				return count, fmt.Errorf("GetBlock failed (%s): %v", blockhash, err)
+			}
+
+			numTx := len(msgBlock.Transactions) + len(msgBlock.STransactions)
+			_, err = db.Exec(db.setSummaryNumTxSQL, numTx, heights[i], hashes[i])
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+		log.Debugf("Backfilled numtx of block summaries up to height %d.",
+			heights[len(heights)-1])
+	}
+}
+
+// backfillBlockFees stores the fee statistics of the blocks with a summary but
+// no fee statistics. The number of stored blocks is returned.
+func (db *wiredDB) backfillBlockFees(quit chan struct{}) (int64, error) {
+	var count int64
+	for {
+		heights, hashes, err := db.retrieveSummaries(db.getSummariesNoFeesSQL,
+			backfillBatchSize)
+		if err != nil {
+			return count, err
+		}
+		if len(heights) == 0 {
+			return count, nil
+		}
+		if count == 0 {
+			log.Infof("Backfilling block fees from height %d...", heights[0])
+		}
+
+		for i := range heights {
+			select {
+			case <-quit:
+				log.Infof("Block fees backfill cancelled at height %d.", heights[i])
+				return count, nil
+			default:
+			}
+
+			blockhash, err := chainhash.NewHashFromStr(hashes[i])
+			if err != nil {
+				return count, fmt.Errorf("invalid block hash %s: %v", hashes[i], err)
+			}
+			msgBlock, err := db.client.GetBlock(blockhash)
+			if err != nil {
+				return count, fmt.Errorf("GetBlock failed (%s): %v", blockhash, err)
+			}
+
+			if err = db.StoreBlockFeeStats(BlockFeeStats(msgBlock)); err != nil {
+				return count, err
+			}
+			count++
+		}
+		log.Debugf("Backfilled block fees up to height %d.",
+			heights[len(heights)-1])
+	}
+}
+
+// retrieveSummariesNoNumTx retrieves the heights and hashes of up to limit
+// block summaries without numtx, in order of height.
+func (db *DB) retrieveSummariesNoNumTx(limit int) ([]int64, []string, error) {
+	return db.retrieveSummaries(db.getSummariesNoNumTxSQL, limit)
+}
+
+// retrieveSummaries retrieves the heights and hashes of up to limit block
+// summaries selected by the query.
+func (db *DB) retrieveSummaries(query string, limit int) ([]int64, []string, error) {
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var heights []int64
+	var hashes []string
+	for rows.Next() {
+		var height int64
+		var hash string
+		if err = rows.Scan(&height, &hash); err != nil {
+			return nil, nil, err
+		}
+		heights = append(heights, height)
+		hashes = append(hashes, hash)
+	}
+	return heights, hashes, rows.Err()
+}
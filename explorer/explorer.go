@@ -19,8 +19,11 @@ import (
 	"github.com/Legenddigital/lddld/lddljson"
 	"github.com/Legenddigital/lddld/lddlutil"
 	"github.com/Legenddigital/lddld/wire"
+	"github.com/Legenddigital/lddldata/agendas"
 	"github.com/Legenddigital/lddldata/blockdata"
 	"github.com/Legenddigital/lddldata/db/dbtypes"
+	"github.com/Legenddigital/lddldata/stakecalc"
+	"github.com/Legenddigital/lddldata/stakediff"
 	"github.com/Legenddigital/lddldata/txhelpers"
 	humanize "github.com/dustin/go-humanize"
 	"github.com/go-chi/chi"
@@ -53,6 +56,7 @@ type explorerDataSourceLite interface {
 	GetMempool() []MempoolTx
 	TxHeight(txid string) (height int64)
 	BlockSubsidy(height int64, voters uint16) *lddljson.GetBlockSubsidyResult
+	GetStakeDiffProjection(windows int, rates []float64) (*stakediff.Projection, error)
 }
 
 // explorerDataSource implements extra data retrieval functions that require a
@@ -67,6 +71,19 @@ type explorerDataSource interface {
 	BlockMissedVotes(blockHash string) ([]string, error)
 }
 
+// agendaSource provides the vote tallies of the consensus agendas for the
+// agendas page.
+type agendaSource interface {
+	Agendas() ([]*agendas.Summary, error)
+	Agenda(id string, withBlocks bool) (*agendas.Agenda, error)
+}
+
+// chartSource is the cache of the historical chart data, which is updated for
+// each new block. The charts page gets the data from the API.
+type chartSource interface {
+	Update() error
+}
+
 // TicketStatusText generates the text to display on the explorer's transaction
 // page for the "POOL STATUS" field.
 func TicketStatusText(s dbtypes.TicketSpendType, p dbtypes.TicketPoolStatus) string {
@@ -102,6 +119,8 @@ type explorerUI struct {
 	Mux             *chi.Mux
 	blockData       explorerDataSourceLite
 	explorerSource  explorerDataSource
+	agendaSource    agendaSource
+	chartSource     chartSource
 	liteMode        bool
 	devPrefetch     bool
 	templates       templates
@@ -139,6 +158,18 @@ func (exp *explorerUI) reloadTemplatesSig(sig os.Signal) {
 	}()
 }
 
+// UseAgendaSource sets the source of the vote tallies for the agendas page,
+// which is unavailable until it is set.
+func (exp *explorerUI) UseAgendaSource(source agendaSource) {
+	exp.agendaSource = source
+}
+
+// UseChartSource sets the source of the historical chart data, which is
+// updated by Store for each new block.
+func (exp *explorerUI) UseChartSource(source chartSource) {
+	exp.chartSource = source
+}
+
 // StopWebsocketHub stops the websocket hub
 func (exp *explorerUI) StopWebsocketHub() {
 	if exp == nil {
@@ -148,6 +179,15 @@ func (exp *explorerUI) StopWebsocketHub() {
 	exp.wsHub.Stop()
 }
 
+// NumWebsocketClients returns the number of clients connected to the websocket
+// hub.
+func (exp *explorerUI) NumWebsocketClients() int {
+	if exp == nil || exp.wsHub == nil {
+		return 0
+	}
+	return exp.wsHub.NumClients()
+}
+
 // New returns an initialized instance of explorerUI
 func New(dataSource explorerDataSourceLite, primaryDataSource explorerDataSource,
 	useRealIP bool, appVersion string, devPrefetch bool) *explorerUI {
@@ -197,7 +237,7 @@ func New(dataSource explorerDataSourceLite, primaryDataSource explorerDataSource
 		log.Errorf("Unable to create new html template: %v", err)
 		return nil
 	}
-	tmpls := []string{"home", "explorer", "mempool", "block", "tx", "address", "rawtx", "error", "parameters"}
+	tmpls := []string{"home", "explorer", "mempool", "block", "tx", "address", "rawtx", "error", "parameters", "agendas", "charts", "calculator"}
 
 	tempDefaults := []string{"extras"}
 
@@ -276,12 +316,22 @@ func (exp *explorerUI) Store(blockData *blockdata.BlockData, _ *wire.MsgBlock) e
 		return fmt.Sprintf("%.2f days", exp.ChainParams.TargetTimePerBlock.Seconds()*PosAvgTotalBlocks/86400)
 	}()
 
-	asr, _ := exp.simulateASR(1000, false, stakePerc,
-		lddlutil.Amount(blockData.ExtraInfo.CoinSupply).ToCoin(),
-		float64(exp.NewBlockData.Height),
-		blockData.CurrentStakeDiff.CurrentStakeDifficulty)
-
-	exp.ExtraInfo.ASR = asr
+	// The annual stake return of fractional tickets with the rewards
+	// reinvested.
+	calc, err := stakecalc.Calculate(exp.ChainParams, stakecalc.Inputs{
+		Height:      newBlockData.Height,
+		Balance:     1000,
+		TicketPrice: blockData.CurrentStakeDiff.CurrentStakeDifficulty,
+		PoolSize:    int64(blockData.PoolInfo.Size),
+		Compound:    true,
+		Days:        365,
+	})
+	if err != nil {
+		log.Warnf("Unable to calculate the ASR: %v", err)
+		exp.ExtraInfo.ASR = 0
+	} else {
+		exp.ExtraInfo.ASR = calc.ASR
+	}
 
 	exp.NewBlockDataMtx.Unlock()
 
@@ -291,7 +341,12 @@ func (exp *explorerUI) Store(blockData *blockdata.BlockData, _ *wire.MsgBlock) e
 
 	// Signal to the websocket hub that a new block was received, but do not
 	// block Store(), and do not hang forever in a goroutine waiting to send.
+	// The charts are updated first, so that they include the new block when
+	// the charts page reloads them on the signal.
 	go func() {
+		if exp.chartSource != nil {
+			exp.updateCharts()
+		}
 		select {
 		case exp.wsHub.HubRelay <- sigNewBlock:
 		case <-time.After(time.Second * 10):
@@ -318,6 +373,12 @@ func (exp *explorerUI) updateDevFundBalance() {
 	}
 }
 
+func (exp *explorerUI) updateCharts() {
+	if err := exp.chartSource.Update(); err != nil {
+		log.Errorf("explorerUI.updateCharts failed: %v", err)
+	}
+}
+
 func (exp *explorerUI) addRoutes() {
 	exp.Mux.Use(middleware.Logger)
 	exp.Mux.Use(middleware.Recoverer)
@@ -344,119 +405,6 @@ func (exp *explorerUI) addRoutes() {
 	exp.Mux.Get("/decodetx", redirect("decodetx"))
 }
 
-// Simulate ticket purchase and re-investment over a full year for a given
-// starting amount of LDDL and calculation parameters.  Generate a TEXT table of
-// the simulation results that can optionally be used for future expansion of
-// lddldata functionality.
-func (exp *explorerUI) simulateASR(StartingLDDLBalance float64, IntegerTicketQty bool,
-	CurrentStakePercent float64, ActualCoinbase float64, CurrentBlockNum float64,
-	ActualTicketPrice float64) (ASR float64, ReturnTable string) {
-
-	// Calculations are only useful on mainnet.  Short circuit calculations if
-	// on any other version of chain params.
-	if exp.ChainParams.Name != "mainnet" {
-		return 0, ""
-	}
-
-	BlocksPerDay := 86400 / exp.ChainParams.TargetTimePerBlock.Seconds()
-	BlocksPerYear := 365 * BlocksPerDay
-	TicketsPurchased := float64(0)
-
-	StakeRewardAtBlock := func(blocknum float64) float64 {
-		// Option 1:  RPC Call
-		Subsidy := exp.blockData.BlockSubsidy(int64(blocknum), 1)
-		return lddlutil.Amount(Subsidy.PoS).ToCoin()
-
-		// Option 2:  Calculation
-		// epoch := math.Floor(blocknum / float64(exp.ChainParams.SubsidyReductionInterval))
-		// RewardProportionPerVote := float64(exp.ChainParams.StakeRewardProportion) / (10 * float64(exp.ChainParams.TicketsPerBlock))
-		// return float64(RewardProportionPerVote) * lddlutil.Amount(exp.ChainParams.BaseSubsidy).ToCoin() *
-		// 	math.Pow(float64(exp.ChainParams.MulSubsidy)/float64(exp.ChainParams.DivSubsidy), epoch)
-	}
-
-	MaxCoinSupplyAtBlock := func(blocknum float64) float64 {
-		// 4th order poly best fit curve to Legenddigital mainnet emissions plot.
-		// Curve fit was done with 0 Y intercept and Pre-Mine added after.
-
-		return (-9E-19*math.Pow(blocknum, 4) +
-			7E-12*math.Pow(blocknum, 3) -
-			2E-05*math.Pow(blocknum, 2) +
-			29.757*blocknum + 76963 +
-			1680000) // Premine 1.68M
-
-	}
-
-	CoinAdjustmentFactor := ActualCoinbase / MaxCoinSupplyAtBlock(CurrentBlockNum)
-
-	TheoreticalTicketPrice := func(blocknum float64) float64 {
-		ProjectedCoinsCirculating := MaxCoinSupplyAtBlock(blocknum) * CoinAdjustmentFactor * CurrentStakePercent
-		TicketPoolSize := (float64(exp.ExtraInfo.Params.MeanVotingBlocks) + float64(exp.ChainParams.TicketMaturity) +
-			float64(exp.ChainParams.CoinbaseMaturity)) * float64(exp.ChainParams.TicketsPerBlock)
-		return ProjectedCoinsCirculating / TicketPoolSize
-
-	}
-	TicketAdjustmentFactor := ActualTicketPrice / TheoreticalTicketPrice(CurrentBlockNum)
-
-	// Prepare for simulation
-	simblock := CurrentBlockNum
-	TicketPrice := ActualTicketPrice
-	LDDLBalance := StartingLDDLBalance
-
-	ReturnTable += fmt.Sprintf("\n\nBLOCKNUM        LDDL  TICKETS TKT_PRICE TKT_REWRD  ACTION\n")
-	ReturnTable += fmt.Sprintf("%8d  %9.2f %8.1f %9.2f %9.2f    INIT\n",
-		int64(simblock), LDDLBalance, TicketsPurchased,
-		TicketPrice, StakeRewardAtBlock(simblock))
-
-	for simblock < (BlocksPerYear + CurrentBlockNum) {
-
-		// Simulate a Purchase on simblock
-		TicketPrice = TheoreticalTicketPrice(simblock) * TicketAdjustmentFactor
-
-		if IntegerTicketQty {
-			// Use this to simulate integer qtys of tickets up to max funds
-			TicketsPurchased = math.Floor(LDDLBalance / TicketPrice)
-		} else {
-			// Use this to simulate ALL funds used to buy tickets - even fractional tickets
-			// which is actually not possible
-			TicketsPurchased = (LDDLBalance / TicketPrice)
-		}
-
-		LDDLBalance -= (TicketPrice * TicketsPurchased)
-		ReturnTable += fmt.Sprintf("%8d  %9.2f %8.1f %9.2f %9.2f     BUY\n",
-			int64(simblock), LDDLBalance, TicketsPurchased,
-			TicketPrice, StakeRewardAtBlock(simblock))
-
-		// Move forward to average vote
-		simblock += (float64(exp.ChainParams.TicketMaturity) + float64(exp.ExtraInfo.Params.MeanVotingBlocks))
-		ReturnTable += fmt.Sprintf("%8d  %9.2f %8.1f %9.2f %9.2f    VOTE\n",
-			int64(simblock), LDDLBalance, TicketsPurchased,
-			(TheoreticalTicketPrice(simblock) * TicketAdjustmentFactor), StakeRewardAtBlock(simblock))
-
-		// Simulate return of funds
-		LDDLBalance += (TicketPrice * TicketsPurchased)
-
-		// Simulate reward
-		LDDLBalance += (StakeRewardAtBlock(simblock) * TicketsPurchased)
-		TicketsPurchased = 0
-
-		// Move forward to coinbase maturity
-		simblock += float64(exp.ChainParams.CoinbaseMaturity)
-
-		ReturnTable += fmt.Sprintf("%8d  %9.2f %8.1f %9.2f %9.2f  REWARD\n",
-			int64(simblock), LDDLBalance, TicketsPurchased,
-			(TheoreticalTicketPrice(simblock) * TicketAdjustmentFactor), StakeRewardAtBlock(simblock))
-
-		// Need to receive funds before we can use them again so add 1 block
-		simblock++
-	}
-
-	// Scale down to exactly 365 days
-	SimulationReward := ((LDDLBalance - StartingLDDLBalance) / StartingLDDLBalance) * 100
-	ASR = (BlocksPerYear / (simblock - CurrentBlockNum)) * SimulationReward
-	ReturnTable += fmt.Sprintf("ASR over 365 Days is %.2f.\n", ASR)
-	return
-}
-
 // Calculate the Mean ticket voting block for network parameters.
 // The expected block (aka mean) of the probability distribution is given by:
 //      sum(B * P(B)), B=1 to 40960
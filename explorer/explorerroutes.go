@@ -17,7 +17,9 @@ import (
 	"github.com/Legenddigital/lddld/chaincfg/chainhash"
 	"github.com/Legenddigital/lddld/lddlutil"
 	"github.com/Legenddigital/lddld/wire"
+	"github.com/Legenddigital/lddldata/agendas"
 	"github.com/Legenddigital/lddldata/db/dbtypes"
+	"github.com/Legenddigital/lddldata/stakediff"
 	"github.com/Legenddigital/lddldata/txhelpers"
 	humanize "github.com/dustin/go-humanize"
 )
@@ -32,25 +34,40 @@ func netName(chainParams *chaincfg.Params) string {
 	}
 }
 
+// homeProjectionWindows is the number of windows of the ticket price
+// projection on the home page.
+const homeProjectionWindows = 4
+
 // Home is the page handler for the "/" path
 func (exp *explorerUI) Home(w http.ResponseWriter, r *http.Request) {
 	height := exp.blockData.GetHeight()
 
 	blocks := exp.blockData.GetExplorerBlocks(height, height-5)
 
+	// The ticket price projection at the recent purchase rate, which is the
+	// second of the default scenarios.
+	var projection *stakediff.Scenario
+	if p, err := exp.blockData.GetStakeDiffProjection(homeProjectionWindows, nil); err != nil {
+		log.Warnf("Unable to project the ticket price: %v", err)
+	} else if len(p.Scenarios) > 1 {
+		projection = p.Scenarios[1]
+	}
+
 	exp.NewBlockDataMtx.Lock()
 	exp.MempoolData.RLock()
 
 	str, err := exp.templates.execTemplateToString("home", struct {
-		Info    *HomeInfo
-		Mempool *MempoolInfo
-		Blocks  []*BlockBasic
-		Version string
-		NetName string
+		Info       *HomeInfo
+		Mempool    *MempoolInfo
+		Blocks     []*BlockBasic
+		Projection *stakediff.Scenario
+		Version    string
+		NetName    string
 	}{
 		exp.ExtraInfo,
 		exp.MempoolData,
 		blocks,
+		projection,
 		exp.Version,
 		exp.NetName,
 	})
@@ -740,3 +757,100 @@ func (exp *explorerUI) ParametersPage(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	io.WriteString(w, str)
 }
+
+// AgendasPage is the page handler for the "/agendas" path
+func (exp *explorerUI) AgendasPage(w http.ResponseWriter, r *http.Request) {
+	if exp.agendaSource == nil {
+		exp.ErrorPage(w, "Not available", "agenda votes are not tracked in lite mode", true)
+		return
+	}
+
+	summaries, err := exp.agendaSource.Agendas()
+	if err != nil {
+		log.Errorf("Unable to tally the agenda votes: %v", err)
+		exp.ErrorPage(w, "Something went wrong...", "and it's not your fault, try refreshing... that usually fixes things", false)
+		return
+	}
+	tallies := make([]*agendas.Agenda, 0, len(summaries))
+	for _, summary := range summaries {
+		agenda, err := exp.agendaSource.Agenda(summary.ID, false)
+		if err != nil || agenda == nil {
+			log.Errorf("Unable to get agenda %s: %v", summary.ID, err)
+			continue
+		}
+		tallies = append(tallies, agenda)
+	}
+
+	str, err := exp.templates.execTemplateToString("agendas", struct {
+		Agendas []*agendas.Agenda
+		Version string
+		NetName string
+	}{
+		tallies,
+		exp.Version,
+		exp.NetName,
+	})
+
+	if err != nil {
+		log.Errorf("Template execute failure: %v", err)
+		exp.ErrorPage(w, "Something went wrong...", "and it's not your fault, try refreshing... that usually fixes things", false)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, str)
+}
+
+// ChartsPage is the page handler for the "/charts" path
+func (exp *explorerUI) ChartsPage(w http.ResponseWriter, r *http.Request) {
+	if exp.chartSource == nil {
+		exp.ErrorPage(w, "Not available", "charts are not available in lite mode", true)
+		return
+	}
+
+	str, err := exp.templates.execTemplateToString("charts", struct {
+		Version string
+		NetName string
+	}{
+		exp.Version,
+		exp.NetName,
+	})
+
+	if err != nil {
+		log.Errorf("Template execute failure: %v", err)
+		exp.ErrorPage(w, "Something went wrong...", "and it's not your fault, try refreshing... that usually fixes things", false)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, str)
+}
+
+// CalculatorPage is the page handler for the "/calculator" path
+func (exp *explorerUI) CalculatorPage(w http.ResponseWriter, r *http.Request) {
+	exp.NewBlockDataMtx.RLock()
+	ticketPrice := exp.ExtraInfo.StakeDiff
+	poolSize := exp.ExtraInfo.PoolInfo.Size
+	exp.NewBlockDataMtx.RUnlock()
+
+	str, err := exp.templates.execTemplateToString("calculator", struct {
+		TicketPrice float64
+		PoolSize    uint32
+		Version     string
+		NetName     string
+	}{
+		ticketPrice,
+		poolSize,
+		exp.Version,
+		exp.NetName,
+	})
+
+	if err != nil {
+		log.Errorf("Template execute failure: %v", err)
+		exp.ErrorPage(w, "Something went wrong...", "and it's not your fault, try refreshing... that usually fixes things", false)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, str)
+}
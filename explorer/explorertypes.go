@@ -394,6 +394,8 @@ type MempoolTx struct {
 	Time     int64     `json:"time"`
 	Size     int32     `json:"size"`
 	TotalOut float64   `json:"total"`
+	Fees     float64   `json:"fees"`
+	FeeRate  float64   `json:"fee_rate"`
 	Type     string    `json:"Type"`
 	VoteInfo *VoteInfo `json:"vote_info"`
 }
@@ -77,11 +77,14 @@ func (exp *explorerUI) mempoolMonitor(txChan chan *NewMempoolTx) {
 			}
 		}
 
+		fees, feeRate := txhelpers.TxFeeRate(msgTx)
 		tx := MempoolTx{
 			Hash:     hash,
 			Time:     ntx.Time,
 			Size:     int32(len(ntx.Hex) / 2),
 			TotalOut: txhelpers.TotalOutFromMsgTx(msgTx).ToCoin(),
+			Fees:     fees.ToCoin(),
+			FeeRate:  feeRate.ToCoin(),
 			Type:     txhelpers.DetermineTxTypeString(msgTx),
 			VoteInfo: voteInfo,
 		}
@@ -135,6 +138,21 @@ func (exp *explorerUI) mempoolMonitor(txChan chan *NewMempoolTx) {
 	}
 }
 
+// MempoolSnapshot returns a copy of the mempool summary and of all the
+// transactions in mempool, most recent first within each transaction type.
+func (exp *explorerUI) MempoolSnapshot() (MempoolShort, []MempoolTx) {
+	exp.MempoolData.RLock()
+	defer exp.MempoolData.RUnlock()
+	txs := make([]MempoolTx, 0, len(exp.MempoolData.Transactions)+
+		len(exp.MempoolData.Tickets)+len(exp.MempoolData.Votes)+
+		len(exp.MempoolData.Revocations))
+	txs = append(txs, exp.MempoolData.Transactions...)
+	txs = append(txs, exp.MempoolData.Tickets...)
+	txs = append(txs, exp.MempoolData.Votes...)
+	txs = append(txs, exp.MempoolData.Revocations...)
+	return exp.MempoolData.MempoolShort, txs
+}
+
 func (exp *explorerUI) StopMempoolMonitor(txChan chan *NewMempoolTx) {
 	log.Infof("Stopping mempool monitor")
 	txChan <- nil
@@ -139,6 +139,9 @@ func makeTemplateFuncMap(params *chaincfg.Params) template.FuncMap {
 			p := (float64(i) / float64(params.StakeDiffWindowSize)) * 100
 			return p
 		},
+		"fractionPercent": func(f float64) float64 {
+			return math.Min(f*100, 100)
+		},
 		"rewardAdjustmentProgress": func(i int) float64 {
 			p := (float64(i) / float64(params.SubsidyReductionInterval)) * 100
 			return p
@@ -10,17 +10,23 @@ import (
 	"path/filepath"
 
 	"github.com/Legenddigital/lddld/rpcclient"
+	"github.com/Legenddigital/lddldata/agendas"
 	"github.com/Legenddigital/lddldata/api"
+	"github.com/Legenddigital/lddldata/api/graphql"
 	"github.com/Legenddigital/lddldata/api/insight"
+	"github.com/Legenddigital/lddldata/api/stream"
 	"github.com/Legenddigital/lddldata/blockdata"
+	"github.com/Legenddigital/lddldata/charts"
 	"github.com/Legenddigital/lddldata/db/lddlpg"
 	"github.com/Legenddigital/lddldata/db/lddlsqlite"
 	"github.com/Legenddigital/lddldata/explorer"
 	"github.com/Legenddigital/lddldata/mempool"
+	"github.com/Legenddigital/lddldata/metrics"
 	"github.com/Legenddigital/lddldata/middleware"
 	notify "github.com/Legenddigital/lddldata/notification"
 	"github.com/Legenddigital/lddldata/rpcutils"
 	"github.com/Legenddigital/lddldata/stakedb"
+	"github.com/Legenddigital/lddldata/watcher"
 	"github.com/Legenddigital/slog"
 	"github.com/jrick/logrotate/rotator"
 )
@@ -64,6 +70,11 @@ var (
 	apiLog        = backendLog.Logger("JAPI")
 	log           = backendLog.Logger("DATD")
 	iapiLog       = backendLog.Logger("IAPI")
+	watcherLog    = backendLog.Logger("WTCH")
+	graphqlLog    = backendLog.Logger("GAPI")
+	streamLog     = backendLog.Logger("STRM")
+	agendasLog    = backendLog.Logger("AGND")
+	chartsLog     = backendLog.Logger("CHRT")
 )
 
 // Initialize package-global logger variables.
@@ -78,8 +89,14 @@ func init() {
 	explorer.UseLogger(expLog)
 	api.UseLogger(apiLog)
 	insight.UseLogger(iapiLog)
+	graphql.UseLogger(graphqlLog)
+	stream.UseLogger(streamLog)
 	middleware.UseLogger(apiLog)
+	metrics.UseLogger(apiLog)
 	notify.UseLogger(notifyLog)
+	watcher.UseLogger(watcherLog)
+	agendas.UseLogger(agendasLog)
+	charts.UseLogger(chartsLog)
 }
 
 // subsystemLoggers maps each subsystem identifier to its associated logger.
@@ -95,6 +112,11 @@ var subsystemLoggers = map[string]slog.Logger{
 	"JAPI": apiLog,
 	"IAPI": iapiLog,
 	"DATD": log,
+	"WTCH": watcherLog,
+	"GAPI": graphqlLog,
+	"STRM": streamLog,
+	"AGND": agendasLog,
+	"CHRT": chartsLog,
 }
 
 // initLogRotator initializes the logging rotater to write logs to logFile and
@@ -6,8 +6,11 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
 	"net"
 	"net/http"
@@ -22,24 +25,75 @@ import (
 
 	"github.com/Legenddigital/lddld/chaincfg/chainhash"
 	"github.com/Legenddigital/lddld/rpcclient"
+	"github.com/Legenddigital/lddldata/agendas"
 	"github.com/Legenddigital/lddldata/api"
+	"github.com/Legenddigital/lddldata/api/graphql"
 	"github.com/Legenddigital/lddldata/api/insight"
+	"github.com/Legenddigital/lddldata/api/openapi"
+	"github.com/Legenddigital/lddldata/api/stream"
 	"github.com/Legenddigital/lddldata/blockdata"
+	"github.com/Legenddigital/lddldata/charts"
 	"github.com/Legenddigital/lddldata/db/dbtypes"
 	"github.com/Legenddigital/lddldata/db/lddlpg"
 	"github.com/Legenddigital/lddldata/db/lddlsqlite"
 	"github.com/Legenddigital/lddldata/explorer"
 	"github.com/Legenddigital/lddldata/mempool"
+	"github.com/Legenddigital/lddldata/metrics"
 	m "github.com/Legenddigital/lddldata/middleware"
 	notify "github.com/Legenddigital/lddldata/notification"
 	"github.com/Legenddigital/lddldata/rpcutils"
 	"github.com/Legenddigital/lddldata/semver"
 	"github.com/Legenddigital/lddldata/txhelpers"
 	"github.com/Legenddigital/lddldata/version"
+	"github.com/Legenddigital/lddldata/watcher"
 	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
 	"github.com/google/gops/agent"
 )
 
+// pgDBInfo returns the PostgreSQL connection information of the config.
+func pgDBInfo(cfg *config) (*lddlpg.DBInfo, error) {
+	pgHost, pgPort := cfg.PGHost, ""
+	if !strings.HasPrefix(pgHost, "/") {
+		var err error
+		pgHost, pgPort, err = net.SplitHostPort(cfg.PGHost)
+		if err != nil {
+			return nil, fmt.Errorf("SplitHostPort failed: %v", err)
+		}
+	}
+	return &lddlpg.DBInfo{
+		Host:   pgHost,
+		Port:   pgPort,
+		User:   cfg.PGUser,
+		Pass:   cfg.PGPass,
+		DBName: cfg.PGDBName,
+	}, nil
+}
+
+// migrateOnly applies the pending PostgreSQL schema migrations, including
+// their data backfills, and reports the resulting schema version.
+func migrateOnly(cfg *config) error {
+	dbi, err := pgDBInfo(cfg)
+	if err != nil {
+		return err
+	}
+	auxDB, err := lddlpg.NewChainDB(dbi, activeChain, nil, false)
+	if auxDB != nil {
+		defer auxDB.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	schemaVersion, err := auxDB.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("unable to get the PostgreSQL schema version: %v", err)
+	}
+	log.Infof("PostgreSQL schema is at version %d. Exiting (--migrate-only).",
+		schemaVersion)
+	return nil
+}
+
 // mainCore does all the work. Deferred functions do not run after os.Exit(),
 // so main wraps this function, which returns a code.
 func mainCore() error {
@@ -79,16 +133,32 @@ func mainCore() error {
 
 	// PostgreSQL
 	usePG := cfg.FullMode
+	if cfg.MigrateOnly && !usePG {
+		return fmt.Errorf("--migrate-only requires --pg")
+	}
 	if usePG {
 		log.Info(`Running in full-functionality mode with PostgreSQL backend enabled.`)
 	} else {
 		log.Info(`Running in "Lite" mode with only SQLite backend and limited functionality.`)
 	}
 
+	// Only apply the PostgreSQL schema migrations, which NewChainDB does,
+	// without the node, the SQLite DB or the stake DB.
+	if cfg.MigrateOnly {
+		return migrateOnly(cfg)
+	}
+
+	// Address watching is enabled by watched addresses in the config, or an
+	// API key for managing the watch list.
+	watchEnabled := len(cfg.WatchAddresses) > 0 || cfg.WatchAPIKey != ""
+	if cfg.SMTPServer != "" && !watchEnabled {
+		log.Warnf("Email alerts are configured, but no addresses are watched.")
+	}
+
 	// Connect to lddld RPC server using websockets
 
 	// Set up the notification handler to deliver blocks through a channel.
-	notify.MakeNtfnChans(cfg.MonitorMempool, usePG)
+	notify.MakeNtfnChans(cfg.MonitorMempool, usePG, watchEnabled)
 
 	// Daemon client connection
 	ntfnHandlers, collectionQueue := notify.MakeNodeNtfnHandlers()
@@ -140,21 +210,11 @@ func mainCore() error {
 	var auxDB *lddlpg.ChainDB
 	var newPGIndexes, updateAllAddresses, updateAllVotes bool
 	if usePG {
-		pgHost, pgPort := cfg.PGHost, ""
-		if !strings.HasPrefix(pgHost, "/") {
-			pgHost, pgPort, err = net.SplitHostPort(cfg.PGHost)
-			if err != nil {
-				return fmt.Errorf("SplitHostPort failed: %v", err)
-			}
-		}
-		dbi := lddlpg.DBInfo{
-			Host:   pgHost,
-			Port:   pgPort,
-			User:   cfg.PGUser,
-			Pass:   cfg.PGPass,
-			DBName: cfg.PGDBName,
+		var dbi *lddlpg.DBInfo
+		if dbi, err = pgDBInfo(cfg); err != nil {
+			return err
 		}
-		auxDB, err = lddlpg.NewChainDB(&dbi, activeChain, baseDB.GetStakeDB(), !cfg.NoDevPrefetch)
+		auxDB, err = lddlpg.NewChainDB(dbi, activeChain, baseDB.GetStakeDB(), !cfg.NoDevPrefetch)
 		if auxDB != nil {
 			defer auxDB.Close()
 		}
@@ -301,8 +361,21 @@ func mainCore() error {
 	defer explore.StopWebsocketHub()
 	defer explore.StopMempoolMonitor(notify.NtfnChans.ExpNewTxChan)
 
+	// Historical chart data from the full mode DB, updated by the explorer for
+	// each new block.
+	var chartCache *charts.Cache
+	if usePG {
+		chartCache = charts.NewCache(auxDB, activeChain)
+		explore.UseChartSource(chartCache)
+	}
+
 	blockDataSavers = append(blockDataSavers, explore)
 
+	// Create the streaming API hub after the savers whose data it replays
+	streamHub := stream.NewHub(&baseDB, activeChain)
+	blockDataSavers = append(blockDataSavers, streamHub)
+	mempoolSavers = append(mempoolSavers, streamHub)
+
 	// Sync up with the blockchain
 	getSyncd := func(updateAddys, updateVotes, newPGInds bool,
 		fetchHeight int64) (int64, int64, error) {
@@ -366,6 +439,46 @@ func mainCore() error {
 	if cerr != nil {
 		return fmt.Errorf("RPC client error: %v (%v)", cerr.Error(), cerr.Cause())
 	}
+
+	// Address watch list and webhooks
+	var addrWatcher *watcher.AddressWatcher
+	var watchAddrs func() map[string]txhelpers.TxAction
+	if watchEnabled {
+		watchCfg := &watcher.Config{
+			DBPath:        filepath.Join(cfg.DataDir, "watcher.db"),
+			Params:        activeChain,
+			Client:        lddldClient,
+			WebhookURL:    cfg.WatchWebhook,
+			WebhookSecret: cfg.WatchWebhookSecret,
+			APIKey:        cfg.WatchAPIKey,
+			MaxAttempts:   cfg.WatchMaxAttempts,
+			Addresses:     cfg.WatchAddresses,
+		}
+		if cfg.SMTPServer != "" {
+			watchCfg.Email = &watcher.EmailConfig{
+				Server:  cfg.SMTPServer,
+				User:    cfg.SMTPUser,
+				Pass:    cfg.SMTPPass,
+				From:    cfg.EmailFrom,
+				To:      strings.Split(cfg.EmailAddr, ","),
+				Subject: cfg.EmailSubject,
+				TLS:     cfg.SMTPTLS,
+			}
+			for i := range watchCfg.Email.To {
+				watchCfg.Email.To[i] = strings.TrimSpace(watchCfg.Email.To[i])
+			}
+		}
+		addrWatcher, err = watcher.New(watchCfg)
+		if err != nil {
+			return fmt.Errorf("Failed to create address watcher: %v", err)
+		}
+		defer addrWatcher.Close()
+
+		if err = addrWatcher.LoadTxFilter(); err != nil {
+			return fmt.Errorf("RPC client error: %v", err)
+		}
+		watchAddrs = addrWatcher.Addresses
+	}
 	// now create and start the monitors that respond to the notification chans
 
 	// Create the insight socket server and add it to block savers if in pg mode
@@ -383,14 +496,13 @@ func mainCore() error {
 	var wg sync.WaitGroup
 
 	// Blockchain monitor for the collector
-	addrMap := make(map[string]txhelpers.TxAction) // for support of watched addresses
-	// On reorg, only update web UI since lddlsqlite's own reorg handler will
-	// deal with patching up the block info database.
-	reorgBlockDataSavers := []blockdata.BlockDataSaver{explore}
+	// On reorg, only update web UI since the lddlsqlite and lddlpg reorg
+	// handlers will deal with patching up the block info databases.
+	reorgBlockDataSavers := []blockdata.BlockDataSaver{explore, streamHub}
 	wsChainMonitor := blockdata.NewChainMonitor(collector, blockDataSavers,
-		reorgBlockDataSavers, quit, &wg, addrMap,
+		reorgBlockDataSavers, quit, &wg, watchAddrs,
 		notify.NtfnChans.ConnectChan, notify.NtfnChans.RecvTxBlockChan,
-		notify.NtfnChans.ReorgChanBlockData)
+		notify.NtfnChans.SpendTxBlockChan, notify.NtfnChans.ReorgChanBlockData)
 
 	// Blockchain monitor for the stake DB
 	sdbChainMonitor := baseDB.NewStakeDBChainMonitor(quit, &wg,
@@ -402,11 +514,21 @@ func mainCore() error {
 
 	// Setup the synchronous handler functions called by the collectionQueue via
 	// OnBlockConnected.
-	collectionQueue.SetSynchronousHandlers([]func(*chainhash.Hash){
+	syncHandlers := []func(*chainhash.Hash){
 		sdbChainMonitor.BlockConnectedSync,     // 1. Stake DB for pool info
 		wsChainMonitor.BlockConnectedSync,      // 2. blockdata for regular block data collection and storage
 		wiredDBChainMonitor.BlockConnectedSync, // 3. lddlsqlite for sqlite DB reorg handling
-	})
+	}
+
+	// Blockchain monitor for the PostgreSQL DB (full mode only)
+	var auxDBChainMonitor *lddlpg.ChainMonitor
+	if usePG {
+		auxDBChainMonitor = auxDB.NewChainMonitor(lddldClient, quit, &wg,
+			notify.NtfnChans.ConnectChanDBPG, notify.NtfnChans.ReorgChanDBPG)
+		// 4. lddlpg for PostgreSQL DB reorg handling
+		syncHandlers = append(syncHandlers, auxDBChainMonitor.BlockConnectedSync)
+	}
+	collectionQueue.SetSynchronousHandlers(syncHandlers)
 
 	// Initial data summary for web ui. stakedb must be at the same height, so
 	// we get do this before starting the monitors.
@@ -439,6 +561,31 @@ func mainCore() error {
 	go wiredDBChainMonitor.BlockConnectedHandler()
 	go wiredDBChainMonitor.ReorgHandler()
 
+	// lddlsqlite columns added by schema upgrades, filled in for old blocks
+	wg.Add(1)
+	go baseDB.BackfillHandler(&wg, quit)
+
+	// lddlpg does not handle new blocks except during reorg
+	if auxDBChainMonitor != nil {
+		wg.Add(2)
+		go auxDBChainMonitor.BlockConnectedHandler()
+		go auxDBChainMonitor.ReorgHandler()
+	}
+
+	// Watched address transactions and webhook deliveries
+	if addrWatcher != nil {
+		wg.Add(3)
+		go addrWatcher.TxHandler(&wg, quit, notify.NtfnChans.RecvTxBlockChan,
+			notify.NtfnChans.SpendTxBlockChan, notify.NtfnChans.RelevantTxMempoolChan)
+		go addrWatcher.DeliveryHandler(&wg, quit)
+		go addrWatcher.EmailHandler(&wg, quit)
+	}
+
+	// Streaming API transactions, reorgs and pings
+	wg.Add(1)
+	go streamHub.NtfnHandler(&wg, quit, notify.NtfnChans.StreamNewTxChan,
+		notify.NtfnChans.ReorgChanStream)
+
 	if cfg.MonitorMempool {
 		mpoolCollector := mempool.NewMempoolDataCollector(lddldClient, activeChain)
 		if mpoolCollector == nil {
@@ -483,6 +630,20 @@ func mainCore() error {
 
 	// Start web API
 	app := api.NewContext(lddldClient, &baseDB, auxDB, cfg.IndentJSON)
+	if addrWatcher != nil {
+		app.Watcher = addrWatcher
+	}
+	app.Stream = streamHub
+	app.Mempool = explore
+	// Agenda vote tallies require the votes stored by the full mode DB.
+	if usePG {
+		agendaTracker := agendas.NewTracker(auxDB, activeChain)
+		app.Agendas = agendaTracker
+		explore.UseAgendaSource(agendaTracker)
+	}
+	if chartCache != nil {
+		app.Charts = chartCache
+	}
 	// Start notification hander to keep /status up-to-date
 	wg.Add(1)
 	go app.StatusNtfnHandler(&wg, quit)
@@ -491,9 +652,37 @@ func mainCore() error {
 
 	apiMux := api.NewAPIRouter(app, cfg.UseRealIP)
 
+	rateLimiter, err := newRateLimiter(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Metrics for monitoring sync status, caches and websocket clients. RPC,
+	// mempool collection and HTTP latencies are recorded by their packages.
+	metrics.NewGaugeFunc("lddldata_node_height", "Best block height of lddld.",
+		func() float64 { return float64(app.NodeHeight()) })
+	stakeDB := baseDB.GetStakeDB()
+	metrics.NewGaugeFunc("lddldata_stakedb_height", "Best block height of the stake database.",
+		func() float64 { return float64(stakeDB.Height()) })
+	metrics.NewGaugeFunc("lddldata_stakedb_poolinfo_cache_size",
+		"Number of blocks in the stake database's ticket pool info cache.",
+		func() float64 { return float64(stakeDB.PoolInfoCacheSize()) })
+	metrics.NewGaugeFunc("lddldata_sqlite_height", "Best block height of the SQLite DB.",
+		func() float64 { return float64(baseDB.GetHeight()) })
+	if usePG {
+		metrics.NewGaugeFunc("lddldata_pg_height", "Best block height of the PostgreSQL DB.",
+			func() float64 { return float64(auxDB.Height()) })
+	}
+	metrics.NewGaugeFunc("lddldata_websocket_clients",
+		"Number of clients connected to the explorer's websocket hub.",
+		func() float64 { return float64(explore.NumWebsocketClients()) })
+
 	webMux := chi.NewRouter()
-	webMux.Get("/", explore.Home)
-	webMux.Get("/ws", explore.RootWebsocket)
+	webMux.Use(metrics.HTTPMiddleware)
+	if cfg.UseRealIP {
+		// The rate limiter identifies clients by their real IP.
+		webMux.Use(middleware.RealIP)
+	}
 	webMux.Get("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./public/images/favicon.ico")
 	})
@@ -503,29 +692,57 @@ func mainCore() error {
 	FileServer(webMux, "/fonts", http.Dir("./public/fonts"), cacheControlMaxAge)
 	FileServer(webMux, "/images", http.Dir("./public/images"), cacheControlMaxAge)
 	webMux.NotFound(explore.NotFound)
-	webMux.Mount("/api", apiMux.Mux)
-
-	webMux.Mount("/explorer", explore.Mux)
-	webMux.Get("/blocks", explore.Blocks)
-	webMux.Get("/mempool", explore.Mempool)
-	webMux.Get("/parameters", explore.ParametersPage)
-	webMux.With(explore.BlockHashPathOrIndexCtx).Get("/block/{blockhash}", explore.Block)
-	webMux.With(explorer.TransactionHashCtx).Get("/tx/{txid}", explore.TxPage)
-	webMux.With(explorer.AddressPathCtx).Get("/address/{address}", explore.AddressPage)
-	webMux.Get("/decodetx", explore.DecodeTxPage)
-	webMux.Get("/search", explore.Search)
+	webMux.Get("/metrics", metrics.Handler)
+
+	// The API, Insight API and explorer pages are rate limited, but not the
+	// static files above.
+	limitedMux := webMux.With(rateLimiter.Middleware)
+	limitedMux.Get("/", explore.Home)
+	limitedMux.Get("/ws", explore.RootWebsocket)
+	limitedMux.Mount("/api", apiMux.Mux)
+
+	// GraphQL queries resolve through the same data sources as the API. The
+	// spending transactions and ticket status require the auxiliary DB.
+	var graphqlAux graphql.DataSourceAux
+	if usePG {
+		graphqlAux = auxDB
+	}
+	graphqlServer, err := graphql.NewServer(&baseDB, graphqlAux, cfg.GraphQLMaxCost, cfg.IndentJSON)
+	if err != nil {
+		return fmt.Errorf("Unable to create the GraphQL schema: %v", err)
+	}
+	limitedMux.Handle("/graphql", graphqlServer)
+
+	limitedMux.Mount("/explorer", explore.Mux)
+	limitedMux.Get("/blocks", explore.Blocks)
+	limitedMux.Get("/mempool", explore.Mempool)
+	limitedMux.Get("/parameters", explore.ParametersPage)
+	limitedMux.Get("/agendas", explore.AgendasPage)
+	limitedMux.Get("/charts", explore.ChartsPage)
+	limitedMux.Get("/calculator", explore.CalculatorPage)
+	limitedMux.With(explore.BlockHashPathOrIndexCtx).Get("/block/{blockhash}", explore.Block)
+	limitedMux.With(explorer.TransactionHashCtx).Get("/tx/{txid}", explore.TxPage)
+	limitedMux.With(explorer.AddressPathCtx).Get("/address/{address}", explore.AddressPage)
+	limitedMux.Get("/decodetx", explore.DecodeTxPage)
+	limitedMux.Get("/search", explore.Search)
+
+	// The OpenAPI document at /api/openapi.json describes the mounted APIs.
+	apiDescriptions := []openapi.API{apiMux.OpenAPI("/api")}
 
 	if usePG {
 		chainDBRPC, _ := lddlpg.NewChainDBRPC(auxDB, lddldClient)
 		insightApp := insight.NewInsightContext(lddldClient, chainDBRPC, activeChain, &baseDB, cfg.IndentJSON)
 		insightMux := insight.NewInsightApiRouter(insightApp, cfg.UseRealIP)
-		webMux.Mount("/insight/api", insightMux.Mux)
+		limitedMux.Mount("/insight/api", insightMux.Mux)
+		apiDescriptions = append(apiDescriptions, insightMux.OpenAPI("/insight/api"))
 
 		if insightSocketServer != nil {
 			webMux.Get("/insight/socket.io/", insightSocketServer.ServeHTTP)
 		}
 	}
 
+	app.OpenAPI = api.NewOpenAPIDocument(apiDescriptions...)
+
 	// HTTP profiler
 	if cfg.HTTPProfile {
 		profPath := cfg.HTTPProfPath
@@ -546,6 +763,20 @@ func mainCore() error {
 	return nil
 }
 
+// printAPIDirectory writes the OpenAPI document describing the API and the
+// Insight API. The routers are created without data sources, so no node or
+// database connection is needed.
+func printAPIDirectory(w io.Writer) error {
+	doc := api.NewOpenAPIDocument(api.NewDirectoryRouter().OpenAPI("/api"),
+		insight.NewDirectoryRouter().OpenAPI("/insight/api"))
+	b, err := json.MarshalIndent(doc, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
 func main() {
 	if err := mainCore(); err != nil {
 		if logRotator != nil {
@@ -646,6 +877,60 @@ func listenAndServeProto(listen, proto string, mux http.Handler) error {
 	}
 }
 
+// defaultInsightRateLimit is the rate limit for the Insight API, which may be
+// overridden with a ratelimit-route setting for /insight/api.
+var defaultInsightRateLimit = m.RouteRateLimit{
+	Pattern:   "/insight/api",
+	RateLimit: m.RateLimit{Rate: 1, Burst: 5},
+}
+
+// newRateLimiter creates the RateLimiter for the API, Insight API and explorer
+// pages from the rate limiting settings in the config. The Insight API is
+// limited to defaultInsightRateLimit unless a route group for it is configured.
+func newRateLimiter(cfg *config) (*m.RateLimiter, error) {
+	defaultLimit := m.RateLimit{
+		Rate:  cfg.RateLimit,
+		Burst: cfg.RateLimitBurst,
+	}
+	if defaultLimit.Burst == 0 {
+		defaultLimit.Burst = int(math.Ceil(defaultLimit.Rate))
+	}
+
+	routes := make([]m.RouteRateLimit, 0, len(cfg.RateLimitRoutes)+1)
+	for _, spec := range cfg.RateLimitRoutes {
+		route, err := m.ParseRouteRateLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	routes = append(routes, defaultInsightRateLimit)
+
+	apiKeys := make(map[string]m.RateLimit, len(cfg.RateLimitAPIKeys))
+	for _, spec := range cfg.RateLimitAPIKeys {
+		key, limit, err := m.ParseAPIKeyRateLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		apiKeys[key] = limit
+	}
+
+	var page []byte
+	if cfg.RateLimitPage != "" {
+		var err error
+		page, err = ioutil.ReadFile(cfg.RateLimitPage)
+		if err != nil {
+			log.Warnf("Unable to read rate limit page %s: %v", cfg.RateLimitPage, err)
+		}
+	}
+
+	if defaultLimit.Rate > 0 {
+		log.Infof("Rate limiting clients to %.2f requests/s (burst %d).",
+			defaultLimit.Rate, defaultLimit.Burst)
+	}
+	return m.NewRateLimiter(defaultLimit, routes, apiKeys, page), nil
+}
+
 // FileServer conveniently sets up a http.FileServer handler to serve
 // static files from a http.FileSystem.
 func FileServer(r chi.Router, path string, root http.FileSystem, CacheControlMaxAge int64) {
@@ -21,6 +21,7 @@ import (
 	"github.com/Legenddigital/lddld/lddlutil"
 	"github.com/Legenddigital/lddld/rpcclient"
 	apitypes "github.com/Legenddigital/lddldata/api/types"
+	"github.com/Legenddigital/lddldata/metrics"
 )
 
 // NewTx models data for a new transaction
@@ -296,11 +297,31 @@ func (m *MempoolData) GetHeight() uint32 {
 	return m.Height
 }
 
+// TicketFeeInfo returns the ticket fee info of the mempool data collected at
+// the given time.
+func (m *MempoolData) TicketFeeInfo(timestamp time.Time) *apitypes.MempoolTicketFeeInfo {
+	feeInfo := &apitypes.MempoolTicketFeeInfo{
+		Height: m.Height,
+		Time:   timestamp.Unix(),
+	}
+	if m.Ticketfees != nil {
+		feeInfo.FeeInfoMempool = m.Ticketfees.FeeInfoMempool
+	}
+	if m.MinableFees != nil {
+		feeInfo.LowestMineable = m.MinableFees.lowestMineableFee
+	}
+	return feeInfo
+}
+
 // GetNumTickets returns number of tickets
 func (m *MempoolData) GetNumTickets() uint32 {
 	return m.NumTickets
 }
 
+// collectLatency tracks the time taken by mempoolDataCollector.Collect.
+var collectLatency = metrics.NewSummaryVec("lddldata_mempool_collect_duration_seconds",
+	"Latency in seconds of mempool data collection.")
+
 type mempoolDataCollector struct {
 	mtx          sync.Mutex
 	lddldChainSvr *rpcclient.Client
@@ -327,6 +348,7 @@ func (t *mempoolDataCollector) Collect() (*MempoolData, error) {
 	defer func(start time.Time) {
 		log.Debugf("mempoolDataCollector.Collect() completed in %v",
 			time.Since(start))
+		collectLatency.ObserveSince(start)
 	}(time.Now())
 
 	// client
@@ -0,0 +1,49 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+var (
+	httpRequests = NewCounterVec("lddldata_http_requests_total",
+		"Number of HTTP requests by route, method and status code.",
+		"route", "method", "code")
+	httpLatency = NewSummaryVec("lddldata_http_request_duration_seconds",
+		"HTTP request latency in seconds by route and method.",
+		"route", "method")
+)
+
+// HTTPMiddleware records the latency and status code of each request by the
+// chi route pattern that handled it, such as "/api/block/{idx}/size". Requests
+// not matching any route are recorded with the route "NotFound". The
+// middleware should be used by the top level router so that the complete
+// pattern of mounted sub-routers is known.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "NotFound"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		httpLatency.ObserveSince(start, route, r.Method)
+		httpRequests.Inc(route, r.Method, strconv.Itoa(status))
+	})
+}
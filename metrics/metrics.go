@@ -0,0 +1,264 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+// Package metrics collects lddldata's operational metrics and serves them in
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric types used in the TYPE line of the exposition format.
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+	typeSummary = "summary"
+)
+
+// metric is implemented by all registered metrics.
+type metric interface {
+	// write outputs the metric's samples, without the HELP and TYPE lines.
+	write(w *bufio.Writer, name string)
+}
+
+// registration is a named metric in the registry.
+type registration struct {
+	name, help, typ string
+	m               metric
+}
+
+// registry holds all registered metrics by name.
+var registry = struct {
+	sync.RWMutex
+	metrics map[string]*registration
+}{
+	metrics: make(map[string]*registration),
+}
+
+// register adds the metric to the registry, replacing any metric already
+// registered with the same name.
+func register(name, help, typ string, m metric) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.metrics[name] = &registration{name, help, typ, m}
+}
+
+// Unregister removes the named metric from the registry.
+func Unregister(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.metrics, name)
+}
+
+// labelKeySep separates label values in the map keys of the vector metrics.
+const labelKeySep = "\xff"
+
+// formatLabels formats the label names and values as {name="value",...}.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i := range names {
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		pairs[i] = names[i] + "=" + strconv.Quote(v)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat formats a sample value.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// funcMetric is a gauge or counter with a value obtained from a function when
+// the metrics are collected.
+type funcMetric struct {
+	fn func() float64
+}
+
+func (f *funcMetric) write(w *bufio.Writer, name string) {
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(f.fn()))
+}
+
+// NewGaugeFunc registers a gauge with a value obtained by calling fn each time
+// the metrics are collected.
+func NewGaugeFunc(name, help string, fn func() float64) {
+	register(name, help, typeGauge, &funcMetric{fn})
+}
+
+// NewCounterFunc registers a counter with a value obtained by calling fn each
+// time the metrics are collected. fn must return a non-decreasing value.
+func NewCounterFunc(name, help string, fn func() float64) {
+	register(name, help, typeCounter, &funcMetric{fn})
+}
+
+// CounterVec is a set of counters partitioned by label values.
+type CounterVec struct {
+	mtx    sync.Mutex
+	labels []string
+	counts map[string]float64
+}
+
+// NewCounterVec creates and registers a CounterVec with the given label names.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		labels: labels,
+		counts: make(map[string]float64),
+	}
+	register(name, help, typeCounter, c)
+	return c
+}
+
+// Inc increments the counter with the given label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter with the given label
+// values.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if c == nil || v < 0 {
+		return
+	}
+	key := strings.Join(labelValues, labelKeySep)
+	c.mtx.Lock()
+	c.counts[key] += v
+	c.mtx.Unlock()
+}
+
+func (c *CounterVec) write(w *bufio.Writer, name string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, key := range sortedKeys(c.counts) {
+		fmt.Fprintf(w, "%s%s %s\n", name,
+			formatLabels(c.labels, strings.Split(key, labelKeySep)),
+			formatFloat(c.counts[key]))
+	}
+}
+
+// summary is the observation count and sum for one set of label values.
+type summary struct {
+	count uint64
+	sum   float64
+}
+
+// SummaryVec is a set of summaries, partitioned by label values, that track the
+// count and sum of observations such as latencies in seconds.
+type SummaryVec struct {
+	mtx       sync.Mutex
+	labels    []string
+	summaries map[string]*summary
+}
+
+// NewSummaryVec creates and registers a SummaryVec with the given label names.
+func NewSummaryVec(name, help string, labels ...string) *SummaryVec {
+	s := &SummaryVec{
+		labels:    labels,
+		summaries: make(map[string]*summary),
+	}
+	register(name, help, typeSummary, s)
+	return s
+}
+
+// Observe records an observation with the given label values.
+func (s *SummaryVec) Observe(v float64, labelValues ...string) {
+	if s == nil {
+		return
+	}
+	key := strings.Join(labelValues, labelKeySep)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	sm, ok := s.summaries[key]
+	if !ok {
+		sm = new(summary)
+		s.summaries[key] = sm
+	}
+	sm.count++
+	sm.sum += v
+}
+
+// ObserveSince records the time elapsed since start, in seconds, with the given
+// label values. It is convenient with defer:
+//
+//	defer latency.ObserveSince(time.Now(), "getblock")
+func (s *SummaryVec) ObserveSince(start time.Time, labelValues ...string) {
+	s.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (s *SummaryVec) write(w *bufio.Writer, name string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, key := range sortedKeys(s.summaries) {
+		labels := formatLabels(s.labels, strings.Split(key, labelKeySep))
+		sm := s.summaries[key]
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatFloat(sm.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, labels, sm.count)
+	}
+}
+
+// sortedKeys returns the keys of a label value map in sorted order.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch mm := m.(type) {
+	case map[string]float64:
+		for k := range mm {
+			keys = append(keys, k)
+		}
+	case map[string]*summary:
+		for k := range mm {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteMetrics writes all registered metrics, sorted by name, to w in the
+// Prometheus text exposition format.
+func WriteMetrics(w *bufio.Writer) error {
+	registry.RLock()
+	regs := make([]*registration, 0, len(registry.metrics))
+	for _, reg := range registry.metrics {
+		regs = append(regs, reg)
+	}
+	registry.RUnlock()
+
+	sort.Slice(regs, func(i, j int) bool { return regs[i].name < regs[j].name })
+
+	for _, reg := range regs {
+		fmt.Fprintf(w, "# HELP %s %s\n", reg.name, reg.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", reg.name, reg.typ)
+		reg.m.write(w, reg.name)
+	}
+	return w.Flush()
+}
+
+// Handler serves the registered metrics in the Prometheus text exposition
+// format.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := WriteMetrics(bufio.NewWriter(w)); err != nil {
+		log.Debugf("Failed to write metrics: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	c := NewCounterVec("test_requests_total", "Test requests.", "code")
+	defer Unregister("test_requests_total")
+	c.Inc("200")
+	c.Inc("200")
+	c.Add(3, "404")
+	c.Add(-1, "404") // ignored
+
+	s := NewSummaryVec("test_latency_seconds", "Test latency.", "method")
+	defer Unregister("test_latency_seconds")
+	s.Observe(0.5, "getblock")
+	s.Observe(1.5, "getblock")
+
+	NewGaugeFunc("test_height", "Test height.", func() float64 { return 42 })
+	defer Unregister("test_height")
+
+	var buf bytes.Buffer
+	if err := WriteMetrics(bufio.NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"# TYPE test_height gauge\ntest_height 42\n",
+		"# HELP test_latency_seconds Test latency.\n# TYPE test_latency_seconds summary\n" +
+			"test_latency_seconds_sum{method=\"getblock\"} 2\n" +
+			"test_latency_seconds_count{method=\"getblock\"} 2\n",
+		"# TYPE test_requests_total counter\n" +
+			"test_requests_total{code=\"200\"} 2\n" +
+			"test_requests_total{code=\"404\"} 3\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("output missing %q:\n%s", e, out)
+		}
+	}
+
+	// Metrics are sorted by name.
+	if strings.Index(out, "test_height") > strings.Index(out, "test_requests_total") {
+		t.Errorf("metrics not sorted by name:\n%s", out)
+	}
+}
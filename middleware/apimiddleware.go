@@ -5,11 +5,9 @@
 package middleware
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"strconv"
@@ -18,14 +16,12 @@ import (
 	"github.com/Legenddigital/lddld/lddljson"
 	apitypes "github.com/Legenddigital/lddldata/api/types"
 	"github.com/go-chi/chi"
-	"github.com/go-chi/docgen"
 )
 
 type contextKey int
 
 const (
-	ctxAPIDocs contextKey = iota
-	ctxAPIStatus
+	ctxAPIStatus contextKey = iota
 	CtxAddress
 	ctxBlockIndex0
 	ctxBlockIndex
@@ -44,6 +40,8 @@ const (
 	ctxStakeVersionLatest
 	ctxRawHexTx
 	ctxM
+	ctxAgendaID
+	ctxChartName
 )
 
 type DataSource interface {
@@ -206,6 +204,28 @@ func GetAddressCtx(r *http.Request) string {
 	return address
 }
 
+// GetAgendaIDCtx retrieves the ctxAgendaID data from the request context. If
+// not set, the return value is an empty string.
+func GetAgendaIDCtx(r *http.Request) string {
+	id, ok := r.Context().Value(ctxAgendaID).(string)
+	if !ok {
+		apiLog.Trace("agenda id not set")
+		return ""
+	}
+	return id
+}
+
+// GetChartNameCtx retrieves the ctxChartName data from the request context.
+// If not set, the return value is an empty string.
+func GetChartNameCtx(r *http.Request) string {
+	name, ok := r.Context().Value(ctxChartName).(string)
+	if !ok {
+		apiLog.Trace("chart name not set")
+		return ""
+	}
+	return name
+}
+
 // GetCountCtx retrieves the ctxCount data ("to") URL path element from the
 // request context. If not set, the return value is 20. TODO: rename this
 // function.
@@ -416,18 +436,24 @@ func AddressPathCtx(next http.Handler) http.Handler {
 	})
 }
 
-// apiDocs generates a middleware with a "docs" in the context containing a map
-// of the routers handlers, etc.
-func apiDocs(mux *chi.Mux) func(next http.Handler) http.Handler {
-	var buf bytes.Buffer
-	json.Indent(&buf, []byte(docgen.JSONRoutesDoc(mux)), "", "\t")
-	docs := buf.String()
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := context.WithValue(r.Context(), ctxAPIDocs, docs)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
-	}
+// AgendaPathCtx returns a http.HandlerFunc that embeds the value at the url
+// part {agendaid} into the request context.
+func AgendaPathCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "agendaid")
+		ctx := context.WithValue(r.Context(), ctxAgendaID, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ChartNamePathCtx returns a http.HandlerFunc that embeds the value at the url
+// part {chartname} into the request context.
+func ChartNamePathCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "chartname")
+		ctx := context.WithValue(r.Context(), ctxChartName, name)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
 // SearchPathCtx returns a http.HandlerFunc that embeds the value at the url part
@@ -441,13 +467,6 @@ func SearchPathCtx(next http.Handler) http.Handler {
 	})
 }
 
-// APIDirectory is the actual handler used with apiDocs
-// (e.g. mux.With(apiDocs(mux)).HandleFunc("/help", APIDirectory))
-func APIDirectory(w http.ResponseWriter, r *http.Request) {
-	docs := r.Context().Value(ctxAPIDocs).(string)
-	io.WriteString(w, docs)
-}
-
 // TransactionsCtx returns a http.Handlerfunc that embeds the {address,
 // blockhash} value in the request into the request context.
 func TransactionsCtx(next http.Handler) http.Handler {
@@ -0,0 +1,255 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/didip/tollbooth"
+	"github.com/didip/tollbooth/limiter"
+)
+
+// APIKeyHeader is the HTTP request header used to present an API key. The key
+// may alternatively be given in the "apikey" URL query parameter.
+const APIKeyHeader = "X-API-Key"
+
+// rateLimitBucketTTL is how long an idle client's token bucket is kept.
+const rateLimitBucketTTL = time.Hour
+
+// RateLimit specifies a sustained request rate, in requests per second, and the
+// number of requests that may be made in a burst.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RouteRateLimit is a RateLimit applied to a group of routes. Pattern is a URL
+// path whose segments may be "*" wildcards matching any single segment. A
+// request path matches when its leading segments match all of the pattern's
+// segments, so "/api/address/*/raw" matches "/api/address/{address}/raw" and
+// any path below it.
+type RouteRateLimit struct {
+	Pattern string
+	RateLimit
+}
+
+// ParseRouteRateLimit parses a route group rate limit specified as
+// "pattern:rate[:burst]".
+func ParseRouteRateLimit(spec string) (RouteRateLimit, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 || !strings.HasPrefix(parts[0], "/") {
+		return RouteRateLimit{}, fmt.Errorf("invalid route rate limit %q, "+
+			"expected /path:rate[:burst]", spec)
+	}
+	rl, err := parseRateLimit(parts[1:])
+	if err != nil {
+		return RouteRateLimit{}, fmt.Errorf("invalid route rate limit %q: %v", spec, err)
+	}
+	return RouteRateLimit{Pattern: parts[0], RateLimit: rl}, nil
+}
+
+// ParseAPIKeyRateLimit parses an API key rate limit specified as
+// "key:rate[:burst]".
+func ParseAPIKeyRateLimit(spec string) (string, RateLimit, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return "", RateLimit{}, fmt.Errorf("invalid API key rate limit, expected key:rate[:burst]")
+	}
+	rl, err := parseRateLimit(parts[1:])
+	if err != nil {
+		return "", RateLimit{}, fmt.Errorf("invalid API key rate limit: %v", err)
+	}
+	return parts[0], rl, nil
+}
+
+// parseRateLimit parses a rate and optional burst. When not specified, the
+// burst is the rate rounded up, with a minimum of 1.
+func parseRateLimit(parts []string) (RateLimit, error) {
+	rate, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || rate <= 0 {
+		return RateLimit{}, fmt.Errorf("rate must be a positive number")
+	}
+	burst := int(math.Max(1, math.Ceil(rate)))
+	if len(parts) > 1 {
+		burst, err = strconv.Atoi(parts[1])
+		if err != nil || burst < 1 {
+			return RateLimit{}, fmt.Errorf("burst must be a positive integer")
+		}
+	}
+	return RateLimit{Rate: rate, Burst: burst}, nil
+}
+
+// routeLimiter is the token bucket limiter for a route group.
+type routeLimiter struct {
+	pattern []string
+	limit   RateLimit
+	limiter *limiter.Limiter
+}
+
+// RateLimiter throttles clients with token buckets. Clients presenting a known
+// API key share the key's bucket, regardless of their IP address. All other
+// clients are identified by IP address, and are limited by the first route
+// group matching the request path, or the default limit if none match. When
+// the RealIP middleware is in use, it must run before the RateLimiter so that
+// the client's real IP is used.
+type RateLimiter struct {
+	defaultLimit   RateLimit
+	defaultLimiter *limiter.Limiter
+	routes         []routeLimiter
+	apiKeyLimits   map[string]RateLimit
+	apiKeyLimiters map[string]*limiter.Limiter
+	htmlPage       []byte
+}
+
+// newLimiter creates a tollbooth limiter for the given limit, with expiring
+// token buckets.
+func newLimiter(rl RateLimit) *limiter.Limiter {
+	return tollbooth.NewLimiter(rl.Rate, &limiter.ExpirableOptions{
+		DefaultExpirationTTL: rateLimitBucketTTL,
+	}).SetBurst(rl.Burst)
+}
+
+// NewRateLimiter creates a RateLimiter. A zero Rate for defaultLimit disables
+// limiting of requests not matching any route group. The route groups are
+// checked in order. htmlPage, if not empty, is served to clients that accept
+// text/html when they are limited.
+func NewRateLimiter(defaultLimit RateLimit, routes []RouteRateLimit,
+	apiKeys map[string]RateLimit, htmlPage []byte) *RateLimiter {
+	rl := &RateLimiter{
+		defaultLimit: defaultLimit,
+		apiKeyLimits: apiKeys,
+		htmlPage:     htmlPage,
+	}
+	if defaultLimit.Rate > 0 {
+		if rl.defaultLimit.Burst < 1 {
+			rl.defaultLimit.Burst = 1
+		}
+		rl.defaultLimiter = newLimiter(rl.defaultLimit)
+	}
+	for _, r := range routes {
+		rl.routes = append(rl.routes, routeLimiter{
+			pattern: splitPath(r.Pattern),
+			limit:   r.RateLimit,
+			limiter: newLimiter(r.RateLimit),
+		})
+	}
+	// The bucket parameters vary by key, so each key has its own limiter.
+	rl.apiKeyLimiters = make(map[string]*limiter.Limiter, len(apiKeys))
+	for key, keyLimit := range apiKeys {
+		rl.apiKeyLimiters[key] = newLimiter(keyLimit)
+	}
+	return rl
+}
+
+// Enabled indicates if any limits are configured.
+func (rl *RateLimiter) Enabled() bool {
+	return rl != nil && (rl.defaultLimiter != nil || len(rl.routes) > 0 ||
+		len(rl.apiKeyLimiters) > 0)
+}
+
+// splitPath splits a URL path into its non-empty segments.
+func splitPath(p string) []string {
+	var segs []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+// matches checks if the path segments match the route group's pattern.
+func (r *routeLimiter) matches(segs []string) bool {
+	if len(segs) < len(r.pattern) {
+		return false
+	}
+	for i, p := range r.pattern {
+		if p != "*" && p != segs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// clientIP gets the host part of the request's RemoteAddr, which is the real IP
+// of the client if the RealIP middleware has been used.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// GetAPIKey gets the API key from the request header or URL query.
+func GetAPIKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("apikey")
+}
+
+// limitReached checks the bucket for the request's client, returning the
+// applicable RateLimit and true if the client has exceeded it.
+func (rl *RateLimiter) limitReached(r *http.Request) (RateLimit, bool) {
+	if key := GetAPIKey(r); key != "" {
+		if keyLimiter, ok := rl.apiKeyLimiters[key]; ok {
+			return rl.apiKeyLimits[key], keyLimiter.LimitReached(key)
+		}
+	}
+
+	ip := clientIP(r)
+	segs := splitPath(r.URL.Path)
+	for i := range rl.routes {
+		route := &rl.routes[i]
+		if route.matches(segs) {
+			return route.limit, route.limiter.LimitReached(ip)
+		}
+	}
+
+	if rl.defaultLimiter == nil {
+		return RateLimit{}, false
+	}
+	return rl.defaultLimit, rl.defaultLimiter.LimitReached(ip)
+}
+
+// Middleware rejects requests with status 429 (Too Many Requests) when the
+// client has exceeded its rate limit. The Retry-After header indicates when a
+// new token will be available. Clients that accept text/html are served the
+// rate limiting HTML page.
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	if !rl.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, reached := rl.limitReached(r)
+		if !reached {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		retryAfter := int(math.Ceil(1 / limit.Rate))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		apiLog.Debugf("Rate limit (%.2f/s) reached by %s for %s.", limit.Rate,
+			clientIP(r), r.URL.Path)
+
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		if len(rl.htmlPage) > 0 && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write(rl.htmlPage)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Too many requests. Retry in %d seconds.", retryAfter),
+			http.StatusTooManyRequests)
+	})
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func doRequest(h http.Handler, remoteAddr, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimiterTooManyRequests(t *testing.T) {
+	rl := NewRateLimiter(RateLimit{Rate: 0.5, Burst: 2}, nil, nil, nil)
+	h := rl.Middleware(okHandler)
+
+	for i := 0; i < 2; i++ {
+		if rec := doRequest(h, "10.0.0.1:1234", "/api/block/best", nil); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within burst: got status %d, expected %d",
+				i, rec.Code, http.StatusOK)
+		}
+	}
+
+	rec := doRequest(h, "10.0.0.1:1234", "/api/block/best", nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over burst: got status %d, expected %d",
+			rec.Code, http.StatusTooManyRequests)
+	}
+	// One token every 2 seconds.
+	if retryAfter := rec.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("got Retry-After %q, expected \"2\"", retryAfter)
+	}
+}
+
+func TestRateLimiterHTMLPage(t *testing.T) {
+	page := []byte("<html>slow down</html>")
+	rl := NewRateLimiter(RateLimit{Rate: 1, Burst: 1}, nil, nil, page)
+	h := rl.Middleware(okHandler)
+
+	accept := http.Header{"Accept": []string{"text/html,application/xhtml+xml"}}
+	doRequest(h, "10.0.0.1:1234", "/blocks", accept)
+	rec := doRequest(h, "10.0.0.1:1234", "/blocks", accept)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, expected %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("got Content-Type %q, expected text/html", rec.Header().Get("Content-Type"))
+	}
+	if rec.Body.String() != string(page) {
+		t.Errorf("got body %q, expected the rate limiting page", rec.Body.String())
+	}
+}
+
+func TestRateLimiterPerClient(t *testing.T) {
+	rl := NewRateLimiter(RateLimit{Rate: 1, Burst: 1}, nil, nil, nil)
+	h := rl.Middleware(okHandler)
+
+	if rec := doRequest(h, "10.0.0.1:1234", "/api/status", nil); rec.Code != http.StatusOK {
+		t.Fatalf("first client: got status %d, expected %d", rec.Code, http.StatusOK)
+	}
+	if rec := doRequest(h, "10.0.0.1:5678", "/api/status", nil); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("first client, other port: got status %d, expected %d",
+			rec.Code, http.StatusTooManyRequests)
+	}
+	// A different IP has its own bucket.
+	if rec := doRequest(h, "10.0.0.2:1234", "/api/status", nil); rec.Code != http.StatusOK {
+		t.Fatalf("second client: got status %d, expected %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimiterRoutesAndAPIKeys(t *testing.T) {
+	routes := []RouteRateLimit{{
+		Pattern:   "/api/address/*/raw",
+		RateLimit: RateLimit{Rate: 1, Burst: 1},
+	}}
+	apiKeys := map[string]RateLimit{"key1": {Rate: 1, Burst: 3}}
+	rl := NewRateLimiter(RateLimit{}, routes, apiKeys, nil)
+	h := rl.Middleware(okHandler)
+
+	// Paths outside the route group are not limited without a default limit.
+	for i := 0; i < 5; i++ {
+		if rec := doRequest(h, "10.0.0.1:1234", "/api/block/best", nil); rec.Code != http.StatusOK {
+			t.Fatalf("unlimited path: got status %d, expected %d", rec.Code, http.StatusOK)
+		}
+	}
+
+	path := "/api/address/Dsaddr/raw/10"
+	if rec := doRequest(h, "10.0.0.1:1234", path, nil); rec.Code != http.StatusOK {
+		t.Fatalf("route group: got status %d, expected %d", rec.Code, http.StatusOK)
+	}
+	if rec := doRequest(h, "10.0.0.1:1234", path, nil); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("route group over burst: got status %d, expected %d",
+			rec.Code, http.StatusTooManyRequests)
+	}
+
+	// The API key's bucket is shared by all clients presenting the key.
+	header := http.Header{APIKeyHeader: []string{"key1"}}
+	for i, addr := range []string{"10.0.0.1:1234", "10.0.0.2:1234", "10.0.0.3:1234"} {
+		if rec := doRequest(h, addr, path, header); rec.Code != http.StatusOK {
+			t.Fatalf("API key request %d: got status %d, expected %d",
+				i, rec.Code, http.StatusOK)
+		}
+	}
+	if rec := doRequest(h, "10.0.0.4:1234", path+"?apikey=key1", nil); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("API key over burst: got status %d, expected %d",
+			rec.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestParseRouteRateLimit(t *testing.T) {
+	rrl, err := ParseRouteRateLimit("/api/address/*/raw:0.5:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rrl.Pattern != "/api/address/*/raw" || rrl.Rate != 0.5 || rrl.Burst != 2 {
+		t.Errorf("unexpected route rate limit %+v", rrl)
+	}
+
+	rrl, err = ParseRouteRateLimit("/api/tx:2.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rrl.Burst != 3 {
+		t.Errorf("got default burst %d, expected 3", rrl.Burst)
+	}
+
+	for _, spec := range []string{"api:1", "/api", "/api:0", "/api:1:0", "/api:x"} {
+		if _, err = ParseRouteRateLimit(spec); err == nil {
+			t.Errorf("expected an error parsing %q", spec)
+		}
+	}
+}
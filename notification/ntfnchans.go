@@ -6,10 +6,12 @@ package notification
 
 import (
 	"github.com/Legenddigital/lddld/chaincfg/chainhash"
+	"github.com/Legenddigital/lddld/lddljson"
 	"github.com/Legenddigital/lddld/lddlutil"
 
 	"github.com/Legenddigital/lddldata/api/insight"
 	"github.com/Legenddigital/lddldata/blockdata"
+	"github.com/Legenddigital/lddldata/db/lddlpg"
 	"github.com/Legenddigital/lddldata/db/lddlsqlite"
 	"github.com/Legenddigital/lddldata/explorer"
 	"github.com/Legenddigital/lddldata/mempool"
@@ -28,9 +30,13 @@ const (
 	// expNewTxChanBuffer is the size of the new transaction buffer for explorer
 	expNewTxChanBuffer = 70
 
+	// streamReorgChanBuffer is the size of the reorg channel buffer for the
+	// stream API, which does not hold up reorg handling.
+	streamReorgChanBuffer = 4
+
 	// relevantMempoolTxChanBuffer is the size of the new transaction channel
 	// buffer, for relevant transactions that are added into mempool.
-	//relevantMempoolTxChanBuffer = 2048
+	relevantMempoolTxChanBuffer = 2048
 )
 
 // NtfnChans collects the chain server notification channels
@@ -41,6 +47,8 @@ var NtfnChans struct {
 	ReorgChanWiredDB                  chan *lddlsqlite.ReorgData
 	ConnectChanStakeDB                chan *chainhash.Hash
 	ReorgChanStakeDB                  chan *stakedb.ReorgData
+	ConnectChanDBPG                   chan *chainhash.Hash
+	ReorgChanDBPG                     chan *lddlpg.ReorgData
 	UpdateStatusNodeHeight            chan uint32
 	UpdateStatusDBHeight              chan uint32
 	SpendTxBlockChan, RecvTxBlockChan chan *txhelpers.BlockWatchedTx
@@ -48,10 +56,12 @@ var NtfnChans struct {
 	NewTxChan                         chan *mempool.NewTx
 	ExpNewTxChan                      chan *explorer.NewMempoolTx
 	InsightNewTxChan                  chan *insight.NewTx
+	StreamNewTxChan                   chan *lddljson.TxRawResult
+	ReorgChanStream                   chan *blockdata.ReorgData
 }
 
 // MakeNtfnChans create notification channels based on config
-func MakeNtfnChans(monitorMempool, postgresEnabled bool) {
+func MakeNtfnChans(monitorMempool, postgresEnabled, watchEnabled bool) {
 	// If we're monitoring for blocks OR collecting block data, these channels
 	// are necessary to handle new block notifications. Otherwise, leave them
 	// as nil so that both a send (below) blocks and a receive (in
@@ -70,17 +80,23 @@ func MakeNtfnChans(monitorMempool, postgresEnabled bool) {
 	NtfnChans.ReorgChanWiredDB = make(chan *lddlsqlite.ReorgData)
 	NtfnChans.ReorgChanStakeDB = make(chan *stakedb.ReorgData)
 
+	// ChainDB (PostgreSQL) channels for handling reorganizations
+	if postgresEnabled {
+		NtfnChans.ConnectChanDBPG = make(chan *chainhash.Hash, blockConnChanBuffer)
+		NtfnChans.ReorgChanDBPG = make(chan *lddlpg.ReorgData)
+	}
+
 	// To update app status
 	NtfnChans.UpdateStatusNodeHeight = make(chan uint32, blockConnChanBuffer)
 	NtfnChans.UpdateStatusDBHeight = make(chan uint32, blockConnChanBuffer)
 
 	// watchaddress
-	// if len(cfg.WatchAddresses) > 0 {
-	// // recv/SpendTxBlockChan come with connected blocks
-	// 	NtfnChans.RecvTxBlockChan = make(chan *txhelpers.BlockWatchedTx, blockConnChanBuffer)
-	// 	NtfnChans.SpendTxBlockChan = make(chan *txhelpers.BlockWatchedTx, blockConnChanBuffer)
-	// 	NtfnChans.RelevantTxMempoolChan = make(chan *lddlutil.Tx, relevantMempoolTxChanBuffer)
-	// }
+	if watchEnabled {
+		// recv/SpendTxBlockChan come with connected blocks
+		NtfnChans.RecvTxBlockChan = make(chan *txhelpers.BlockWatchedTx, blockConnChanBuffer)
+		NtfnChans.SpendTxBlockChan = make(chan *txhelpers.BlockWatchedTx, blockConnChanBuffer)
+		NtfnChans.RelevantTxMempoolChan = make(chan *lddlutil.Tx, relevantMempoolTxChanBuffer)
+	}
 
 	if monitorMempool {
 		NtfnChans.NewTxChan = make(chan *mempool.NewTx, newTxChanBuffer)
@@ -92,6 +108,10 @@ func MakeNtfnChans(monitorMempool, postgresEnabled bool) {
 	if postgresEnabled {
 		NtfnChans.InsightNewTxChan = make(chan *insight.NewTx, expNewTxChanBuffer)
 	}
+
+	// New mempool tx and reorg chans for the stream API
+	NtfnChans.StreamNewTxChan = make(chan *lddljson.TxRawResult, expNewTxChanBuffer)
+	NtfnChans.ReorgChanStream = make(chan *blockdata.ReorgData, streamReorgChanBuffer)
 }
 
 // CloseNtfnChans close all notification channels
@@ -105,6 +125,9 @@ func CloseNtfnChans() {
 	if NtfnChans.ConnectChanStakeDB != nil {
 		close(NtfnChans.ConnectChanStakeDB)
 	}
+	if NtfnChans.ConnectChanDBPG != nil {
+		close(NtfnChans.ConnectChanDBPG)
+	}
 
 	if NtfnChans.ReorgChanBlockData != nil {
 		close(NtfnChans.ReorgChanBlockData)
@@ -115,6 +138,9 @@ func CloseNtfnChans() {
 	if NtfnChans.ReorgChanStakeDB != nil {
 		close(NtfnChans.ReorgChanStakeDB)
 	}
+	if NtfnChans.ReorgChanDBPG != nil {
+		close(NtfnChans.ReorgChanDBPG)
+	}
 
 	if NtfnChans.UpdateStatusNodeHeight != nil {
 		close(NtfnChans.UpdateStatusNodeHeight)
@@ -144,4 +170,11 @@ func CloseNtfnChans() {
 	if NtfnChans.InsightNewTxChan != nil {
 		close(NtfnChans.InsightNewTxChan)
 	}
+
+	if NtfnChans.StreamNewTxChan != nil {
+		close(NtfnChans.StreamNewTxChan)
+	}
+	if NtfnChans.ReorgChanStream != nil {
+		close(NtfnChans.ReorgChanStream)
+	}
 }
@@ -16,6 +16,7 @@ import (
 	"github.com/Legenddigital/lddld/wire"
 	"github.com/Legenddigital/lddldata/api/insight"
 	"github.com/Legenddigital/lddldata/blockdata"
+	"github.com/Legenddigital/lddldata/db/lddlpg"
 	"github.com/Legenddigital/lddldata/db/lddlsqlite"
 	"github.com/Legenddigital/lddldata/explorer"
 	"github.com/Legenddigital/lddldata/mempool"
@@ -47,14 +48,8 @@ func RegisterNodeNtfnHandlers(lddldClient *rpcclient.Client) *ContextualError {
 			"notification registration failed", err)
 	}
 
-	// Register a Tx filter for addresses (receiving).  The filter applies to
-	// OnRelevantTxAccepted.
-	// TODO: register outpoints (third argument).
-	// if len(addresses) > 0 {
-	// 	if err = lddldClient.LoadTxFilter(true, addresses, nil); err != nil {
-	// 		return newContextualError("load tx filter failed", err)
-	// 	}
-	// }
+	// The Tx filter for watched addresses, which applies to
+	// OnRelevantTxAccepted, is loaded by the address watcher.
 
 	return nil
 }
@@ -158,6 +153,19 @@ func MakeNodeNtfnHandlers() (*rpcclient.NotificationHandlers, *collectionQueue)
 		},
 		OnReorganization: func(oldHash *chainhash.Hash, oldHeight int32,
 			newHash *chainhash.Hash, newHeight int32) {
+			// Notify stream API clients without waiting for them. The blocks
+			// of the new chain follow.
+			select {
+			case NtfnChans.ReorgChanStream <- &blockdata.ReorgData{
+				OldChainHead:   *oldHash,
+				OldChainHeight: oldHeight,
+				NewChainHead:   *newHash,
+				NewChainHeight: newHeight,
+			}:
+			default:
+				log.Warn("ReorgChanStream buffer full!")
+			}
+
 			wg := new(sync.WaitGroup)
 			// Send reorg data to lddlsqlite's monitor
 			wg.Add(1)
@@ -187,6 +195,20 @@ func MakeNodeNtfnHandlers() (*rpcclient.NotificationHandlers, *collectionQueue)
 				wg.Done()
 			}
 
+			// Send reorg data to ChainDB's monitor (nil channel in lite mode)
+			wg.Add(1)
+			select {
+			case NtfnChans.ReorgChanDBPG <- &lddlpg.ReorgData{
+				OldChainHead:   *oldHash,
+				OldChainHeight: oldHeight,
+				NewChainHead:   *newHash,
+				NewChainHeight: newHeight,
+				WG:             wg,
+			}:
+			default:
+				wg.Done()
+			}
+
 			// Send reorg data to stakedb's monitor
 			wg.Add(1)
 			select {
@@ -232,6 +254,9 @@ func MakeNodeNtfnHandlers() (*rpcclient.NotificationHandlers, *collectionQueue)
 				log.Debugf("Detected transaction %v in mempool containing registered address.",
 					txHash.String())
 			default:
+				if NtfnChans.RelevantTxMempoolChan != nil {
+					log.Warn("RelevantTxMempoolChan buffer full!")
+				}
 			}
 		},
 
@@ -260,6 +285,12 @@ func MakeNodeNtfnHandlers() (*rpcclient.NotificationHandlers, *collectionQueue)
 				}
 			}
 
+			select {
+			case NtfnChans.StreamNewTxChan <- txDetails:
+			default:
+				log.Warn("StreamNewTxChan buffer full!")
+			}
+
 			hash, _ := chainhash.NewHashFromStr(txDetails.Txid)
 			select {
 			case NtfnChans.NewTxChan <- &mempool.NewTx{
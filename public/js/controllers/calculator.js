@@ -0,0 +1,48 @@
+(() => {
+
+    function coins(v) {
+        return v.toFixed(2)
+    }
+
+    app.register("calculator", class extends Stimulus.Controller {
+        static get targets() {
+            return [ "form", "error", "summary", "periods" ]
+        }
+
+        connect() {
+            this.calculate()
+        }
+
+        calculate(e) {
+            if (e) e.preventDefault()
+            var query = $(this.formTarget).serializeArray().filter((f) => f.value !== "")
+            $.getJSON("/api/stake/calculator?" + $.param(query)).then((calc) => {
+                $(this.errorTarget).text("")
+                this.render(calc)
+            }, (xhr) => {
+                $(this.errorTarget).text(xhr.responseText || "Unable to calculate the stake return")
+            })
+        }
+
+        render(calc) {
+            $(this.summaryTarget).html(
+                `<div>Annual stake return: <span class="mono fs18">${calc.asr.toFixed(2)}%</span></div>` +
+                `<div>Return over ${calc.inputs.days} days: <span class="mono">${calc.return.toFixed(2)}%</span>, ` +
+                `final balance <span class="mono">${coins(calc.final_balance)}</span> LDDL</div>` +
+                `<div class="fs13">A ticket votes with a probability of ${(calc.vote_probability * 100).toFixed(2)}%, ` +
+                `on average ${Math.round(calc.mean_vote_blocks)} blocks after it matures. ` +
+                `Each period is ${calc.period_blocks} blocks.</div>`)
+            $(this.periodsTarget).html((calc.periods || []).map((p) => {
+                return `<tr><td>${p.period}</td>` +
+                    `<td>${p.purchase_height}</td>` +
+                    `<td>${p.vote_height}</td>` +
+                    `<td class="mono text-right">${coins(p.ticket_price)}</td>` +
+                    `<td class="mono text-right">${calc.inputs.integer_tickets ? p.tickets : p.tickets.toFixed(2)}</td>` +
+                    `<td class="mono text-right">${coins(p.staked)}</td>` +
+                    `<td class="mono text-right">${coins(p.reward)}</td>` +
+                    `<td class="mono text-right">${coins(p.vsp_fee)}</td>` +
+                    `<td class="mono text-right">${coins(p.balance)}</td></tr>`
+            }).join(""))
+        }
+    })
+})()
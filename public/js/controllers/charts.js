@@ -0,0 +1,307 @@
+(() => {
+
+    const colors = ["#2970ff", "#2ed6a1", "#fd714a", "#8997a5"]
+    const padding = { top: 10, right: 60, bottom: 25, left: 60 }
+
+    function formatX(x, axis) {
+        if (axis === "time") {
+            return new Date(x * 1000).toISOString().substr(0, 10)
+        }
+        return String(x)
+    }
+
+    function formatY(y) {
+        var abs = Math.abs(y)
+        if (abs >= 1e12) return (y / 1e12).toFixed(1) + "T"
+        if (abs >= 1e9) return (y / 1e9).toFixed(1) + "G"
+        if (abs >= 1e6) return (y / 1e6).toFixed(1) + "M"
+        if (abs >= 1e3) return (y / 1e3).toFixed(1) + "k"
+        if (abs > 0 && abs < 1) return y.toFixed(3)
+        return y.toFixed(2)
+    }
+
+    // LineChart draws the series of one or more charts of the API on a
+    // canvas, with a left and an optional right axis. Dragging across the
+    // chart zooms in, and double clicking zooms out.
+    class LineChart {
+        constructor(canvas, legend, series) {
+            this.canvas = canvas
+            this.legend = legend
+            this.series = series
+            this.x = []
+            this.axis = "height"
+            this.values = []
+            this.view = null
+            this.hover = null
+            this.dragStart = null
+            this.dragEnd = null
+
+            canvas.addEventListener("mousedown", (e) => {
+                this.dragStart = this.dragEnd = this.offsetX(e)
+            })
+            canvas.addEventListener("mousemove", (e) => {
+                var px = this.offsetX(e)
+                if (this.dragStart !== null) {
+                    this.dragEnd = px
+                }
+                this.hover = this.indexAt(px)
+                this.draw()
+            })
+            canvas.addEventListener("mouseleave", () => {
+                this.hover = null
+                this.dragStart = null
+                this.draw()
+            })
+            canvas.addEventListener("mouseup", () => {
+                if (this.dragStart !== null && Math.abs(this.dragEnd - this.dragStart) > 5) {
+                    var i0 = this.indexAt(Math.min(this.dragStart, this.dragEnd))
+                    var i1 = this.indexAt(Math.max(this.dragStart, this.dragEnd))
+                    if (i1 > i0) {
+                        this.view = [this.x[i0], this.x[i1]]
+                    }
+                }
+                this.dragStart = null
+                this.draw()
+            })
+            canvas.addEventListener("dblclick", () => {
+                this.resetZoom()
+                this.draw()
+            })
+        }
+
+        resetZoom() {
+            this.view = null
+        }
+
+        setData(charts) {
+            var first = charts[this.series[0].chart]
+            this.x = first.x || []
+            this.axis = first.axis
+            this.values = this.series.map((s) => {
+                var found = _.find(charts[s.chart].series, { name: s.series })
+                return found ? found.values : []
+            })
+            this.draw()
+        }
+
+        offsetX(e) {
+            var rect = this.canvas.getBoundingClientRect()
+            return e.clientX - rect.left
+        }
+
+        // range returns the indexes of the first and last points in view.
+        range() {
+            var i0 = 0, i1 = this.x.length - 1
+            if (this.view) {
+                i0 = _.sortedIndex(this.x, this.view[0])
+                i1 = Math.min(_.sortedLastIndex(this.x, this.view[1]) - 1, i1)
+            }
+            return [i0, i1]
+        }
+
+        indexAt(px) {
+            var [i0, i1] = this.range()
+            var width = this.canvas.clientWidth - padding.left - padding.right
+            if (i1 <= i0 || width <= 0) return i0
+            var f = (px - padding.left) / width
+            f = Math.min(Math.max(f, 0), 1)
+            return i0 + Math.round(f * (i1 - i0))
+        }
+
+        draw() {
+            var canvas = this.canvas
+            var ratio = window.devicePixelRatio || 1
+            var cssWidth = canvas.clientWidth
+            var cssHeight = canvas.clientHeight
+            canvas.width = cssWidth * ratio
+            canvas.height = cssHeight * ratio
+            var ctx = canvas.getContext("2d")
+            ctx.setTransform(ratio, 0, 0, ratio, 0, 0)
+            ctx.clearRect(0, 0, cssWidth, cssHeight)
+
+            var textColor = window.getComputedStyle(canvas).color
+            ctx.font = "11px sans-serif"
+            ctx.fillStyle = textColor
+            ctx.strokeStyle = textColor
+
+            var [i0, i1] = this.range()
+            if (this.x.length === 0 || i1 < i0) {
+                ctx.fillText("No data", padding.left, padding.top + 20)
+                this.renderLegend(null)
+                return
+            }
+
+            var width = cssWidth - padding.left - padding.right
+            var height = cssHeight - padding.top - padding.bottom
+            var xPixel = (i) => padding.left + (i1 > i0 ? (i - i0) / (i1 - i0) * width : width / 2)
+
+            // The value range of each axis.
+            var bounds = { left: [Infinity, -Infinity], right: [Infinity, -Infinity] }
+            this.series.forEach((s, k) => {
+                var b = bounds[s.axis || "left"]
+                for (var i = i0; i <= i1; i++) {
+                    var v = this.values[k][i]
+                    if (v < b[0]) b[0] = v
+                    if (v > b[1]) b[1] = v
+                }
+            })
+            _.each(bounds, (b) => {
+                if (b[0] > 0 && b[0] > (b[1] - b[0])) {
+                    b[0] = b[0] - (b[1] - b[0]) * 0.1
+                } else if (b[0] > 0) {
+                    b[0] = 0
+                }
+                if (b[1] <= b[0]) b[1] = b[0] + 1
+            })
+            var yPixel = (v, axis) => {
+                var b = bounds[axis || "left"]
+                return padding.top + height - (v - b[0]) / (b[1] - b[0]) * height
+            }
+
+            // Axes, grid lines and labels.
+            ctx.globalAlpha = 0.2
+            ctx.beginPath()
+            for (var t = 0; t <= 4; t++) {
+                var y = padding.top + t * height / 4
+                ctx.moveTo(padding.left, y)
+                ctx.lineTo(padding.left + width, y)
+            }
+            ctx.stroke()
+            ctx.globalAlpha = 1
+            var hasRight = _.some(this.series, { axis: "right" })
+            for (var t = 0; t <= 4; t++) {
+                var y = padding.top + t * height / 4
+                var f = 1 - t / 4
+                ctx.textAlign = "right"
+                ctx.fillText(formatY(bounds.left[0] + f * (bounds.left[1] - bounds.left[0])), padding.left - 5, y + 4)
+                if (hasRight) {
+                    ctx.textAlign = "left"
+                    ctx.fillText(formatY(bounds.right[0] + f * (bounds.right[1] - bounds.right[0])), padding.left + width + 5, y + 4)
+                }
+            }
+            ctx.textAlign = "center"
+            var ticks = Math.min(4, i1 - i0)
+            for (var t = 0; t <= ticks; t++) {
+                var i = ticks ? i0 + Math.round(t * (i1 - i0) / ticks) : i0
+                ctx.fillText(formatX(this.x[i], this.axis), xPixel(i), padding.top + height + 16)
+            }
+
+            // The lines, drawn with the minimum and maximum value of each
+            // pixel column when there are more points than pixels.
+            this.series.forEach((s, k) => {
+                var values = this.values[k]
+                ctx.strokeStyle = colors[k % colors.length]
+                ctx.lineWidth = 1.5
+                ctx.beginPath()
+                var column = null, min, max, last
+                for (var i = i0; i <= i1; i++) {
+                    var px = Math.round(xPixel(i))
+                    var v = values[i]
+                    if (px !== column) {
+                        if (column !== null) {
+                            ctx.lineTo(column, yPixel(min, s.axis))
+                            ctx.lineTo(column, yPixel(max, s.axis))
+                            ctx.lineTo(column, yPixel(last, s.axis))
+                        } else {
+                            ctx.moveTo(px, yPixel(v, s.axis))
+                        }
+                        column = px
+                        min = max = v
+                    }
+                    min = Math.min(min, v)
+                    max = Math.max(max, v)
+                    last = v
+                }
+                ctx.lineTo(column, yPixel(min, s.axis))
+                ctx.lineTo(column, yPixel(max, s.axis))
+                ctx.lineTo(column, yPixel(last, s.axis))
+                ctx.stroke()
+            })
+
+            // The hovered point and the zoom selection.
+            ctx.strokeStyle = textColor
+            ctx.lineWidth = 1
+            if (this.hover !== null && this.hover >= i0 && this.hover <= i1) {
+                ctx.globalAlpha = 0.5
+                ctx.beginPath()
+                ctx.moveTo(xPixel(this.hover), padding.top)
+                ctx.lineTo(xPixel(this.hover), padding.top + height)
+                ctx.stroke()
+                ctx.globalAlpha = 1
+            }
+            if (this.dragStart !== null && this.dragEnd !== this.dragStart) {
+                ctx.globalAlpha = 0.15
+                ctx.fillRect(Math.min(this.dragStart, this.dragEnd), padding.top,
+                    Math.abs(this.dragEnd - this.dragStart), height)
+                ctx.globalAlpha = 1
+            }
+
+            this.renderLegend(this.hover !== null ? this.hover : i1)
+        }
+
+        renderLegend(i) {
+            if (!this.legend) return
+            var html = this.series.map((s, k) => {
+                var value = i === null ? "" : ": " + formatY(this.values[k][i])
+                return `<span class="pr-3"><span class="chart-swatch" style="background: ${colors[k % colors.length]}"></span>${s.label}${value}</span>`
+            }).join("")
+            if (i !== null) {
+                html += `<span class="mono">${this.axis === "time" ? "" : "block "}${formatX(this.x[i], this.axis)}</span>`
+            }
+            $(this.legend).html(html)
+        }
+    }
+
+    app.register("charts", class extends Stimulus.Controller {
+        static get targets() {
+            return [ "bin", "chart", "legend" ]
+        }
+
+        connect() {
+            this.bin = "day"
+            this.charts = this.chartTargets.map((el, i) => {
+                return new LineChart(el, this.legendTargets[i], JSON.parse(el.dataset.series))
+            })
+            this.load()
+
+            // Reload the charts with each new block.
+            this.onNewBlock = () => this.load()
+            ws.registerEvtHandler("newblock", this.onNewBlock)
+            this.onResize = _.debounce(() => {
+                this.charts.forEach((chart) => chart.draw())
+            }, 200)
+            window.addEventListener("resize", this.onResize)
+        }
+
+        disconnect() {
+            ws.deregisterEvtHandlers("newblock", this.onNewBlock)
+            window.removeEventListener("resize", this.onResize)
+        }
+
+        setBin(e) {
+            this.bin = e.target.dataset.bin
+            this.binTargets.forEach((el) => {
+                $(el).toggleClass("active", el === e.target)
+            })
+            this.charts.forEach((chart) => chart.resetZoom())
+            this.load()
+        }
+
+        load() {
+            var bin = this.bin
+            var names = _.uniq(_.flatten(this.charts.map((chart) => {
+                return chart.series.map((s) => s.chart)
+            })))
+            Promise.all(names.map((name) => {
+                return $.getJSON(`/api/chart/${name}?bin=${bin}`)
+            })).then((results) => {
+                // Ignore the responses of a previous bin.
+                if (bin !== this.bin) return
+                var data = _.zipObject(names, results)
+                this.charts.forEach((chart) => chart.setData(data))
+            }, (err) => {
+                console.log("Failed to load the charts", err)
+            })
+        }
+    })
+})()
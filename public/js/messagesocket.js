@@ -29,7 +29,14 @@ var MessageSocket = function(uri) {
     handlers[eventID].push(handler);
     return this;
   };
-  this.deregisterEvtHandlers = function(eventID, handlerName) {
+  // deregister all handlers of an event, or only the given handler
+  this.deregisterEvtHandlers = function(eventID, handler) {
+    if (typeof handler == "function") {
+      handlers[eventID] = (handlers[eventID] || []).filter(function(h) {
+        return h !== handler;
+      });
+      return this;
+    }
     handlers[eventID] = []
     return this;
   };
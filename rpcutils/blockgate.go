@@ -6,6 +6,7 @@ package rpcutils
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/Legenddigital/lddld/chaincfg/chainhash"
 	"github.com/Legenddigital/lddld/lddlutil"
@@ -31,6 +32,7 @@ type MasterBlockGetter interface {
 	UpdateToBestBlock() (*lddlutil.Block, error)
 	UpdateToNextBlock() (*lddlutil.Block, error)
 	UpdateToBlock(height int64) (*lddlutil.Block, error)
+	PrefetchBlock(height int64) error
 }
 
 // BlockGate is an implementation of MasterBlockGetter with cache
@@ -44,6 +46,10 @@ type BlockGate struct {
 	heightWaiters map[int64][]chan chainhash.Hash
 	hashWaiters   map[chainhash.Hash][]chan int64
 	expireQueue   heightHashQueue
+
+	// Blocks retrieved ahead of UpdateToBlock by PrefetchBlock.
+	prefetchMtx sync.Mutex
+	prefetched  map[int64]*lddlutil.Block
 }
 
 type heightHashQueue struct {
@@ -75,7 +81,7 @@ func heightInQueue(q heightHashQueue, height int64) bool {
 }
 
 // ensure BlockGate satisfies BlockGetter
-var _ BlockGetter = (*BlockGate)(nil)
+var _ MasterBlockGetter = (*BlockGate)(nil)
 
 // NewBlockGate constructs a new BlockGate, wrapping an RPC client, with a
 // specified block cache capacity.
@@ -88,6 +94,7 @@ func NewBlockGate(client *rpcclient.Client, capacity int) *BlockGate {
 		blockWithHash: make(map[chainhash.Hash]*lddlutil.Block),
 		heightWaiters: make(map[int64][]chan chainhash.Hash),
 		hashWaiters:   make(map[chainhash.Hash][]chan int64),
+		prefetched:    make(map[int64]*lddlutil.Block),
 		expireQueue: heightHashQueue{
 			cap: capacity,
 		},
@@ -105,6 +112,7 @@ func (g *BlockGate) SetFetchToHeight(height int64) {
 
 // NodeHeight gets the chain height from lddld.
 func (g *BlockGate) NodeHeight() (int64, error) {
+	defer observeRPC("getbestblock", time.Now())
 	_, height, err := g.client.GetBestBlock()
 	return height, err
 }
@@ -158,7 +166,9 @@ func (g *BlockGate) Block(hash chainhash.Hash) (*lddlutil.Block, error) {
 
 // UpdateToBestBlock gets the best block via RPC and updates the cache.
 func (g *BlockGate) UpdateToBestBlock() (*lddlutil.Block, error) {
+	start := time.Now()
 	_, height, err := g.client.GetBestBlock()
+	observeRPC("getbestblock", start)
 	if err != nil {
 		return nil, fmt.Errorf("GetBestBlockHash failed: %v", err)
 	}
@@ -183,10 +193,54 @@ func (g *BlockGate) UpdateToBlock(height int64) (*lddlutil.Block, error) {
 	return g.updateToBlock(height)
 }
 
-func (g *BlockGate) updateToBlock(height int64) (*lddlutil.Block, error) {
-	block, hash, err := GetBlock(height, g.client)
+// PrefetchBlock gets the block at the specified height on the main chain from
+// lddld and holds it for the following UpdateToBlock call for the height,
+// without changing the best block or signaling waiters. PrefetchBlock may be
+// called concurrently, allowing several blocks to be fetched ahead of a
+// consumer that requires them in order.
+func (g *BlockGate) PrefetchBlock(height int64) error {
+	block, _, err := GetBlock(height, g.client)
 	if err != nil {
-		return nil, fmt.Errorf("GetBlock (%d) failed: %v", height, err)
+		return fmt.Errorf("GetBlock (%d) failed: %v", height, err)
+	}
+
+	g.prefetchMtx.Lock()
+	g.prefetched[height] = block
+	g.prefetchMtx.Unlock()
+	return nil
+}
+
+// takePrefetched removes and returns the prefetched block at the specified
+// height, if there is one and it extends the cached block at the previous
+// height. A block that does not extend it was orphaned by a reorganization
+// after it was fetched. The caller must hold the BlockGate lock.
+func (g *BlockGate) takePrefetched(height int64) *lddlutil.Block {
+	g.prefetchMtx.Lock()
+	block, ok := g.prefetched[height]
+	delete(g.prefetched, height)
+	g.prefetchMtx.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if prevHash, ok := g.hashAtHeight[height-1]; ok &&
+		block.MsgBlock().Header.PrevBlock != prevHash {
+		return nil
+	}
+	return block
+}
+
+func (g *BlockGate) updateToBlock(height int64) (*lddlutil.Block, error) {
+	block := g.takePrefetched(height)
+	var hash *chainhash.Hash
+	if block != nil {
+		hash = block.Hash()
+	} else {
+		var err error
+		block, hash, err = GetBlock(height, g.client)
+		if err != nil {
+			return nil, fmt.Errorf("GetBlock (%d) failed: %v", height, err)
+		}
 	}
 
 	g.height = height
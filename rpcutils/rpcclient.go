@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strconv"
+	"time"
 
 	"github.com/Legenddigital/lddld/chaincfg"
 	"github.com/Legenddigital/lddld/chaincfg/chainhash"
@@ -16,12 +17,22 @@ import (
 	"github.com/Legenddigital/lddld/rpcclient"
 	"github.com/Legenddigital/lddld/wire"
 	apitypes "github.com/Legenddigital/lddldata/api/types"
+	"github.com/Legenddigital/lddldata/metrics"
 	"github.com/Legenddigital/lddldata/semver"
 	"github.com/Legenddigital/lddldata/txhelpers"
 )
 
 var requiredChainServerAPI = semver.NewSemver(3, 0, 0)
 
+// rpcLatency tracks the latency of the node RPCs made by this package.
+var rpcLatency = metrics.NewSummaryVec("lddldata_rpc_call_duration_seconds",
+	"Latency in seconds of lddld RPC calls by method.", "method")
+
+// observeRPC records the latency of an RPC started at the given time.
+func observeRPC(method string, start time.Time) {
+	rpcLatency.ObserveSince(start, method)
+}
+
 // ConnectNodeRPC attempts to create a new websocket connection to a lddld node,
 // with the given credentials and optional notification handlers.
 func ConnectNodeRPC(host, user, pass, cert string, disableTLS bool,
@@ -130,13 +141,17 @@ func BuildBlockHeaderVerbose(header *wire.BlockHeader, params *chaincfg.Params,
 // block index specified by idx via an RPC connection to a chain server.
 func GetBlockHeaderVerbose(client *rpcclient.Client, params *chaincfg.Params,
 	idx int64) *lddljson.GetBlockHeaderVerboseResult {
+	start := time.Now()
 	blockhash, err := client.GetBlockHash(idx)
+	observeRPC("getblockhash", start)
 	if err != nil {
 		log.Errorf("GetBlockHash(%d) failed: %v", idx, err)
 		return nil
 	}
 
+	start = time.Now()
 	blockHeaderVerbose, err := client.GetBlockHeaderVerbose(blockhash)
+	observeRPC("getblockheader", start)
 	if err != nil {
 		log.Errorf("GetBlockHeaderVerbose(%v) failed: %v", blockhash, err)
 		return nil
@@ -149,13 +164,17 @@ func GetBlockHeaderVerbose(client *rpcclient.Client, params *chaincfg.Params,
 // specified by idx via an RPC connection to a chain server.
 func GetBlockVerbose(client *rpcclient.Client, params *chaincfg.Params,
 	idx int64, verboseTx bool) *lddljson.GetBlockVerboseResult {
+	start := time.Now()
 	blockhash, err := client.GetBlockHash(idx)
+	observeRPC("getblockhash", start)
 	if err != nil {
 		log.Errorf("GetBlockHash(%d) failed: %v", idx, err)
 		return nil
 	}
 
+	start = time.Now()
 	blockVerbose, err := client.GetBlockVerbose(blockhash, verboseTx)
+	observeRPC("getblock", start)
 	if err != nil {
 		log.Errorf("GetBlockVerbose(%v) failed: %v", blockhash, err)
 		return nil
@@ -174,7 +193,9 @@ func GetBlockVerboseByHash(client *rpcclient.Client, params *chaincfg.Params,
 		return nil
 	}
 
+	start := time.Now()
 	blockVerbose, err := client.GetBlockVerbose(blockhash, verboseTx)
+	observeRPC("getblock", start)
 	if err != nil {
 		log.Errorf("GetBlockVerbose(%v) failed: %v", blockhash, err)
 		return nil
@@ -186,11 +207,15 @@ func GetBlockVerboseByHash(client *rpcclient.Client, params *chaincfg.Params,
 // GetStakeDiffEstimates combines the results of EstimateStakeDiff and
 // GetStakeDifficulty into a *apitypes.StakeDiff.
 func GetStakeDiffEstimates(client *rpcclient.Client) *apitypes.StakeDiff {
+	start := time.Now()
 	stakeDiff, err := client.GetStakeDifficulty()
+	observeRPC("getstakedifficulty", start)
 	if err != nil {
 		return nil
 	}
+	start = time.Now()
 	estStakeDiff, err := client.EstimateStakeDiff(nil)
+	observeRPC("estimatestakediff", start)
 	if err != nil {
 		return nil
 	}
@@ -206,12 +231,16 @@ func GetStakeDiffEstimates(client *rpcclient.Client) *apitypes.StakeDiff {
 
 // GetBlock gets a block at the given height from a chain server.
 func GetBlock(ind int64, client *rpcclient.Client) (*lddlutil.Block, *chainhash.Hash, error) {
+	start := time.Now()
 	blockhash, err := client.GetBlockHash(ind)
+	observeRPC("getblockhash", start)
 	if err != nil {
 		return nil, nil, fmt.Errorf("GetBlockHash(%d) failed: %v", ind, err)
 	}
 
+	start = time.Now()
 	msgBlock, err := client.GetBlock(blockhash)
+	observeRPC("getblock", start)
 	if err != nil {
 		return nil, blockhash,
 			fmt.Errorf("GetBlock failed (%s): %v", blockhash, err)
@@ -223,7 +252,9 @@ func GetBlock(ind int64, client *rpcclient.Client) (*lddlutil.Block, *chainhash.
 
 // GetBlockByHash gets the block with the given hash from a chain server.
 func GetBlockByHash(blockhash *chainhash.Hash, client *rpcclient.Client) (*lddlutil.Block, error) {
+	start := time.Now()
 	msgBlock, err := client.GetBlock(blockhash)
+	observeRPC("getblock", start)
 	if err != nil {
 		return nil, fmt.Errorf("GetBlock failed (%s): %v", blockhash, err)
 	}
@@ -240,7 +271,9 @@ func GetTransactionVerboseByID(client *rpcclient.Client, txid string) (*lddljson
 		return nil, err
 	}
 
+	start := time.Now()
 	txraw, err := client.GetRawTransactionVerbose(txhash)
+	observeRPC("getrawtransaction", start)
 	if err != nil {
 		log.Errorf("GetRawTransactionVerbose failed for: %v", txhash)
 		return nil, err
@@ -257,8 +290,10 @@ func SearchRawTransaction(client *rpcclient.Client, count int, address string) (
 		return nil, err
 	}
 	//change the 1000 000 number demo for now
+	start := time.Now()
 	txs, err := client.SearchRawTransactionsVerbose(addr, 0, count,
 		true, true, nil)
+	observeRPC("searchrawtransactions", start)
 	if err != nil {
 		log.Warnf("SearchRawTransaction failed for address %s: %v", addr, err)
 	}
@@ -0,0 +1,184 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+// Package stakecalc simulates the purchase of tickets and the reinvestment of
+// the vote rewards over a period of time, to estimate the return of staking.
+package stakecalc
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Legenddigital/lddld/blockchain"
+	"github.com/Legenddigital/lddld/chaincfg"
+	"github.com/Legenddigital/lddld/lddlutil"
+)
+
+// Inputs are the parameters of a staking simulation. Amounts are in coins.
+type Inputs struct {
+	// Height is the block at which the first tickets are purchased.
+	Height int64 `json:"height"`
+	// Balance is the starting balance.
+	Balance float64 `json:"balance"`
+	// TicketPrice is the ticket price at Height.
+	TicketPrice float64 `json:"ticket_price"`
+	// PriceChange is the change of the ticket price in percent per year.
+	PriceChange float64 `json:"price_change"`
+	// PoolSize is the size of the live ticket pool, which sets the time
+	// until a ticket votes.
+	PoolSize int64 `json:"pool_size"`
+	// VSPFee is the fee of a voting service provider in percent of the vote
+	// reward.
+	VSPFee float64 `json:"vsp_fee"`
+	// Compound reinvests the rewards in tickets. Otherwise only the starting
+	// balance is staked.
+	Compound bool `json:"compound"`
+	// IntegerTickets limits the purchases to whole tickets. Otherwise the
+	// whole balance is staked, as with a stake pool share.
+	IntegerTickets bool `json:"integer_tickets"`
+	// Days is the duration of the simulation.
+	Days int64 `json:"days"`
+}
+
+// Period is the purchase of tickets and their vote. The reward is the expected
+// reward of the tickets, net of the VSP fee.
+type Period struct {
+	Period          int     `json:"period"`
+	PurchaseHeight  int64   `json:"purchase_height"`
+	VoteHeight      int64   `json:"vote_height"`
+	SpendableHeight int64   `json:"spendable_height"`
+	TicketPrice     float64 `json:"ticket_price"`
+	Tickets         float64 `json:"tickets"`
+	Staked          float64 `json:"staked"`
+	Reward          float64 `json:"reward"`
+	VSPFee          float64 `json:"vsp_fee"`
+	Balance         float64 `json:"balance"`
+}
+
+// Calculation is the schedule of the periods of a staking simulation and its
+// return. Return is in percent over the simulated blocks, and ASR is the
+// annualized return.
+type Calculation struct {
+	Inputs          Inputs    `json:"inputs"`
+	VoteProbability float64   `json:"vote_probability"`
+	MeanVoteBlocks  float64   `json:"mean_vote_blocks"`
+	PeriodBlocks    int64     `json:"period_blocks"`
+	Periods         []*Period `json:"periods"`
+	FinalBalance    float64   `json:"final_balance"`
+	TotalReward     float64   `json:"total_reward"`
+	Return          float64   `json:"return"`
+	ASR             float64   `json:"asr"`
+}
+
+// Validate checks that the inputs can be simulated.
+func (in *Inputs) Validate() error {
+	switch {
+	case in.Height < 0:
+		return fmt.Errorf("invalid height %d", in.Height)
+	case !(in.Balance > 0):
+		return fmt.Errorf("balance must be positive")
+	case !(in.TicketPrice > 0):
+		return fmt.Errorf("ticket price must be positive")
+	case !(in.PriceChange > -100):
+		return fmt.Errorf("price change must be more than -100%%")
+	case in.PoolSize < 1:
+		return fmt.Errorf("pool size must be positive")
+	case !(in.VSPFee >= 0 && in.VSPFee <= 100):
+		return fmt.Errorf("VSP fee must be from 0 to 100%%")
+	case in.Days < 1:
+		return fmt.Errorf("days must be positive")
+	}
+	return nil
+}
+
+// VoteOdds returns the probability that a ticket votes before it expires
+// with a live pool of the given size, and the mean number of blocks from
+// its maturity to its vote.
+func VoteOdds(params *chaincfg.Params, poolSize int64) (float64, float64) {
+	p := math.Min(float64(params.TicketsPerBlock)/float64(poolSize), 1)
+	notCalled := 1.0 // the probability that the ticket was not called yet
+	var mean float64
+	for i := 1; i <= int(params.TicketExpiry); i++ {
+		mean += float64(i) * p * notCalled
+		notCalled *= 1 - p
+	}
+	voted := 1 - notCalled
+	if voted == 0 {
+		return 0, 0
+	}
+	return voted, mean / voted
+}
+
+// Calculate simulates the staking of the inputs with the stake subsidy of the
+// chain parameters. Each period buys tickets at the ticket price of its
+// purchase height, and the staked coins and the rewards are spendable again
+// after the mean vote and the coinbase maturity. Tickets that expire return
+// their price without a reward.
+func Calculate(params *chaincfg.Params, in Inputs) (*Calculation, error) {
+	if err := in.Validate(); err != nil {
+		return nil, err
+	}
+
+	voted, meanVote := VoteOdds(params, in.PoolSize)
+	voteBlocks := int64(params.TicketMaturity) + int64(meanVote+0.5)
+	periodBlocks := voteBlocks + int64(params.CoinbaseMaturity) + 1
+	blocksPerYear := float64(365*24*time.Hour) / float64(params.TargetTimePerBlock)
+	end := in.Height + int64(float64(in.Days)*blocksPerYear/365)
+
+	calc := &Calculation{
+		Inputs:          in,
+		VoteProbability: voted,
+		MeanVoteBlocks:  meanVote,
+		PeriodBlocks:    periodBlocks,
+	}
+
+	subsidyCache := blockchain.NewSubsidyCache(in.Height, params)
+	balance := in.Balance
+	height := in.Height
+	for height < end {
+		years := float64(height-in.Height) / blocksPerYear
+		price := in.TicketPrice * math.Pow(1+in.PriceChange/100, years)
+
+		funds := balance
+		if !in.Compound {
+			funds = math.Min(balance, in.Balance)
+		}
+		tickets := funds / price
+		if in.IntegerTickets {
+			tickets = math.Floor(tickets)
+		}
+		if tickets == 0 {
+			break
+		}
+
+		voteHeight := height + voteBlocks
+		voteSubsidy := lddlutil.Amount(blockchain.CalcStakeVoteSubsidy(subsidyCache,
+			voteHeight, params)).ToCoin()
+		reward := tickets * voteSubsidy * voted
+		fee := reward * in.VSPFee / 100
+		balance += reward - fee
+
+		calc.Periods = append(calc.Periods, &Period{
+			Period:          len(calc.Periods) + 1,
+			PurchaseHeight:  height,
+			VoteHeight:      voteHeight,
+			SpendableHeight: height + periodBlocks,
+			TicketPrice:     price,
+			Tickets:         tickets,
+			Staked:          tickets * price,
+			Reward:          reward - fee,
+			VSPFee:          fee,
+			Balance:         balance,
+		})
+		calc.TotalReward += reward - fee
+		height += periodBlocks
+	}
+
+	calc.FinalBalance = balance
+	calc.Return = calc.TotalReward / in.Balance * 100
+	if height > in.Height {
+		calc.ASR = calc.Return * blocksPerYear / float64(height-in.Height)
+	}
+	return calc, nil
+}
@@ -0,0 +1,87 @@
+package stakecalc
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Legenddigital/lddld/chaincfg"
+)
+
+func TestVoteOdds(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	voted, mean := VoteOdds(params, int64(params.TicketsPerBlock))
+	if voted != 1 || mean != 1 {
+		t.Errorf("expected a vote in the first block, got %v %v", voted, mean)
+	}
+
+	// A pool at its target size.
+	voted, mean = VoteOdds(params, int64(params.TicketPoolSize)*int64(params.TicketsPerBlock))
+	if voted < 0.99 || voted >= 1 || mean < 7000 || mean > float64(params.TicketPoolSize) {
+		t.Errorf("unexpected vote odds %v %v", voted, mean)
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	in := Inputs{
+		Height:      250000,
+		Balance:     1000,
+		TicketPrice: 100,
+		PoolSize:    40960,
+		Compound:    true,
+		Days:        365,
+	}
+	calc, err := Calculate(params, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calc.Periods) == 0 || calc.TotalReward <= 0 || calc.ASR <= 0 {
+		t.Fatalf("unexpected calculation %+v", calc)
+	}
+	for i, p := range calc.Periods {
+		if p.PurchaseHeight != in.Height+int64(i)*calc.PeriodBlocks {
+			t.Errorf("unexpected purchase height of period %d: %d", p.Period, p.PurchaseHeight)
+		}
+	}
+	last := calc.Periods[len(calc.Periods)-1]
+	if math.Abs(last.Balance-calc.FinalBalance) > 1e-9 {
+		t.Errorf("final balance %v differs from the last period %v", calc.FinalBalance, last.Balance)
+	}
+
+	// Without compounding only the starting balance earns rewards.
+	in.Compound = false
+	simple, err := Calculate(params, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if simple.TotalReward > calc.TotalReward {
+		t.Errorf("simple reward %v exceeds compound reward %v", simple.TotalReward, calc.TotalReward)
+	}
+
+	// The VSP takes all of the reward.
+	in.VSPFee = 100
+	calc, err = Calculate(params, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calc.TotalReward != 0 || calc.FinalBalance != in.Balance {
+		t.Errorf("expected no reward, got %v", calc.TotalReward)
+	}
+
+	// The balance is too small for a whole ticket.
+	in.VSPFee = 0
+	in.IntegerTickets = true
+	in.Balance = 50
+	calc, err = Calculate(params, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calc.Periods) != 0 || calc.ASR != 0 {
+		t.Errorf("expected no periods, got %d", len(calc.Periods))
+	}
+
+	in.Balance = 0
+	if _, err = Calculate(params, in); err == nil {
+		t.Error("expected an error for a zero balance")
+	}
+}
@@ -67,6 +67,13 @@ func (c *PoolInfoCache) Set(hash chainhash.Hash, p *apitypes.TicketPoolInfo) {
 	}
 }
 
+// Size returns the number of elements in the cache.
+func (c *PoolInfoCache) Size() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.poolInfo)
+}
+
 // SetCapacity sets the cache capacity to the specified number of elements. If
 // the new capacity is smaller than the current cache size, elements are
 // automatically evicted until the desired size is reached.
@@ -816,6 +823,34 @@ func (db *StakeDatabase) expires() ([]chainhash.Hash, []bool) {
 	return expires, spent
 }
 
+// LiveTicketExpiries returns the height of the best block, and the number of
+// live tickets that will expire at each of the next TicketExpiry heights if
+// they are not called to vote first.
+func (db *StakeDatabase) LiveTicketExpiries() (int64, []int) {
+	db.nodeMtx.RLock()
+	defer db.nodeMtx.RUnlock()
+	height := int64(db.BestNode.Height())
+	expiry := int64(db.params.TicketExpiry)
+
+	// A ticket expires TicketExpiry blocks after entering the live pool.
+	matured := db.PoolDB.MaturedTickets(expiry)
+	expiries := make([]int, expiry)
+	for _, hash := range db.BestNode.LiveTickets() {
+		maturityHeight, ok := matured[hash]
+		if !ok {
+			log.Debugf("Live ticket %v not found in the pool diffs.", hash)
+			continue
+		}
+		// The pool diffs should be at the same height as BestNode.
+		i := maturityHeight + expiry - height - 1
+		if i < 0 || i >= expiry {
+			continue
+		}
+		expiries[i]++
+	}
+	return height, expiries
+}
+
 // PoolInfoBest computes ticket pool value using the database and, if needed, the
 // node RPC client to fetch ticket values that are not cached. Returned are a
 // structure including ticket pool value, size, and average value.
@@ -906,6 +941,12 @@ func (db *StakeDatabase) PoolInfo(hash chainhash.Hash) (*apitypes.TicketPoolInfo
 	return db.poolInfo.Get(hash)
 }
 
+// PoolInfoCacheSize returns the number of blocks with ticket pool info in the
+// PoolInfoCache.
+func (db *StakeDatabase) PoolInfoCacheSize() int {
+	return db.poolInfo.Size()
+}
+
 // PoolSize returns the ticket pool size in the best node of the stake database
 func (db *StakeDatabase) PoolSize() int {
 	db.nodeMtx.Lock()
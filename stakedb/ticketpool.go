@@ -418,6 +418,20 @@ func (tp *TicketPool) CurrentPoolSize() int {
 	return len(tp.pool)
 }
 
+// MaturedTickets maps the tickets that entered the live pool at each of the
+// last n heights, up to the tip, to the height at which they entered.
+func (tp *TicketPool) MaturedTickets(n int64) map[chainhash.Hash]int64 {
+	tp.RLock()
+	defer tp.RUnlock()
+	matured := make(map[chainhash.Hash]int64)
+	for height := tp.tip; height > tp.tip-n && height > 0; height-- {
+		for _, hash := range tp.diffs[height-1].In {
+			matured[hash] = height
+		}
+	}
+	return matured
+}
+
 // Pool attempts to get the tickets in the live pool at the specified height. It
 // will advance/retreat the cursor as needed to reach the desired height, and
 // then extract the tickets from the resulting pool map.
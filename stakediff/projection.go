@@ -0,0 +1,339 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+// Package stakediff projects the ticket price of the coming stake difficulty
+// windows by simulating the ticket pool under a given ticket purchase rate.
+package stakediff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Legenddigital/lddld/chaincfg"
+	"github.com/Legenddigital/lddld/lddlutil"
+)
+
+// statsWindows is the number of complete windows from which the recent
+// purchase rate and its standard deviation are computed.
+const statsWindows = 8
+
+// bandZ is the number of standard deviations of the purchase rate that the
+// confidence bands of a scenario span, for a 95% interval.
+const bandZ = 1.96
+
+// ErrNoData is returned by Project when it is given no blocks.
+var ErrNoData = errors.New("no blocks to project from")
+
+// Block is the stake data of a main chain block that a projection starts
+// from.
+type Block struct {
+	Height int64
+	// FreshStake is the number of tickets purchased in the block.
+	FreshStake int64
+	// PoolSize is the size of the live ticket pool after the block.
+	PoolSize int64
+	// StakeDiff is the ticket price of the block in atoms.
+	StakeDiff int64
+}
+
+// Window is the projected ticket price of a stake difficulty window. Prices
+// are in coins.
+type Window struct {
+	Window      int64   `json:"window"`
+	StartHeight int64   `json:"start_height"`
+	Price       float64 `json:"price"`
+	PriceLow    float64 `json:"price_low"`
+	PriceHigh   float64 `json:"price_high"`
+	PoolSize    int64   `json:"pool_size"`
+}
+
+// Scenario is the projection for a constant ticket purchase rate, in tickets
+// per block.
+type Scenario struct {
+	Rate    float64   `json:"rate"`
+	Windows []*Window `json:"windows"`
+}
+
+// Projection is the state of the chain that the scenarios are projected
+// from, and the scenarios.
+type Projection struct {
+	Height          int64       `json:"height"`
+	WindowSize      int64       `json:"window_size"`
+	CurrentWindow   int64       `json:"current_window"`
+	CurrentPrice    float64     `json:"current_price"`
+	PoolSize        int64       `json:"pool_size"`
+	ImmatureTickets int64       `json:"immature_tickets"`
+	MempoolTickets  int64       `json:"mempool_tickets"`
+	RecentRate      float64     `json:"recent_rate"`
+	RateStdDev      float64     `json:"rate_std_dev"`
+	Scenarios       []*Scenario `json:"scenarios"`
+}
+
+// HistorySize is the number of blocks, ending at the best block, that
+// Project needs to compute the recent purchase rates and the first retarget.
+func HistorySize(params *chaincfg.Params) int64 {
+	return (statsWindows+1)*params.StakeDiffWindowSize + int64(params.TicketMaturity)
+}
+
+// Project simulates the ticket pool after the last of the given consecutive
+// blocks for each of the purchase rates, and computes the ticket price of the
+// next windows with the stake difficulty algorithm of DCP-0001. The tickets in
+// mempool are purchased first, and the purchases of a block are limited to
+// MaxFreshStakePerBlock. Tickets that are not mined by the end of a window are
+// dropped. The pool loses TicketsPerBlock tickets each block, so misses and
+// expirations are not accounted for. If no rates are given, the projection
+// includes no purchases, the recent rate and the maximum rate. The price
+// bands of each scenario are the prices for the rate plus and minus 1.96
+// standard deviations of the purchase rate of the recent windows.
+func Project(params *chaincfg.Params, blocks []Block, mempoolTickets int64,
+	windows int, rates []float64) (*Projection, error) {
+	if len(blocks) == 0 {
+		return nil, ErrNoData
+	}
+	for i := 1; i < len(blocks); i++ {
+		if blocks[i].Height != blocks[i-1].Height+1 {
+			return nil, fmt.Errorf("block %d does not follow block %d",
+				blocks[i].Height, blocks[i-1].Height)
+		}
+	}
+
+	sim := newSimulator(params, blocks, mempoolTickets)
+	best := blocks[len(blocks)-1]
+	winSize := params.StakeDiffWindowSize
+	rate, stdDev := sim.recentRate()
+
+	p := &Projection{
+		Height:          best.Height,
+		WindowSize:      winSize,
+		CurrentWindow:   best.Height / winSize,
+		CurrentPrice:    lddlutil.Amount(best.StakeDiff).ToCoin(),
+		PoolSize:        best.PoolSize,
+		ImmatureTickets: int64(sim.immature(best.Height)),
+		MempoolTickets:  mempoolTickets,
+		RecentRate:      rate,
+		RateStdDev:      stdDev,
+	}
+
+	if len(rates) == 0 {
+		rates = []float64{0, rate, float64(params.MaxFreshStakePerBlock)}
+	}
+	maxRate := float64(params.MaxFreshStakePerBlock)
+	for _, r := range rates {
+		r = math.Min(math.Max(r, 0), maxRate)
+		prices, pools := sim.run(r, windows)
+		low, _ := sim.run(math.Max(r-bandZ*stdDev, 0), windows)
+		high, _ := sim.run(math.Min(r+bandZ*stdDev, maxRate), windows)
+
+		scenario := &Scenario{Rate: r}
+		firstWindow := best.Height/winSize + 1
+		for i := range prices {
+			// Fewer purchases usually lower the price, but the restorative
+			// force of the algorithm can reverse that near the target.
+			lo := minInt64(prices[i], minInt64(low[i], high[i]))
+			hi := maxInt64(prices[i], maxInt64(low[i], high[i]))
+			scenario.Windows = append(scenario.Windows, &Window{
+				Window:      firstWindow + int64(i),
+				StartHeight: (firstWindow + int64(i)) * winSize,
+				Price:       lddlutil.Amount(prices[i]).ToCoin(),
+				PriceLow:    lddlutil.Amount(lo).ToCoin(),
+				PriceHigh:   lddlutil.Amount(hi).ToCoin(),
+				PoolSize:    int64(pools[i] + 0.5),
+			})
+		}
+		p.Scenarios = append(p.Scenarios, scenario)
+	}
+
+	return p, nil
+}
+
+// simulator holds the fresh stake and pool size of the known blocks, indexed
+// by height minus start.
+type simulator struct {
+	params  *chaincfg.Params
+	start   int64
+	fresh   []float64
+	pool    []float64
+	diff    int64
+	mempool int64
+}
+
+func newSimulator(params *chaincfg.Params, blocks []Block, mempoolTickets int64) *simulator {
+	s := &simulator{
+		params:  params,
+		start:   blocks[0].Height,
+		fresh:   make([]float64, len(blocks)),
+		pool:    make([]float64, len(blocks)),
+		diff:    blocks[len(blocks)-1].StakeDiff,
+		mempool: mempoolTickets,
+	}
+	for i := range blocks {
+		s.fresh[i] = float64(blocks[i].FreshStake)
+		s.pool[i] = float64(blocks[i].PoolSize)
+	}
+	return s
+}
+
+// recentRate returns the mean purchase rate of the last complete window, and
+// the standard deviation of the mean rates of the recent complete windows.
+func (s *simulator) recentRate() (float64, float64) {
+	winSize := s.params.StakeDiffWindowSize
+	best := s.start + int64(len(s.fresh)) - 1
+	var means []float64
+	for end := (best+1)/winSize*winSize - 1; len(means) < statsWindows; end -= winSize {
+		first := end - winSize + 1
+		if first < s.start {
+			break
+		}
+		var sum float64
+		for h := first; h <= end; h++ {
+			sum += s.fresh[h-s.start]
+		}
+		means = append(means, sum/float64(winSize))
+	}
+	if len(means) == 0 {
+		return 0, 0
+	}
+
+	var mean, variance float64
+	for _, m := range means {
+		mean += m
+	}
+	mean /= float64(len(means))
+	for _, m := range means {
+		variance += (m - mean) * (m - mean)
+	}
+	variance /= float64(len(means))
+	return means[0], math.Sqrt(variance)
+}
+
+// immature returns the number of tickets purchased in the TicketMaturity
+// blocks ending at height.
+func (s *simulator) immature(height int64) float64 {
+	var sum float64
+	for h := height - int64(s.params.TicketMaturity) + 1; h <= height; h++ {
+		if i := h - s.start; i >= 0 && i < int64(len(s.fresh)) {
+			sum += s.fresh[i]
+		}
+	}
+	return sum
+}
+
+// run simulates the blocks until the start of the given number of windows at
+// a purchase rate, and returns the ticket price in atoms of each window and
+// the size of the live pool before it.
+func (s *simulator) run(rate float64, windows int) ([]int64, []float64) {
+	params := s.params
+	winSize := params.StakeDiffWindowSize
+	maturity := int64(params.TicketMaturity)
+	votes := float64(params.TicketsPerBlock)
+	maxFresh := float64(params.MaxFreshStakePerBlock)
+
+	sim := &simulator{
+		params: params,
+		start:  s.start,
+		fresh:  append([]float64(nil), s.fresh...),
+		pool:   append([]float64(nil), s.pool...),
+	}
+	best := s.start + int64(len(s.fresh)) - 1
+	end := (best/winSize + int64(windows)) * winSize
+
+	diff := s.diff
+	queue := float64(s.mempool)
+	prices := make([]int64, 0, windows)
+	pools := make([]float64, 0, windows)
+	for height := best + 1; height <= end; height++ {
+		if height%winSize == 0 {
+			diff = sim.nextDiff(height, diff)
+			prices = append(prices, diff)
+			pools = append(pools, sim.pool[height-1-sim.start])
+			queue = 0
+		}
+
+		queue += rate
+		fresh := math.Min(queue, maxFresh)
+		queue -= fresh
+
+		var matured float64
+		if i := height - maturity - sim.start; i >= 0 {
+			matured = sim.fresh[i]
+		}
+		pool := math.Max(sim.pool[len(sim.pool)-1]+matured-votes, 0)
+
+		sim.fresh = append(sim.fresh, fresh)
+		sim.pool = append(sim.pool, pool)
+	}
+	return prices, pools
+}
+
+// nextDiff returns the stake difficulty of the block at a window start height
+// given the stake difficulty of the window before it.
+func (s *simulator) nextDiff(height, curDiff int64) int64 {
+	params := s.params
+	if height < int64(params.CoinbaseMaturity)+1 {
+		return params.MinimumStakeDiff
+	}
+
+	prevPoolAll := s.poolAll(height - params.StakeDiffWindowSize - 1)
+	curPoolAll := s.poolAll(height - 1)
+	if prevPoolAll == 0 {
+		return curDiff
+	}
+	targetPoolAll := float64(params.TicketsPerBlock) *
+		float64(int64(params.TicketPoolSize)+int64(params.TicketMaturity))
+
+	nextDiff := float64(curDiff) * curPoolAll * curPoolAll /
+		(prevPoolAll * targetPoolAll)
+
+	maxDiff := float64(estimateSupply(params, height) / int64(params.TicketPoolSize))
+	nextDiff = math.Min(nextDiff, maxDiff)
+	return maxInt64(int64(nextDiff), params.MinimumStakeDiff)
+}
+
+// poolAll returns the size of the live pool after the block at height plus
+// the number of immature tickets.
+func (s *simulator) poolAll(height int64) float64 {
+	i := height - s.start
+	if i < 0 || i >= int64(len(s.pool)) {
+		return 0
+	}
+	return s.pool[i] + s.immature(height)
+}
+
+// estimateSupply returns the estimated coin supply at height that limits
+// the stake difficulty, as computed by the consensus rules.
+func estimateSupply(params *chaincfg.Params, height int64) int64 {
+	if height <= 0 {
+		return 0
+	}
+
+	supply := params.BlockOneSubsidy()
+	reductions := height / params.SubsidyReductionInterval
+	subsidy := params.BaseSubsidy
+	for i := int64(0); i < reductions; i++ {
+		supply += params.SubsidyReductionInterval * subsidy
+
+		subsidy *= params.MulSubsidy
+		subsidy /= params.DivSubsidy
+	}
+	supply += (1 + height%params.SubsidyReductionInterval) * subsidy
+
+	// The subsidies of blocks 0 and 1 were added above as regular blocks.
+	supply -= params.BaseSubsidy * 2
+
+	return supply
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,79 @@
+package stakediff
+
+import (
+	"testing"
+
+	"github.com/Legenddigital/lddld/chaincfg"
+)
+
+var testParams = &chaincfg.Params{
+	StakeDiffWindowSize:      8,
+	TicketMaturity:           4,
+	TicketsPerBlock:          5,
+	TicketPoolSize:           16,
+	MinimumStakeDiff:         1e4,
+	MaxFreshStakePerBlock:    20,
+	CoinbaseMaturity:         4,
+	SubsidyReductionInterval: 1000,
+	BaseSubsidy:              1e12,
+	MulSubsidy:               100,
+	DivSubsidy:               101,
+}
+
+func TestProject(t *testing.T) {
+	// A pool at its target size, with the tickets that vote each block
+	// replaced by new purchases.
+	var blocks []Block
+	for h := int64(0); h < 100; h++ {
+		blocks = append(blocks, Block{Height: h, FreshStake: 5, PoolSize: 80,
+			StakeDiff: 1e8})
+	}
+
+	p, err := Project(testParams, blocks, 0, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.CurrentWindow != 12 || p.ImmatureTickets != 20 || p.RecentRate != 5 ||
+		p.RateStdDev != 0 {
+		t.Fatalf("unexpected projection %+v", p)
+	}
+	if len(p.Scenarios) != 3 {
+		t.Fatalf("expected 3 scenarios, got %d", len(p.Scenarios))
+	}
+
+	none, recent, full := p.Scenarios[0], p.Scenarios[1], p.Scenarios[2]
+	for i, w := range recent.Windows {
+		if w.Window != int64(13+i) || w.StartHeight != w.Window*8 {
+			t.Errorf("unexpected window %+v", w)
+		}
+		if w.Price != 1 || w.PoolSize != 80 {
+			t.Errorf("expected a steady price and pool, got %+v", w)
+		}
+		if w.PriceLow != w.Price || w.PriceHigh != w.Price {
+			t.Errorf("expected no bands without rate variance, got %+v", w)
+		}
+		if none.Windows[i].Price >= w.Price || full.Windows[i].Price <= w.Price {
+			t.Errorf("window %d prices not ordered by rate: %v %v %v", w.Window,
+				none.Windows[i].Price, w.Price, full.Windows[i].Price)
+		}
+	}
+
+	// Tickets in mempool raise the price of the next window.
+	p, err = Project(testParams, blocks, 40, 1, []float64{5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Scenarios[0].Windows[0].Price <= 1 {
+		t.Errorf("expected a higher price, got %v", p.Scenarios[0].Windows[0].Price)
+	}
+
+	if _, err = Project(testParams, nil, 0, 1, nil); err != ErrNoData {
+		t.Errorf("expected ErrNoData, got %v", err)
+	}
+	if _, err = Project(testParams, blocks[:2:2], 0, 1, nil); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err = Project(testParams, append(blocks[:2:2], blocks[3]), 0, 1, nil); err == nil {
+		t.Error("expected an error for a gap in the blocks")
+	}
+}
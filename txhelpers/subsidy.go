@@ -10,11 +10,27 @@ import (
 	"github.com/Legenddigital/lddld/chaincfg"
 )
 
-// UltimateSubsidy computes the total subsidy over the entire subsidy
-// distribution period of the network.
-func UltimateSubsidy(params *chaincfg.Params) int64 {
+// SubsidyInterval is the projected coin creation of a subsidy reduction
+// interval, with the stake subsidy of every vote of each block. Amounts are in
+// atoms, and Supply is the total coin supply at the end of the interval.
+type SubsidyInterval struct {
+	Interval    int64 `json:"interval"`
+	StartHeight int64 `json:"start_height"`
+	EndHeight   int64 `json:"end_height"`
+	PoW         int64 `json:"pow"`
+	PoS         int64 `json:"pos"`
+	Dev         int64 `json:"dev"`
+	Total       int64 `json:"total"`
+	Supply      int64 `json:"supply"`
+}
+
+// SupplySchedule computes the coin creation of each subsidy reduction interval
+// of the network until the subsidy ends. The supply includes the block one
+// subsidy, which is not part of the first interval's subsidies.
+func SupplySchedule(params *chaincfg.Params) []SubsidyInterval {
 	subsidyCache := blockchain.NewSubsidyCache(0, params)
 
+	var intervals []SubsidyInterval
 	totalSubsidy := params.BlockOneSubsidy()
 	for i := int64(0); ; i++ {
 		// Genesis block or first block.
@@ -40,14 +56,65 @@ func UltimateSubsidy(params *chaincfg.Params) int64 {
 			if (work + stake + tax) == 0 {
 				break // all done
 			}
-			totalSubsidy += ((work + stake + tax) * numBlocks)
+
+			interval := SubsidyInterval{
+				Interval:    int64(len(intervals)),
+				StartHeight: height,
+				EndHeight:   i - 1,
+				PoW:         work * numBlocks,
+				PoS:         stake * numBlocks,
+				Dev:         tax * numBlocks,
+			}
 
 			// First reduction internal -- subtract the stake subsidy for blocks
 			// before the staking system is enabled.
 			if i == params.SubsidyReductionInterval {
-				totalSubsidy -= stake * (params.StakeValidationHeight - 2)
+				interval.PoS -= stake * (params.StakeValidationHeight - 2)
 			}
+
+			interval.Total = interval.PoW + interval.PoS + interval.Dev
+			totalSubsidy += interval.Total
+			interval.Supply = totalSubsidy
+			intervals = append(intervals, interval)
 		}
 	}
-	return totalSubsidy
+	return intervals
+}
+
+// UltimateSubsidy computes the total subsidy over the entire subsidy
+// distribution period of the network.
+func UltimateSubsidy(params *chaincfg.Params) int64 {
+	intervals := SupplySchedule(params)
+	if len(intervals) == 0 {
+		return params.BlockOneSubsidy()
+	}
+	return intervals[len(intervals)-1].Supply
+}
+
+// BlockSubsidy computes the coins created by a block with the given number of
+// votes: the sum of the work, vote and developer subsidies, or the premine for
+// block one.
+func BlockSubsidy(subsidyCache *blockchain.SubsidyCache, height int64,
+	voters uint16, params *chaincfg.Params) int64 {
+	switch height {
+	case 0:
+		return 0
+	case 1:
+		return params.BlockOneSubsidy()
+	}
+	work, stake, tax := BlockSubsidyParts(subsidyCache, height, voters, params)
+	return work + stake + tax
+}
+
+// BlockSubsidyParts computes the work, vote and developer subsidies of a block
+// with the given number of votes. The premine of block one is not included.
+func BlockSubsidyParts(subsidyCache *blockchain.SubsidyCache, height int64,
+	voters uint16, params *chaincfg.Params) (work, stake, tax int64) {
+	if height <= 1 {
+		return 0, 0, 0
+	}
+	work = blockchain.CalcBlockWorkSubsidy(subsidyCache, height, voters, params)
+	tax = blockchain.CalcBlockTaxSubsidy(subsidyCache, height, voters, params)
+	stake = blockchain.CalcStakeVoteSubsidy(subsidyCache, height, params) * int64(voters)
+	return
 }
@@ -13,3 +13,28 @@ func TestBlockSubsidy(t *testing.T) {
 		t.Errorf("Bad total subsidy; want 2099999999800912, got %v", totalSubsidy)
 	}
 }
+
+func TestSupplySchedule(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	intervals := SupplySchedule(params)
+	if len(intervals) == 0 {
+		t.Fatal("empty supply schedule")
+	}
+
+	supply := params.BlockOneSubsidy()
+	for i, interval := range intervals {
+		if interval.Interval != int64(i) || interval.EndHeight != (int64(i)+1)*params.SubsidyReductionInterval-1 {
+			t.Fatalf("unexpected interval %+v", interval)
+		}
+		if interval.Total != interval.PoW+interval.PoS+interval.Dev {
+			t.Errorf("interval %d total %d is not the sum of its parts", i, interval.Total)
+		}
+		supply += interval.Total
+		if interval.Supply != supply {
+			t.Errorf("interval %d supply %d, expected %d", i, interval.Supply, supply)
+		}
+	}
+	if supply != UltimateSubsidy(params) {
+		t.Errorf("schedule supply %d differs from the ultimate subsidy", supply)
+	}
+}
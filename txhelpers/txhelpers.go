@@ -50,7 +50,12 @@ type VerboseTransactionGetter interface {
 // watched addresses
 type BlockWatchedTx struct {
 	BlockHeight   int64
+	BlockHash     chainhash.Hash
 	TxsForAddress map[string][]*lddlutil.Tx
+	// AmountsForAddress, if set, are the amounts of each address by
+	// transaction hash, such as those spent as found by
+	// BlockConsumesOutpointWithAddresses.
+	AmountsForAddress map[string]map[chainhash.Hash]lddlutil.Amount
 }
 
 // TxAction is what is happening to the transaction (mined or inserted into
@@ -280,48 +285,44 @@ func TxConsumesOutpointWithAddress(msgTx *wire.MsgTx, addr string,
 // includes transactions that spend from outputs created using any of the
 // addresses in addrs. The TxAction for each address is not important, but it
 // would logically be TxMined. Both regular and stake transactions are checked.
-// The RPC client is used to get the PreviousOutPoint for each TxIn of each
+// The RPC client is used to get the previous transaction of each TxIn of each
 // transaction in the block, from which the address is obtained from the
-// PkScript of that output. chaincfg Params is required to decode the script.
+// PkScript of the spent output. Each previous transaction is retrieved once,
+// and those in the block itself are not retrieved. chaincfg Params is required
+// to decode the script. The returned maps contain the spending transactions
+// for each address, and the amount spent from the address by each of them.
 func BlockConsumesOutpointWithAddresses(block *lddlutil.Block, addrs map[string]TxAction,
-	c RawTransactionGetter, params *chaincfg.Params) map[string][]*lddlutil.Tx {
+	c RawTransactionGetter, params *chaincfg.Params) (map[string][]*lddlutil.Tx,
+	map[string]map[chainhash.Hash]lddlutil.Amount) {
 	addrMap := make(map[string][]*lddlutil.Tx)
+	amountMap := make(map[string]map[chainhash.Hash]lddlutil.Amount)
+
+	// The previous transactions, nil if they could not be retrieved.
+	prevTxns := make(map[chainhash.Hash]*lddlutil.Tx)
+	for _, tx := range block.Transactions() {
+		prevTxns[*tx.Hash()] = tx
+	}
+	for _, tx := range block.STransactions() {
+		prevTxns[*tx.Hash()] = tx
+	}
+	getPrevTx := func(hash *chainhash.Hash) *lddlutil.Tx {
+		tx, ok := prevTxns[*hash]
+		if !ok {
+			tx = getRawTransaction(c, hash)
+			prevTxns[*hash] = tx
+		}
+		return tx
+	}
 
 	checkForOutpointAddr := func(blockTxs []*lddlutil.Tx) {
 		for _, tx := range blockTxs {
-			for _, txIn := range tx.MsgTx().TxIn {
-				prevOut := &txIn.PreviousOutPoint
-				if bytes.Equal(zeroHash[:], prevOut.Hash[:]) {
-					continue
-				}
-				// For each TxIn, check the indicated vout index in the txid of the
-				// previous outpoint.
-				// txrr, err := c.GetRawTransactionVerbose(&prevOut.Hash)
-				prevTx, err := c.GetRawTransaction(&prevOut.Hash)
-				if err != nil {
-					fmt.Printf("Unable to get raw transaction for %s\n", prevOut.Hash.String())
-					continue
-				}
-
-				// prevOut.Index should tell us which one, but check all anyway
-				for _, txOut := range prevTx.MsgTx().TxOut {
-					_, txAddrs, _, err := txscript.ExtractPkScriptAddrs(
-						txOut.Version, txOut.PkScript, params)
-					if err != nil {
-						fmt.Printf("ExtractPkScriptAddrs: %v\n", err.Error())
-						continue
-					}
-
-					for _, txAddr := range txAddrs {
-						addrstr := txAddr.EncodeAddress()
-						if _, ok := addrs[addrstr]; ok {
-							if addrMap[addrstr] == nil {
-								addrMap[addrstr] = make([]*lddlutil.Tx, 0)
-							}
-							addrMap[addrstr] = append(addrMap[addrstr], prevTx)
-						}
-					}
+			for addrstr, amount := range txSpendsFromAddresses(tx.MsgTx(), addrs,
+				getPrevTx, params) {
+				addrMap[addrstr] = append(addrMap[addrstr], tx)
+				if amountMap[addrstr] == nil {
+					amountMap[addrstr] = make(map[chainhash.Hash]lddlutil.Amount)
 				}
+				amountMap[addrstr][*tx.Hash()] = amount
 			}
 		}
 	}
@@ -329,7 +330,90 @@ func BlockConsumesOutpointWithAddresses(block *lddlutil.Block, addrs map[string]
 	checkForOutpointAddr(block.Transactions())
 	checkForOutpointAddr(block.STransactions())
 
-	return addrMap
+	return addrMap, amountMap
+}
+
+// TxSpendsFromAddresses checks the inputs of a transaction for previous
+// outpoints paying to any of the addresses in addrs, returning the total amount
+// spent from each such address. The RPC client is used to get the previous
+// outpoint for each TxIn, from which the address is obtained from the PkScript
+// of that output.
+func TxSpendsFromAddresses(msgTx *wire.MsgTx, addrs map[string]TxAction,
+	c RawTransactionGetter, params *chaincfg.Params) map[string]lddlutil.Amount {
+	getPrevTx := func(hash *chainhash.Hash) *lddlutil.Tx {
+		return getRawTransaction(c, hash)
+	}
+	return txSpendsFromAddresses(msgTx, addrs, getPrevTx, params)
+}
+
+// getRawTransaction gets a transaction with the RPC client, or nil if it could
+// not be retrieved.
+func getRawTransaction(c RawTransactionGetter, hash *chainhash.Hash) *lddlutil.Tx {
+	tx, err := c.GetRawTransaction(hash)
+	if err != nil {
+		fmt.Printf("Unable to get raw transaction for %s\n", hash.String())
+		return nil
+	}
+	return tx
+}
+
+func txSpendsFromAddresses(msgTx *wire.MsgTx, addrs map[string]TxAction,
+	getPrevTx func(*chainhash.Hash) *lddlutil.Tx, params *chaincfg.Params) map[string]lddlutil.Amount {
+	spent := make(map[string]lddlutil.Amount)
+	for _, txIn := range msgTx.TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		if bytes.Equal(zeroHash[:], prevOut.Hash[:]) {
+			continue
+		}
+		// For each TxIn, check the indicated vout index in the txid of the
+		// previous outpoint.
+		prevTx := getPrevTx(&prevOut.Hash)
+		if prevTx == nil {
+			continue
+		}
+		prevTxOuts := prevTx.MsgTx().TxOut
+		if int(prevOut.Index) >= len(prevTxOuts) {
+			continue
+		}
+		txOut := prevTxOuts[prevOut.Index]
+
+		_, txAddrs, _, err := txscript.ExtractPkScriptAddrs(
+			txOut.Version, txOut.PkScript, params)
+		if err != nil {
+			fmt.Printf("ExtractPkScriptAddrs: %v\n", err.Error())
+			continue
+		}
+
+		for _, txAddr := range txAddrs {
+			addrstr := txAddr.EncodeAddress()
+			if _, ok := addrs[addrstr]; ok {
+				spent[addrstr] += lddlutil.Amount(txOut.Value)
+			}
+		}
+	}
+	return spent
+}
+
+// TxPaysToAddresses checks the outputs of a transaction for any paying to the
+// addresses in addrs, returning the total amount received by each such address.
+func TxPaysToAddresses(msgTx *wire.MsgTx, addrs map[string]TxAction,
+	params *chaincfg.Params) map[string]lddlutil.Amount {
+	received := make(map[string]lddlutil.Amount)
+	for _, txOut := range msgTx.TxOut {
+		_, txAddrs, _, err := txscript.ExtractPkScriptAddrs(txOut.Version,
+			txOut.PkScript, params)
+		if err != nil {
+			fmt.Printf("ExtractPkScriptAddrs: %v\n", err.Error())
+			continue
+		}
+		for _, txAddr := range txAddrs {
+			addrstr := txAddr.EncodeAddress()
+			if _, ok := addrs[addrstr]; ok {
+				received[addrstr] += lddlutil.Amount(txOut.Value)
+			}
+		}
+	}
+	return received
 }
 
 // TxPaysToAddress returns a slice of outpoints of a transaction which pay to
@@ -615,9 +699,18 @@ func SSGenVoteChoices(tx *wire.MsgTx, params *chaincfg.Params) (BlockValidation,
 		return validBlock, 0, 0, nil, err
 	}
 
-	// Determine the ssgen's vote version and get the relevant consensus
-	// deployments containing the vote items targeted.
+	// Determine the ssgen's vote version, and decode the vote bits for the
+	// relevant consensus deployments containing the vote items targeted.
 	voteVersion := stake.SSGenVersion(tx)
+	choices := VoteBitsChoices(voteVersion, voteBits, params)
+
+	return validBlock, voteVersion, voteBits, choices, nil
+}
+
+// VoteBitsChoices decodes the choices made by vote bits on each of the vote
+// items (consensus deployments) of a vote version. Vote items for which the
+// vote bits do not select a valid choice are omitted.
+func VoteBitsChoices(voteVersion uint32, voteBits uint16, params *chaincfg.Params) []*VoteChoice {
 	deployments := params.Deployments[voteVersion]
 
 	// Allocate space for each choice
@@ -628,6 +721,9 @@ func SSGenVoteChoices(tx *wire.MsgTx, params *chaincfg.Params) (BlockValidation,
 	for d := range deployments {
 		voteAgenda := &deployments[d].Vote
 		choiceIndex := voteAgenda.VoteIndex(voteBits)
+		if choiceIndex < 0 {
+			continue
+		}
 		voteChoice := VoteChoice{
 			ID:          voteAgenda.Id,
 			Description: voteAgenda.Description,
@@ -640,7 +736,7 @@ func SSGenVoteChoices(tx *wire.MsgTx, params *chaincfg.Params) (BlockValidation,
 		choices = append(choices, &voteChoice)
 	}
 
-	return validBlock, voteVersion, voteBits, choices, nil
+	return choices
 }
 
 // FeeInfoBlock computes ticket fee statistics for the tickets included in the
@@ -748,6 +844,66 @@ func FeeRateInfoBlock(block *lddlutil.Block) *lddljson.FeeInfoBlock {
 	return feeInfo
 }
 
+// FeeRateStats models statistics of the fees (LDDL) and fee rates (LDDL/kB) of
+// a set of transactions.
+type FeeRateStats struct {
+	Number int     `json:"number"`
+	Fees   float64 `json:"fees"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+}
+
+// FeeRateStatsTxns computes the total fees and fee rate statistics of the
+// given transactions.
+func FeeRateStatsTxns(txns []*wire.MsgTx) FeeRateStats {
+	stats := FeeRateStats{Number: len(txns)}
+	if stats.Number == 0 {
+		return stats
+	}
+
+	stats.Min = math.MaxFloat64
+	var fees lddlutil.Amount
+	feeRates := make([]float64, 0, stats.Number)
+	for _, msgTx := range txns {
+		fee := TxFee(msgTx)
+		fees += fee
+		feeRate := 1000 * fee.ToCoin() / float64(msgTx.SerializeSize())
+		if feeRate < stats.Min {
+			stats.Min = feeRate
+		}
+		if feeRate > stats.Max {
+			stats.Max = feeRate
+		}
+		stats.Mean += feeRate
+		feeRates = append(feeRates, feeRate)
+	}
+
+	stats.Fees = fees.ToCoin()
+	stats.Mean /= float64(stats.Number)
+	stats.Median = MedianCoin(feeRates)
+	return stats
+}
+
+// BlockFeeRateStats computes the fee rate statistics of the regular and stake
+// transaction trees of a block. The coinbase and the votes, which spend newly
+// generated coins, are excluded.
+func BlockFeeRateStats(msgBlock *wire.MsgBlock) (regular, stakeTree FeeRateStats) {
+	var regularTxns []*wire.MsgTx
+	if len(msgBlock.Transactions) > 1 {
+		regularTxns = msgBlock.Transactions[1:]
+	}
+	stakeTxns := make([]*wire.MsgTx, 0, len(msgBlock.STransactions))
+	for _, msgTx := range msgBlock.STransactions {
+		if stake.IsSSGen(msgTx) {
+			continue
+		}
+		stakeTxns = append(stakeTxns, msgTx)
+	}
+	return FeeRateStatsTxns(regularTxns), FeeRateStatsTxns(stakeTxns)
+}
+
 // MsgTxFromHex returns a wire.MsgTx struct built from the transaction hex string
 func MsgTxFromHex(txhex string) (*wire.MsgTx, error) {
 	txBytes, err := hex.DecodeString(txhex)
@@ -0,0 +1,329 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package watcher
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// SMTP connection security modes of EmailConfig.TLS.
+const (
+	// SMTPTLSAuto uses implicit TLS for port 465, otherwise STARTTLS if the
+	// server supports it.
+	SMTPTLSAuto = "auto"
+	// SMTPTLS connects with TLS (SMTPS).
+	SMTPTLS = "tls"
+	// SMTPStartTLS requires the STARTTLS command.
+	SMTPStartTLS = "starttls"
+	// SMTPNoTLS never uses TLS. Authentication is then only possible with a
+	// server on localhost.
+	SMTPNoTLS = "none"
+)
+
+const (
+	// DefaultEmailSubject is the subject used if EmailConfig.Subject is not
+	// set. The address is appended.
+	DefaultEmailSubject = "lddldata transaction notification"
+	// DefaultEmailBatchDelay is how long events are collected before they are
+	// emailed, if EmailConfig.BatchDelay is not set.
+	DefaultEmailBatchDelay = 10 * time.Second
+
+	smtpTimeout = 30 * time.Second
+	// emailMaxAttempts is the number of times sending a batch is attempted.
+	emailMaxAttempts = 3
+	// emailSentBlocksMemory is the number of address and block pairs for which
+	// an email was sent that are remembered, so that events arriving late for
+	// the same block are not sent in a second email.
+	emailSentBlocksMemory = 1000
+)
+
+// EmailConfig is the configuration of the email notifications.
+type EmailConfig struct {
+	// Server is the SMTP server host:port.
+	Server string
+	User   string
+	Pass   string
+	From   string
+	To     []string
+	// Subject is the email subject, to which the address is appended.
+	Subject string
+	// TLS is one of the SMTP connection security modes. The default is
+	// SMTPTLSAuto.
+	TLS        string
+	BatchDelay time.Duration
+}
+
+// emailTemplate is the body of an email for a batch of events for one address.
+var emailTemplate = template.Must(template.New("email").Parse(
+	`Activity for watched address {{.Address}} {{if .BlockHash}}in block {{.BlockHeight}} ({{.BlockHash}}){{else}}in mempool{{end}}:
+{{range .Events}}
+  {{if eq .Event "receive"}}Received{{else}}Spent{{end}} {{printf "%.8f" .Amount}} LDDL in transaction {{.TxID}}{{end}}
+
+Sent by lddldata.
+`))
+
+// emailBatch collects the events for an address in one block, or in mempool if
+// BlockHash is empty.
+type emailBatch struct {
+	Address     string
+	BlockHeight int64
+	BlockHash   string
+	Events      []*Event
+
+	first    time.Time
+	attempts int
+}
+
+// key identifies the batch of an address and block.
+func batchKey(address, blockHash string) string {
+	return address + "/" + blockHash
+}
+
+// emailNotifier batches events, sending at most one email per block and
+// address, and one email per address for mempool events in each batch period.
+type emailNotifier struct {
+	cfg EmailConfig
+
+	mtx       sync.Mutex
+	batches   map[string]*emailBatch
+	sent      map[string]bool
+	sentOrder []string
+}
+
+// newEmailNotifier validates the configuration and creates an emailNotifier.
+func newEmailNotifier(cfg EmailConfig) (*emailNotifier, error) {
+	if _, _, err := net.SplitHostPort(cfg.Server); err != nil {
+		return nil, fmt.Errorf("invalid SMTP server %s: %v", cfg.Server, err)
+	}
+	if len(cfg.To) == 0 {
+		return nil, fmt.Errorf("no email recipients")
+	}
+	switch cfg.TLS {
+	case "":
+		cfg.TLS = SMTPTLSAuto
+	case SMTPTLSAuto, SMTPTLS, SMTPStartTLS, SMTPNoTLS:
+	default:
+		return nil, fmt.Errorf("invalid SMTP TLS mode %s", cfg.TLS)
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.To[0]
+	}
+	if cfg.Subject == "" {
+		cfg.Subject = DefaultEmailSubject
+	}
+	if cfg.BatchDelay <= 0 {
+		cfg.BatchDelay = DefaultEmailBatchDelay
+	}
+	return &emailNotifier{
+		cfg:     cfg,
+		batches: make(map[string]*emailBatch),
+		sent:    make(map[string]bool),
+	}, nil
+}
+
+// add queues the event in the batch for its address and block.
+func (n *emailNotifier) add(ev *Event) {
+	key := batchKey(ev.Address, ev.BlockHash)
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	if n.sent[key] {
+		log.Warnf("Email for address %s in block %s already sent. Not emailing "+
+			"%s of tx %s.", ev.Address, ev.BlockHash, ev.Event, ev.TxID)
+		return
+	}
+	batch, ok := n.batches[key]
+	if !ok {
+		batch = &emailBatch{
+			Address:     ev.Address,
+			BlockHeight: ev.BlockHeight,
+			BlockHash:   ev.BlockHash,
+			first:       time.Now(),
+		}
+		n.batches[key] = batch
+	}
+	batch.Events = append(batch.Events, ev)
+}
+
+// run sends the batches as they become due until quit is closed, when all
+// remaining batches are sent.
+func (n *emailNotifier) run(quit chan struct{}) {
+	ticker := time.NewTicker(n.cfg.BatchDelay / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.flush(false)
+		case <-quit:
+			n.flush(true)
+			return
+		}
+	}
+}
+
+// flush sends the batches collected for at least the batch delay, or all
+// batches if force is true.
+func (n *emailNotifier) flush(force bool) {
+	var due []*emailBatch
+	n.mtx.Lock()
+	for key, batch := range n.batches {
+		if force || time.Since(batch.first) >= n.cfg.BatchDelay {
+			due = append(due, batch)
+			delete(n.batches, key)
+			if batch.BlockHash != "" {
+				n.markSent(key)
+			}
+		}
+	}
+	n.mtx.Unlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].first.Before(due[j].first)
+	})
+
+	for _, batch := range due {
+		batch.attempts++
+		err := n.send(batch)
+		if err == nil {
+			log.Debugf("Emailed %d events for address %s.", len(batch.Events),
+				batch.Address)
+			continue
+		}
+		if batch.attempts >= emailMaxAttempts || force {
+			log.Errorf("Failed to email notification for address %s: %v",
+				batch.Address, err)
+			continue
+		}
+		log.Warnf("Failed to email notification for address %s (will retry): %v",
+			batch.Address, err)
+		n.requeue(batch)
+	}
+}
+
+// markSent remembers that the batch with the given key was sent. The caller
+// must hold the lock.
+func (n *emailNotifier) markSent(key string) {
+	if n.sent[key] {
+		return
+	}
+	n.sent[key] = true
+	n.sentOrder = append(n.sentOrder, key)
+	if len(n.sentOrder) > emailSentBlocksMemory {
+		delete(n.sent, n.sentOrder[0])
+		n.sentOrder = n.sentOrder[1:]
+	}
+}
+
+// requeue returns a batch that failed to send, merging any events queued for
+// the same address and block since it was taken.
+func (n *emailNotifier) requeue(batch *emailBatch) {
+	key := batchKey(batch.Address, batch.BlockHash)
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	if newer, ok := n.batches[key]; ok {
+		batch.Events = append(batch.Events, newer.Events...)
+	}
+	n.batches[key] = batch
+}
+
+// message formats the email for a batch.
+func (n *emailNotifier) message(batch *emailBatch) ([]byte, error) {
+	var body bytes.Buffer
+	if err := emailTemplate.Execute(&body, batch); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	subject := n.cfg.Subject + " (" + batch.Address + ")"
+	fmt.Fprintf(&msg, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.Replace(body.String(), "\n", "\r\n", -1))
+	return msg.Bytes(), nil
+}
+
+// send emails the batch.
+func (n *emailNotifier) send(batch *emailBatch) error {
+	msg, err := n.message(batch)
+	if err != nil {
+		return err
+	}
+
+	host, port, _ := net.SplitHostPort(n.cfg.Server)
+	mode := n.cfg.TLS
+	if mode == SMTPTLSAuto && port == "465" {
+		mode = SMTPTLS
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	if mode == SMTPTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", n.cfg.Server, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", n.cfg.Server)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if mode == SMTPTLSAuto || mode == SMTPStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		} else if mode == SMTPStartTLS {
+			return fmt.Errorf("SMTP server %s does not support STARTTLS", n.cfg.Server)
+		}
+	}
+
+	if n.cfg.User != "" {
+		auth := smtp.PlainAuth("", n.cfg.User, n.cfg.Pass, host)
+		if err = c.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err = c.Mail(n.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range n.cfg.To {
+		if err = c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = wc.Write(msg); err != nil {
+		wc.Close()
+		return err
+	}
+	if err = wc.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
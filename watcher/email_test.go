@@ -0,0 +1,152 @@
+package watcher
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer is a minimal SMTP server that records the messages it
+// receives.
+type fakeSMTPServer struct {
+	ln       net.Listener
+	mtx      sync.Mutex
+	messages []string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTPServer{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost fake SMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH"):
+			reply("235 ok")
+		case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var msg strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				msg.WriteString(l)
+			}
+			s.mtx.Lock()
+			s.messages = append(s.messages, msg.String())
+			s.mtx.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) received() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func TestEmailNotifier(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	defer server.ln.Close()
+
+	n, err := newEmailNotifier(EmailConfig{
+		Server:     server.ln.Addr().String(),
+		User:       "user",
+		Pass:       "pass",
+		To:         []string{"alerts@example.com"},
+		TLS:        SMTPNoTLS,
+		BatchDelay: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrA, addrB := "DsAddressA", "DsAddressB"
+	n.add(&Event{Event: EventReceive, Status: StatusMempool, Address: addrA,
+		TxID: "tx1", Amount: 1.5})
+	n.add(&Event{Event: EventReceive, Status: StatusConfirmed, Address: addrA,
+		TxID: "tx1", Amount: 1.5, BlockHeight: 100, BlockHash: "block100"})
+	n.add(&Event{Event: EventSpend, Status: StatusConfirmed, Address: addrA,
+		TxID: "tx2", Amount: 2, BlockHeight: 100, BlockHash: "block100"})
+	n.add(&Event{Event: EventReceive, Status: StatusConfirmed, Address: addrB,
+		TxID: "tx2", Amount: 0.5, BlockHeight: 100, BlockHash: "block100"})
+
+	// Nothing is due before the batch delay.
+	n.flush(false)
+	if len(server.received()) != 0 {
+		t.Fatalf("sent emails before the batch delay")
+	}
+
+	// One email for addrA in mempool, one for addrA in block 100, and one for
+	// addrB in block 100.
+	n.flush(true)
+	msgs := server.received()
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 emails, got %d", len(msgs))
+	}
+
+	var blockEmailA string
+	for _, msg := range msgs {
+		if strings.Contains(msg, addrA) && strings.Contains(msg, "block100") {
+			blockEmailA = msg
+		}
+	}
+	for _, want := range []string{
+		"Subject: " + DefaultEmailSubject + " (" + addrA + ")",
+		"Received 1.50000000 LDDL in transaction tx1",
+		"Spent 2.00000000 LDDL in transaction tx2",
+	} {
+		if !strings.Contains(blockEmailA, want) {
+			t.Errorf("email missing %q:\n%s", want, blockEmailA)
+		}
+	}
+
+	// A late event for a block already emailed is not sent again.
+	n.add(&Event{Event: EventReceive, Status: StatusConfirmed, Address: addrB,
+		TxID: "tx3", Amount: 1, BlockHeight: 100, BlockHash: "block100"})
+	n.flush(true)
+	if len(server.received()) != 3 {
+		t.Errorf("sent a second email for the same address and block")
+	}
+}
@@ -0,0 +1,509 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+// Package watcher maintains a durable list of watched addresses, and notifies
+// webhooks when funds are received by or spent from a watched address, both
+// when the transaction enters mempool and when it is mined.
+package watcher
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Legenddigital/lddld/chaincfg"
+	"github.com/Legenddigital/lddld/chaincfg/chainhash"
+	"github.com/Legenddigital/lddld/lddlutil"
+	"github.com/Legenddigital/lddld/wire"
+	"github.com/Legenddigital/lddldata/txhelpers"
+	"github.com/asdine/storm"
+)
+
+// Event types and transaction statuses of an Event.
+const (
+	EventReceive = "receive"
+	EventSpend   = "spend"
+
+	StatusMempool   = "mempool"
+	StatusConfirmed = "confirmed"
+)
+
+// Sources of a Watch.
+const (
+	SourceConfig = "config"
+	SourceAPI    = "api"
+)
+
+// DefaultMaxAttempts is the number of webhook delivery attempts used if
+// Config.MaxAttempts is not set.
+const DefaultMaxAttempts = 8
+
+// NodeClient is an interface satisfied by rpcclient.Client, and required to
+// load the node's transaction filter and to look up the previous outpoints of
+// transaction inputs.
+type NodeClient interface {
+	txhelpers.RawTransactionGetter
+	LoadTxFilter(reload bool, addresses []lddlutil.Address, outPoints []wire.OutPoint) error
+}
+
+// Watch is a watched address, with an optional webhook URL that overrides the
+// default webhook.
+type Watch struct {
+	Address    string `storm:"id" json:"address"`
+	WebhookURL string `json:"webhook_url,omitempty"`
+	Source     string `json:"source"`
+	Created    int64  `json:"created"`
+}
+
+// Event is the JSON payload POSTed to a webhook when a watched address
+// receives or spends funds.
+type Event struct {
+	Event       string  `json:"event"`
+	Status      string  `json:"status"`
+	Address     string  `json:"address"`
+	TxID        string  `json:"txid"`
+	Amount      float64 `json:"amount"`
+	BlockHeight int64   `json:"block_height,omitempty"`
+	BlockHash   string  `json:"block_hash,omitempty"`
+	Time        int64   `json:"time"`
+}
+
+// Config is the configuration of an AddressWatcher.
+type Config struct {
+	// DBPath is the file name of the watch list and delivery log DB.
+	DBPath string
+	Params *chaincfg.Params
+	Client NodeClient
+	// WebhookURL is the default webhook for watches without their own.
+	WebhookURL string
+	// WebhookSecret is used to sign webhook payloads. If empty, payloads are
+	// not signed.
+	WebhookSecret string
+	// APIKey is required to manage the watch list via the API. If empty, the
+	// watch list may only be set via Addresses.
+	APIKey      string
+	MaxAttempts int
+	// Addresses are watched in addition to those added via the API. Watches
+	// from a previous run's Addresses that are no longer listed are removed.
+	Addresses []string
+	// Email enables email notifications if not nil.
+	Email *EmailConfig
+}
+
+// AddressWatcher manages the watch list and the delivery of webhooks.
+type AddressWatcher struct {
+	db          *storm.DB
+	params      *chaincfg.Params
+	client      NodeClient
+	webhookURL  string
+	secret      []byte
+	apiKey      []byte
+	maxAttempts int
+	httpClient  *http.Client
+	wake        chan struct{}
+	email       *emailNotifier
+
+	mtx     sync.RWMutex
+	watches map[string]*Watch
+}
+
+// New opens the watch list DB, and adds the addresses in the Config to the
+// watch list.
+func New(cfg *Config) (*AddressWatcher, error) {
+	db, err := storm.Open(cfg.DBPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open watcher DB %s: %v", cfg.DBPath, err)
+	}
+
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	w := &AddressWatcher{
+		db:          db,
+		params:      cfg.Params,
+		client:      cfg.Client,
+		webhookURL:  cfg.WebhookURL,
+		secret:      []byte(cfg.WebhookSecret),
+		apiKey:      []byte(cfg.APIKey),
+		maxAttempts: maxAttempts,
+		httpClient:  &http.Client{Timeout: webhookTimeout},
+		wake:        make(chan struct{}, 1),
+		watches:     make(map[string]*Watch),
+	}
+
+	if cfg.Email != nil {
+		if w.email, err = newEmailNotifier(*cfg.Email); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	var watches []Watch
+	if err = db.All(&watches); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to load watch list: %v", err)
+	}
+
+	configAddrs := make(map[string]bool, len(cfg.Addresses))
+	for _, addr := range cfg.Addresses {
+		configAddrs[addr] = true
+	}
+
+	for i := range watches {
+		watch := &watches[i]
+		// Drop addresses removed from the config since the last run.
+		if watch.Source == SourceConfig && !configAddrs[watch.Address] {
+			if err = db.DeleteStruct(watch); err != nil {
+				db.Close()
+				return nil, err
+			}
+			continue
+		}
+		w.watches[watch.Address] = watch
+	}
+
+	for addr := range configAddrs {
+		if _, ok := w.watches[addr]; ok {
+			continue
+		}
+		if _, err = w.save(addr, "", SourceConfig); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	log.Infof("Watching %d addresses.", len(w.watches))
+	return w, nil
+}
+
+// Close closes the watch list DB.
+func (w *AddressWatcher) Close() error {
+	return w.db.Close()
+}
+
+// Authorized checks the API key presented by a client.
+func (w *AddressWatcher) Authorized(key string) bool {
+	if len(w.apiKey) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(key), w.apiKey) == 1
+}
+
+// validateAddress checks that the address is valid for the active network.
+func (w *AddressWatcher) validateAddress(address string) (lddlutil.Address, error) {
+	addr, err := lddlutil.DecodeAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %v", address, err)
+	}
+	if !addr.IsForNet(w.params) {
+		return nil, fmt.Errorf("address %s is not for %s", address, w.params.Name)
+	}
+	return addr, nil
+}
+
+// save validates and stores a watch, and adds it to the watch list. The caller
+// must hold the write lock, or have exclusive access during construction.
+func (w *AddressWatcher) save(address, webhookURL, source string) (*Watch, error) {
+	if _, err := w.validateAddress(address); err != nil {
+		return nil, err
+	}
+	watch := &Watch{
+		Address:    address,
+		WebhookURL: webhookURL,
+		Source:     source,
+		Created:    time.Now().Unix(),
+	}
+	if old, ok := w.watches[address]; ok {
+		watch.Source, watch.Created = old.Source, old.Created
+	}
+	if err := w.db.Save(watch); err != nil {
+		return nil, fmt.Errorf("unable to save watch for %s: %v", address, err)
+	}
+	w.watches[address] = watch
+	return watch, nil
+}
+
+// Add watches the address, notifying webhookURL or the default webhook if
+// empty. An existing watch for the address has its webhook URL replaced.
+func (w *AddressWatcher) Add(address, webhookURL string) (*Watch, error) {
+	w.mtx.Lock()
+	_, existed := w.watches[address]
+	watch, err := w.save(address, webhookURL, SourceAPI)
+	w.mtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if !existed {
+		// Add the address to the node's filter without reloading it, which
+		// would drop the outpoints the node has learned.
+		addr, _ := lddlutil.DecodeAddress(address)
+		if err = w.client.LoadTxFilter(false, []lddlutil.Address{addr}, nil); err != nil {
+			log.Errorf("Failed to add %s to the node's tx filter: %v", address, err)
+		}
+	}
+
+	copied := *watch
+	return &copied, nil
+}
+
+// Remove stops watching the address. The returned bool indicates if the
+// address was watched.
+func (w *AddressWatcher) Remove(address string) (bool, error) {
+	w.mtx.Lock()
+	watch, ok := w.watches[address]
+	if !ok {
+		w.mtx.Unlock()
+		return false, nil
+	}
+	if err := w.db.DeleteStruct(watch); err != nil {
+		w.mtx.Unlock()
+		return true, fmt.Errorf("unable to delete watch for %s: %v", address, err)
+	}
+	delete(w.watches, address)
+	w.mtx.Unlock()
+
+	return true, w.LoadTxFilter()
+}
+
+// Get returns the watch for the address, or nil if it is not watched.
+func (w *AddressWatcher) Get(address string) *Watch {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	watch, ok := w.watches[address]
+	if !ok {
+		return nil
+	}
+	copied := *watch
+	return &copied
+}
+
+// List returns all watches, sorted by address.
+func (w *AddressWatcher) List() []Watch {
+	w.mtx.RLock()
+	watches := make([]Watch, 0, len(w.watches))
+	for _, watch := range w.watches {
+		watches = append(watches, *watch)
+	}
+	w.mtx.RUnlock()
+
+	sort.Slice(watches, func(i, j int) bool {
+		return watches[i].Address < watches[j].Address
+	})
+	return watches
+}
+
+// Addresses returns the watched addresses in the form used by the txhelpers
+// functions for finding watched transactions in blocks.
+func (w *AddressWatcher) Addresses() map[string]txhelpers.TxAction {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	addrs := make(map[string]txhelpers.TxAction, len(w.watches))
+	for addr := range w.watches {
+		addrs[addr] = txhelpers.TxMined
+	}
+	return addrs
+}
+
+// LoadTxFilter reloads the node's transaction filter with the watched
+// addresses so that relevant mempool transactions are notified. The node adds
+// the outpoints of outputs paying to a watched address to the filter as they
+// are seen, so spends of outputs received before the filter was loaded are
+// only detected when they are mined.
+func (w *AddressWatcher) LoadTxFilter() error {
+	w.mtx.RLock()
+	addrs := make([]lddlutil.Address, 0, len(w.watches))
+	for address := range w.watches {
+		addr, err := lddlutil.DecodeAddress(address)
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	w.mtx.RUnlock()
+
+	if err := w.client.LoadTxFilter(true, addrs, nil); err != nil {
+		return fmt.Errorf("load tx filter failed: %v", err)
+	}
+	return nil
+}
+
+// TxHandler receives the watched transactions found in connected blocks and
+// the relevant transactions accepted into mempool, and queues a webhook for
+// each watched address involved.
+func (w *AddressWatcher) TxHandler(wg *sync.WaitGroup, quit chan struct{},
+	recvTxBlockChan, spendTxBlockChan <-chan *txhelpers.BlockWatchedTx,
+	mempoolTxChan <-chan *lddlutil.Tx) {
+	defer wg.Done()
+	for {
+		select {
+		case blockTxs, ok := <-recvTxBlockChan:
+			if !ok {
+				log.Warnf("Receiving tx block channel closed.")
+				return
+			}
+			w.processBlockTxs(EventReceive, blockTxs)
+
+		case blockTxs, ok := <-spendTxBlockChan:
+			if !ok {
+				log.Warnf("Spending tx block channel closed.")
+				return
+			}
+			w.processBlockTxs(EventSpend, blockTxs)
+
+		case tx, ok := <-mempoolTxChan:
+			if !ok {
+				log.Warnf("Relevant mempool tx channel closed.")
+				return
+			}
+			w.processMempoolTx(tx)
+
+		case <-quit:
+			log.Debugf("Got quit signal. Exiting watched tx handler.")
+			return
+		}
+	}
+}
+
+// EmailHandler sends the batched email notifications until quit is closed. It
+// returns immediately if email notifications are not enabled.
+func (w *AddressWatcher) EmailHandler(wg *sync.WaitGroup, quit chan struct{}) {
+	defer wg.Done()
+	if w.email == nil {
+		return
+	}
+	w.email.run(quit)
+	log.Debugf("Got quit signal. Exiting email notification handler.")
+}
+
+// processBlockTxs queues confirmed events for the transactions of a block.
+// The amounts spent are those found with the block's transactions, since
+// looking up the previous outpoints again would repeat the RPCs. Confirmed
+// events are not retracted if the block is orphaned by a reorganization.
+func (w *AddressWatcher) processBlockTxs(event string, blockTxs *txhelpers.BlockWatchedTx) {
+	now := time.Now().Unix()
+	for address, txs := range blockTxs.TxsForAddress {
+		addrs := map[string]txhelpers.TxAction{address: txhelpers.TxMined}
+		seen := make(map[chainhash.Hash]bool, len(txs))
+		for _, tx := range txs {
+			if seen[*tx.Hash()] {
+				continue
+			}
+			seen[*tx.Hash()] = true
+
+			var amount lddlutil.Amount
+			if event == EventReceive {
+				amount = txhelpers.TxPaysToAddresses(tx.MsgTx(), addrs,
+					w.params)[address]
+			} else {
+				amount = blockTxs.AmountsForAddress[address][*tx.Hash()]
+			}
+
+			w.queueEvent(&Event{
+				Event:       event,
+				Status:      StatusConfirmed,
+				Address:     address,
+				TxID:        tx.Hash().String(),
+				Amount:      amount.ToCoin(),
+				BlockHeight: blockTxs.BlockHeight,
+				BlockHash:   blockTxs.BlockHash.String(),
+				Time:        now,
+			})
+		}
+	}
+}
+
+// processMempoolTx queues mempool events for each watched address paid by or
+// spent from in the transaction.
+func (w *AddressWatcher) processMempoolTx(tx *lddlutil.Tx) {
+	addrs := w.Addresses()
+	if len(addrs) == 0 {
+		return
+	}
+	now := time.Now().Unix()
+	txid := tx.Hash().String()
+
+	received := txhelpers.TxPaysToAddresses(tx.MsgTx(), addrs, w.params)
+	for address, amount := range received {
+		w.queueEvent(&Event{
+			Event:   EventReceive,
+			Status:  StatusMempool,
+			Address: address,
+			TxID:    txid,
+			Amount:  amount.ToCoin(),
+			Time:    now,
+		})
+	}
+
+	spent := txhelpers.TxSpendsFromAddresses(tx.MsgTx(), addrs, w.client, w.params)
+	for address, amount := range spent {
+		w.queueEvent(&Event{
+			Event:   EventSpend,
+			Status:  StatusMempool,
+			Address: address,
+			TxID:    txid,
+			Amount:  amount.ToCoin(),
+			Time:    now,
+		})
+	}
+}
+
+// queueEvent adds the event to the email notifications, if enabled, and stores
+// a delivery of the event to the webhook of the watched address, waking the
+// delivery handler.
+func (w *AddressWatcher) queueEvent(ev *Event) {
+	w.mtx.RLock()
+	watch, ok := w.watches[ev.Address]
+	w.mtx.RUnlock()
+	if !ok {
+		return
+	}
+
+	log.Infof("Watched address %s: %s of %v in tx %s (%s).", ev.Address,
+		ev.Event, ev.Amount, ev.TxID, ev.Status)
+
+	if w.email != nil {
+		w.email.add(ev)
+	}
+
+	url := watch.WebhookURL
+	if url == "" {
+		url = w.webhookURL
+	}
+	if url == "" {
+		return
+	}
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		log.Errorf("Failed to encode webhook payload: %v", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	delivery := &Delivery{
+		URL:         url,
+		Event:       ev.Event,
+		Address:     ev.Address,
+		TxID:        ev.TxID,
+		Payload:     payload,
+		Status:      DeliveryPending,
+		NextAttempt: now,
+		Created:     now,
+	}
+	if err = w.db.Save(delivery); err != nil {
+		log.Errorf("Failed to store webhook delivery: %v", err)
+		return
+	}
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
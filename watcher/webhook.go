@@ -0,0 +1,228 @@
+// Copyright (c) 2018, The Legenddigital developers
+// See LICENSE for details.
+
+package watcher
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+)
+
+// Webhook request headers.
+const (
+	// SignatureHeader contains "sha256=" followed by the hex encoded
+	// HMAC-SHA256 of the request body, keyed with the webhook secret.
+	SignatureHeader = "X-Lddldata-Signature"
+	// DeliveryHeader contains the delivery ID, which is the same for retries.
+	DeliveryHeader = "X-Lddldata-Delivery"
+	// EventHeader contains the event type, receive or spend.
+	EventHeader = "X-Lddldata-Event"
+)
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	webhookTimeout = 15 * time.Second
+	// pollInterval is how often the delivery handler checks for retries that
+	// have become due.
+	pollInterval = 5 * time.Second
+	// Retries are delayed by retryBaseDelay * 2^(attempts-1), up to
+	// retryMaxDelay.
+	retryBaseDelay = 10 * time.Second
+	retryMaxDelay  = time.Hour
+	// deliveredRetention is how long successful deliveries are kept in the
+	// delivery log. Failed deliveries are kept until removed manually.
+	deliveredRetention = 30 * 24 * time.Hour
+)
+
+// Delivery is the delivery log record of a webhook.
+type Delivery struct {
+	ID          int             `storm:"id,increment" json:"id"`
+	URL         string          `json:"url"`
+	Event       string          `json:"event"`
+	Address     string          `storm:"index" json:"address"`
+	TxID        string          `json:"txid"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `storm:"index" json:"status"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt int64           `json:"next_attempt,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	Created     int64           `json:"created"`
+	Delivered   int64           `json:"delivered,omitempty"`
+}
+
+// Deliveries returns up to N of the most recent deliveries, optionally only
+// those with the given status.
+func (w *AddressWatcher) Deliveries(status string, N int) ([]Delivery, error) {
+	var query storm.Query
+	if status == "" {
+		query = w.db.Select()
+	} else {
+		query = w.db.Select(q.Eq("Status", status))
+	}
+
+	var deliveries []Delivery
+	err := query.OrderBy("ID").Reverse().Limit(N).Find(&deliveries)
+	if err == storm.ErrNotFound {
+		return []Delivery{}, nil
+	}
+	return deliveries, err
+}
+
+// DeliveryHandler POSTs queued webhooks, retrying failed deliveries with
+// exponential backoff until the maximum number of attempts is reached.
+func (w *AddressWatcher) DeliveryHandler(wg *sync.WaitGroup, quit chan struct{}) {
+	defer wg.Done()
+
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(24 * time.Hour)
+	defer prune.Stop()
+
+	w.pruneDelivered()
+	w.deliverDue(quit)
+
+	for {
+		select {
+		case <-w.wake:
+			w.deliverDue(quit)
+		case <-poll.C:
+			w.deliverDue(quit)
+		case <-prune.C:
+			w.pruneDelivered()
+		case <-quit:
+			log.Debugf("Got quit signal. Exiting webhook delivery handler.")
+			return
+		}
+	}
+}
+
+// deliverDue attempts each pending delivery that is due, in the order they
+// were queued.
+func (w *AddressWatcher) deliverDue(quit chan struct{}) {
+	var due []Delivery
+	err := w.db.Select(q.Eq("Status", DeliveryPending),
+		q.Lte("NextAttempt", time.Now().Unix())).OrderBy("ID").Find(&due)
+	if err != nil {
+		if err != storm.ErrNotFound {
+			log.Errorf("Failed to load pending webhook deliveries: %v", err)
+		}
+		return
+	}
+
+	for i := range due {
+		select {
+		case <-quit:
+			return
+		default:
+		}
+		w.attempt(&due[i])
+	}
+}
+
+// attempt POSTs the delivery's payload and records the result.
+func (w *AddressWatcher) attempt(d *Delivery) {
+	d.Attempts++
+	err := w.post(d)
+	now := time.Now()
+	switch {
+	case err == nil:
+		d.Status = DeliveryDelivered
+		d.Delivered = now.Unix()
+		d.NextAttempt = 0
+		d.LastError = ""
+		log.Debugf("Delivered webhook %d to %s.", d.ID, d.URL)
+	case d.Attempts >= w.maxAttempts:
+		d.Status = DeliveryFailed
+		d.NextAttempt = 0
+		d.LastError = err.Error()
+		log.Warnf("Giving up on webhook %d to %s after %d attempts: %v",
+			d.ID, d.URL, d.Attempts, err)
+	default:
+		d.NextAttempt = now.Add(retryDelay(d.Attempts)).Unix()
+		d.LastError = err.Error()
+		log.Debugf("Webhook %d to %s failed (attempt %d): %v", d.ID, d.URL,
+			d.Attempts, err)
+	}
+
+	if err = w.db.Save(d); err != nil {
+		log.Errorf("Failed to update webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+// post sends the delivery's payload to its URL. Any response status other than
+// 2xx is an error.
+func (w *AddressWatcher) post(d *Delivery) error {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, strconv.Itoa(d.ID))
+	req.Header.Set(EventHeader, d.Event)
+	if len(w.secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+sign(w.secret, d.Payload))
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	// Drain the body so the connection may be reused.
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("response status %s", resp.Status)
+	}
+	return nil
+}
+
+// pruneDelivered removes successful deliveries older than deliveredRetention
+// from the delivery log.
+func (w *AddressWatcher) pruneDelivered() {
+	cutoff := time.Now().Add(-deliveredRetention).Unix()
+	err := w.db.Select(q.Eq("Status", DeliveryDelivered),
+		q.Lt("Created", cutoff)).Delete(new(Delivery))
+	if err != nil && err != storm.ErrNotFound {
+		log.Errorf("Failed to prune the webhook delivery log: %v", err)
+	}
+}
+
+// sign computes the hex encoded HMAC-SHA256 of the payload.
+func sign(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay is the delay before the next delivery attempt after the given
+// number of failed attempts.
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= retryMaxDelay {
+			return retryMaxDelay
+		}
+	}
+	return delay
+}